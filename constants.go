@@ -1,10 +1,9 @@
 package main
 
 const (
-	// Filename length limits
-	MaxFolderNameLen     = 120
-	MaxVideoFilenameLen  = 110
-	MaxTrackFilenameLen  = 255
+	// Filename length limits. Folder and video name limits live in the
+	// processor package, see processor.MaxFolderNameLen.
+	MaxTrackFilenameLen = 255
 
 	// File permissions (cross-platform)
 	DefaultFilePerms = 0644
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"main/pkg/config"
+	"main/pkg/fsutil"
+	"main/pkg/logger"
+	"main/pkg/models"
+	"main/pkg/processor"
+)
+
+// itemContext holds what every URL in the list is processed with
+type itemContext struct {
+	cfg          *config.Config
+	legacyToken  string
+	uguID        string
+	streamParams *models.StreamParams
+	checkpoint   *processor.BatchCheckpoint // nil unless the URLs came from a list
+}
+
+// itemFailure records a URL that failed to download
+type itemFailure struct {
+	num int
+	url string
+	err error
+}
+
+// processItems processes the URLs, up to concurrentItems at a time, and
+// returns the failed ones in list order. Items that aren't available for
+// download are listed separately and don't count as failures.
+func processItems(proc *processor.Processor, ctx *itemContext, urls []string) []itemFailure {
+	total := len(urls)
+	var failures, unavailable []itemFailure
+
+	if ctx.cfg.ConcurrentItems <= 1 {
+		for i, url := range urls {
+			if ctx.checkpoint.Done(url) {
+				continue
+			}
+			fmt.Printf("Item %d of %d:\n", i+1, total)
+			err := processItem(proc, ctx, url, i+1, total)
+			if errors.Is(err, models.ErrNotAvailable) {
+				unavailable = append(unavailable, itemFailure{num: i + 1, url: url, err: err})
+			} else if err != nil {
+				failures = append(failures, itemFailure{num: i + 1, url: url, err: err})
+				logFailedUrl(ctx.cfg.FailedLog, url)
+			} else if !proc.Incomplete() {
+				markCompleted(ctx.checkpoint, url)
+			}
+		}
+		printUnavailable(unavailable, total)
+		finishCheckpoint(ctx.checkpoint)
+		return failures
+	}
+
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		jobs = make(chan int)
+	)
+	for w := 0; w < min(ctx.cfg.ConcurrentItems, total); w++ {
+		wg.Add(1)
+		// Each worker gets its own processor since per-release state isn't shared
+		go func(proc *processor.Processor) {
+			defer wg.Done()
+			for i := range jobs {
+				fmt.Printf("[%d/%d] Starting %s\n", i+1, total, urls[i])
+				err := processItem(proc, ctx, urls[i], i+1, total)
+				if errors.Is(err, models.ErrNotAvailable) {
+					fmt.Printf("[%d/%d] Skipped, not available\n", i+1, total)
+					mu.Lock()
+					unavailable = append(unavailable, itemFailure{num: i + 1, url: urls[i], err: err})
+					mu.Unlock()
+				} else if err != nil {
+					fmt.Printf("[%d/%d] Failed: %s\n", i+1, total, err)
+					mu.Lock()
+					failures = append(failures, itemFailure{num: i + 1, url: urls[i], err: err})
+					logFailedUrl(ctx.cfg.FailedLog, urls[i])
+					mu.Unlock()
+				} else if proc.Incomplete() {
+					fmt.Printf("[%d/%d] Done, with failures\n", i+1, total)
+				} else {
+					fmt.Printf("[%d/%d] Done\n", i+1, total)
+					markCompleted(ctx.checkpoint, urls[i])
+				}
+			}
+		}(proc.Fork())
+	}
+	for i := range urls {
+		if !ctx.checkpoint.Done(urls[i]) {
+			jobs <- i
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	sort.Slice(failures, func(i, j int) bool {
+		return failures[i].num < failures[j].num
+	})
+	sort.Slice(unavailable, func(i, j int) bool {
+		return unavailable[i].num < unavailable[j].num
+	})
+	printUnavailable(unavailable, total)
+	if len(failures) > 0 {
+		fmt.Printf("\n%d of %d items failed:\n", len(failures), total)
+		for _, failure := range failures {
+			fmt.Printf("   - Item %d (%s): %s\n", failure.num, failure.url, failure.err)
+		}
+	}
+	finishCheckpoint(ctx.checkpoint)
+	return failures
+}
+
+// markCompleted records a completed URL in the batch checkpoint. A checkpoint
+// that can't be saved only costs a resumed run some time, so it isn't fatal.
+func markCompleted(checkpoint *processor.BatchCheckpoint, url string) {
+	if err := checkpoint.MarkDone(url); err != nil {
+		logger.GetLogger().WithError(err).WithField("url", url).Warn("Failed to save batch checkpoint")
+	}
+}
+
+// finishCheckpoint deletes the batch checkpoint if the whole list completed
+func finishCheckpoint(checkpoint *processor.BatchCheckpoint) {
+	if err := checkpoint.Finish(); err != nil {
+		logger.GetLogger().WithError(err).Warn("Failed to delete batch checkpoint")
+	}
+}
+
+// printUnavailable lists the items skipped because they aren't available for
+// download, like pre-releases, so they can be tried again later
+func printUnavailable(unavailable []itemFailure, total int) {
+	if len(unavailable) == 0 {
+		return
+	}
+	fmt.Printf("\n%d of %d items skipped, not available for download:\n", len(unavailable), total)
+	for _, item := range unavailable {
+		fmt.Printf("   - Item %d (%s): %s\n", item.num, item.url, item.err)
+	}
+}
+
+// logFailedUrl appends a failed URL to the --failed-log file as soon as it
+// fails, so the list survives the run being cut short. It's one URL per line,
+// which can be passed straight back as the URL list.
+func logFailedUrl(path, url string) {
+	if path == "" {
+		return
+	}
+	f, err := fsutil.AppendFile(path)
+	if err == nil {
+		_, err = fmt.Fprintln(f, url)
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	if err != nil {
+		logger.GetLogger().WithError(err).WithField("path", path).Warn("Failed to write to the failed log")
+		fmt.Println("Failed to write to the failed log.")
+	}
+}
+
+// processItem dispatches a URL to the processor. Invalid and skipped URLs
+// aren't counted as failures. Items that succeed with some of them failed are
+// left marked incomplete on the processor, so they aren't checkpointed.
+func processItem(proc *processor.Processor, ctx *itemContext, url string, itemNum, itemTotal int) error {
+	cfg := ctx.cfg
+	streamParams := ctx.streamParams
+	proc.ResetIncomplete()
+
+	itemId, mediaType := models.CheckUrl(url)
+	if itemId == "" {
+		fmt.Println("Invalid URL:", url)
+		return nil
+	}
+
+	if cfg.Sync && mediaType != 5 {
+		fmt.Println("Sync only supports artist URLs, skipping:", url)
+		return nil
+	}
+
+	if cfg.JSONMeta {
+		return proc.DumpMeta(itemId, mediaType, ctx.legacyToken)
+	}
+
+	if cfg.VerifyPath != "" {
+		if mediaType != 0 {
+			fmt.Println("Verify only supports album URLs, skipping:", url)
+			return nil
+		}
+		return proc.VerifyAlbum(itemId, cfg.VerifyPath)
+	}
+
+	var itemErr error
+	switch mediaType {
+	case 0:
+		itemErr = proc.ProcessAlbum(itemId, streamParams, nil)
+	case 1, 2:
+		itemErr = proc.ProcessPlaylist(itemId, ctx.legacyToken, streamParams, false)
+	case 3:
+		itemErr = proc.ProcessCatalogPlist(itemId, ctx.legacyToken, streamParams)
+	case 4, 10:
+		itemErr = proc.ProcessVideo(itemId, "", streamParams, nil, false)
+	case 5:
+		if cfg.Sync {
+			itemErr = proc.SyncArtist(itemId, streamParams)
+		} else {
+			itemErr = proc.ProcessArtist(itemId, streamParams)
+		}
+	case 6, 7:
+		itemErr = proc.ProcessVideo(itemId, "", streamParams, nil, true)
+	case 8:
+		itemErr = proc.ProcessWebcast(itemId, ctx.uguID, streamParams)
+	case 9:
+		itemErr = proc.ProcessPaidLstream(itemId, ctx.uguID, streamParams)
+	case 11:
+		itemErr = proc.ProcessSingleTrack(itemId, streamParams)
+	}
+
+	if itemErr != nil && !errors.Is(itemErr, models.ErrNotAvailable) {
+		context := map[string]interface{}{
+			"item_type": models.GetItemTypeName(mediaType),
+			"item_id":   itemId,
+			"item_num":  itemNum,
+			"total":     itemTotal,
+			"url":       url,
+		}
+		logger.WrapError(itemErr, context)
+		logger.GetLogger().Error("Item processing failed",
+			"type", models.GetItemTypeName(mediaType),
+			"id", itemId,
+			"url", url)
+	}
+	return itemErr
+}
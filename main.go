@@ -1,11 +1,13 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"path/filepath"
 	"runtime"
 	"strings"
+	"time"
 
 	"main/pkg/api"
 	"main/pkg/config"
@@ -14,17 +16,10 @@ import (
 	"main/pkg/logger"
 	"main/pkg/models"
 	"main/pkg/processor"
+	"main/pkg/remote"
 )
 
 func main() {
-	fmt.Println(`
- _____                ____                _           _
-|   | |_ _ ___ ___   |    \ ___ _ _ _ ___| |___ ___ _| |___ ___
-| | | | | | . |_ -|  |  |  | . | | | |   | | . | .'| . | -_|  _|
-|_|___|___|_  |___|  |____/|___|_____|_|_|_|___|__,|___|___|_|
-	  |___|
-`)
-
 	// Change to script directory
 	scriptDir, err := getScriptDir()
 	if err != nil {
@@ -42,26 +37,96 @@ func main() {
 		os.Exit(1)
 	}
 
+	// Keep stdout for the metadata alone so it can be piped into other tools
+	if cfg.JSONMeta {
+		processor.SetJSONMetaOutput(os.Stdout)
+		os.Stdout = os.Stderr
+		logger.GetLogger().SetOutput(os.Stderr)
+	}
+
+	if !cfg.Quiet {
+		fmt.Println(`
+ _____                ____                _           _
+|   | |_ _ ___ ___   |    \ ___ _ _ _ ___| |___ ___ _| |___ ___
+| | | | | | . |_ -|  |  |  | . | | | |   | | . | .'| . | -_|  _|
+|_|___|___|_  |___|  |____/|___|_____|_|_|_|___|__,|___|___|_|
+	  |___|
+`)
+	}
+
 	// Create output directory
 	err = fsutil.MakeDirs(cfg.OutPath)
 	if err != nil {
 		logger.GetLogger().WithError(err).Error("Failed to make output folder")
 		os.Exit(1)
 	}
+	if cfg.TempDir != "" {
+		if err := fsutil.MakeDirs(cfg.TempDir); err != nil {
+			logger.GetLogger().WithError(err).Error("Failed to make temp folder")
+			os.Exit(1)
+		}
+	}
+
+	if cfg.TrimSilence || cfg.Normalize {
+		fmt.Print("Warning: --trim-silence and --normalize re-encode every track. Lossy tracks lose quality, " +
+			"and none of the files will match the originals from Nugs.\n\n")
+	}
+	if cfg.NoClean {
+		fmt.Print("Warning: --no-clean keeps temporary, encrypted TS and chapter files. They build up in the " +
+			"output and temp folders until you delete them.\n\n")
+	}
+
+	// Wait before signing in, so the token is fresh when downloads start
+	if !cfg.StartAt.IsZero() {
+		waitUntil(cfg.StartAt)
+	}
 
 	// Initialize API client
 	apiClient := api.NewClient()
-
-	// Authenticate if no token provided
-	var token string
-	if cfg.Token == "" {
-		token, err = apiClient.Auth(cfg.Email, cfg.Password)
+	if cfg.AppVersion != "" {
+		apiClient.SetAppVersion(cfg.AppVersion)
+	}
+	// Full user agent strings take priority over the app version
+	if cfg.UserAgent != "" {
+		apiClient.UserAgent = cfg.UserAgent
+	}
+	if cfg.UserAgentTwo != "" {
+		apiClient.UserAgentTwo = cfg.UserAgentTwo
+	}
+	if cfg.MaxConnsPerHost > 0 {
+		apiClient.SetMaxConnsPerHost(cfg.MaxConnsPerHost)
+	}
+	apiClient.ArtistPageConcurrency = cfg.ArtistPageConcurrency
+	apiClient.ArtistCrawlDelay = time.Duration(cfg.ArtistCrawlDelay) * time.Millisecond
+	apiClient.SetDownloadLimit(cfg.Concurrency)
+	if cfg.DNSServer != "" || len(cfg.HostOverrides) > 0 {
+		apiClient.SetResolver(cfg.DNSServer, cfg.HostOverrides)
+	}
+	// Proxies clone the transport set up above, so they go last
+	if err := apiClient.SetProxies(cfg.ProxyList); err != nil {
+		logger.GetLogger().WithError(err).Error("Failed to set up proxies")
+		os.Exit(1)
+	}
+	apiClient.SetExtraHeaders(cfg.ExtraHeaders)
+	if cfg.TestProxy {
+		testProxies(apiClient, len(cfg.Urls) > 0)
+	}
+	if cfg.NoCookieJar {
+		apiClient.DisableCookies()
+	}
+	if cfg.Cookies != "" {
+		err = apiClient.LoadCookies(cfg.Cookies)
 		if err != nil {
-			logger.GetLogger().WithError(err).Error("Failed to authenticate")
+			logger.GetLogger().WithError(err).Error("Failed to load cookies")
 			os.Exit(1)
 		}
-	} else {
-		token = cfg.Token
+	}
+
+	// Authenticate if no token provided
+	token, err := authenticate(apiClient, cfg)
+	if err != nil {
+		logger.GetLogger().WithError(err).Error("Failed to authenticate")
+		os.Exit(1)
 	}
 
 	// Get user info
@@ -96,53 +161,125 @@ func main() {
 	streamParams := models.ParseStreamParams(userId, subInfo, isPromo)
 
 	// Initialize downloader and processor
+	downloader.SetVerboseFfmpeg(cfg.Verbose)
 	downloader := downloader.NewDownloader(apiClient, cfg)
 	processor := processor.NewProcessor(apiClient, downloader, cfg)
+	if dest := openRemote(cfg); dest != nil {
+		defer dest.Close()
+		processor.SetRemote(dest)
+	}
 
 	// Process URLs
-	albumTotal := len(cfg.Urls)
-	for albumNum, url := range cfg.Urls {
-		fmt.Printf("Item %d of %d:\n", albumNum+1, albumTotal)
+	urls := cfg.Urls
+	if cfg.Limit > 0 && len(urls) > cfg.Limit {
+		fmt.Printf("Limiting to %d of %d items.\n", cfg.Limit, len(urls))
+		urls = urls[:cfg.Limit]
+	}
+	// Progress lines from items downloading at the same time would overwrite each other
+	if cfg.ConcurrentItems > 1 {
+		downloader.SetProgressFunc(func(models.ProgressEvent) {})
+	}
+
+	ctx := &itemContext{
+		cfg:          cfg,
+		legacyToken:  legacyToken,
+		uguID:        uguID,
+		streamParams: streamParams,
+		checkpoint:   openCheckpoint(cfg),
+	}
+	start := time.Now()
+	failures := processItems(processor, ctx, urls)
+	if !cfg.Quiet {
+		fmt.Println("\n" + processor.Summary(len(urls), time.Since(start)))
+	}
+
+	if cfg.Strict && len(failures) > 0 {
+		fmt.Printf("%d of %d items failed.\n", len(failures), len(urls))
+		os.Exit(1)
+	}
+}
 
-		itemId, mediaType := models.CheckUrl(url)
-		if itemId == "" {
-			fmt.Println("Invalid URL:", url)
+// testProxies checks each proxy reaches the Nugs API. Any failure ends the
+// run, as would a check on its own without URLs to download.
+func testProxies(apiClient *api.Client, carryOn bool) {
+	fmt.Println("Checking proxies...")
+	checks := apiClient.CheckProxies(context.Background())
+	failed := 0
+	for _, check := range checks {
+		name := check.Proxy
+		if name == "" {
+			name = "No proxy"
+		}
+		if check.Err != nil {
+			failed++
+			logger.GetLogger().WithError(check.Err).WithField("proxy", check.Proxy).Error("Proxy check failed")
+			fmt.Printf("%s: failed, %v\n", name, check.Err)
 			continue
 		}
-
-		var itemErr error
-		switch mediaType {
-		case 0:
-			itemErr = processor.ProcessAlbum(itemId, streamParams, nil)
-		case 1, 2:
-			itemErr = processor.ProcessPlaylist(itemId, legacyToken, streamParams, false)
-		case 3:
-			itemErr = processor.ProcessCatalogPlist(itemId, legacyToken, streamParams)
-		case 4, 10:
-			itemErr = processor.ProcessVideo(itemId, "", streamParams, nil, false)
-		case 5:
-			itemErr = processor.ProcessArtist(itemId, streamParams)
-		case 6, 7, 8:
-			itemErr = processor.ProcessVideo(itemId, "", streamParams, nil, true)
-		case 9:
-			itemErr = processor.ProcessPaidLstream(itemId, uguID, streamParams)
+		egress := "IP unknown"
+		if check.EgressIP != "" {
+			egress = "IP " + check.EgressIP
 		}
+		fmt.Printf("%s: OK in %s, %s\n", name, check.Elapsed.Round(time.Millisecond), egress)
+	}
 
-		if itemErr != nil {
-			context := map[string]interface{}{
-				"item_type": models.GetItemTypeName(mediaType),
-				"item_id":   itemId,
-				"item_num":  albumNum + 1,
-				"total":     albumTotal,
-				"url":       url,
-			}
-			logger.WrapError(itemErr, context)
-			logger.GetLogger().Error("Item processing failed",
-				"type", models.GetItemTypeName(mediaType),
-				"id", itemId,
-				"url", url)
-		}
+	if failed > 0 {
+		fmt.Printf("%d of %d proxies failed.\n", failed, len(checks))
+		os.Exit(1)
+	}
+	if !carryOn {
+		os.Exit(0)
+	}
+	fmt.Println()
+}
+
+// openCheckpoint loads the checkpoint of a URL list, so a run that was cut
+// short skips the items it already completed. Runs that don't download
+// anything aren't checkpointed.
+func openCheckpoint(cfg *config.Config) *processor.BatchCheckpoint {
+	if !cfg.BatchList || cfg.JSONMeta || cfg.DumpURLs || cfg.SimulateQuality || cfg.VerifyPath != "" {
+		return nil
+	}
+
+	dir := filepath.Join(os.Getenv("HOME"), ".nugs-downloader", "batches")
+	checkpoint, err := processor.OpenBatchCheckpoint(dir, cfg.Urls)
+	if err != nil {
+		logger.GetLogger().WithError(err).Warn("Failed to load batch checkpoint")
+		fmt.Println("Failed to load batch checkpoint, starting the list from the top.")
+		return nil
+	}
+	if completed := checkpoint.Completed(); completed > 0 {
+		fmt.Printf("Resuming batch, skipping %d of %d items completed in an earlier run.\n", completed, len(cfg.Urls))
 	}
+	return checkpoint
+}
+
+// openRemote connects to the remote destination finished downloads are
+// moved to, if one is set. Runs that don't download anything don't need it.
+func openRemote(cfg *config.Config) remote.Destination {
+	if cfg.RemoteDest == "" && cfg.RemoteCommand == "" {
+		return nil
+	}
+	if cfg.JSONMeta || cfg.DumpURLs || cfg.SimulateQuality || cfg.VerifyPath != "" {
+		return nil
+	}
+
+	dest, err := remote.Open(cfg.RemoteDest, cfg.RemoteCommand, cfg.RemoteKey)
+	if err != nil {
+		logger.GetLogger().WithError(err).Error("Failed to connect to the remote destination")
+		os.Exit(1)
+	}
+	return dest
+}
+
+// waitUntil sleeps until the given time for --start-at
+func waitUntil(start time.Time) {
+	wait := time.Until(start)
+	if wait <= 0 {
+		return
+	}
+	fmt.Printf("Waiting until %s to start...\n", start.Local().Format("2006-01-02 15:04:05"))
+	time.Sleep(wait)
 }
 
 // getScriptDir returns the directory of the script
@@ -174,3 +311,68 @@ func wasRunFromSrc() bool {
 	buildPath := filepath.Join(os.TempDir(), "go-build")
 	return strings.HasPrefix(os.Args[0], buildPath)
 }
+
+// authenticate returns an access token from the config, the token cache, or
+// a fresh login, in that order of preference
+func authenticate(apiClient *api.Client, cfg *config.Config) (string, error) {
+	if cfg.Token != "" && !cfg.DeviceLogin {
+		if !models.IsTokenExpired(cfg.Token) {
+			return cfg.Token, nil
+		}
+		expiry, _ := models.TokenExpiry(cfg.Token)
+		if cfg.Email == "" || cfg.Password == "" {
+			return "", fmt.Errorf("the token in your config expired on %s, get a new one", expiry.Format("2006-01-02 15:04"))
+		}
+		fmt.Printf("The token in your config expired on %s, logging in with your email and password instead.\n", expiry.Format("2006-01-02 15:04"))
+	}
+
+	cachePath := filepath.Join(os.Getenv("HOME"), ".nugs-downloader", "token.json")
+	if cfg.CacheToken {
+		cache, err := api.LoadTokenCache(cachePath)
+		if err != nil {
+			fmt.Println("Failed to read token cache, logging in again.")
+		} else if cache != nil && cache.Valid() {
+			return cache.AccessToken, nil
+		}
+	}
+
+	var (
+		tokens *models.AuthResponse
+		err    error
+	)
+	if cfg.DeviceLogin {
+		tokens, err = deviceLogin(apiClient)
+	} else {
+		tokens, err = apiClient.AuthTokens(cfg.Email, cfg.Password)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	if cfg.CacheToken {
+		err = api.SaveTokenCache(cachePath, api.NewTokenCache(tokens))
+		if err != nil {
+			fmt.Println("Failed to save token cache.")
+		}
+	}
+
+	return tokens.AccessToken, nil
+}
+
+// deviceLogin runs the device authorization flow, asking the user to approve
+// the login in a browser
+func deviceLogin(apiClient *api.Client) (*models.AuthResponse, error) {
+	device, err := apiClient.RequestDeviceCode()
+	if err != nil {
+		return nil, err
+	}
+
+	verifyUrl := device.VerificationURIComplete
+	if verifyUrl == "" {
+		verifyUrl = device.VerificationURI
+	}
+	fmt.Printf("To log in, visit %s and enter the code %s\n", verifyUrl, device.UserCode)
+	fmt.Println("Waiting for approval...")
+
+	return apiClient.PollDeviceToken(device)
+}
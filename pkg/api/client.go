@@ -1,59 +1,257 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"net"
 	"net/http"
 	"net/http/cookiejar"
 	"net/url"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/grafov/m3u8"
 	"main/pkg/models"
 )
 
 const (
-	devKey         = "x7f54tgbdyc64y656thy47er4"
-	clientId       = "Eg7HuH873H65r5rt325UytR5429"
-	userAgent      = "NugsNet/3.26.724 (Android; 7.1.2; Asus; ASUS_Z01QD; Scale/2.0; en)"
-	userAgentTwo   = "nugsnetAndroid"
-	authUrl        = "https://id.nugs.net/connect/token"
-	streamApiBase  = "https://streamapi.nugs.net/"
-	subInfoUrl     = "https://subscriptions.nugs.net/api/v1/me/subscriptions"
-	userInfoUrl    = "https://id.nugs.net/connect/userinfo"
-	playerUrl      = "https://play.nugs.net/"
-)
-
-var (
-	jar, _ = cookiejar.New(nil)
-	client = &http.Client{Jar: jar}
+	devKey        = "x7f54tgbdyc64y656thy47er4"
+	clientId      = "Eg7HuH873H65r5rt325UytR5429"
+	appVersion    = "3.26.724"
+	userAgentFmt  = "NugsNet/%s (Android; 7.1.2; Asus; ASUS_Z01QD; Scale/2.0; en)"
+	userAgentTwo  = "nugsnetAndroid"
+	authUrl       = "https://id.nugs.net/connect/token"
+	deviceAuthUrl = "https://id.nugs.net/connect/deviceauthorization"
+	streamApiBase = "https://streamapi.nugs.net/"
+	subInfoUrl    = "https://subscriptions.nugs.net/api/v1/me/subscriptions"
+	userInfoUrl   = "https://id.nugs.net/connect/userinfo"
+	playerUrl     = "https://play.nugs.net/"
+
+	// Device flow defaults from RFC 8628
+	defaultDevicePollInterval = 5
+	deviceSlowDownStep        = 5 * time.Second
+	deviceCodeGrantType       = "urn:ietf:params:oauth:grant-type:device_code"
+	authScope                 = "openid profile email nugsnet:api nugsnet:legacyapi offline_access"
+
+	// Metadata retry defaults
+	defaultMaxRetries = 3
+	defaultRetryDelay = time.Second
+	// maxRetryAfter caps the wait a 429's Retry-After can ask for
+	maxRetryAfter = 5 * time.Minute
+
+	// artistPageSize is the number of containers requested per artist page
+	artistPageSize = 100
 )
 
 // Client represents the API client
 type Client struct {
-	BaseAuthURL     string
-	BaseUserInfoURL string
-	BaseSubInfoURL  string
-	BaseStreamURL   string
+	// Endpoint overrides for pointing the client at a mock or mirror. Empty
+	// fields use the nugs.net defaults. PlayerURL is sent as the referer for
+	// media downloads.
+	BaseAuthURL       string
+	BaseDeviceAuthURL string
+	BaseUserInfoURL   string
+	BaseSubInfoURL    string
+	BaseStreamURL     string
+	PlayerURL         string
+	IPEchoURL         string
+
+	// MaxRetries is the number of attempts made for metadata requests that
+	// fail with a 5xx or 429 status or a timeout. RetryDelay is the base
+	// delay of the linear backoff between attempts, used when a 429 doesn't
+	// say how long to wait with Retry-After.
+	MaxRetries int
+	RetryDelay time.Duration
+
+	// ArtistPageConcurrency is the number of artist pages fetched at once
+	// after the first. One or less fetches them one by one.
+	ArtistPageConcurrency int
+	// ArtistCrawlDelay is waited between the metadata requests of an artist
+	// crawl, between its pages and then between its containers, so large
+	// discographies don't get the account throttled
+	ArtistCrawlDelay time.Duration
+
+	// UserAgent is sent to the auth and metadata endpoints, UserAgentTwo to
+	// the stream and player endpoints.
+	UserAgent    string
+	UserAgentTwo string
+
+	httpClient    *http.Client
+	downloadSlots chan struct{} // nil when downloads aren't limited
 }
 
-// NewClient creates a new API client
+// NewClient creates a new API client with its own cookie jar
 func NewClient() *Client {
-	return &Client{}
+	jar, _ := cookiejar.New(nil)
+
+	return &Client{
+		httpClient:   &http.Client{Jar: jar},
+		MaxRetries:   defaultMaxRetries,
+		RetryDelay:   defaultRetryDelay,
+		UserAgent:    FormatUserAgent(appVersion),
+		UserAgentTwo: userAgentTwo,
+	}
+}
+
+// FormatUserAgent builds the app user agent for the given app version
+func FormatUserAgent(version string) string {
+	return fmt.Sprintf(userAgentFmt, version)
+}
+
+// SetAppVersion updates the user agents to report the given app version.
+// The stream user agent doesn't carry a version, so only UserAgent changes.
+func (c *Client) SetAppVersion(version string) {
+	c.UserAgent = FormatUserAgent(version)
 }
 
 // GetHTTPClient returns the underlying HTTP client
 func (c *Client) GetHTTPClient() *http.Client {
-	return client
+	return c.httpClient
+}
+
+// GetWithRedirectPolicy sends a GET through the client's transport, so
+// proxies, DNS overrides and extra headers apply, with checkRedirect deciding
+// which redirects to follow. Cookies are neither sent nor kept.
+func (c *Client) GetWithRedirectPolicy(rawURL string, checkRedirect func(req *http.Request, via []*http.Request) error) (*http.Response, error) {
+	httpClient := &http.Client{
+		Transport:     c.httpClient.Transport,
+		Timeout:       c.httpClient.Timeout,
+		CheckRedirect: checkRedirect,
+	}
+	return httpClient.Get(rawURL)
+}
+
+// SetMaxConnsPerHost bounds the connections open to any single host, so
+// parallel downloads don't hammer one CDN host. Zero means no limit.
+func (c *Client) SetMaxConnsPerHost(n int) {
+	transport := c.cloneTransport()
+	transport.MaxConnsPerHost = n
+	transport.MaxIdleConnsPerHost = n
+	c.httpClient.Transport = transport
+}
+
+// cloneTransport returns a copy of the client's transport to modify, so
+// transports shared with other clients are left alone
+func (c *Client) cloneTransport() *http.Transport {
+	transport, ok := c.httpClient.Transport.(*http.Transport)
+	if !ok || transport == nil {
+		return http.DefaultTransport.(*http.Transport).Clone()
+	}
+	return transport.Clone()
+}
+
+// ResetCookies replaces the cookie jar with an empty one, so a session from
+// an earlier token isn't reused after switching accounts
+func (c *Client) ResetCookies() {
+	jar, _ := cookiejar.New(nil)
+	c.httpClient.Jar = jar
+}
+
+// DisableCookies drops the cookie jar, so no cookies are kept between requests
+func (c *Client) DisableCookies() {
+	c.httpClient.Jar = nil
+}
+
+// SetHTTPClient replaces the underlying HTTP client, e.g. to use a proxy,
+// a timeout or a test transport
+func (c *Client) SetHTTPClient(httpClient *http.Client) {
+	c.httpClient = httpClient
+}
+
+// doWithRetry sends a request, retrying on 5xx responses and timeouts with a
+// linear backoff, and on 429 responses after their Retry-After. Requests with
+// a body are replayed via GetBody, so only requests that are safe to repeat
+// should be sent through here.
+func (c *Client) doWithRetry(req *http.Request) (*http.Response, error) {
+	attempts := c.MaxRetries
+	if attempts < 1 {
+		attempts = 1
+	}
+
+	var (
+		lastErr error
+		wait    time.Duration
+	)
+	for attempt := 0; attempt < attempts; attempt++ {
+		if attempt > 0 {
+			time.Sleep(wait)
+			if req.GetBody != nil {
+				body, err := req.GetBody()
+				if err != nil {
+					return nil, err
+				}
+				req.Body = body
+			}
+		}
+
+		wait = time.Duration(attempt+1) * c.RetryDelay
+
+		resp, err := c.httpClient.Do(req)
+		if err != nil {
+			lastErr = err
+			if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+				continue
+			}
+			return nil, err
+		}
+
+		if resp.StatusCode == http.StatusTooManyRequests {
+			resp.Body.Close()
+			lastErr = errors.New(resp.Status)
+			if after, ok := retryAfter(resp.Header.Get("Retry-After")); ok {
+				wait = after
+			}
+			continue
+		}
+		if resp.StatusCode >= http.StatusInternalServerError {
+			resp.Body.Close()
+			lastErr = errors.New(resp.Status)
+			continue
+		}
+
+		return resp, nil
+	}
+
+	return nil, lastErr
+}
+
+// retryAfter parses a Retry-After header, given in seconds or as an HTTP
+// date, capped at maxRetryAfter
+func retryAfter(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	var after time.Duration
+	if secs, err := strconv.Atoi(value); err == nil {
+		after = time.Duration(secs) * time.Second
+	} else if date, err := http.ParseTime(value); err == nil {
+		after = time.Until(date)
+	} else {
+		return 0, false
+	}
+	return min(max(after, 0), maxRetryAfter), true
 }
 
 // Auth authenticates with the Nugs API
 func (c *Client) Auth(email, pwd string) (string, error) {
+	tokens, err := c.AuthTokens(email, pwd)
+	if err != nil {
+		return "", err
+	}
+	return tokens.AccessToken, nil
+}
+
+// AuthTokens authenticates with email and password and returns the full
+// token response, including the refresh token
+func (c *Client) AuthTokens(email, pwd string) (*models.AuthResponse, error) {
 	data := url.Values{}
 	data.Set("client_id", clientId)
 	data.Set("grant_type", "password")
-	data.Set("scope", "openid profile email nugsnet:api nugsnet:legacyapi offline_access")
+	data.Set("scope", authScope)
 	data.Set("username", email)
 	data.Set("password", pwd)
 
@@ -64,28 +262,137 @@ func (c *Client) Auth(email, pwd string) (string, error) {
 
 	req, err := http.NewRequest(http.MethodPost, authURL, strings.NewReader(data.Encode()))
 	if err != nil {
-		return "", err
+		return nil, err
 	}
-	req.Header.Add("User-Agent", userAgent)
+	req.Header.Add("User-Agent", c.UserAgent)
 	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
 
-	do, err := client.Do(req)
+	do, err := c.doWithRetry(req)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 	defer do.Body.Close()
 
 	if do.StatusCode != http.StatusOK {
-		return "", errors.New(do.Status)
+		return nil, errors.New(do.Status)
 	}
 
 	var obj models.AuthResponse
 	err = json.NewDecoder(do.Body).Decode(&obj)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
-	return obj.AccessToken, nil
+	return &obj, nil
+}
+
+// RequestDeviceCode starts the device authorization flow. The user approves
+// the returned code in a browser while PollDeviceToken waits for the token.
+func (c *Client) RequestDeviceCode() (*models.DeviceAuthResponse, error) {
+	data := url.Values{}
+	data.Set("client_id", clientId)
+	data.Set("scope", authScope)
+
+	deviceAuthURL := deviceAuthUrl
+	if c.BaseDeviceAuthURL != "" {
+		deviceAuthURL = c.BaseDeviceAuthURL
+	}
+
+	req, err := http.NewRequest(http.MethodPost, deviceAuthURL, strings.NewReader(data.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Add("User-Agent", c.UserAgent)
+	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+
+	do, err := c.doWithRetry(req)
+	if err != nil {
+		return nil, err
+	}
+	defer do.Body.Close()
+
+	if do.StatusCode != http.StatusOK {
+		return nil, errors.New(do.Status)
+	}
+
+	var obj models.DeviceAuthResponse
+	err = json.NewDecoder(do.Body).Decode(&obj)
+	if err != nil {
+		return nil, err
+	}
+	if obj.DeviceCode == "" {
+		return nil, errors.New("the identity server didn't return a device code")
+	}
+	if obj.Interval <= 0 {
+		obj.Interval = defaultDevicePollInterval
+	}
+
+	return &obj, nil
+}
+
+// PollDeviceToken polls the token endpoint until the device code is approved,
+// denied or expires, and returns the tokens
+func (c *Client) PollDeviceToken(device *models.DeviceAuthResponse) (*models.AuthResponse, error) {
+	data := url.Values{}
+	data.Set("client_id", clientId)
+	data.Set("grant_type", deviceCodeGrantType)
+	data.Set("device_code", device.DeviceCode)
+
+	authURL := authUrl
+	if c.BaseAuthURL != "" {
+		authURL = c.BaseAuthURL
+	}
+
+	interval := time.Duration(device.Interval) * time.Second
+	var deadline time.Time
+	if device.ExpiresIn > 0 {
+		deadline = time.Now().Add(time.Duration(device.ExpiresIn) * time.Second)
+	}
+
+	for {
+		if !deadline.IsZero() && time.Now().After(deadline) {
+			return nil, errors.New("device code expired before it was approved")
+		}
+		time.Sleep(interval)
+
+		req, err := http.NewRequest(http.MethodPost, authURL, strings.NewReader(data.Encode()))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Add("User-Agent", c.UserAgent)
+		req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+
+		do, err := c.doWithRetry(req)
+		if err != nil {
+			return nil, err
+		}
+
+		if do.StatusCode == http.StatusOK {
+			var obj models.AuthResponse
+			err = json.NewDecoder(do.Body).Decode(&obj)
+			do.Body.Close()
+			if err != nil {
+				return nil, err
+			}
+			return &obj, nil
+		}
+
+		var tokenErr models.TokenErrorResponse
+		json.NewDecoder(do.Body).Decode(&tokenErr)
+		do.Body.Close()
+
+		switch tokenErr.Error {
+		case "authorization_pending":
+		case "slow_down":
+			interval += deviceSlowDownStep
+		case "access_denied":
+			return nil, errors.New("device login was denied")
+		case "expired_token":
+			return nil, errors.New("device code expired before it was approved")
+		default:
+			return nil, errors.New(do.Status)
+		}
+	}
 }
 
 // GetUserInfo retrieves user information
@@ -100,9 +407,9 @@ func (c *Client) GetUserInfo(token string) (string, error) {
 		return "", err
 	}
 	req.Header.Add("Authorization", "Bearer "+token)
-	req.Header.Add("User-Agent", userAgent)
+	req.Header.Add("User-Agent", c.UserAgent)
 
-	do, err := client.Do(req)
+	do, err := c.doWithRetry(req)
 	if err != nil {
 		return "", err
 	}
@@ -133,9 +440,9 @@ func (c *Client) GetSubInfo(token string) (*models.SubInfo, error) {
 		return nil, err
 	}
 	req.Header.Add("Authorization", "Bearer "+token)
-	req.Header.Add("User-Agent", userAgent)
+	req.Header.Add("User-Agent", c.UserAgent)
 
-	do, err := client.Do(req)
+	do, err := c.doWithRetry(req)
 	if err != nil {
 		return nil, err
 	}
@@ -171,9 +478,9 @@ func (c *Client) GetAlbumMeta(albumId string) (*models.AlbumMeta, error) {
 	query.Set("containerID", albumId)
 	query.Set("vdisp", "1")
 	req.URL.RawQuery = query.Encode()
-	req.Header.Add("User-Agent", userAgent)
+	req.Header.Add("User-Agent", c.UserAgent)
 
-	do, err := client.Do(req)
+	do, err := c.doWithRetry(req)
 	if err != nil {
 		return nil, err
 	}
@@ -223,9 +530,9 @@ func (c *Client) GetPlistMeta(plistId, email, legacyToken string, cat bool) (*mo
 		query.Set("token", legacyToken)
 	}
 	req.URL.RawQuery = query.Encode()
-	req.Header.Add("User-Agent", userAgentTwo)
+	req.Header.Add("User-Agent", c.UserAgentTwo)
 
-	do, err := client.Do(req)
+	do, err := c.doWithRetry(req)
 	if err != nil {
 		return nil, err
 	}
@@ -244,11 +551,80 @@ func (c *Client) GetPlistMeta(plistId, email, legacyToken string, cat bool) (*mo
 	return &obj, nil
 }
 
-// GetArtistMeta retrieves artist metadata
+// GetArtistMeta retrieves every page of an artist's containers. With
+// ArtistPageConcurrency above one, pages after the first are fetched in
+// parallel batches once the first page shows the API honours the page size.
 func (c *Client) GetArtistMeta(artistId string) ([]*models.ArtistMeta, error) {
-	var allArtistMeta []*models.ArtistMeta
-	offset := 1
+	first, err := c.getArtistPage(artistId, 1)
+	if err != nil || first == nil {
+		return nil, err
+	}
+	allArtistMeta := []*models.ArtistMeta{first}
+	offset := 1 + len(first.Response.Containers)
+
+	if c.ArtistPageConcurrency > 1 && len(first.Response.Containers) == artistPageSize {
+		var done bool
+		allArtistMeta, offset, done, err = c.getArtistPageBatches(artistId, offset, allArtistMeta)
+		if err != nil || done {
+			return allArtistMeta, err
+		}
+	}
+
+	for {
+		time.Sleep(c.ArtistCrawlDelay)
+		page, err := c.getArtistPage(artistId, offset)
+		if err != nil {
+			return nil, err
+		}
+		if page == nil {
+			break
+		}
+		allArtistMeta = append(allArtistMeta, page)
+		offset += len(page.Response.Containers)
+	}
+
+	return allArtistMeta, nil
+}
+
+// getArtistPageBatches fetches full pages from offset in parallel batches,
+// merging them in order. It stops at the first page that isn't full: when
+// that's the end of the list it reports done, otherwise the API's counts
+// can't be trusted and the offset to carry on sequentially from is returned.
+func (c *Client) getArtistPageBatches(artistId string, offset int, allArtistMeta []*models.ArtistMeta) ([]*models.ArtistMeta, int, bool, error) {
+	for {
+		time.Sleep(c.ArtistCrawlDelay)
+		pages := make([]*models.ArtistMeta, c.ArtistPageConcurrency)
+		errs := make([]error, len(pages))
+		var wg sync.WaitGroup
+		for i := range pages {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				pages[i], errs[i] = c.getArtistPage(artistId, offset+i*artistPageSize)
+			}(i)
+		}
+		wg.Wait()
+
+		for i, page := range pages {
+			if errs[i] != nil {
+				return nil, 0, false, errs[i]
+			}
+			if page == nil {
+				return allArtistMeta, offset, true, nil
+			}
+			allArtistMeta = append(allArtistMeta, page)
+			offset += len(page.Response.Containers)
+			if len(page.Response.Containers) != artistPageSize {
+				// Pages fetched after this one assumed it was full
+				return allArtistMeta, offset, false, nil
+			}
+		}
+	}
+}
 
+// getArtistPage fetches the page of an artist's containers starting at
+// offset. It returns nil once there are no more containers.
+func (c *Client) getArtistPage(artistId string, offset int) (*models.ArtistMeta, error) {
 	streamURL := streamApiBase
 	if c.BaseStreamURL != "" {
 		streamURL = c.BaseStreamURL
@@ -256,46 +632,37 @@ func (c *Client) GetArtistMeta(artistId string) ([]*models.ArtistMeta, error) {
 
 	query := url.Values{}
 	query.Set("method", "catalog.containersAll")
-	query.Set("limit", "100")
+	query.Set("limit", strconv.Itoa(artistPageSize))
 	query.Set("artistList", artistId)
 	query.Set("availType", "1")
 	query.Set("vdisp", "1")
+	query.Set("startOffset", strconv.Itoa(offset))
 
-	for {
-		req, err := http.NewRequest(http.MethodGet, streamURL+"api.aspx", nil)
-		if err != nil {
-			return nil, err
-		}
-		query.Set("startOffset", strconv.Itoa(offset))
-		req.URL.RawQuery = query.Encode()
-		req.Header.Add("User-Agent", userAgent)
-
-		do, err := client.Do(req)
-		if err != nil {
-			return nil, err
-		}
-
-		if do.StatusCode != http.StatusOK {
-			do.Body.Close()
-			return nil, errors.New(do.Status)
-		}
+	req, err := http.NewRequest(http.MethodGet, streamURL+"api.aspx", nil)
+	if err != nil {
+		return nil, err
+	}
+	req.URL.RawQuery = query.Encode()
+	req.Header.Add("User-Agent", c.UserAgent)
 
-		var obj models.ArtistMeta
-		err = json.NewDecoder(do.Body).Decode(&obj)
-		do.Body.Close()
-		if err != nil {
-			return nil, err
-		}
+	do, err := c.doWithRetry(req)
+	if err != nil {
+		return nil, err
+	}
+	defer do.Body.Close()
 
-		retLen := len(obj.Response.Containers)
-		if retLen == 0 {
-			break
-		}
-		allArtistMeta = append(allArtistMeta, &obj)
-		offset += retLen
+	if do.StatusCode != http.StatusOK {
+		return nil, errors.New(do.Status)
 	}
 
-	return allArtistMeta, nil
+	var obj models.ArtistMeta
+	if err := json.NewDecoder(do.Body).Decode(&obj); err != nil {
+		return nil, err
+	}
+	if obj.Response == nil || len(obj.Response.Containers) == 0 {
+		return nil, nil
+	}
+	return &obj, nil
 }
 
 // GetStreamMeta retrieves stream metadata
@@ -326,9 +693,9 @@ func (c *Client) GetStreamMeta(trackId, skuId, format int, streamParams *models.
 	query.Set("startDateStamp", streamParams.StartStamp)
 	query.Set("endDateStamp", streamParams.EndStamp)
 	req.URL.RawQuery = query.Encode()
-	req.Header.Add("User-Agent", userAgentTwo)
+	req.Header.Add("User-Agent", c.UserAgentTwo)
 
-	do, err := client.Do(req)
+	do, err := c.doWithRetry(req)
 	if err != nil {
 		return "", err
 	}
@@ -366,9 +733,9 @@ func (c *Client) GetPurchasedManUrl(skuID int, showID, userID, uguID string) (st
 	query.Set("nn_userID", userID)
 	query.Set("app", "1")
 	req.URL.RawQuery = query.Encode()
-	req.Header.Add("User-Agent", userAgentTwo)
+	req.Header.Add("User-Agent", c.UserAgentTwo)
 
-	do, err := client.Do(req)
+	do, err := c.httpClient.Do(req)
 	if err != nil {
 		return "", err
 	}
@@ -387,9 +754,34 @@ func (c *Client) GetPurchasedManUrl(skuID int, showID, userID, uguID string) (st
 	return obj.FileURL, nil
 }
 
+// PlayerReferer returns the web player URL media downloads are referred from
+func (c *Client) PlayerReferer() string {
+	if c.PlayerURL != "" {
+		return c.PlayerURL
+	}
+	return playerUrl
+}
+
+// StatusError is returned by DownloadFile when the server answers with an
+// unexpected status, so callers can tell missing files from server trouble
+type StatusError struct {
+	StatusCode int
+	Status     string
+}
+
+func (e *StatusError) Error() string {
+	return e.Status
+}
+
 // DownloadFile downloads a file from the given URL
 func (c *Client) DownloadFile(url, referer string) (*http.Response, error) {
-	req, err := http.NewRequest(http.MethodGet, url, nil)
+	return c.DownloadFileContext(context.Background(), url, referer)
+}
+
+// DownloadFileContext is DownloadFile with a context that cancels the
+// request, including reads of the response body
+func (c *Client) DownloadFileContext(ctx context.Context, url, referer string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -397,17 +789,17 @@ func (c *Client) DownloadFile(url, referer string) (*http.Response, error) {
 	if referer != "" {
 		req.Header.Add("Referer", referer)
 	}
-	req.Header.Add("User-Agent", userAgent)
+	req.Header.Add("User-Agent", c.UserAgent)
 	req.Header.Add("Range", "bytes=0-")
 
-	resp, err := client.Do(req)
+	resp, err := c.DoDownload(req)
 	if err != nil {
 		return nil, err
 	}
 
 	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusPartialContent {
 		resp.Body.Close()
-		return nil, errors.New(resp.Status)
+		return nil, &StatusError{StatusCode: resp.StatusCode, Status: resp.Status}
 	}
 
 	return resp, nil
@@ -415,24 +807,14 @@ func (c *Client) DownloadFile(url, referer string) (*http.Response, error) {
 
 // GetM3U8Playlist retrieves and parses an M3U8 playlist
 func (c *Client) GetM3U8Playlist(url string) (*m3u8.MasterPlaylist, error) {
-	resp, err := client.Get(url)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return nil, errors.New(resp.Status)
-	}
-
-	playlist, _, err := m3u8.DecodeFrom(resp.Body, true)
+	playlist, err := c.getPlaylist(url)
 	if err != nil {
 		return nil, err
 	}
 
 	master, ok := playlist.(*m3u8.MasterPlaylist)
 	if !ok {
-		return nil, errors.New("not a master playlist")
+		return nil, fmt.Errorf("expected a master playlist but got a media playlist with %d segments: %s", playlist.(*m3u8.MediaPlaylist).Count(), url)
 	}
 
 	return master, nil
@@ -440,25 +822,79 @@ func (c *Client) GetM3U8Playlist(url string) (*m3u8.MasterPlaylist, error) {
 
 // GetMediaPlaylist retrieves and parses a media playlist
 func (c *Client) GetMediaPlaylist(url string) (*m3u8.MediaPlaylist, error) {
-	resp, err := client.Get(url)
+	playlist, err := c.getPlaylist(url)
 	if err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, errors.New(resp.Status)
+	media, ok := playlist.(*m3u8.MediaPlaylist)
+	if !ok {
+		return nil, fmt.Errorf("expected a media playlist but got a master playlist with %d variants: %s", len(playlist.(*m3u8.MasterPlaylist).Variants), url)
 	}
 
-	playlist, _, err := m3u8.DecodeFrom(resp.Body, true)
-	if err != nil {
-		return nil, err
+	return media, nil
+}
+
+// getPlaylist fetches and decodes a playlist of either type. 5xx responses
+// and timeouts are retried by doWithRetry, and manifests that fail to parse
+// or look incomplete, usually because the response was cut short, are
+// fetched again.
+func (c *Client) getPlaylist(url string) (m3u8.Playlist, error) {
+	attempts := c.MaxRetries
+	if attempts < 1 {
+		attempts = 1
 	}
 
-	media, ok := playlist.(*m3u8.MediaPlaylist)
-	if !ok {
-		return nil, errors.New("not a media playlist")
+	var lastErr error
+	for attempt := 0; attempt < attempts; attempt++ {
+		if attempt > 0 {
+			time.Sleep(time.Duration(attempt) * c.RetryDelay)
+		}
+
+		req, err := http.NewRequest(http.MethodGet, url, nil)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := c.doWithRetry(req)
+		if err != nil {
+			return nil, err
+		}
+
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			return nil, errors.New(resp.Status)
+		}
+
+		playlist, _, err := m3u8.DecodeFrom(resp.Body, true)
+		resp.Body.Close()
+		if err != nil {
+			lastErr = fmt.Errorf("failed to parse playlist: %w", err)
+			continue
+		}
+		if playlistIncomplete(playlist) {
+			lastErr = fmt.Errorf("playlist is incomplete: %s", url)
+			continue
+		}
+		return playlist, nil
 	}
 
-	return media, nil
+	return nil, lastErr
+}
+
+// playlistIncomplete reports whether a decoded playlist looks cut short. The
+// decoder accepts a master playlist truncated mid-variant, leaving a variant
+// without a URI.
+func playlistIncomplete(playlist m3u8.Playlist) bool {
+	switch pl := playlist.(type) {
+	case *m3u8.MasterPlaylist:
+		for _, variant := range pl.Variants {
+			if variant.URI == "" {
+				return true
+			}
+		}
+		return len(pl.Variants) == 0
+	case *m3u8.MediaPlaylist:
+		return pl.Count() == 0
+	}
+	return true
 }
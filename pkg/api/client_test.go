@@ -4,7 +4,10 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"strconv"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/suite"
@@ -221,6 +224,71 @@ func (suite *ApiTestSuite) TestNewClient() {
 	assert.NotNil(suite.T(), client.GetHTTPClient())
 }
 
+// TestNewClient_Independent tests that clients don't share an HTTP client or cookies
+func (suite *ApiTestSuite) TestNewClient_Independent() {
+	client1 := NewClient()
+	client2 := NewClient()
+
+	assert.NotSame(suite.T(), client1.GetHTTPClient(), client2.GetHTTPClient())
+	assert.NotSame(suite.T(), client1.GetHTTPClient().Jar, client2.GetHTTPClient().Jar)
+}
+
+// TestResetCookies tests cookies are dropped or cleared on request
+func (suite *ApiTestSuite) TestResetCookies() {
+	client := NewClient()
+	u, _ := url.Parse("https://play.nugs.net/")
+	client.GetHTTPClient().Jar.SetCookies(u, []*http.Cookie{{Name: "session", Value: "old"}})
+
+	client.ResetCookies()
+	suite.Require().NotNil(client.GetHTTPClient().Jar)
+	assert.Empty(suite.T(), client.GetHTTPClient().Jar.Cookies(u))
+
+	client.DisableCookies()
+	assert.Nil(suite.T(), client.GetHTTPClient().Jar)
+}
+
+// TestSetHTTPClient tests that requests go through an injected HTTP client
+func (suite *ApiTestSuite) TestSetHTTPClient() {
+	var used bool
+	suite.client.SetHTTPClient(&http.Client{
+		Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+			used = true
+			return http.DefaultTransport.RoundTrip(req)
+		}),
+	})
+
+	token, err := suite.client.Auth("test@example.com", "testpass")
+
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "mock-access-token", token)
+	assert.True(suite.T(), used)
+}
+
+// TestSetMaxConnsPerHost tests the per-host connection limit on the transport
+func (suite *ApiTestSuite) TestSetMaxConnsPerHost() {
+	client := NewClient()
+	client.SetMaxConnsPerHost(4)
+
+	transport, ok := client.GetHTTPClient().Transport.(*http.Transport)
+	suite.Require().True(ok)
+	assert.Equal(suite.T(), 4, transport.MaxConnsPerHost)
+	assert.Equal(suite.T(), 4, transport.MaxIdleConnsPerHost)
+	assert.NotSame(suite.T(), http.DefaultTransport, transport)
+
+	// Requests still work through the limited transport
+	suite.client.SetMaxConnsPerHost(1)
+	token, err := suite.client.Auth("test@example.com", "testpass")
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "mock-access-token", token)
+}
+
+// roundTripFunc adapts a function to http.RoundTripper
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
 // TestAuth_Success tests successful authentication
 func (suite *ApiTestSuite) TestAuth_Success() {
 	token, err := suite.client.Auth("test@example.com", "testpass")
@@ -339,6 +407,23 @@ func (suite *ApiTestSuite) TestDownloadFile_NotFound() {
 	assert.Nil(suite.T(), resp)
 }
 
+// TestPlayerReferer tests media downloads are referred from the configured player
+func (suite *ApiTestSuite) TestPlayerReferer() {
+	var referer string
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		referer = r.Referer()
+	}))
+	defer testServer.Close()
+
+	assert.Equal(suite.T(), playerUrl, suite.client.PlayerReferer())
+
+	suite.client.PlayerURL = "https://player.mirror.example/"
+	resp, err := suite.client.DownloadFile(testServer.URL, suite.client.PlayerReferer())
+	suite.Require().NoError(err)
+	resp.Body.Close()
+	assert.Equal(suite.T(), "https://player.mirror.example/", referer)
+}
+
 // TestGetM3U8Playlist_Success tests successful M3U8 playlist retrieval
 func (suite *ApiTestSuite) TestGetM3U8Playlist_Success() {
 	playlistURL := suite.server.URL + "/playlist.m3u8"
@@ -377,11 +462,332 @@ func (suite *ApiTestSuite) TestGetMediaPlaylist_InvalidURL() {
 	assert.Nil(suite.T(), playlist)
 }
 
+// TestGetM3U8Playlist_RetriesTruncated tests that cut-short manifests are fetched again
+func (suite *ApiTestSuite) TestGetM3U8Playlist_RetriesTruncated() {
+	attempts := 0
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		switch attempts {
+		case 1:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		case 2:
+			w.Write([]byte("#EXTM3U\n#EXT-X-VERSION:3\n#EXT-X-STREAM-INF:BANDW"))
+		case 3:
+			w.Write([]byte("<html>"))
+		default:
+			w.Write([]byte("#EXTM3U\n#EXT-X-VERSION:3\n#EXT-X-STREAM-INF:BANDWIDTH=1280000\naudio.m3u8\n"))
+		}
+	}))
+	defer testServer.Close()
+
+	suite.client.RetryDelay = time.Millisecond
+
+	playlist, err := suite.client.GetM3U8Playlist(testServer.URL + "/playlist.m3u8")
+
+	suite.Require().NoError(err)
+	assert.Equal(suite.T(), 4, attempts)
+	assert.Len(suite.T(), playlist.Variants, 1)
+}
+
+// TestGetMediaPlaylist_ParseErrorsExhausted tests the error once every fetch fails to parse
+func (suite *ApiTestSuite) TestGetMediaPlaylist_ParseErrorsExhausted() {
+	attempts := 0
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		w.Write([]byte("#EXTM3U\n"))
+	}))
+	defer testServer.Close()
+
+	suite.client.RetryDelay = time.Millisecond
+
+	playlist, err := suite.client.GetMediaPlaylist(testServer.URL + "/media.m3u8")
+
+	assert.Error(suite.T(), err)
+	assert.Contains(suite.T(), err.Error(), "failed to parse playlist")
+	assert.Nil(suite.T(), playlist)
+	assert.Equal(suite.T(), defaultMaxRetries, attempts)
+}
+
+// TestGetPlaylist_TypeMismatch tests that the wrong playlist type is reported
+// clearly and not retried
+func (suite *ApiTestSuite) TestGetPlaylist_TypeMismatch() {
+	master, err := suite.client.GetM3U8Playlist(suite.server.URL + "/media.m3u8")
+	assert.Nil(suite.T(), master)
+	suite.Require().Error(err)
+	assert.Contains(suite.T(), err.Error(), "expected a master playlist but got a media playlist with 1 segments")
+
+	media, err := suite.client.GetMediaPlaylist(suite.server.URL + "/playlist.m3u8")
+	assert.Nil(suite.T(), media)
+	suite.Require().Error(err)
+	assert.Contains(suite.T(), err.Error(), "expected a media playlist but got a master playlist with 1 variants")
+}
+
+// artistPageServer serves total containers with ids counting up from 1, in
+// pages of pageSize regardless of the requested limit
+func artistPageServer(total, pageSize int) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		offset, _ := strconv.Atoi(r.URL.Query().Get("startOffset"))
+		var containers []*models.AlbArtResp
+		for id := offset; id <= total && id < offset+pageSize; id++ {
+			containers = append(containers, &models.AlbArtResp{ContainerID: id})
+		}
+		json.NewEncoder(w).Encode(models.ArtistMeta{Response: &models.ArtistResp{Containers: containers}})
+	}))
+}
+
+// TestGetArtistMeta_Concurrent tests pages fetched in parallel are merged in
+// order, including when the API doesn't honour the page size
+func (suite *ApiTestSuite) TestGetArtistMeta_Concurrent() {
+	for _, tc := range []struct {
+		name        string
+		total       int
+		pageSize    int
+		concurrency int
+	}{
+		{"sequential", 250, artistPageSize, 0},
+		{"parallel", 250, artistPageSize, 3},
+		{"exact pages", 300, artistPageSize, 2},
+		{"page size ignored", 250, 60, 3},
+	} {
+		server := artistPageServer(tc.total, tc.pageSize)
+		suite.client.BaseStreamURL = server.URL + "/"
+		suite.client.ArtistPageConcurrency = tc.concurrency
+
+		pages, err := suite.client.GetArtistMeta("461")
+		server.Close()
+		suite.Require().NoError(err, tc.name)
+
+		var ids []int
+		for _, page := range pages {
+			for _, container := range page.Response.Containers {
+				ids = append(ids, container.ContainerID)
+			}
+		}
+		suite.Require().Len(ids, tc.total, tc.name)
+		for i, id := range ids {
+			suite.Require().Equal(i+1, id, tc.name)
+		}
+	}
+}
+
+// TestGetArtistMeta_CrawlDelay tests the wait between artist pages
+func (suite *ApiTestSuite) TestGetArtistMeta_CrawlDelay() {
+	server := artistPageServer(250, artistPageSize)
+	defer server.Close()
+	suite.client.BaseStreamURL = server.URL + "/"
+	suite.client.ArtistCrawlDelay = 20 * time.Millisecond
+
+	start := time.Now()
+	pages, err := suite.client.GetArtistMeta("461")
+	suite.Require().NoError(err)
+	assert.Len(suite.T(), pages, 3)
+	// Three pages and the empty one that ends the list, with a wait before
+	// each after the first
+	assert.GreaterOrEqual(suite.T(), time.Since(start), 3*suite.client.ArtistCrawlDelay)
+}
+
+// TestGetAlbumMeta_RetriesTooManyRequests tests that 429 responses are
+// retried after their Retry-After
+func (suite *ApiTestSuite) TestGetAlbumMeta_RetriesTooManyRequests() {
+	attempts := 0
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		if attempts == 1 {
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		response := models.AlbumMeta{Response: &models.AlbArtResp{ContainerID: 123}}
+		json.NewEncoder(w).Encode(response)
+	}))
+	defer testServer.Close()
+
+	suite.client.BaseStreamURL = testServer.URL + "/"
+	suite.client.RetryDelay = time.Millisecond
+
+	start := time.Now()
+	albumMeta, err := suite.client.GetAlbumMeta("123")
+
+	suite.Require().NoError(err)
+	assert.Equal(suite.T(), 2, attempts)
+	assert.Equal(suite.T(), 123, albumMeta.Response.ContainerID)
+	assert.GreaterOrEqual(suite.T(), time.Since(start), time.Second)
+}
+
+// TestRetryAfter tests Retry-After parsing
+func (suite *ApiTestSuite) TestRetryAfter() {
+	after, ok := retryAfter("30")
+	assert.True(suite.T(), ok)
+	assert.Equal(suite.T(), 30*time.Second, after)
+
+	after, ok = retryAfter(time.Now().Add(time.Hour).UTC().Format(http.TimeFormat))
+	assert.True(suite.T(), ok)
+	assert.Equal(suite.T(), maxRetryAfter, after)
+
+	after, ok = retryAfter(time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat))
+	assert.True(suite.T(), ok)
+	assert.Equal(suite.T(), time.Duration(0), after)
+
+	_, ok = retryAfter("")
+	assert.False(suite.T(), ok)
+	_, ok = retryAfter("soon")
+	assert.False(suite.T(), ok)
+}
+
+// TestGetAlbumMeta_RetriesServerErrors tests that 5xx responses are retried
+func (suite *ApiTestSuite) TestGetAlbumMeta_RetriesServerErrors() {
+	attempts := 0
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		if attempts < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		response := models.AlbumMeta{Response: &models.AlbArtResp{ContainerID: 123}}
+		json.NewEncoder(w).Encode(response)
+	}))
+	defer testServer.Close()
+
+	suite.client.BaseStreamURL = testServer.URL + "/"
+	suite.client.RetryDelay = time.Millisecond
+
+	albumMeta, err := suite.client.GetAlbumMeta("123")
+
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 3, attempts)
+	assert.Equal(suite.T(), 123, albumMeta.Response.ContainerID)
+}
+
+// TestGetStreamMeta_RetriesExhausted tests the error after all attempts fail
+func (suite *ApiTestSuite) TestGetStreamMeta_RetriesExhausted() {
+	attempts := 0
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer testServer.Close()
+
+	suite.client.BaseStreamURL = testServer.URL + "/"
+	suite.client.RetryDelay = time.Millisecond
+
+	streamLink, err := suite.client.GetStreamMeta(123, 0, 2, &models.StreamParams{})
+
+	assert.Error(suite.T(), err)
+	assert.Contains(suite.T(), err.Error(), "502")
+	assert.Empty(suite.T(), streamLink)
+	assert.Equal(suite.T(), defaultMaxRetries, attempts)
+}
+
+// TestGetUserInfo_NoRetryOnClientError tests that 4xx responses aren't retried
+func (suite *ApiTestSuite) TestGetUserInfo_NoRetryOnClientError() {
+	attempts := 0
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer testServer.Close()
+
+	suite.client.BaseUserInfoURL = testServer.URL
+	suite.client.RetryDelay = time.Millisecond
+
+	_, err := suite.client.GetUserInfo("mock-access-token")
+
+	assert.Error(suite.T(), err)
+	assert.Equal(suite.T(), 1, attempts)
+}
+
+// TestDeviceLogin tests the device authorization flow through to a token
+func (suite *ApiTestSuite) TestDeviceLogin() {
+	polls := 0
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/connect/deviceauthorization":
+			json.NewEncoder(w).Encode(models.DeviceAuthResponse{
+				DeviceCode:      "device-123",
+				UserCode:        "ABCD-EFGH",
+				VerificationURI: "https://id.nugs.net/device",
+				ExpiresIn:       300,
+			})
+		case "/connect/token":
+			assert.Equal(suite.T(), deviceCodeGrantType, r.FormValue("grant_type"))
+			assert.Equal(suite.T(), "device-123", r.FormValue("device_code"))
+			polls++
+			if polls < 3 {
+				w.WriteHeader(http.StatusBadRequest)
+				json.NewEncoder(w).Encode(models.TokenErrorResponse{Error: "authorization_pending"})
+				return
+			}
+			json.NewEncoder(w).Encode(models.AuthResponse{AccessToken: "device-access-token"})
+		}
+	}))
+	defer testServer.Close()
+
+	suite.client.BaseDeviceAuthURL = testServer.URL + "/connect/deviceauthorization"
+	suite.client.BaseAuthURL = testServer.URL + "/connect/token"
+
+	device, err := suite.client.RequestDeviceCode()
+	suite.Require().NoError(err)
+	assert.Equal(suite.T(), "ABCD-EFGH", device.UserCode)
+	assert.Equal(suite.T(), defaultDevicePollInterval, device.Interval)
+
+	// Don't wait out the real interval in tests
+	device.Interval = 0
+	tokens, err := suite.client.PollDeviceToken(device)
+	suite.Require().NoError(err)
+	assert.Equal(suite.T(), "device-access-token", tokens.AccessToken)
+	assert.Equal(suite.T(), 3, polls)
+}
+
+// TestDeviceLogin_Denied tests that a denied device code stops polling
+func (suite *ApiTestSuite) TestDeviceLogin_Denied() {
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(models.TokenErrorResponse{Error: "access_denied"})
+	}))
+	defer testServer.Close()
+
+	suite.client.BaseAuthURL = testServer.URL
+	_, err := suite.client.PollDeviceToken(&models.DeviceAuthResponse{DeviceCode: "device-123"})
+
+	assert.Error(suite.T(), err)
+	assert.Contains(suite.T(), err.Error(), "denied")
+}
+
+// TestSetAppVersion tests that the app version is formatted into the user agent
+func (suite *ApiTestSuite) TestSetAppVersion() {
+	client := NewClient()
+	assert.Contains(suite.T(), client.UserAgent, "NugsNet/"+appVersion+" ")
+	assert.Equal(suite.T(), userAgentTwo, client.UserAgentTwo)
+
+	client.SetAppVersion("4.1.0")
+	assert.Equal(suite.T(), "NugsNet/4.1.0 (Android; 7.1.2; Asus; ASUS_Z01QD; Scale/2.0; en)", client.UserAgent)
+	assert.Equal(suite.T(), userAgentTwo, client.UserAgentTwo)
+}
+
+// TestUserAgentOverride tests that requests carry the client's user agent
+func (suite *ApiTestSuite) TestUserAgentOverride() {
+	var gotUA string
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotUA = r.Header.Get("User-Agent")
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer testServer.Close()
+
+	suite.client.BaseUserInfoURL = testServer.URL
+	suite.client.UserAgent = "CustomAgent/1.0"
+
+	suite.client.GetUserInfo("mock-access-token")
+
+	assert.Equal(suite.T(), "CustomAgent/1.0", gotUA)
+}
+
 // TestConstants tests that constants are properly defined
 func (suite *ApiTestSuite) TestConstants() {
 	assert.Equal(suite.T(), "x7f54tgbdyc64y656thy47er4", devKey)
 	assert.Equal(suite.T(), "Eg7HuH873H65r5rt325UytR5429", clientId)
-	assert.Contains(suite.T(), userAgent, "NugsNet")
+	assert.Contains(suite.T(), FormatUserAgent(appVersion), "NugsNet")
 	assert.Contains(suite.T(), userAgentTwo, "nugsnetAndroid")
 	assert.Contains(suite.T(), authUrl, "id.nugs.net")
 	assert.Contains(suite.T(), streamApiBase, "streamapi.nugs.net")
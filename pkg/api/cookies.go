@@ -0,0 +1,102 @@
+package api
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// httpOnlyPrefix marks HttpOnly cookies in Netscape cookie files
+const httpOnlyPrefix = "#HttpOnly_"
+
+// LoadCookies loads a Netscape-format cookie file, as exported by browser
+// extensions, into the client's cookie jar
+func (c *Client) LoadCookies(path string) error {
+	if c.httpClient.Jar == nil {
+		return fmt.Errorf("http client has no cookie jar")
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		cookie, cookieUrl, err := parseNetscapeCookie(scanner.Text())
+		if err != nil {
+			return fmt.Errorf("line %d: %w", lineNum, err)
+		}
+		if cookie == nil {
+			continue
+		}
+		c.httpClient.Jar.SetCookies(cookieUrl, []*http.Cookie{cookie})
+	}
+
+	return scanner.Err()
+}
+
+// parseNetscapeCookie parses one line of a Netscape cookie file. Blank lines
+// and comments return a nil cookie.
+func parseNetscapeCookie(line string) (*http.Cookie, *url.URL, error) {
+	line = strings.TrimRight(line, "\r\n")
+
+	httpOnly := false
+	if strings.HasPrefix(line, httpOnlyPrefix) {
+		httpOnly = true
+		line = strings.TrimPrefix(line, httpOnlyPrefix)
+	}
+	if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "#") {
+		return nil, nil, nil
+	}
+
+	fields := strings.Split(line, "\t")
+	if len(fields) != 7 {
+		return nil, nil, fmt.Errorf("expected 7 tab-separated fields, got %d", len(fields))
+	}
+
+	domain := fields[0]
+	includeSubdomains := strings.EqualFold(fields[1], "TRUE")
+	secure := strings.EqualFold(fields[3], "TRUE")
+
+	expiry, err := strconv.ParseInt(fields[4], 10, 64)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid expiry %q", fields[4])
+	}
+
+	cookie := &http.Cookie{
+		Name:     fields[5],
+		Value:    fields[6],
+		Path:     fields[2],
+		Secure:   secure,
+		HttpOnly: httpOnly,
+	}
+	// Host-only cookies are set without a domain so the jar ties them to the host
+	if includeSubdomains {
+		cookie.Domain = domain
+	}
+	// Zero means a session cookie
+	if expiry > 0 {
+		cookie.Expires = time.Unix(expiry, 0)
+	}
+
+	scheme := "http"
+	if secure {
+		scheme = "https"
+	}
+	cookieUrl := &url.URL{
+		Scheme: scheme,
+		Host:   strings.TrimPrefix(domain, "."),
+		Path:   cookie.Path,
+	}
+
+	return cookie, cookieUrl, nil
+}
@@ -0,0 +1,70 @@
+package api
+
+import (
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+const testCookieFile = `# Netscape HTTP Cookie File
+# This is a generated file! Do not edit.
+
+.nugs.net	TRUE	/	TRUE	4102444800	session	abc123
+#HttpOnly_play.nugs.net	FALSE	/	TRUE	0	auth	xyz789
+`
+
+// CookiesTestSuite covers loading Netscape cookie files
+type CookiesTestSuite struct {
+	suite.Suite
+	tempDir string
+}
+
+// SetupTest creates a temporary directory for cookie files
+func (suite *CookiesTestSuite) SetupTest() {
+	suite.tempDir = suite.T().TempDir()
+}
+
+// TestLoadCookies tests that cookies land in the client's jar
+func (suite *CookiesTestSuite) TestLoadCookies() {
+	path := filepath.Join(suite.tempDir, "cookies.txt")
+	suite.Require().NoError(os.WriteFile(path, []byte(testCookieFile), 0644))
+
+	client := NewClient()
+	suite.Require().NoError(client.LoadCookies(path))
+
+	u, _ := url.Parse("https://play.nugs.net/")
+	cookies := client.GetHTTPClient().Jar.Cookies(u)
+	values := map[string]string{}
+	for _, cookie := range cookies {
+		values[cookie.Name] = cookie.Value
+	}
+	assert.Equal(suite.T(), "abc123", values["session"])
+	assert.Equal(suite.T(), "xyz789", values["auth"])
+
+	// Subdomain cookie reaches other hosts, host-only cookie doesn't
+	u, _ = url.Parse("https://streamapi.nugs.net/")
+	cookies = client.GetHTTPClient().Jar.Cookies(u)
+	suite.Require().Len(cookies, 1)
+	assert.Equal(suite.T(), "session", cookies[0].Name)
+}
+
+// TestLoadCookies_Errors tests missing and malformed cookie files
+func (suite *CookiesTestSuite) TestLoadCookies_Errors() {
+	client := NewClient()
+	assert.Error(suite.T(), client.LoadCookies(filepath.Join(suite.tempDir, "missing.txt")))
+
+	path := filepath.Join(suite.tempDir, "cookies.txt")
+	suite.Require().NoError(os.WriteFile(path, []byte(".nugs.net\tTRUE\t/\n"), 0644))
+	assert.Error(suite.T(), client.LoadCookies(path))
+
+	suite.Require().NoError(os.WriteFile(path, []byte(".nugs.net\tTRUE\t/\tTRUE\tsoon\tsession\tabc\n"), 0644))
+	assert.Error(suite.T(), client.LoadCookies(path))
+}
+
+func TestCookiesTestSuite(t *testing.T) {
+	suite.Run(t, new(CookiesTestSuite))
+}
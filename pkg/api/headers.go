@@ -0,0 +1,33 @@
+package api
+
+import "net/http"
+
+// headerTransport is a RoundTripper that sets extra headers on every request
+type headerTransport struct {
+	base    http.RoundTripper
+	headers map[string]string
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *headerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// RoundTrippers mustn't modify the caller's request
+	req = req.Clone(req.Context())
+	for name, value := range t.headers {
+		req.Header.Set(name, value)
+	}
+	return t.base.RoundTrip(req)
+}
+
+// SetExtraHeaders sets the given headers on every request the client sends,
+// metadata, stream, key and segment requests alike, replacing any the request
+// already has. It wraps the current transport, so call it after SetProxies.
+func (c *Client) SetExtraHeaders(headers map[string]string) {
+	if len(headers) == 0 {
+		return
+	}
+	base := c.httpClient.Transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	c.httpClient.Transport = &headerTransport{base: base, headers: headers}
+}
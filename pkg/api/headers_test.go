@@ -0,0 +1,90 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+// HeadersTestSuite covers the extra headers set on every request
+type HeadersTestSuite struct {
+	suite.Suite
+}
+
+// TestSetExtraHeaders tests that downloads and direct requests carry the
+// extra headers, which replace the client's own
+func (suite *HeadersTestSuite) TestSetExtraHeaders() {
+	var got []http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = append(got, r.Header.Clone())
+	}))
+	defer server.Close()
+
+	client := NewClient()
+	client.SetExtraHeaders(map[string]string{"X-Client-Id": "abc123", "User-Agent": "custom"})
+
+	resp, err := client.DownloadFile(server.URL, "")
+	suite.Require().NoError(err)
+	resp.Body.Close()
+
+	req, err := http.NewRequest(http.MethodGet, server.URL, nil)
+	suite.Require().NoError(err)
+	resp, err = client.GetHTTPClient().Do(req)
+	suite.Require().NoError(err)
+	resp.Body.Close()
+
+	suite.Require().Len(got, 2)
+	for _, header := range got {
+		assert.Equal(suite.T(), "abc123", header.Get("X-Client-Id"))
+		assert.Equal(suite.T(), "custom", header.Get("User-Agent"))
+	}
+	// The caller's request is left as it was
+	assert.Empty(suite.T(), req.Header.Get("X-Client-Id"))
+}
+
+// TestGetWithRedirectPolicy tests requests sent with their own redirect
+// policy still carry the extra headers, on each hop
+func (suite *HeadersTestSuite) TestGetWithRedirectPolicy() {
+	var got []http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = append(got, r.Header.Clone())
+		if r.URL.Path == "/short" {
+			http.Redirect(w, r, "/long", http.StatusFound)
+		}
+	}))
+	defer server.Close()
+
+	client := NewClient()
+	client.SetExtraHeaders(map[string]string{"X-Client-Id": "abc123"})
+
+	resp, err := client.GetWithRedirectPolicy(server.URL+"/short", nil)
+	suite.Require().NoError(err)
+	resp.Body.Close()
+	suite.Require().Len(got, 2)
+	for _, header := range got {
+		assert.Equal(suite.T(), "abc123", header.Get("X-Client-Id"))
+	}
+
+	// The policy decides where redirects stop
+	resp, err = client.GetWithRedirectPolicy(server.URL+"/short", func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	})
+	suite.Require().NoError(err)
+	resp.Body.Close()
+	assert.Equal(suite.T(), http.StatusFound, resp.StatusCode)
+	assert.Len(suite.T(), got, 3)
+}
+
+// TestSetExtraHeaders_None tests that no headers leave the transport alone
+func (suite *HeadersTestSuite) TestSetExtraHeaders_None() {
+	client := NewClient()
+	client.SetExtraHeaders(nil)
+	assert.Nil(suite.T(), client.GetHTTPClient().Transport)
+}
+
+func TestHeadersTestSuite(t *testing.T) {
+	suite.Run(t, new(HeadersTestSuite))
+}
@@ -0,0 +1,73 @@
+package api
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"sync"
+)
+
+// SetDownloadLimit bounds the file downloads in flight across the whole
+// process, whichever item, track, segment or asset they belong to. Each
+// download holds a slot from the request until its body is read to the end
+// or closed. Zero means no limit.
+func (c *Client) SetDownloadLimit(n int) {
+	if n <= 0 {
+		c.downloadSlots = nil
+		return
+	}
+	c.downloadSlots = make(chan struct{}, n)
+}
+
+// LimitDownload runs send once a download slot is free, and frees it again
+// when the response body is done with or send fails. Waiting for a slot
+// gives up when ctx is cancelled.
+func (c *Client) LimitDownload(ctx context.Context, send func() (*http.Response, error)) (*http.Response, error) {
+	slots := c.downloadSlots
+	if slots == nil {
+		return send()
+	}
+
+	select {
+	case slots <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	release := sync.OnceFunc(func() { <-slots })
+
+	resp, err := send()
+	if err != nil {
+		release()
+		return nil, err
+	}
+	resp.Body = &slotBody{ReadCloser: resp.Body, release: release}
+	return resp, nil
+}
+
+// DoDownload sends a file download request under the download limit
+func (c *Client) DoDownload(req *http.Request) (*http.Response, error) {
+	return c.LimitDownload(req.Context(), func() (*http.Response, error) {
+		return c.httpClient.Do(req)
+	})
+}
+
+// slotBody frees its download slot at the end of the body, so callers that
+// close it late, or not at all, don't hold up other downloads
+type slotBody struct {
+	io.ReadCloser
+	release func()
+}
+
+func (b *slotBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if err == io.EOF {
+		b.release()
+	}
+	return n, err
+}
+
+func (b *slotBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.release()
+	return err
+}
@@ -0,0 +1,108 @@
+package api
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+// LimiterTestSuite covers the global download limit
+type LimiterTestSuite struct {
+	suite.Suite
+	server *httptest.Server
+}
+
+func (suite *LimiterTestSuite) SetupTest() {
+	suite.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("data"))
+	}))
+}
+
+func (suite *LimiterTestSuite) TearDownTest() {
+	suite.server.Close()
+}
+
+// download tries a download that gives up after a short wait for a slot
+func (suite *LimiterTestSuite) download(client *Client) (*http.Response, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	return client.DownloadFileContext(ctx, suite.server.URL, "")
+}
+
+// TestSetDownloadLimit_HoldsSlotUntilClosed tests that an open download
+// blocks the next one until its body is closed
+func (suite *LimiterTestSuite) TestSetDownloadLimit_HoldsSlotUntilClosed() {
+	client := NewClient()
+	client.SetDownloadLimit(1)
+
+	first, err := client.DownloadFile(suite.server.URL, "")
+	suite.Require().NoError(err)
+
+	_, err = suite.download(client)
+	assert.ErrorIs(suite.T(), err, context.DeadlineExceeded)
+
+	first.Body.Close()
+	second, err := suite.download(client)
+	suite.Require().NoError(err)
+	second.Body.Close()
+	// Closing twice doesn't free a slot twice
+	first.Body.Close()
+	assert.Len(suite.T(), client.downloadSlots, 0)
+}
+
+// TestSetDownloadLimit_ReleasesAtEOF tests that reading a body to the end
+// frees its slot before it's closed
+func (suite *LimiterTestSuite) TestSetDownloadLimit_ReleasesAtEOF() {
+	client := NewClient()
+	client.SetDownloadLimit(1)
+
+	first, err := client.DownloadFile(suite.server.URL, "")
+	suite.Require().NoError(err)
+	defer first.Body.Close()
+	body, err := io.ReadAll(first.Body)
+	suite.Require().NoError(err)
+	assert.Equal(suite.T(), "data", string(body))
+
+	second, err := suite.download(client)
+	suite.Require().NoError(err)
+	second.Body.Close()
+}
+
+// TestSetDownloadLimit_ReleasesOnError tests that failed requests don't keep
+// their slot
+func (suite *LimiterTestSuite) TestSetDownloadLimit_ReleasesOnError() {
+	client := NewClient()
+	client.SetDownloadLimit(1)
+
+	_, err := client.DownloadFile("http://127.0.0.1:0/missing", "")
+	suite.Require().Error(err)
+
+	resp, err := suite.download(client)
+	suite.Require().NoError(err)
+	resp.Body.Close()
+}
+
+// TestSetDownloadLimit_Unlimited tests that zero turns the limit off
+func (suite *LimiterTestSuite) TestSetDownloadLimit_Unlimited() {
+	client := NewClient()
+	client.SetDownloadLimit(1)
+	client.SetDownloadLimit(0)
+
+	first, err := client.DownloadFile(suite.server.URL, "")
+	suite.Require().NoError(err)
+	defer first.Body.Close()
+
+	second, err := suite.download(client)
+	suite.Require().NoError(err)
+	second.Body.Close()
+}
+
+func TestLimiterTestSuite(t *testing.T) {
+	suite.Run(t, new(LimiterTestSuite))
+}
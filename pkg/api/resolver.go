@@ -0,0 +1,59 @@
+package api
+
+import (
+	"context"
+	"net"
+	"strings"
+	"time"
+)
+
+// SetResolver makes the client look hosts up through dnsServer instead of the
+// system resolver, and connect to the fixed IPs in hostOverrides for the hosts
+// listed there. TLS still verifies against the original host name. An empty
+// dnsServer keeps the system resolver.
+func (c *Client) SetResolver(dnsServer string, hostOverrides map[string]string) {
+	transport := c.cloneTransport()
+	transport.DialContext = resolverDialContext(dnsServer, hostOverrides)
+	c.httpClient.Transport = transport
+}
+
+// resolverDialContext builds a DialContext that applies the DNS server and
+// host overrides of SetResolver
+func resolverDialContext(dnsServer string, hostOverrides map[string]string) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	dialer := &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+	}
+	if dnsServer != "" {
+		dnsAddr := DNSServerAddr(dnsServer)
+		dialer.Resolver = &net.Resolver{
+			PreferGo: true,
+			Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, network, dnsAddr)
+			},
+		}
+	}
+
+	overrides := make(map[string]string, len(hostOverrides))
+	for host, ip := range hostOverrides {
+		overrides[strings.ToLower(host)] = ip
+	}
+
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		if host, port, err := net.SplitHostPort(addr); err == nil {
+			if ip, ok := overrides[strings.ToLower(host)]; ok {
+				addr = net.JoinHostPort(ip, port)
+			}
+		}
+		return dialer.DialContext(ctx, network, addr)
+	}
+}
+
+// DNSServerAddr adds the default DNS port to a server address without one
+func DNSServerAddr(server string) string {
+	if _, _, err := net.SplitHostPort(server); err == nil {
+		return server
+	}
+	return net.JoinHostPort(server, "53")
+}
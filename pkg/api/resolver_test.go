@@ -0,0 +1,51 @@
+package api
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+// ResolverTestSuite covers custom DNS servers and host overrides
+type ResolverTestSuite struct {
+	suite.Suite
+}
+
+// TestSetResolver_HostOverride tests that an overridden host connects to the pinned IP
+func (suite *ResolverTestSuite) TestSetResolver_HostOverride() {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Host))
+	}))
+	defer server.Close()
+
+	u, err := url.Parse(server.URL)
+	suite.Require().NoError(err)
+
+	client := NewClient()
+	client.SetResolver("", map[string]string{"API.nugs.invalid": "127.0.0.1"})
+
+	resp, err := client.GetHTTPClient().Get("http://api.nugs.invalid:" + u.Port() + "/")
+	suite.Require().NoError(err)
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	suite.Require().NoError(err)
+	// The request still carries the original host
+	assert.Equal(suite.T(), "api.nugs.invalid:"+u.Port(), string(body))
+}
+
+// TestDNSServerAddr tests that DNS servers default to port 53
+func (suite *ResolverTestSuite) TestDNSServerAddr() {
+	assert.Equal(suite.T(), "1.1.1.1:53", DNSServerAddr("1.1.1.1"))
+	assert.Equal(suite.T(), "1.1.1.1:5353", DNSServerAddr("1.1.1.1:5353"))
+	assert.Equal(suite.T(), "[2606:4700:4700::1111]:53", DNSServerAddr("2606:4700:4700::1111"))
+}
+
+func TestResolverTestSuite(t *testing.T) {
+	suite.Run(t, new(ResolverTestSuite))
+}
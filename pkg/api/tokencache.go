@@ -0,0 +1,85 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"main/pkg/fsutil"
+	"main/pkg/models"
+)
+
+// tokenExpirySkew is how long before expiry a cached token stops being used,
+// so it doesn't run out partway through a run's first requests
+const tokenExpirySkew = 5 * time.Minute
+
+// TokenCache is an access token saved between runs
+type TokenCache struct {
+	AccessToken  string    `json:"access_token"`
+	RefreshToken string    `json:"refresh_token,omitempty"`
+	ExpiresAt    time.Time `json:"expires_at"`
+}
+
+// NewTokenCache builds a cache entry from a token response. The expiry comes
+// from the JWT's exp claim, falling back to the response's expires_in.
+func NewTokenCache(tokens *models.AuthResponse) *TokenCache {
+	expiresAt, err := models.TokenExpiry(tokens.AccessToken)
+	if err != nil {
+		expiresAt = time.Now().Add(time.Duration(tokens.ExpiresIn) * time.Second)
+	}
+
+	return &TokenCache{
+		AccessToken:  tokens.AccessToken,
+		RefreshToken: tokens.RefreshToken,
+		ExpiresAt:    expiresAt,
+	}
+}
+
+// Valid reports whether the cached access token can still be used
+func (t *TokenCache) Valid() bool {
+	return t.AccessToken != "" && time.Now().Add(tokenExpirySkew).Before(t.ExpiresAt)
+}
+
+// LoadTokenCache reads a token cache file, returning nil if there isn't one
+func LoadTokenCache(path string) (*TokenCache, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read token cache: %w", err)
+	}
+
+	var cache TokenCache
+	if err := json.Unmarshal(data, &cache); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal token cache: %w", err)
+	}
+
+	return &cache, nil
+}
+
+// SaveTokenCache writes a token cache file readable only by the current user
+func SaveTokenCache(path string, cache *TokenCache) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("failed to create token cache directory: %w", err)
+	}
+
+	data, err := json.MarshalIndent(cache, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal token cache: %w", err)
+	}
+
+	// Write to temporary file first for atomicity
+	tempFile := fsutil.TempPathFor(path)
+	if err := os.WriteFile(tempFile, data, 0600); err != nil {
+		return fmt.Errorf("failed to write token cache: %w", err)
+	}
+	if err := os.Rename(tempFile, path); err != nil {
+		os.Remove(tempFile)
+		return fmt.Errorf("failed to save token cache: %w", err)
+	}
+
+	return nil
+}
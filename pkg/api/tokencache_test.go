@@ -0,0 +1,77 @@
+package api
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"main/pkg/models"
+)
+
+// TokenCacheTestSuite covers saving and reusing access tokens
+type TokenCacheTestSuite struct {
+	suite.Suite
+	path string
+}
+
+// SetupTest picks a cache path in a temporary directory
+func (suite *TokenCacheTestSuite) SetupTest() {
+	suite.path = filepath.Join(suite.T().TempDir(), "state", "token.json")
+}
+
+// testJWT builds an unsigned JWT with the given exp claim
+func testJWT(exp time.Time) string {
+	payload, _ := json.Marshal(models.Payload{LegacyToken: "legacy", Exp: exp.Unix()})
+	return "header." + base64.RawURLEncoding.EncodeToString(payload) + ".signature"
+}
+
+// TestNewTokenCache tests expiry from the exp claim and from expires_in
+func (suite *TokenCacheTestSuite) TestNewTokenCache() {
+	exp := time.Now().Add(time.Hour).Truncate(time.Second)
+	cache := NewTokenCache(&models.AuthResponse{AccessToken: testJWT(exp), RefreshToken: "refresh"})
+	assert.True(suite.T(), exp.Equal(cache.ExpiresAt))
+	assert.Equal(suite.T(), "refresh", cache.RefreshToken)
+	assert.True(suite.T(), cache.Valid())
+
+	cache = NewTokenCache(&models.AuthResponse{AccessToken: "opaque", ExpiresIn: 3600})
+	assert.WithinDuration(suite.T(), time.Now().Add(time.Hour), cache.ExpiresAt, time.Minute)
+}
+
+// TestValid tests that expired and nearly expired tokens aren't reused
+func (suite *TokenCacheTestSuite) TestValid() {
+	assert.False(suite.T(), (&TokenCache{AccessToken: "t", ExpiresAt: time.Now().Add(-time.Minute)}).Valid())
+	assert.False(suite.T(), (&TokenCache{AccessToken: "t", ExpiresAt: time.Now().Add(time.Minute)}).Valid())
+	assert.False(suite.T(), (&TokenCache{ExpiresAt: time.Now().Add(time.Hour)}).Valid())
+	assert.True(suite.T(), (&TokenCache{AccessToken: "t", ExpiresAt: time.Now().Add(time.Hour)}).Valid())
+}
+
+// TestSaveAndLoadTokenCache tests round-tripping the cache file
+func (suite *TokenCacheTestSuite) TestSaveAndLoadTokenCache() {
+	cache, err := LoadTokenCache(suite.path)
+	assert.NoError(suite.T(), err)
+	assert.Nil(suite.T(), cache)
+
+	saved := &TokenCache{AccessToken: "access", RefreshToken: "refresh", ExpiresAt: time.Now().Add(time.Hour)}
+	suite.Require().NoError(SaveTokenCache(suite.path, saved))
+
+	info, err := os.Stat(suite.path)
+	suite.Require().NoError(err)
+	if os.PathSeparator == '/' {
+		assert.Equal(suite.T(), os.FileMode(0600), info.Mode().Perm())
+	}
+
+	cache, err = LoadTokenCache(suite.path)
+	suite.Require().NoError(err)
+	assert.Equal(suite.T(), "access", cache.AccessToken)
+	assert.Equal(suite.T(), "refresh", cache.RefreshToken)
+	assert.True(suite.T(), saved.ExpiresAt.Equal(cache.ExpiresAt))
+}
+
+func TestTokenCacheTestSuite(t *testing.T) {
+	suite.Run(t, new(TokenCacheTestSuite))
+}
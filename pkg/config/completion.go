@@ -0,0 +1,146 @@
+package config
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"regexp"
+	"strings"
+)
+
+// completionCommands are the commands taken in place of the first URL
+var completionCommands = []string{"sync", "verify"}
+
+// completionFlag is a command line flag as offered by completion scripts
+type completionFlag struct {
+	long       string
+	short      string
+	help       string
+	takesValue bool
+}
+
+// completionFlags lists the documented flags in Args, read from their struct
+// tags so the scripts keep up as flags are added, plus go-arg's --help
+func completionFlags() []completionFlag {
+	var flags []completionFlag
+	argsType := reflect.TypeOf(Args{})
+	for i := 0; i < argsType.NumField(); i++ {
+		field := argsType.Field(i)
+		tag, ok := field.Tag.Lookup("arg")
+		help := field.Tag.Get("help")
+		if !ok || tag == "positional" || help == "" {
+			continue
+		}
+
+		flag := completionFlag{
+			help:       help,
+			takesValue: field.Type.Kind() != reflect.Bool,
+		}
+		for _, name := range strings.Split(tag, ",") {
+			if strings.HasPrefix(name, "--") {
+				flag.long = strings.TrimPrefix(name, "--")
+			} else if strings.HasPrefix(name, "-") {
+				flag.short = strings.TrimPrefix(name, "-")
+			}
+		}
+		if flag.long != "" {
+			flags = append(flags, flag)
+		}
+	}
+	return append(flags, completionFlag{long: "help", short: "h", help: "Display this help and exit"})
+}
+
+// WriteCompletion writes a completion script for the program to w, for bash,
+// zsh or fish
+func WriteCompletion(w io.Writer, shell, program string) error {
+	flags := completionFlags()
+	switch shell {
+	case "bash":
+		_, err := io.WriteString(w, bashCompletion(program, flags))
+		return err
+	case "zsh":
+		_, err := io.WriteString(w, zshCompletion(program, flags))
+		return err
+	case "fish":
+		_, err := io.WriteString(w, fishCompletion(program, flags))
+		return err
+	default:
+		return fmt.Errorf("completion shell must be bash, zsh or fish")
+	}
+}
+
+// nonIdentChars are stripped from program names to make shell function names
+var nonIdentChars = regexp.MustCompile(`[^A-Za-z0-9_]`)
+
+func bashCompletion(program string, flags []completionFlag) string {
+	var names, valueNames []string
+	for _, flag := range flags {
+		names = append(names, "--"+flag.long)
+		if flag.short != "" {
+			names = append(names, "-"+flag.short)
+		}
+		if flag.takesValue {
+			valueNames = append(valueNames, "--"+flag.long)
+			if flag.short != "" {
+				valueNames = append(valueNames, "-"+flag.short)
+			}
+		}
+	}
+
+	function := "_" + nonIdentChars.ReplaceAllString(program, "_")
+	var b strings.Builder
+	fmt.Fprintf(&b, "# bash completion for %s\n", program)
+	fmt.Fprintf(&b, "%s() {\n", function)
+	b.WriteString("    local cur=\"${COMP_WORDS[COMP_CWORD]}\" prev=\"${COMP_WORDS[COMP_CWORD-1]}\"\n")
+	fmt.Fprintf(&b, "    case \"$prev\" in\n        %s)\n", strings.Join(valueNames, "|"))
+	b.WriteString("            COMPREPLY=($(compgen -f -- \"$cur\"))\n            return ;;\n    esac\n")
+	b.WriteString("    if [[ \"$cur\" == -* ]]; then\n")
+	fmt.Fprintf(&b, "        COMPREPLY=($(compgen -W \"%s\" -- \"$cur\"))\n", strings.Join(names, " "))
+	b.WriteString("    elif [[ $COMP_CWORD -eq 1 ]]; then\n")
+	fmt.Fprintf(&b, "        COMPREPLY=($(compgen -W \"%s\" -- \"$cur\") $(compgen -f -- \"$cur\"))\n", strings.Join(completionCommands, " "))
+	b.WriteString("    else\n        COMPREPLY=($(compgen -f -- \"$cur\"))\n    fi\n}\n")
+	fmt.Fprintf(&b, "complete -o filenames -F %s %s\n", function, program)
+	return b.String()
+}
+
+func zshCompletion(program string, flags []completionFlag) string {
+	// Help goes in single quotes and square brackets
+	escape := strings.NewReplacer("'", `'\''`, "[", `\[`, "]", `\]`)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "#compdef %s\n\n_arguments -s \\\n", program)
+	for _, flag := range flags {
+		help := "[" + escape.Replace(flag.help) + "]"
+		value := ""
+		if flag.takesValue {
+			value = ":" + flag.long + ":_files"
+		}
+		if flag.short != "" {
+			fmt.Fprintf(&b, "    '(-%s --%s)'{-%s,--%s}'%s%s' \\\n", flag.short, flag.long, flag.short, flag.long, help, value)
+		} else {
+			fmt.Fprintf(&b, "    '--%s%s%s' \\\n", flag.long, help, value)
+		}
+	}
+	fmt.Fprintf(&b, "    '1: :{_alternative \"commands:command:(%s)\" \"files:url list:_files\"}' \\\n", strings.Join(completionCommands, " "))
+	b.WriteString("    '*:url list:_files'\n")
+	return b.String()
+}
+
+func fishCompletion(program string, flags []completionFlag) string {
+	escape := strings.NewReplacer(`\`, `\\`, "'", `\'`)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "# fish completion for %s\n", program)
+	fmt.Fprintf(&b, "complete -c %s -n __fish_use_subcommand -a '%s'\n", program, strings.Join(completionCommands, " "))
+	for _, flag := range flags {
+		fmt.Fprintf(&b, "complete -c %s -l %s", program, flag.long)
+		if flag.short != "" {
+			fmt.Fprintf(&b, " -s %s", flag.short)
+		}
+		if flag.takesValue {
+			b.WriteString(" -r")
+		}
+		fmt.Fprintf(&b, " -d '%s'\n", escape.Replace(flag.help))
+	}
+	return b.String()
+}
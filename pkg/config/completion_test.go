@@ -0,0 +1,54 @@
+package config
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+// CompletionTestSuite covers the shell completion scripts
+type CompletionTestSuite struct {
+	suite.Suite
+}
+
+// TestCompletionFlags tests that flags are read from the Args tags
+func (suite *CompletionTestSuite) TestCompletionFlags() {
+	flags := make(map[string]completionFlag)
+	for _, flag := range completionFlags() {
+		flags[flag.long] = flag
+	}
+
+	assert.Equal(suite.T(), completionFlag{long: "format", short: "f", help: "Audio format (1-5)", takesValue: true}, flags["format"])
+	assert.False(suite.T(), flags["skip-videos"].takesValue)
+	assert.True(suite.T(), flags["completion"].takesValue)
+	assert.Contains(suite.T(), flags, "help")
+	// Undocumented flags stay out of the scripts
+	assert.NotContains(suite.T(), flags, "max-bytes-per-file")
+}
+
+// TestWriteCompletion tests each shell's script lists the flags
+func (suite *CompletionTestSuite) TestWriteCompletion() {
+	var bash bytes.Buffer
+	suite.Require().NoError(WriteCompletion(&bash, "bash", "nugs_dl_x64"))
+	assert.Contains(suite.T(), bash.String(), "--format -f --video-format -v")
+	assert.Contains(suite.T(), bash.String(), "complete -o filenames -F _nugs_dl_x64 nugs_dl_x64\n")
+
+	var zsh bytes.Buffer
+	suite.Require().NoError(WriteCompletion(&zsh, "zsh", "nugs_dl_x64"))
+	assert.Contains(suite.T(), zsh.String(), "#compdef nugs_dl_x64\n")
+	assert.Contains(suite.T(), zsh.String(), `'(-o --output)'{-o,--output}'[Output directory]:output:_files'`)
+	assert.Contains(suite.T(), zsh.String(), `'--force-video[Force video download]'`)
+
+	var fish bytes.Buffer
+	suite.Require().NoError(WriteCompletion(&fish, "fish", "nugs_dl_x64"))
+	assert.Contains(suite.T(), fish.String(), "complete -c nugs_dl_x64 -l format -s f -r -d 'Audio format (1-5)'\n")
+	assert.Contains(suite.T(), fish.String(), "complete -c nugs_dl_x64 -l dedup -d 'Skip repeated tracks in playlists'\n")
+
+	assert.Error(suite.T(), WriteCompletion(&bytes.Buffer{}, "tcsh", "nugs_dl_x64"))
+}
+
+func TestCompletionTestSuite(t *testing.T) {
+	suite.Run(t, new(CompletionTestSuite))
+}
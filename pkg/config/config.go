@@ -4,11 +4,20 @@ import (
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/alexflint/go-arg"
 	"main/pkg/fsutil"
 	"main/pkg/logger"
+	"main/pkg/models"
+	"main/pkg/remote"
 )
 
 const (
@@ -32,40 +41,249 @@ var (
 
 // Config represents the application configuration
 type Config struct {
-	Email         string `json:"email"`
-	Password      string `json:"password"`
-	Token         string `json:"token"`
-	Format        int    `json:"format"`
-	VideoFormat   int    `json:"videoFormat"`
-	OutPath       string `json:"outPath"`
-	WantRes       string
-	FfmpegNameStr string
-	Urls          []string
-	ForceVideo    bool
-	SkipVideos    bool
-	SkipChapters  bool
-	UseFfmpegEnvVar bool `json:"useFfmpegEnvVar"`
+	Email            string `json:"email"`
+	Password         string `json:"password"`
+	Token            string `json:"token"`
+	Format           int    `json:"format"`
+	VideoFormat      int    `json:"videoFormat"`
+	OutPath          string `json:"outPath"`
+	TempDir          string `json:"tempDir"`
+	WantRes          string
+	FfmpegNameStr    string
+	Urls             []string
+	BatchList        bool // some URLs came from a .txt list, so the run is checkpointed
+	ForceVideo       bool
+	SkipVideos       bool
+	SkipChapters     bool
+	PlaylistByArtist bool
+	Sync             bool
+	VerifyPath       string
+	ProxyList        []string
+	AudioOnly        bool
+	Verbose          bool
+	Quiet            bool
+	Limit            int
+	FormatSubfolder  bool
+	DeviceLogin      bool
+	OriginalNames    bool
+	ArtistFilter     string
+	Strict           bool
+	MaxBytesPerFile  int64
+	Product          string
+	AllProducts      bool
+	AlsoAudio        bool
+	SetMtime         bool
+	MtimeFromHeader  bool
+	Dedup            bool
+	NoFallback       bool
+	NoFolder         bool
+	StartAt          time.Time
+	WaitForAvailable bool
+	DumpURLs         bool
+	SimulateQuality  bool
+	TestProxy        bool
+	NoClean          bool
+	JSONMeta         bool
+	TrimSilence      bool
+	Normalize        bool
+	UseFfmpegEnvVar  bool   `json:"useFfmpegEnvVar"`
+	Comment          string `json:"comment"`
+	AppVersion       string `json:"appVersion"`
+	UserAgent        string `json:"userAgent"`
+	UserAgentTwo     string `json:"userAgentTwo"`
+	MinFreeSpace     int    `json:"minFreeSpace"`
+	TrackTimeout     int    `json:"trackTimeout"`
+	Cookies          string `json:"cookies"`
+	NoCookieJar      bool   `json:"noCookieJar"`
+	CacheToken       bool   `json:"cacheToken"`
+	VideoContainer   string `json:"videoContainer"`
+	VideoTemplate    string `json:"videoTemplate"`
+	MaxConnsPerHost  int    `json:"maxConnsPerHost"`
+	ConcurrentItems  int    `json:"concurrentItems"`
+	Concurrency      int    `json:"concurrency"`
+	AacBitrate       int    `json:"aacBitrate"`
+	MinBitrate       int    `json:"minBitrate"`
+
+	ArtistPageConcurrency int    `json:"artistPageConcurrency"`
+	ArtistCrawlDelay      int    `json:"artistCrawlDelay"`
+	SourcePreference      string `json:"sourcePreference"`
+
+	PostDownloadHook         string `json:"postDownloadHook"`
+	PostDownloadHookRequired bool   `json:"postDownloadHookRequired"`
+
+	RemoteDest    string `json:"remoteDest"`
+	RemoteCommand string `json:"remoteCommand"`
+	RemoteKey     string `json:"remoteKey"`
+
+	FailedLog string `json:"failedLog"`
+
+	ConvertTo    string   `json:"convertTo"`
+	IDTags       bool     `json:"idTags"`
+	SortTags     bool     `json:"sortTags"`
+	SortArticles []string `json:"sortArticles"`
+
+	AlbumChecksums bool   `json:"albumChecksums"`
+	DiscFolders    bool   `json:"discFolders"`
+	DatePrefix     bool   `json:"datePrefix"`
+	Setlist        bool   `json:"setlist"`
+	CueSheet       string `json:"cueSheet"`
+	KeepTracks     bool   `json:"keepTracks"`
+	Artwork        string `json:"artwork"`
+	Poster         string `json:"poster"`
+	Subtitles      string `json:"subtitles"`
+
+	DNSServer     string            `json:"dnsServer"`
+	HostOverrides map[string]string `json:"hostOverrides"`
+	Proxies       string            `json:"proxies"`
+	ExtraHeaders  map[string]string `json:"extraHeaders"`
+
+	MaxFolderNameLen    int `json:"maxFolderNameLength"`
+	MaxVideoFilenameLen int `json:"maxFilenameLength"`
+
+	// Profiles are named sets of options that override the ones above when
+	// picked with --profile. They're kept raw so only the options a profile
+	// sets are overridden.
+	Profiles map[string]json.RawMessage `json:"profiles"`
 }
 
 // Args represents command line arguments
 type Args struct {
-	Urls         []string `arg:"positional" help:"URLs to process"`
-	Format       *int     `arg:"-f,--format" help:"Audio format (1-5)"`
-	VideoFormat  *int     `arg:"-v,--video-format" help:"Video format (1-5)"`
-	OutPath      string   `arg:"-o,--output" help:"Output directory"`
-	ForceVideo   bool     `arg:"--force-video" help:"Force video download"`
-	SkipVideos   bool     `arg:"--skip-videos" help:"Skip video downloads"`
-	SkipChapters bool     `arg:"--skip-chapters" help:"Skip chapter metadata"`
+	Urls             []string `arg:"positional" help:"URLs to process"`
+	Format           *int     `arg:"-f,--format" help:"Audio format (1-5)"`
+	VideoFormat      *int     `arg:"-v,--video-format" help:"Video format (1-5)"`
+	Res              string   `arg:"--res" help:"Video resolution by pixel height, e.g. 1440, overriding the video format"`
+	OutPath          string   `arg:"-o,--output" help:"Output directory"`
+	TempDir          string   `arg:"--temp-dir" help:"Directory for encrypted TS and chapter files while they're in use"`
+	ForceVideo       bool     `arg:"--force-video" help:"Force video download"`
+	SkipVideos       bool     `arg:"--skip-videos" help:"Skip video downloads"`
+	SkipChapters     bool     `arg:"--skip-chapters" help:"Skip chapter metadata"`
+	Comment          string   `arg:"--comment" help:"Custom comment tag for downloaded tracks"`
+	PlaylistByArtist bool     `arg:"--playlist-by-artist" help:"Put playlist tracks in per-artist subfolders"`
+	ArtistFilter     string   `arg:"--artist-filter" help:"Only download artist items by this artist id or name regex"`
+	SourcePreference string   `arg:"--source-preference" help:"For shows with several recordings, only download the best by source: soundboard, matrix or audience first"`
+	PreferSoundboard bool     `arg:"--prefer-soundboard" help:"Same as --source-preference soundboard"`
+	OriginalNames    bool     `arg:"--original-names" help:"Name tracks after their CDN filenames instead of numbered titles"`
+	MaxConnsPerHost  *int     `arg:"--concurrency-per-host" help:"Maximum connections to any one host (0 = unlimited)"`
+	ConcurrentItems  *int     `arg:"--concurrent-items" help:"Number of URLs to download at the same time"`
+	Concurrency      *int     `arg:"--concurrency" help:"Maximum file downloads at the same time across all items (0 = unlimited)"`
+	AacBitrate       *int     `arg:"--aac-bitrate" help:"Highest AAC bitrate in Kbps for HLS-only tracks (0 = highest available)"`
+	MinBitrate       *int     `arg:"--min-bitrate" help:"Fail HLS-only tracks whose AAC bitrate in Kbps is below this (0 = no minimum)"`
+	ArtistPages      *int     `arg:"--artist-page-concurrency" help:"Number of artist metadata pages to fetch at the same time"`
+	ArtistDelay      *int     `arg:"--artist-crawl-delay" help:"Milliseconds to wait between the metadata requests of an artist download"`
+	VideoContainer   string   `arg:"--video-container" help:"Video container, mp4 or mkv"`
+	CacheToken       bool     `arg:"--cache-token" help:"Save the login token and reuse it until it expires"`
+	DeviceLogin      bool     `arg:"--device-login" help:"Log in by approving a code in your browser (for 2FA accounts)"`
+	Cookies          string   `arg:"--cookies" help:"Netscape-format cookie file to load"`
+	NoCookieJar      bool     `arg:"--no-cookie-jar" help:"Don't keep cookies between requests"`
+	FormatSubfolder  bool     `arg:"--format-subfolder" help:"Add the track quality to album folder names, e.g. [FLAC16]"`
+	Limit            int      `arg:"--limit" help:"Only process the first N items (0 = unlimited)"`
+	Verbose          bool     `arg:"--verbose" help:"Log structured details of each track download, and ffmpeg's output"`
+	Quiet            bool     `arg:"--quiet" help:"Don't print the banner or the summary at the end of the run"`
+	AudioOnly        bool     `arg:"--audio-only" help:"Save only the audio of videos and livestreams as M4A"`
+	AppVersion       string   `arg:"--app-version" help:"Nugs app version to report in the user agents"`
+	MinFreeSpace     *int     `arg:"--min-free-space" help:"Free disk space in MB to keep on top of each download"`
+	TrackTimeout     *int     `arg:"--track-timeout" help:"Seconds a track download can take before it's failed and the next one started (0 = no limit)"`
+	PostDownloadHook string   `arg:"--post-download-hook" help:"Command to run after each track and album completes"`
+	RemoteDest       string   `arg:"--remote-dest" help:"sftp:// URL to move finished downloads to"`
+	RemoteCommand    string   `arg:"--remote-command" help:"Command to move each finished file with, e.g. rclone copyto {local} nas:music/{remote}"`
+	FailedLog        string   `arg:"--failed-log" help:"Text file to append failed URLs to, which can be passed back as the URL list to retry them"`
+	ConvertTo        string   `arg:"--convert-to" help:"Convert lossless tracks after download, e.g. wav"`
+	Strict           bool     `arg:"--strict" help:"Fail a release if any of its tracks fail"`
+	Product          string   `arg:"--product" help:"Video product to download, by number or format name"`
+	AllProducts      bool     `arg:"--all-products" help:"Download every video product into its own subfolder"`
+	AlsoAudio        bool     `arg:"--also-audio" help:"Also download the tracks of a video's audio-only product"`
+	IDTags           bool     `arg:"--id-tags" help:"Tag tracks with their Nugs container, artist, track and song ids"`
+	SortTags         bool     `arg:"--sort-tags" help:"Tag tracks with artist and album sort names without leading articles like \"The\""`
+	AlbumChecksums   bool     `arg:"--album-checksums" help:"Write a checksums.md5 of each album's tracks"`
+	DiscFolders      bool     `arg:"--disc-folders" help:"Put the tracks of multi-disc releases in Disc 1, Disc 2... subfolders"`
+	DatePrefix       bool     `arg:"--date-prefix" help:"Start album folder names with the show date, e.g. 2022-10-29"`
+	Setlist          bool     `arg:"--setlist" help:"Write a setlist.txt with each album's track listing and show notes"`
+	CueSheet         string   `arg:"--cue-sheet" help:"Join FLAC albums into one file with a CUE sheet: sidecar, embed or both"`
+	KeepTracks       bool     `arg:"--keep-tracks" help:"Keep the track files that --cue-sheet joins"`
+	Artwork          string   `arg:"--artwork" help:"Embed release artwork in tracks: front or all"`
+	Poster           string   `arg:"--poster" help:"Save video posters next to videos (save), or also embed them as the cover (embed)"`
+	Subtitles        string   `arg:"--subtitles" help:"Save video subtitles next to videos as .vtt files (save), or mux them into the video (embed)"`
+	SetMtime         bool     `arg:"--set-mtime" help:"Set track modification times to the show or release date"`
+	MtimeFromHeader  bool     `arg:"--mtime-from-header" help:"Set track modification times to the CDN's Last-Modified time, when --set-mtime has no date to use"`
+	Dedup            bool     `arg:"--dedup" help:"Skip repeated tracks in playlists"`
+	NoFallback       bool     `arg:"--no-fallback" help:"Skip tracks that aren't available in the requested format instead of falling back"`
+	NoFolder         bool     `arg:"--no-folder" help:"Save album and playlist tracks straight into the output folder, named after their release"`
+	StartAt          string   `arg:"--start-at" help:"Wait until this time before starting, e.g. 2026-06-01T20:00:00-04:00 (RFC3339)"`
+	WaitForAvailable bool     `arg:"--wait-for-available" help:"Wait up to a day for livestreams that haven't started yet to become available, then download them"`
+	DumpURLs         bool     `arg:"--dump-urls" help:"Print the resolved stream and manifest URLs to stderr instead of downloading"`
+	SimulateQuality  bool     `arg:"--simulate-quality" help:"Print each track's probed formats and how one would be picked, instead of downloading"`
+	NoClean          bool     `arg:"--no-clean" help:"Keep temporary, encrypted and chapter files instead of deleting them, for debugging"`
+	JSONMeta         bool     `arg:"--json-meta" help:"Print each URL's metadata as JSON to stdout instead of downloading"`
+	TrimSilence      bool     `arg:"--trim-silence" help:"Re-encode tracks with silence over 5 seconds removed (lossy tracks lose quality)"`
+	Normalize        bool     `arg:"--normalize" help:"Re-encode tracks with loudness normalised to -16 LUFS (lossy tracks lose quality)"`
+	DNSServer        string   `arg:"--dns-server" help:"DNS server to look hosts up with instead of the system resolver"`
+	Proxies          string   `arg:"--proxies" help:"Text file of proxy URLs, one per line, to rotate requests through"`
+	TestProxy        bool     `arg:"--test-proxy" help:"Check each proxy reaches the Nugs API before starting, and show the IP it connects from"`
+	MaxFolderNameLen *int     `arg:"--max-folder-name-length" help:"Longest album or playlist folder name (0 = platform default)"`
+	MaxFilenameLen   *int     `arg:"--max-filename-length" help:"Longest video filename (0 = platform default)"`
+	VideoTemplate    string   `arg:"--video-template" help:"Video filename template, e.g. \"{date} {artist} - {title} [{res}]\""`
+	Profile          string   `arg:"--profile" help:"Config profile to use on top of the base config"`
+	Completion       string   `arg:"--completion" help:"Print a shell completion script for bash, zsh or fish and exit"`
+	// Test mode for trying the whole flow against the real API cheaply, so it's left out of the docs
+	MaxBytesPerFile int64 `arg:"--max-bytes-per-file"`
+}
+
+// validateResolver checks that the DNS server and host overrides are IPs
+func validateResolver(dnsServer string, hostOverrides map[string]string) error {
+	if dnsServer != "" {
+		host := dnsServer
+		if h, _, err := net.SplitHostPort(dnsServer); err == nil {
+			host = h
+		}
+		if net.ParseIP(host) == nil {
+			return fmt.Errorf("dns server must be an IP address, optionally with a port: %s", dnsServer)
+		}
+	}
+	for host, ip := range hostOverrides {
+		if net.ParseIP(ip) == nil {
+			return fmt.Errorf("host override for %s must be an IP address: %s", host, ip)
+		}
+	}
+	return nil
+}
+
+// validateHeaders checks that extra headers can be sent as they are, so a
+// stray colon or line break is caught before any request fails on it
+func validateHeaders(headers map[string]string) error {
+	for name, value := range headers {
+		if name == "" || strings.ContainsAny(name, " \t\r\n:") {
+			return fmt.Errorf("invalid extra header name: %q", name)
+		}
+		if strings.ContainsAny(value, "\r\n") {
+			return fmt.Errorf("extra header %s can't contain line breaks", name)
+		}
+	}
+	return nil
 }
 
 // ParseCfg parses configuration from config.json and command line arguments
 func ParseCfg() (*Config, error) {
+	args := parseArgs()
+	// Completion scripts don't need a config file, so they're written first
+	if args.Completion != "" {
+		err := WriteCompletion(os.Stdout, strings.ToLower(args.Completion), filepath.Base(os.Args[0]))
+		if err != nil {
+			return nil, err
+		}
+		os.Exit(0)
+	}
+
 	cfg, err := readConfig()
 	if err != nil {
 		return nil, fmt.Errorf("failed to read config: %w", err)
 	}
+	if args.Profile != "" {
+		err = applyProfile(cfg, args.Profile)
+		if err != nil {
+			return nil, err
+		}
+	}
 
-	args := parseArgs()
 	if args.Format != nil {
 		cfg.Format = *args.Format
 	}
@@ -81,14 +299,135 @@ func ParseCfg() (*Config, error) {
 		return nil, fmt.Errorf("video format must be between %d and %d", MinVideoFormat, MaxVideoFormat)
 	}
 
+	if args.VideoContainer != "" {
+		cfg.VideoContainer = args.VideoContainer
+	}
+	cfg.VideoContainer = strings.ToLower(cfg.VideoContainer)
+	if cfg.VideoContainer == "" {
+		cfg.VideoContainer = "mp4"
+	}
+	if !(cfg.VideoContainer == "mp4" || cfg.VideoContainer == "mkv") {
+		return nil, fmt.Errorf("video container must be mp4 or mkv")
+	}
+
+	if args.ConvertTo != "" {
+		cfg.ConvertTo = args.ConvertTo
+	}
+	cfg.ConvertTo = strings.ToLower(cfg.ConvertTo)
+	if !(cfg.ConvertTo == "" || cfg.ConvertTo == "wav") {
+		return nil, fmt.Errorf("convert target must be wav")
+	}
+
+	if args.FailedLog != "" {
+		cfg.FailedLog = args.FailedLog
+	}
+	// URL lists are only read from .txt files, so anything else couldn't be retried
+	if cfg.FailedLog != "" && !strings.HasSuffix(cfg.FailedLog, ".txt") {
+		return nil, fmt.Errorf("failed log must be a .txt file: %s", cfg.FailedLog)
+	}
+
+	if args.CueSheet != "" {
+		cfg.CueSheet = args.CueSheet
+	}
+	cfg.CueSheet = strings.ToLower(cfg.CueSheet)
+	if !(cfg.CueSheet == "" || cfg.CueSheet == "sidecar" || cfg.CueSheet == "embed" || cfg.CueSheet == "both") {
+		return nil, fmt.Errorf("cue sheet must be sidecar, embed or both")
+	}
+
+	if args.Artwork != "" {
+		cfg.Artwork = args.Artwork
+	}
+	cfg.Artwork = strings.ToLower(cfg.Artwork)
+	if !(cfg.Artwork == "" || cfg.Artwork == "front" || cfg.Artwork == "all") {
+		return nil, fmt.Errorf("artwork must be front or all")
+	}
+
+	if args.PreferSoundboard {
+		cfg.SourcePreference = models.SourceSoundboard
+	}
+	if args.SourcePreference != "" {
+		cfg.SourcePreference = args.SourcePreference
+	}
+	cfg.SourcePreference = strings.ToLower(cfg.SourcePreference)
+	if !(cfg.SourcePreference == "" || slices.Contains(models.Sources, cfg.SourcePreference)) {
+		return nil, fmt.Errorf("source preference must be soundboard, matrix or audience")
+	}
+
+	if args.Poster != "" {
+		cfg.Poster = args.Poster
+	}
+	cfg.Poster = strings.ToLower(cfg.Poster)
+	if !(cfg.Poster == "" || cfg.Poster == "save" || cfg.Poster == "embed") {
+		return nil, fmt.Errorf("poster must be save or embed")
+	}
+
+	if args.VideoTemplate != "" {
+		cfg.VideoTemplate = args.VideoTemplate
+	}
+	cfg.VideoTemplate = strings.TrimSpace(cfg.VideoTemplate)
+
+	if args.Subtitles != "" {
+		cfg.Subtitles = args.Subtitles
+	}
+	cfg.Subtitles = strings.ToLower(cfg.Subtitles)
+	if !(cfg.Subtitles == "" || cfg.Subtitles == "save" || cfg.Subtitles == "embed") {
+		return nil, fmt.Errorf("subtitles must be save or embed")
+	}
+
+	if args.MaxFolderNameLen != nil {
+		cfg.MaxFolderNameLen = *args.MaxFolderNameLen
+	}
+	if args.MaxFilenameLen != nil {
+		cfg.MaxVideoFilenameLen = *args.MaxFilenameLen
+	}
+	// Most filesystems cap a single name at 255 bytes
+	for _, limit := range []int{cfg.MaxFolderNameLen, cfg.MaxVideoFilenameLen} {
+		if limit < 0 || limit > 255 {
+			return nil, fmt.Errorf("name length limits must be between 0 and 255")
+		}
+	}
+
+	if args.DNSServer != "" {
+		cfg.DNSServer = args.DNSServer
+	}
+	if err := validateResolver(cfg.DNSServer, cfg.HostOverrides); err != nil {
+		return nil, err
+	}
+	if err := validateHeaders(cfg.ExtraHeaders); err != nil {
+		return nil, err
+	}
+
+	if args.Proxies != "" {
+		cfg.Proxies = args.Proxies
+	}
+	if cfg.Proxies != "" {
+		cfg.ProxyList, err = fsutil.ReadTxtFile(cfg.Proxies)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read proxies file: %w", err)
+		}
+		if len(cfg.ProxyList) == 0 {
+			return nil, fmt.Errorf("proxies file has no proxies: %s", cfg.Proxies)
+		}
+	}
+
 	// Set resolution and output path
 	cfg.WantRes = resolveRes[cfg.VideoFormat]
+	if args.Res != "" {
+		height, err := strconv.Atoi(args.Res)
+		if err != nil || height <= 0 {
+			return nil, fmt.Errorf("resolution must be a pixel height, e.g. 1440: %s", args.Res)
+		}
+		cfg.WantRes = strconv.Itoa(height)
+	}
 	if args.OutPath != "" {
 		cfg.OutPath = args.OutPath
 	}
 	if cfg.OutPath == "" {
 		cfg.OutPath = "Nugs downloads"
 	}
+	if args.TempDir != "" {
+		cfg.TempDir = args.TempDir
+	}
 
 	// Clean token
 	if cfg.Token != "" {
@@ -103,7 +442,16 @@ func ParseCfg() (*Config, error) {
 	}
 
 	// Process URLs
-	cfg.Urls, err = processUrls(args.Urls)
+	var urls []string
+	urls, cfg.Sync = splitSyncCommand(args.Urls)
+	urls, cfg.VerifyPath, err = splitVerifyCommand(urls)
+	if err != nil {
+		return nil, err
+	}
+	cfg.BatchList = slices.ContainsFunc(urls, func(url string) bool {
+		return strings.HasSuffix(url, ".txt")
+	})
+	cfg.Urls, err = processUrls(urls)
 	if err != nil {
 		logger.GetLogger().WithError(err).Error("Failed to process URLs")
 		return nil, err
@@ -113,6 +461,167 @@ func ParseCfg() (*Config, error) {
 	cfg.ForceVideo = args.ForceVideo
 	cfg.SkipVideos = args.SkipVideos
 	cfg.SkipChapters = args.SkipChapters
+	cfg.PlaylistByArtist = args.PlaylistByArtist
+	cfg.AudioOnly = args.AudioOnly
+	cfg.Verbose = args.Verbose
+	cfg.Quiet = args.Quiet
+	cfg.Limit = args.Limit
+	cfg.FormatSubfolder = args.FormatSubfolder
+	cfg.DeviceLogin = args.DeviceLogin
+	cfg.OriginalNames = args.OriginalNames
+	cfg.ArtistFilter = args.ArtistFilter
+	cfg.Strict = args.Strict
+	cfg.MaxBytesPerFile = args.MaxBytesPerFile
+	cfg.Product = args.Product
+	cfg.AllProducts = args.AllProducts
+	cfg.AlsoAudio = args.AlsoAudio
+	cfg.SetMtime = args.SetMtime
+	cfg.MtimeFromHeader = args.MtimeFromHeader
+	cfg.Dedup = args.Dedup
+	cfg.NoFallback = args.NoFallback
+	cfg.NoFolder = args.NoFolder
+	cfg.WaitForAvailable = args.WaitForAvailable
+	cfg.DumpURLs = args.DumpURLs
+	cfg.SimulateQuality = args.SimulateQuality
+	cfg.TestProxy = args.TestProxy
+	cfg.NoClean = args.NoClean
+	cfg.JSONMeta = args.JSONMeta
+	cfg.TrimSilence = args.TrimSilence
+	cfg.Normalize = args.Normalize
+	if args.StartAt != "" {
+		cfg.StartAt, err = time.Parse(time.RFC3339, args.StartAt)
+		if err != nil {
+			return nil, fmt.Errorf("start time must be in RFC3339 format, e.g. 2026-06-01T20:00:00-04:00: %w", err)
+		}
+	}
+	if cfg.MaxBytesPerFile < 0 {
+		return nil, fmt.Errorf("max bytes per file can't be negative")
+	}
+	if _, err := regexp.Compile("(?i)" + cfg.ArtistFilter); err != nil {
+		return nil, fmt.Errorf("invalid artist filter: %w", err)
+	}
+	if args.IDTags {
+		cfg.IDTags = true
+	}
+	if args.SortTags {
+		cfg.SortTags = true
+	}
+	if args.AlbumChecksums {
+		cfg.AlbumChecksums = true
+	}
+	if args.DiscFolders {
+		cfg.DiscFolders = true
+	}
+	if args.DatePrefix {
+		cfg.DatePrefix = true
+	}
+	if args.Setlist {
+		cfg.Setlist = true
+	}
+	if args.KeepTracks {
+		cfg.KeepTracks = true
+	}
+	if args.CacheToken {
+		cfg.CacheToken = true
+	}
+	if cfg.Limit < 0 {
+		return nil, fmt.Errorf("limit can't be negative")
+	}
+	if args.Comment != "" {
+		cfg.Comment = args.Comment
+	}
+	if args.AppVersion != "" {
+		cfg.AppVersion = args.AppVersion
+	}
+	if args.MaxConnsPerHost != nil {
+		cfg.MaxConnsPerHost = *args.MaxConnsPerHost
+	}
+	if cfg.MaxConnsPerHost < 0 {
+		return nil, fmt.Errorf("connections per host can't be negative")
+	}
+	if args.ConcurrentItems != nil {
+		cfg.ConcurrentItems = *args.ConcurrentItems
+	}
+	if cfg.ConcurrentItems < 0 {
+		return nil, fmt.Errorf("concurrent items can't be negative")
+	}
+	if cfg.ConcurrentItems == 0 {
+		cfg.ConcurrentItems = 1
+	}
+	if args.Concurrency != nil {
+		cfg.Concurrency = *args.Concurrency
+	}
+	if cfg.Concurrency < 0 {
+		return nil, fmt.Errorf("concurrency can't be negative")
+	}
+	if args.AacBitrate != nil {
+		cfg.AacBitrate = *args.AacBitrate
+	}
+	if cfg.AacBitrate < 0 {
+		return nil, fmt.Errorf("aac bitrate can't be negative")
+	}
+	if args.MinBitrate != nil {
+		cfg.MinBitrate = *args.MinBitrate
+	}
+	if cfg.MinBitrate < 0 {
+		return nil, fmt.Errorf("min bitrate can't be negative")
+	}
+	if cfg.AacBitrate > 0 && cfg.MinBitrate > cfg.AacBitrate {
+		return nil, fmt.Errorf("min bitrate can't be above the aac bitrate")
+	}
+	if args.ArtistPages != nil {
+		cfg.ArtistPageConcurrency = *args.ArtistPages
+	}
+	if cfg.ArtistPageConcurrency < 0 {
+		return nil, fmt.Errorf("artist page concurrency can't be negative")
+	}
+	if args.ArtistDelay != nil {
+		cfg.ArtistCrawlDelay = *args.ArtistDelay
+	}
+	if cfg.ArtistCrawlDelay < 0 {
+		return nil, fmt.Errorf("artist crawl delay can't be negative")
+	}
+	if args.Cookies != "" {
+		cfg.Cookies = args.Cookies
+	}
+	if args.NoCookieJar {
+		cfg.NoCookieJar = true
+	}
+	if cfg.NoCookieJar && cfg.Cookies != "" {
+		return nil, fmt.Errorf("a cookie file can't be loaded without a cookie jar")
+	}
+	if args.PostDownloadHook != "" {
+		cfg.PostDownloadHook = args.PostDownloadHook
+	}
+	if args.RemoteDest != "" {
+		cfg.RemoteDest = args.RemoteDest
+	}
+	if args.RemoteCommand != "" {
+		cfg.RemoteCommand = args.RemoteCommand
+	}
+	if cfg.RemoteDest != "" && cfg.RemoteCommand != "" {
+		return nil, fmt.Errorf("a remote destination and a remote command can't both be set")
+	}
+	if cfg.RemoteDest != "" {
+		if _, err := remote.ParseDest(cfg.RemoteDest); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.RemoteCommand != "" && !strings.Contains(cfg.RemoteCommand, "{local}") {
+		return nil, fmt.Errorf("remote command must contain {local}: %s", cfg.RemoteCommand)
+	}
+	if args.MinFreeSpace != nil {
+		cfg.MinFreeSpace = *args.MinFreeSpace
+	}
+	if cfg.MinFreeSpace < 0 {
+		return nil, fmt.Errorf("minimum free space can't be negative")
+	}
+	if args.TrackTimeout != nil {
+		cfg.TrackTimeout = *args.TrackTimeout
+	}
+	if cfg.TrackTimeout < 0 {
+		return nil, fmt.Errorf("track timeout can't be negative")
+	}
 
 	return cfg, nil
 }
@@ -133,6 +642,32 @@ func readConfig() (*Config, error) {
 	return &cfg, nil
 }
 
+// applyProfile merges a named profile over the base config. Options the
+// profile doesn't set keep their base values.
+func applyProfile(cfg *Config, name string) error {
+	profile, ok := cfg.Profiles[name]
+	if !ok {
+		names := make([]string, 0, len(cfg.Profiles))
+		for profileName := range cfg.Profiles {
+			names = append(names, profileName)
+		}
+		if len(names) == 0 {
+			return fmt.Errorf("profile %q not found, the config has no profiles", name)
+		}
+		slices.Sort(names)
+		return fmt.Errorf("profile %q not found, the config has: %s", name, strings.Join(names, ", "))
+	}
+
+	profiles := cfg.Profiles
+	err := json.Unmarshal(profile, cfg)
+	// Profiles can't nest
+	cfg.Profiles = profiles
+	if err != nil {
+		return fmt.Errorf("invalid profile %q: %w", name, err)
+	}
+	return nil
+}
+
 // parseArgs parses command line arguments
 func parseArgs() *Args {
 	var args Args
@@ -140,6 +675,27 @@ func parseArgs() *Args {
 	return &args
 }
 
+// splitSyncCommand strips a leading "sync" command from the positional
+// arguments and reports whether it was present
+func splitSyncCommand(urls []string) ([]string, bool) {
+	if len(urls) > 0 && urls[0] == "sync" {
+		return urls[1:], true
+	}
+	return urls, false
+}
+
+// splitVerifyCommand strips a leading "verify" command and the folder after
+// the album URL from the positional arguments, returning the folder to verify
+func splitVerifyCommand(urls []string) ([]string, string, error) {
+	if len(urls) == 0 || urls[0] != "verify" {
+		return urls, "", nil
+	}
+	if len(urls) != 3 {
+		return nil, "", fmt.Errorf("verify takes an album URL and a folder, e.g. verify https://play.nugs.net/release/23329 \"Artist - Album\"")
+	}
+	return urls[1:2], urls[2], nil
+}
+
 // processUrls processes URL arguments, handling text files
 func processUrls(urls []string) ([]string, error) {
 	var processed []string
@@ -6,6 +6,7 @@ import (
 	"path/filepath"
 	"runtime"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/suite"
@@ -85,6 +86,7 @@ func (suite *ConfigTestSuite) TestParseCfg_CommandLineOverrides() {
 		"-o", "cli-output",
 		"--force-video",
 		"--skip-videos",
+		"--audio-only",
 		"https://example.com/test",
 	}
 
@@ -97,6 +99,684 @@ func (suite *ConfigTestSuite) TestParseCfg_CommandLineOverrides() {
 	assert.Equal(suite.T(), "cli-output", cfg.OutPath) // Overridden from CLI
 	assert.True(suite.T(), cfg.ForceVideo)
 	assert.True(suite.T(), cfg.SkipVideos)
+	assert.True(suite.T(), cfg.AudioOnly)
+}
+
+// TestParseCfg_CommentOverride tests that --comment overrides the config comment
+func (suite *ConfigTestSuite) TestParseCfg_CommentOverride() {
+	configData := Config{
+		Format:      2,
+		VideoFormat: 3,
+		Comment:     "from config",
+	}
+	suite.createConfigFile(configData)
+
+	os.Args = []string{"program"}
+	cfg, err := ParseCfg()
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "from config", cfg.Comment)
+
+	os.Args = []string{"program", "--comment", "from cli"}
+	cfg, err = ParseCfg()
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "from cli", cfg.Comment)
+}
+
+// TestParseCfg_MinFreeSpace tests the free space margin option
+func (suite *ConfigTestSuite) TestParseCfg_MinFreeSpace() {
+	configData := Config{
+		Format:       2,
+		VideoFormat:  3,
+		MinFreeSpace: 500,
+	}
+	suite.createConfigFile(configData)
+
+	os.Args = []string{"program"}
+	cfg, err := ParseCfg()
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 500, cfg.MinFreeSpace)
+
+	os.Args = []string{"program", "--min-free-space", "0"}
+	cfg, err = ParseCfg()
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 0, cfg.MinFreeSpace)
+
+	os.Args = []string{"program", "--min-free-space", "-1"}
+	_, err = ParseCfg()
+	assert.Error(suite.T(), err)
+}
+
+// TestParseCfg_StartAt tests the start time must be RFC3339
+func (suite *ConfigTestSuite) TestParseCfg_StartAt() {
+	configData := Config{
+		Format:      2,
+		VideoFormat: 3,
+	}
+	suite.createConfigFile(configData)
+
+	os.Args = []string{"program"}
+	cfg, err := ParseCfg()
+	assert.NoError(suite.T(), err)
+	assert.True(suite.T(), cfg.StartAt.IsZero())
+
+	os.Args = []string{"program", "--start-at", "2026-06-01T20:00:00-04:00", "--wait-for-available"}
+	cfg, err = ParseCfg()
+	assert.NoError(suite.T(), err)
+	assert.True(suite.T(), cfg.StartAt.Equal(time.Date(2026, 6, 2, 0, 0, 0, 0, time.UTC)))
+	assert.True(suite.T(), cfg.WaitForAvailable)
+
+	os.Args = []string{"program", "--start-at", "8pm"}
+	_, err = ParseCfg()
+	assert.Error(suite.T(), err)
+}
+
+// TestParseCfg_FailedLog tests the failed URL log option
+func (suite *ConfigTestSuite) TestParseCfg_FailedLog() {
+	configData := Config{
+		Format:      2,
+		VideoFormat: 3,
+		FailedLog:   "failures.txt",
+	}
+	suite.createConfigFile(configData)
+
+	os.Args = []string{"program"}
+	cfg, err := ParseCfg()
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "failures.txt", cfg.FailedLog)
+
+	os.Args = []string{"program", "--failed-log", "retry.txt"}
+	cfg, err = ParseCfg()
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "retry.txt", cfg.FailedLog)
+
+	// Only .txt files are read back as URL lists
+	os.Args = []string{"program", "--failed-log", "failures.log"}
+	_, err = ParseCfg()
+	assert.Error(suite.T(), err)
+}
+
+// TestParseCfg_BatchList tests that runs with URL lists are marked for
+// checkpointing and runs with only URLs aren't
+func (suite *ConfigTestSuite) TestParseCfg_BatchList() {
+	suite.createConfigFile(Config{Format: 2, VideoFormat: 3})
+	txtPath := filepath.Join(suite.tempDir, "urls.txt")
+	suite.Require().NoError(os.WriteFile(txtPath, []byte("https://play.nugs.net/release/23329\n"), 0644))
+
+	os.Args = []string{"program", "https://play.nugs.net/release/23790"}
+	cfg, err := ParseCfg()
+	assert.NoError(suite.T(), err)
+	assert.False(suite.T(), cfg.BatchList)
+
+	os.Args = []string{"program", "https://play.nugs.net/release/23790", txtPath}
+	cfg, err = ParseCfg()
+	assert.NoError(suite.T(), err)
+	assert.True(suite.T(), cfg.BatchList)
+}
+
+// TestParseCfg_Proxies tests the proxy list is read from its file
+func (suite *ConfigTestSuite) TestParseCfg_Proxies() {
+	configData := Config{
+		Format:      2,
+		VideoFormat: 3,
+	}
+	suite.createConfigFile(configData)
+
+	os.Args = []string{"program"}
+	cfg, err := ParseCfg()
+	assert.NoError(suite.T(), err)
+	assert.Empty(suite.T(), cfg.ProxyList)
+
+	proxiesPath := filepath.Join(suite.tempDir, "proxies.txt")
+	err = os.WriteFile(proxiesPath, []byte("http://1.2.3.4:8080\n\nsocks5://5.6.7.8:1080\n"), 0644)
+	suite.Require().NoError(err)
+	os.Args = []string{"program", "--proxies", proxiesPath}
+	cfg, err = ParseCfg()
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), []string{"http://1.2.3.4:8080", "socks5://5.6.7.8:1080"}, cfg.ProxyList)
+
+	os.Args = []string{"program", "--proxies", filepath.Join(suite.tempDir, "missing.txt")}
+	_, err = ParseCfg()
+	assert.Error(suite.T(), err)
+}
+
+// TestParseCfg_Limit tests the item limit option
+func (suite *ConfigTestSuite) TestParseCfg_Limit() {
+	configData := Config{
+		Format:      2,
+		VideoFormat: 3,
+	}
+	suite.createConfigFile(configData)
+
+	os.Args = []string{"program"}
+	cfg, err := ParseCfg()
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 0, cfg.Limit)
+
+	os.Args = []string{"program", "--limit", "5"}
+	cfg, err = ParseCfg()
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 5, cfg.Limit)
+
+	os.Args = []string{"program", "--limit", "-1"}
+	_, err = ParseCfg()
+	assert.Error(suite.T(), err)
+}
+
+// TestParseCfg_VideoContainer tests the video container option and its validation
+func (suite *ConfigTestSuite) TestParseCfg_VideoContainer() {
+	configData := Config{
+		Format:      2,
+		VideoFormat: 3,
+	}
+	suite.createConfigFile(configData)
+
+	os.Args = []string{"program"}
+	cfg, err := ParseCfg()
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "mp4", cfg.VideoContainer)
+
+	os.Args = []string{"program", "--video-container", "MKV"}
+	cfg, err = ParseCfg()
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "mkv", cfg.VideoContainer)
+
+	os.Args = []string{"program", "--video-container", "avi"}
+	_, err = ParseCfg()
+	assert.Error(suite.T(), err)
+}
+
+// TestParseCfg_Res tests the resolution override takes priority over the video format
+func (suite *ConfigTestSuite) TestParseCfg_Res() {
+	configData := Config{
+		Format:      2,
+		VideoFormat: 3,
+	}
+	suite.createConfigFile(configData)
+
+	os.Args = []string{"program", "--res", "1440"}
+	cfg, err := ParseCfg()
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 3, cfg.VideoFormat)
+	assert.Equal(suite.T(), "1440", cfg.WantRes)
+
+	os.Args = []string{"program", "--res", "0900"}
+	cfg, err = ParseCfg()
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "900", cfg.WantRes)
+
+	for _, res := range []string{"1080p", "0", "4k"} {
+		os.Args = []string{"program", "--res", res}
+		_, err = ParseCfg()
+		assert.Error(suite.T(), err, res)
+	}
+}
+
+// TestParseCfg_ArtistFilter tests that bad artist filter regexes are rejected
+func (suite *ConfigTestSuite) TestParseCfg_ArtistFilter() {
+	configData := Config{
+		Format:      2,
+		VideoFormat: 3,
+	}
+	suite.createConfigFile(configData)
+
+	os.Args = []string{"program", "--artist-filter", "^Phish$"}
+	cfg, err := ParseCfg()
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "^Phish$", cfg.ArtistFilter)
+
+	os.Args = []string{"program", "--artist-filter", "Trey ("}
+	_, err = ParseCfg()
+	assert.Error(suite.T(), err)
+}
+
+// TestParseCfg_MaxConnsPerHost tests the per-host connection limit option
+func (suite *ConfigTestSuite) TestParseCfg_MaxConnsPerHost() {
+	configData := Config{
+		Format:          2,
+		VideoFormat:     3,
+		MaxConnsPerHost: 4,
+	}
+	suite.createConfigFile(configData)
+
+	os.Args = []string{"program"}
+	cfg, err := ParseCfg()
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 4, cfg.MaxConnsPerHost)
+
+	os.Args = []string{"program", "--concurrency-per-host", "2"}
+	cfg, err = ParseCfg()
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 2, cfg.MaxConnsPerHost)
+
+	os.Args = []string{"program", "--concurrency-per-host", "-1"}
+	_, err = ParseCfg()
+	assert.Error(suite.T(), err)
+}
+
+// TestParseCfg_Resolver tests the DNS server and host override options
+func (suite *ConfigTestSuite) TestParseCfg_Resolver() {
+	configData := Config{
+		Format:        2,
+		VideoFormat:   3,
+		HostOverrides: map[string]string{"play.nugs.net": "192.0.2.10"},
+	}
+	suite.createConfigFile(configData)
+
+	os.Args = []string{"program", "--dns-server", "1.1.1.1"}
+	cfg, err := ParseCfg()
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "1.1.1.1", cfg.DNSServer)
+	assert.Equal(suite.T(), "192.0.2.10", cfg.HostOverrides["play.nugs.net"])
+
+	os.Args = []string{"program", "--dns-server", "dns.example.com"}
+	_, err = ParseCfg()
+	assert.Error(suite.T(), err)
+
+	configData.HostOverrides = map[string]string{"play.nugs.net": "not-an-ip"}
+	suite.createConfigFile(configData)
+	os.Args = []string{"program"}
+	_, err = ParseCfg()
+	assert.Error(suite.T(), err)
+}
+
+// TestParseCfg_NameLimits tests the folder and filename length options
+func (suite *ConfigTestSuite) TestParseCfg_NameLimits() {
+	configData := Config{
+		Format:           2,
+		VideoFormat:      3,
+		MaxFolderNameLen: 60,
+	}
+	suite.createConfigFile(configData)
+
+	os.Args = []string{"program", "--max-filename-length", "90"}
+	cfg, err := ParseCfg()
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 60, cfg.MaxFolderNameLen)
+	assert.Equal(suite.T(), 90, cfg.MaxVideoFilenameLen)
+
+	os.Args = []string{"program", "--max-folder-name-length", "300"}
+	_, err = ParseCfg()
+	assert.Error(suite.T(), err)
+}
+
+// TestParseCfg_Artwork tests the artwork option
+func (suite *ConfigTestSuite) TestParseCfg_Artwork() {
+	configData := Config{
+		Format:      2,
+		VideoFormat: 3,
+		Artwork:     "Front",
+	}
+	suite.createConfigFile(configData)
+
+	os.Args = []string{"program"}
+	cfg, err := ParseCfg()
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "front", cfg.Artwork)
+
+	os.Args = []string{"program", "--artwork", "all"}
+	cfg, err = ParseCfg()
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "all", cfg.Artwork)
+
+	os.Args = []string{"program", "--artwork", "back"}
+	_, err = ParseCfg()
+	assert.Error(suite.T(), err)
+}
+
+// TestParseCfg_Poster tests the poster option
+func (suite *ConfigTestSuite) TestParseCfg_Poster() {
+	configData := Config{
+		Format:      2,
+		VideoFormat: 3,
+	}
+	suite.createConfigFile(configData)
+
+	os.Args = []string{"program", "--poster", "Embed"}
+	cfg, err := ParseCfg()
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "embed", cfg.Poster)
+
+	os.Args = []string{"program", "--poster", "thumbnail"}
+	_, err = ParseCfg()
+	assert.Error(suite.T(), err)
+}
+
+// TestParseCfg_TrackTimeout tests the track timeout option
+func (suite *ConfigTestSuite) TestParseCfg_TrackTimeout() {
+	configData := Config{
+		Format:       2,
+		VideoFormat:  3,
+		TrackTimeout: 600,
+	}
+	suite.createConfigFile(configData)
+
+	os.Args = []string{"program"}
+	cfg, err := ParseCfg()
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 600, cfg.TrackTimeout)
+
+	os.Args = []string{"program", "--track-timeout", "0"}
+	cfg, err = ParseCfg()
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 0, cfg.TrackTimeout)
+
+	os.Args = []string{"program", "--track-timeout=-5"}
+	_, err = ParseCfg()
+	assert.Error(suite.T(), err)
+}
+
+// TestParseCfg_VideoTemplate tests the video template option
+func (suite *ConfigTestSuite) TestParseCfg_VideoTemplate() {
+	configData := Config{
+		Format:        2,
+		VideoFormat:   3,
+		VideoTemplate: " {artist} - {title} ",
+	}
+	suite.createConfigFile(configData)
+
+	os.Args = []string{"program"}
+	cfg, err := ParseCfg()
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "{artist} - {title}", cfg.VideoTemplate)
+
+	os.Args = []string{"program", "--video-template", "{date} {title} [{res}]"}
+	cfg, err = ParseCfg()
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "{date} {title} [{res}]", cfg.VideoTemplate)
+}
+
+// TestParseCfg_CueSheet tests the cue sheet option
+func (suite *ConfigTestSuite) TestParseCfg_CueSheet() {
+	configData := Config{
+		Format:      2,
+		VideoFormat: 3,
+		CueSheet:    "Sidecar",
+	}
+	suite.createConfigFile(configData)
+
+	os.Args = []string{"program"}
+	cfg, err := ParseCfg()
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "sidecar", cfg.CueSheet)
+	assert.False(suite.T(), cfg.KeepTracks)
+
+	os.Args = []string{"program", "--cue-sheet", "both", "--keep-tracks"}
+	cfg, err = ParseCfg()
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "both", cfg.CueSheet)
+	assert.True(suite.T(), cfg.KeepTracks)
+
+	os.Args = []string{"program", "--cue-sheet", "chapters"}
+	_, err = ParseCfg()
+	assert.Error(suite.T(), err)
+}
+
+// TestParseCfg_Subtitles tests the subtitles option
+func (suite *ConfigTestSuite) TestParseCfg_Subtitles() {
+	configData := Config{
+		Format:      2,
+		VideoFormat: 3,
+	}
+	suite.createConfigFile(configData)
+
+	os.Args = []string{"program", "--subtitles", "Embed"}
+	cfg, err := ParseCfg()
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "embed", cfg.Subtitles)
+
+	os.Args = []string{"program", "--subtitles", "burn"}
+	_, err = ParseCfg()
+	assert.Error(suite.T(), err)
+}
+
+// TestParseCfg_NoCookieJar tests a cookie file needs the cookie jar
+func (suite *ConfigTestSuite) TestParseCfg_NoCookieJar() {
+	configData := Config{
+		Format:      2,
+		VideoFormat: 3,
+	}
+	suite.createConfigFile(configData)
+
+	os.Args = []string{"program", "--no-cookie-jar"}
+	cfg, err := ParseCfg()
+	assert.NoError(suite.T(), err)
+	assert.True(suite.T(), cfg.NoCookieJar)
+
+	os.Args = []string{"program", "--no-cookie-jar", "--cookies", "cookies.txt"}
+	_, err = ParseCfg()
+	assert.Error(suite.T(), err)
+}
+
+// TestParseCfg_ConcurrentItems tests concurrent items default to one at a time
+func (suite *ConfigTestSuite) TestParseCfg_ConcurrentItems() {
+	configData := Config{
+		Format:      2,
+		VideoFormat: 3,
+	}
+	suite.createConfigFile(configData)
+
+	os.Args = []string{"program"}
+	cfg, err := ParseCfg()
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 1, cfg.ConcurrentItems)
+
+	os.Args = []string{"program", "--concurrent-items", "4"}
+	cfg, err = ParseCfg()
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 4, cfg.ConcurrentItems)
+
+	os.Args = []string{"program", "--concurrent-items", "-1"}
+	_, err = ParseCfg()
+	assert.Error(suite.T(), err)
+}
+
+// TestParseCfg_Concurrency tests the global download limit
+func (suite *ConfigTestSuite) TestParseCfg_Concurrency() {
+	configData := Config{
+		Format:      2,
+		VideoFormat: 3,
+		Concurrency: 6,
+	}
+	suite.createConfigFile(configData)
+
+	os.Args = []string{"program"}
+	cfg, err := ParseCfg()
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 6, cfg.Concurrency)
+
+	os.Args = []string{"program", "--concurrency", "0"}
+	cfg, err = ParseCfg()
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 0, cfg.Concurrency)
+
+	os.Args = []string{"program", "--concurrency=-2"}
+	_, err = ParseCfg()
+	assert.Error(suite.T(), err)
+}
+
+// TestParseCfg_SourcePreference tests the source preference option
+func (suite *ConfigTestSuite) TestParseCfg_SourcePreference() {
+	configData := Config{
+		Format:           2,
+		VideoFormat:      3,
+		SourcePreference: "Matrix",
+	}
+	suite.createConfigFile(configData)
+
+	os.Args = []string{"program"}
+	cfg, err := ParseCfg()
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "matrix", cfg.SourcePreference)
+
+	os.Args = []string{"program", "--prefer-soundboard"}
+	cfg, err = ParseCfg()
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "soundboard", cfg.SourcePreference)
+
+	os.Args = []string{"program", "--source-preference", "audience"}
+	cfg, err = ParseCfg()
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "audience", cfg.SourcePreference)
+
+	os.Args = []string{"program", "--source-preference", "fm"}
+	_, err = ParseCfg()
+	assert.Error(suite.T(), err)
+}
+
+// TestParseCfg_Profile tests merging a named profile over the base config
+func (suite *ConfigTestSuite) TestParseCfg_Profile() {
+	configData := Config{
+		Email:       "test@example.com",
+		Format:      2,
+		VideoFormat: 3,
+		OutPath:     "Downloads",
+		Profiles: map[string]json.RawMessage{
+			"phish":  json.RawMessage(`{"outPath": "Phish", "format": 1}`),
+			"broken": json.RawMessage(`{"format": "flac"}`),
+		},
+	}
+	suite.createConfigFile(configData)
+
+	os.Args = []string{"program"}
+	cfg, err := ParseCfg()
+	suite.Require().NoError(err)
+	assert.Equal(suite.T(), "Downloads", cfg.OutPath)
+	assert.Equal(suite.T(), 2, cfg.Format)
+
+	os.Args = []string{"program", "--profile", "phish"}
+	cfg, err = ParseCfg()
+	suite.Require().NoError(err)
+	assert.Equal(suite.T(), "Phish", cfg.OutPath)
+	assert.Equal(suite.T(), 1, cfg.Format)
+	assert.Equal(suite.T(), "test@example.com", cfg.Email)
+
+	// Args still take priority over the profile
+	os.Args = []string{"program", "--profile", "phish", "--format", "3"}
+	cfg, err = ParseCfg()
+	suite.Require().NoError(err)
+	assert.Equal(suite.T(), 3, cfg.Format)
+
+	os.Args = []string{"program", "--profile", "dead"}
+	_, err = ParseCfg()
+	suite.Require().Error(err)
+	assert.Contains(suite.T(), err.Error(), "broken, phish")
+
+	os.Args = []string{"program", "--profile", "broken"}
+	_, err = ParseCfg()
+	assert.Error(suite.T(), err)
+}
+
+// TestParseCfg_MinBitrate tests the minimum AAC bitrate option
+func (suite *ConfigTestSuite) TestParseCfg_MinBitrate() {
+	configData := Config{
+		Format:      2,
+		VideoFormat: 3,
+		MinBitrate:  128,
+	}
+	suite.createConfigFile(configData)
+
+	os.Args = []string{"program"}
+	cfg, err := ParseCfg()
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 128, cfg.MinBitrate)
+
+	os.Args = []string{"program", "--min-bitrate", "0"}
+	cfg, err = ParseCfg()
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 0, cfg.MinBitrate)
+
+	os.Args = []string{"program", "--min-bitrate=-1"}
+	_, err = ParseCfg()
+	assert.Error(suite.T(), err)
+
+	// The minimum can't rule out every stream the cap allows
+	os.Args = []string{"program", "--aac-bitrate", "96"}
+	_, err = ParseCfg()
+	assert.Error(suite.T(), err)
+}
+
+// TestParseCfg_ArtistCrawlDelay tests the artist crawl delay option
+func (suite *ConfigTestSuite) TestParseCfg_ArtistCrawlDelay() {
+	configData := Config{
+		Format:           2,
+		VideoFormat:      3,
+		ArtistCrawlDelay: 500,
+	}
+	suite.createConfigFile(configData)
+
+	os.Args = []string{"program"}
+	cfg, err := ParseCfg()
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 500, cfg.ArtistCrawlDelay)
+
+	os.Args = []string{"program", "--artist-crawl-delay", "0"}
+	cfg, err = ParseCfg()
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 0, cfg.ArtistCrawlDelay)
+
+	os.Args = []string{"program", "--artist-crawl-delay=-1"}
+	_, err = ParseCfg()
+	assert.Error(suite.T(), err)
+}
+
+// TestParseCfg_Remote tests the remote destination options
+func (suite *ConfigTestSuite) TestParseCfg_Remote() {
+	configData := Config{
+		Format:      2,
+		VideoFormat: 3,
+		RemoteDest:  "sftp://me@nas/volume1/music",
+	}
+	suite.createConfigFile(configData)
+
+	os.Args = []string{"program"}
+	cfg, err := ParseCfg()
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "sftp://me@nas/volume1/music", cfg.RemoteDest)
+
+	os.Args = []string{"program", "--remote-dest", "ftp://me@nas/music"}
+	_, err = ParseCfg()
+	assert.Error(suite.T(), err)
+
+	// Only one of a destination and a command
+	os.Args = []string{"program", "--remote-command", "rclone copyto {local} nas:{remote}"}
+	_, err = ParseCfg()
+	assert.Error(suite.T(), err)
+
+	configData.RemoteDest = ""
+	suite.createConfigFile(configData)
+	cfg, err = ParseCfg()
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "rclone copyto {local} nas:{remote}", cfg.RemoteCommand)
+
+	os.Args = []string{"program", "--remote-command", "rclone copyto nas:music"}
+	_, err = ParseCfg()
+	assert.Error(suite.T(), err)
+}
+
+// TestParseCfg_ExtraHeaders tests extra header validation
+func (suite *ConfigTestSuite) TestParseCfg_ExtraHeaders() {
+	configData := Config{
+		Format:       2,
+		VideoFormat:  3,
+		ExtraHeaders: map[string]string{"X-Client-Id": "abc123"},
+	}
+	suite.createConfigFile(configData)
+
+	os.Args = []string{"program"}
+	cfg, err := ParseCfg()
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "abc123", cfg.ExtraHeaders["X-Client-Id"])
+
+	for _, headers := range []map[string]string{
+		{"X-Client-Id:": "abc123"},
+		{"X Client": "abc123"},
+		{"X-Client-Id": "abc\r\nX-Other: 1"},
+	} {
+		configData.ExtraHeaders = headers
+		suite.createConfigFile(configData)
+		_, err = ParseCfg()
+		assert.Error(suite.T(), err, headers)
+	}
 }
 
 // TestParseCfg_InvalidFormat tests invalid format ranges
@@ -375,6 +1055,36 @@ func (suite *ConfigTestSuite) TestResolveRes() {
 	assert.Equal(suite.T(), "2160", resolveRes[5])
 }
 
+func (suite *ConfigTestSuite) TestSplitSyncCommand() {
+	urls, sync := splitSyncCommand([]string{"sync", "https://play.nugs.net/#/artist/461"})
+	assert.True(suite.T(), sync)
+	assert.Equal(suite.T(), []string{"https://play.nugs.net/#/artist/461"}, urls)
+
+	urls, sync = splitSyncCommand([]string{"https://play.nugs.net/release/23329"})
+	assert.False(suite.T(), sync)
+	assert.Equal(suite.T(), []string{"https://play.nugs.net/release/23329"}, urls)
+
+	urls, sync = splitSyncCommand(nil)
+	assert.False(suite.T(), sync)
+	assert.Empty(suite.T(), urls)
+}
+
+// TestSplitVerifyCommand tests the verify command and its folder argument
+func (suite *ConfigTestSuite) TestSplitVerifyCommand() {
+	urls, folder, err := splitVerifyCommand([]string{"verify", "https://play.nugs.net/release/23329", "Show"})
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), []string{"https://play.nugs.net/release/23329"}, urls)
+	assert.Equal(suite.T(), "Show", folder)
+
+	urls, folder, err = splitVerifyCommand([]string{"https://play.nugs.net/release/23329"})
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), []string{"https://play.nugs.net/release/23329"}, urls)
+	assert.Empty(suite.T(), folder)
+
+	_, _, err = splitVerifyCommand([]string{"verify", "https://play.nugs.net/release/23329"})
+	assert.Error(suite.T(), err)
+}
+
 // Helper method to create config.json file
 func (suite *ConfigTestSuite) createConfigFile(cfg Config) {
 	configPath := filepath.Join(suite.tempDir, "config.json")
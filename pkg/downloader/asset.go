@@ -0,0 +1,78 @@
+package downloader
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+
+	"main/pkg/fsutil"
+	"main/pkg/models"
+)
+
+// imageMagic holds the leading bytes of the image formats Nugs serves
+var imageMagic = [][]byte{
+	{0xff, 0xd8, 0xff}, // JPEG
+	{0x89, 'P', 'N', 'G', '\r', '\n', 0x1a, '\n'},
+}
+
+// ValidateImage checks a file starts like a JPEG or PNG, so error pages and
+// cut-short responses aren't saved as artwork
+func ValidateImage(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return models.NewDownloadError(models.ErrFileSystem, "Cannot read downloaded image", "Check file permissions", false, err)
+	}
+	defer f.Close()
+
+	head := make([]byte, 8)
+	n, _ := io.ReadFull(f, head)
+	for _, magic := range imageMagic {
+		if bytes.HasPrefix(head[:n], magic) {
+			return nil
+		}
+	}
+	return models.NewDownloadError(models.ErrCorruption, "Downloaded file isn't a JPEG or PNG image", "The image may be unavailable - try again later", true, nil)
+}
+
+// SafeDownloadAsset downloads a release extra such as a cover or booklet page
+// with retries. It's written to a temporary file and only moved into place
+// once its size and, when validate is set, its contents check out, so an
+// interrupted download never leaves a broken file behind.
+func (d *Downloader) SafeDownloadAsset(assetPath, url string, validate func(path string) error) error {
+	tempPath := fsutil.TempPathFor(assetPath)
+	defer os.Remove(tempPath)
+
+	resp, err := d.downloadFileWithRetry(context.Background(), url, d.apiClient.PlayerReferer())
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	f, err := fsutil.OpenFile(tempPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return models.NewDownloadError(models.ErrFileSystem, "Cannot create temporary file", "Check write permissions for the download directory", false, err)
+	}
+	written, err := io.Copy(f, resp.Body)
+	d.downloaded.Add(written)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return models.NewDownloadError(models.ErrNetwork, "Download failed", "Check your internet connection and try again", true, err)
+	}
+
+	if resp.ContentLength > 0 && written != resp.ContentLength {
+		return models.NewDownloadError(models.ErrCorruption, "Downloaded file size mismatch", "The download may be corrupted - try again", true, nil)
+	}
+	if validate != nil {
+		if err := validate(tempPath); err != nil {
+			return err
+		}
+	}
+
+	if err := os.Rename(tempPath, assetPath); err != nil {
+		return models.NewDownloadError(models.ErrFileSystem, "Cannot finalize download", "Check write permissions for the download directory", false, err)
+	}
+	return nil
+}
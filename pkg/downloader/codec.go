@@ -0,0 +1,77 @@
+package downloader
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"regexp"
+
+	"main/pkg/logger"
+)
+
+// passthroughCodecs are the audio codecs an .m4a can hold as they are. HLS
+// audio in anything else, like AC-3 from video sources, is re-encoded.
+var passthroughCodecs = map[string]bool{
+	"aac":  true,
+	"alac": true,
+}
+
+// reencodeBitrate is the AAC bitrate HLS audio that can't be copied is
+// re-encoded at, high enough not to audibly lose more
+const reencodeBitrate = "256k"
+
+// audioCodecRegex matches the first audio stream in ffmpeg's description of
+// its input, e.g. "Stream #0:0[0x101]: Audio: aac (LC) ([15][0][0][0] / 0x000F)"
+var audioCodecRegex = regexp.MustCompile(`Stream #\d+:\d+.*?: Audio: (\w+)`)
+
+// parseAudioCodec returns the codec of the first audio stream ffmpeg
+// described, or "" if there's none
+func parseAudioCodec(probe string) string {
+	match := audioCodecRegex.FindStringSubmatch(probe)
+	if match == nil {
+		return ""
+	}
+	return match[1]
+}
+
+// ProbeAudioCodec returns the codec of the audio in a TS. ffmpeg is run
+// without an output, so it only describes its input and exits with an error.
+func ProbeAudioCodec(tsData []byte, ffmpegNameStr string) (string, error) {
+	var errBuffer bytes.Buffer
+	cmd := exec.Command(ffmpegNameStr, "-hide_banner", "-i", "pipe:")
+	cmd.Stdin = bytes.NewReader(tsData)
+	cmd.Stderr = &errBuffer
+	err := cmd.Run()
+
+	codec := parseAudioCodec(errBuffer.String())
+	if codec == "" {
+		return "", fmt.Errorf("no audio stream found: %v\n%s", err, errBuffer.String())
+	}
+	return codec, nil
+}
+
+// tsToAacArgs builds the ffmpeg arguments for TsToAac. The output is named
+// .tmp by callers, so the container is given explicitly.
+func tsToAacArgs(outPath, codec string) []string {
+	args := []string{"-hide_banner", "-i", "pipe:", "-vn"}
+	if passthroughCodecs[codec] {
+		args = append(args, "-c:a", "copy")
+	} else {
+		args = append(args, "-c:a", "aac", "-b:a", reencodeBitrate)
+	}
+	return append(args, "-f", "ipod", outPath)
+}
+
+// audioCodec probes a TS for TsToAac. Probing is only a safeguard, so if it
+// fails the audio is assumed to be AAC, as Nugs HLS audio almost always is.
+func audioCodec(tsData []byte, ffmpegNameStr string) string {
+	codec, err := ProbeAudioCodec(tsData, ffmpegNameStr)
+	if err != nil {
+		logger.GetLogger().WithError(err).Warn("Failed to probe HLS audio codec, assuming AAC")
+		return "aac"
+	}
+	if !passthroughCodecs[codec] {
+		fmt.Printf("Track audio is %s, which an .m4a can't hold, re-encoding to AAC...\n", codec)
+	}
+	return codec
+}
@@ -0,0 +1,60 @@
+package downloader
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"main/pkg/models"
+)
+
+// concatList builds an ffmpeg concat demuxer list of the given files. Paths
+// are made absolute since the demuxer resolves them against the list's folder.
+func concatList(paths []string) (string, error) {
+	var b strings.Builder
+	for _, path := range paths {
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			return "", err
+		}
+		// Quotes are closed, escaped and reopened, as in a shell
+		fmt.Fprintf(&b, "file '%s'\n", strings.ReplaceAll(abs, "'", `'\''`))
+	}
+	return b.String(), nil
+}
+
+// concatArgs builds the ffmpeg arguments for ConcatFlac. The tracks' own tags
+// are dropped, as they'd only describe the first one.
+func concatArgs(listPath, outPath string, metadata *models.TrackMetadata, cueSheet string) []string {
+	args := []string{"-hide_banner", "-f", "concat", "-safe", "0", "-i", listPath, "-map", "0:a", "-map_metadata", "-1"}
+	args = append(args, audioTagArgs(outPath, metadata)...)
+	if cueSheet != "" {
+		args = append(args, "-metadata", "CUESHEET="+cueSheet)
+	}
+	return append(args, "-c:a", "flac", outPath)
+}
+
+// ConcatFlac joins FLAC tracks into one FLAC file at outPath, tagged with
+// metadata. The audio is re-encoded losslessly so the joined file has a
+// correct header. cueSheet, when set, is embedded as a CUESHEET tag.
+func ConcatFlac(trackPaths []string, outPath, ffmpegNameStr string, metadata *models.TrackMetadata, cueSheet string) error {
+	list, err := concatList(trackPaths)
+	if err != nil {
+		return err
+	}
+	listPath := outPath + ".concat.txt"
+	if err := os.WriteFile(listPath, []byte(list), 0644); err != nil {
+		return err
+	}
+	defer os.Remove(listPath)
+
+	cmd := exec.Command(ffmpegNameStr, concatArgs(listPath, outPath, metadata, cueSheet)...)
+	stderr, err := runFfmpeg(cmd)
+	if err != nil {
+		os.Remove(outPath)
+		return fmt.Errorf("ffmpeg concat failed: %s\n%s", err, stderr)
+	}
+	return nil
+}
@@ -2,6 +2,7 @@ package downloader
 
 import (
 	"bytes"
+	"context"
 	"crypto/aes"
 	"crypto/cipher"
 	"encoding/hex"
@@ -17,7 +18,10 @@ import (
 	"path/filepath"
 	"regexp"
 	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"main/pkg/api"
@@ -34,6 +38,9 @@ type Downloader struct {
 	apiClient     *api.Client
 	config        *config.Config
 	resumeManager *ResumeManager
+	progressFunc  models.ProgressFunc
+	lastModified  sync.Map     // download path -> Last-Modified time of its response
+	downloaded    atomic.Int64 // bytes downloaded this run, across every file
 }
 
 // NewDownloader creates a new downloader instance
@@ -49,6 +56,50 @@ func NewDownloader(apiClient *api.Client, cfg *config.Config) *Downloader {
 	}
 }
 
+// SetProgressFunc sets a callback that receives download progress instead of
+// it being printed, for programs embedding the downloader. nil restores printing.
+func (d *Downloader) SetProgressFunc(fn models.ProgressFunc) {
+	d.progressFunc = fn
+}
+
+// newWriteCounter creates a progress counter for a download to path
+func (d *Downloader) newWriteCounter(path string, total, downloaded int64) *models.WriteCounter {
+	return &models.WriteCounter{
+		Total:      total,
+		TotalStr:   humanize.Bytes(uint64(total)),
+		StartTime:  time.Now().UnixMilli(),
+		Downloaded: downloaded,
+		Path:       path,
+		OnProgress: d.progressFunc,
+		Limit:      d.config.MaxBytesPerFile,
+		Tally:      &d.downloaded,
+	}
+}
+
+// BytesDownloaded returns the bytes downloaded so far this run, including
+// files that later failed
+func (d *Downloader) BytesDownloaded() int64 {
+	return d.downloaded.Load()
+}
+
+// SamplePath returns where a download cut short by --max-bytes-per-file is
+// kept, so it's never mistaken for the complete file
+func SamplePath(path string) string {
+	return path + ".sample"
+}
+
+// keepSample moves a download cut short by the byte limit to its sample path
+// and returns models.ErrSampleLimit
+func keepSample(partPath, path string) error {
+	fmt.Println("")
+	if err := os.Rename(partPath, SamplePath(path)); err != nil {
+		os.Remove(partPath)
+		return err
+	}
+	fmt.Printf("Stopped at the byte limit, kept a partial sample: %s\n", SamplePath(path))
+	return models.ErrSampleLimit
+}
+
 // DownloadTrack downloads a single track without metadata tagging.
 // Note: This function does not support resume functionality.
 // Use DownloadTrackWithMetadata() for downloads that should support resuming.
@@ -59,22 +110,25 @@ func (d *Downloader) DownloadTrack(trackPath, url string) error {
 	}
 	defer f.Close()
 
-	resp, err := d.apiClient.DownloadFile(url, "https://play.nugs.net/")
+	ctx, cancel := d.trackContext()
+	defer cancel()
+	resp, err := d.apiClient.DownloadFileContext(ctx, url, d.apiClient.PlayerReferer())
 	if err != nil {
-		return err
+		return d.trackTimedOut(ctx, err)
 	}
 	defer resp.Body.Close()
+	d.recordLastModified(trackPath, resp.Header)
 
 	totalBytes := resp.ContentLength
-	counter := &models.WriteCounter{
-		Total:     totalBytes,
-		TotalStr:  humanize.Bytes(uint64(totalBytes)),
-		StartTime: time.Now().UnixMilli(),
-	}
+	counter := d.newWriteCounter(trackPath, totalBytes, 0)
 
 	_, err = io.Copy(f, io.TeeReader(resp.Body, counter))
+	if errors.Is(err, models.ErrSampleLimit) {
+		f.Close()
+		return keepSample(trackPath, trackPath)
+	}
 	fmt.Println("")
-	return err
+	return d.trackTimedOut(ctx, err)
 }
 
 // DownloadVideo downloads a video file
@@ -96,8 +150,7 @@ func (d *Downloader) DownloadVideo(videoPath, url string) error {
 		return err
 	}
 	req.Header.Add("Range", fmt.Sprintf("bytes=%d-", startByte))
-	httpClient := d.apiClient.GetHTTPClient()
-	do, err := httpClient.Do(req)
+	do, err := d.apiClient.DoDownload(req)
 	if err != nil {
 		return err
 	}
@@ -108,22 +161,43 @@ func (d *Downloader) DownloadVideo(videoPath, url string) error {
 	}
 
 	if startByte > 0 {
-		fmt.Printf("TS already exists locally, resuming from byte %d...\n", startByte)
-		startByte = 0
+		startByte, err = resumeOffset(f, startByte, do.StatusCode)
+		if err != nil {
+			return err
+		}
+		if startByte > 0 {
+			fmt.Printf("TS already exists locally, resuming from byte %d...\n", startByte)
+		} else {
+			fmt.Println("Server ignored the resume request, downloading the TS again from the start...")
+		}
 	}
 
-	totalBytes := do.ContentLength
-	counter := &models.WriteCounter{
-		Total:      totalBytes,
-		TotalStr:   humanize.Bytes(uint64(totalBytes)),
-		StartTime:  time.Now().UnixMilli(),
-		Downloaded: startByte,
-	}
+	totalBytes := startByte + do.ContentLength
+	counter := d.newWriteCounter(videoPath, totalBytes, startByte)
 	_, err = io.Copy(f, io.TeeReader(do.Body, counter))
+	if errors.Is(err, models.ErrSampleLimit) {
+		f.Close()
+		return keepSample(videoPath, videoPath)
+	}
 	fmt.Println("")
 	return err
 }
 
+// resumeOffset readies a partly downloaded file for a response to a request
+// for the bytes from startByte on, returning where the response goes. Servers
+// that ignore the Range header answer 200 with the whole file, so the file is
+// emptied rather than have its start written twice.
+func resumeOffset(f *os.File, startByte int64, statusCode int) (int64, error) {
+	if statusCode != http.StatusPartialContent {
+		startByte = 0
+		if err := f.Truncate(0); err != nil {
+			return 0, err
+		}
+	}
+	_, err := f.Seek(startByte, io.SeekStart)
+	return startByte, err
+}
+
 // DownloadLstream downloads livestream segments with automatic resume support.
 // This function can resume interrupted livestream downloads by tracking segment progress
 // and restarting from the first incomplete segment. Resume state is automatically
@@ -222,8 +296,7 @@ func (d *Downloader) downloadSegmentsFromIndex(videoPath, baseUrl string, segUrl
 			return models.NewDownloadError(models.ErrNetwork, "Failed to create segment request", "Check network connection", true, err)
 		}
 
-		httpClient := d.apiClient.GetHTTPClient()
-		resp, err := httpClient.Do(req)
+		resp, err := d.apiClient.DoDownload(req)
 		if err != nil {
 			return models.NewDownloadError(models.ErrNetwork, "Failed to download segment", "Check network connection", true, err)
 		}
@@ -236,6 +309,7 @@ func (d *Downloader) downloadSegmentsFromIndex(videoPath, baseUrl string, segUrl
 		// Read segment data
 		segmentData, err := io.ReadAll(resp.Body)
 		resp.Body.Close()
+		d.downloaded.Add(int64(len(segmentData)))
 		if err != nil {
 			return models.NewDownloadError(models.ErrNetwork, "Failed to read segment data", "Check network connection", true, err)
 		}
@@ -316,6 +390,23 @@ func GetTrackQual(quals []*models.Quality, wantFmt int) *models.Quality {
 	return nil
 }
 
+// GetTrackQualWithFallback selects the wanted quality, falling back through
+// TrackFallback until one is available. It returns the chosen quality and its
+// format, or nil once the fallbacks run out.
+func GetTrackQualWithFallback(quals []*models.Quality, wantFmt int) (*models.Quality, int) {
+	for {
+		if quality := GetTrackQual(quals, wantFmt); quality != nil {
+			return quality, wantFmt
+		}
+
+		fallback, ok := models.TrackFallback[wantFmt]
+		if !ok {
+			return nil, wantFmt
+		}
+		wantFmt = fallback
+	}
+}
+
 // CheckIfHlsOnly checks if all qualities are HLS-only
 func CheckIfHlsOnly(quals []*models.Quality) bool {
 	for _, quality := range quals {
@@ -337,11 +428,14 @@ func (d *Downloader) ParseHlsMaster(qual *models.Quality) error {
 		return master.Variants[x].Bandwidth > master.Variants[y].Bandwidth
 	})
 
-	variantUri := master.Variants[0].URI
+	variantUri := chooseAudioVariant(master.Variants, d.config.AacBitrate).URI
 	bitrate := extractBitrate(variantUri)
 	if bitrate == "" {
 		return errors.New("no regex match for manifest bitrate")
 	}
+	if kbps, _ := strconv.Atoi(bitrate); kbps < d.config.MinBitrate {
+		return fmt.Errorf("%w: %s Kbps AAC is below the %d Kbps minimum", models.ErrBelowMinBitrate, bitrate, d.config.MinBitrate)
+	}
 
 	qual.Specs = bitrate + " Kbps AAC"
 	manBase, q, err := d.GetManifestBase(qual.URL)
@@ -352,6 +446,25 @@ func (d *Downloader) ParseHlsMaster(qual *models.Quality) error {
 	return nil
 }
 
+// chooseAudioVariant picks the highest bitrate AAC variant at or below
+// maxKbps, or the lowest one if they're all above it. Variants must be sorted
+// by bandwidth, highest first, and 0 picks the highest.
+func chooseAudioVariant(variants []*m3u8.Variant, maxKbps int) *m3u8.Variant {
+	if maxKbps <= 0 {
+		return variants[0]
+	}
+	for _, variant := range variants {
+		kbps, err := strconv.Atoi(extractBitrate(variant.URI))
+		if err != nil {
+			kbps = int(variant.Bandwidth / 1000)
+		}
+		if kbps <= maxKbps {
+			return variant
+		}
+	}
+	return variants[len(variants)-1]
+}
+
 // GetManifestBase extracts base URL from manifest URL
 func (d *Downloader) GetManifestBase(manifestUrl string) (string, string, error) {
 	u, err := urlPkg.Parse(manifestUrl)
@@ -478,16 +591,18 @@ func GetKey(keyUrl string, apiClient *api.Client) ([]byte, error) {
 	return buf, nil
 }
 
-// DecryptTrack decrypts AES-encrypted track
-func DecryptTrack(key, iv []byte) ([]byte, error) {
-	encData, err := os.ReadFile("temp_enc.ts")
+// DecryptTrack decrypts AES-128-CBC encrypted track data
+func DecryptTrack(encData, key, iv []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
 	if err != nil {
 		return nil, err
 	}
 
-	block, err := aes.NewCipher(key)
-	if err != nil {
-		return nil, err
+	if len(iv) != aes.BlockSize {
+		return nil, fmt.Errorf("IV must be %d bytes, got %d", aes.BlockSize, len(iv))
+	}
+	if len(encData)%aes.BlockSize != 0 {
+		return nil, fmt.Errorf("encrypted data length %d is not a multiple of the AES block size", len(encData))
 	}
 
 	ecb := cipher.NewCBCDecrypter(block, iv)
@@ -497,21 +612,34 @@ func DecryptTrack(key, iv []byte) ([]byte, error) {
 	return decrypted, nil
 }
 
-// TsToAac converts TS to AAC using ffmpeg
+// TsToAac converts TS to AAC using ffmpeg. AAC audio is copied, anything
+// else is re-encoded so the result is a valid .m4a.
 func TsToAac(decData []byte, outPath, ffmpegNameStr string) error {
-	var errBuffer bytes.Buffer
-	cmd := exec.Command(ffmpegNameStr, "-i", "pipe:", "-c:a", "copy", outPath)
+	codec := audioCodec(decData, ffmpegNameStr)
+	cmd := exec.Command(ffmpegNameStr, tsToAacArgs(outPath, codec)...)
 	cmd.Stdin = bytes.NewReader(decData)
-	cmd.Stderr = &errBuffer
-
-	err := cmd.Run()
+	stderr, err := runFfmpeg(cmd)
 	if err != nil {
-		errString := fmt.Sprintf("%s\n%s", err, errBuffer.String())
+		errString := fmt.Sprintf("%s\n%s", err, stderr)
 		return errors.New(errString)
 	}
 	return nil
 }
 
+// checkKeyMethod checks that an HLS track is encrypted the way DecryptTrack
+// decrypts it, with the whole segment in AES-128-CBC. SAMPLE-AES only
+// encrypts the audio samples, so decrypting it as a whole gives garbage.
+func checkKeyMethod(key *m3u8.Key) error {
+	method := "NONE"
+	if key != nil {
+		method = key.Method
+	}
+	if method != "AES-128" {
+		return fmt.Errorf("%w: HLS encryption method %s isn't supported", models.ErrUnsupportedFormat, method)
+	}
+	return nil
+}
+
 // HlsOnly processes HLS-only tracks
 func (d *Downloader) HlsOnly(trackPath, manUrl, ffmpegNameStr string) error {
 	media, err := d.apiClient.GetMediaPlaylist(manUrl)
@@ -521,6 +649,9 @@ func (d *Downloader) HlsOnly(trackPath, manUrl, ffmpegNameStr string) error {
 
 	tsUrl := media.Segments[0].URI
 	key := media.Key
+	if err := checkKeyMethod(key); err != nil {
+		return err
+	}
 
 	// Construct full URLs if they're relative
 	manBase, query, err := d.GetManifestBase(manUrl)
@@ -549,23 +680,34 @@ func (d *Downloader) HlsOnly(trackPath, manUrl, ffmpegNameStr string) error {
 		return err
 	}
 
-	err = d.DownloadTrack("temp_enc.ts", tsUrl)
+	encPath := d.TempPath("temp_enc_*.ts")
+	err = d.DownloadTrack(encPath, tsUrl)
+	// Nothing looks up the Last-Modified of a unique temp name, so it's dropped
+	d.TakeLastModified(encPath)
+	if err != nil {
+		d.removeTemp(encPath)
+		return err
+	}
+
+	encData, err := os.ReadFile(encPath)
 	if err != nil {
+		d.removeTemp(encPath)
 		return err
 	}
 
-	decData, err := DecryptTrack(keyBytes, iv)
+	decData, err := DecryptTrack(encData, keyBytes, iv)
 	if err != nil {
+		d.removeTemp(encPath)
 		return err
 	}
 
-	err = os.Remove("temp_enc.ts")
+	err = d.discardEncrypted(encPath, trackPath)
 	if err != nil {
 		return err
 	}
 
 	// Convert to AAC with temporary filename, then tag it
-	tempAacPath := trackPath + ".tmp"
+	tempAacPath := fsutil.TempPathFor(trackPath)
 	err = TsToAac(decData, tempAacPath, ffmpegNameStr)
 	if err != nil {
 		return err
@@ -591,6 +733,9 @@ func (d *Downloader) HlsOnlyWithMetadata(trackPath, manUrl, ffmpegNameStr string
 
 	tsUrl := media.Segments[0].URI
 	key := media.Key
+	if err := checkKeyMethod(key); err != nil {
+		return err
+	}
 
 	// Construct full URLs if they're relative
 	manBase, query, err := d.GetManifestBase(manUrl)
@@ -619,23 +764,34 @@ func (d *Downloader) HlsOnlyWithMetadata(trackPath, manUrl, ffmpegNameStr string
 		return err
 	}
 
-	err = d.DownloadTrack("temp_enc.ts", tsUrl)
+	encPath := d.TempPath("temp_enc_*.ts")
+	err = d.DownloadTrack(encPath, tsUrl)
+	// Nothing looks up the Last-Modified of a unique temp name, so it's dropped
+	d.TakeLastModified(encPath)
+	if err != nil {
+		d.removeTemp(encPath)
+		return err
+	}
+
+	encData, err := os.ReadFile(encPath)
 	if err != nil {
+		d.removeTemp(encPath)
 		return err
 	}
 
-	decData, err := DecryptTrack(keyBytes, iv)
+	decData, err := DecryptTrack(encData, keyBytes, iv)
 	if err != nil {
+		d.removeTemp(encPath)
 		return err
 	}
 
-	err = os.Remove("temp_enc.ts")
+	err = d.discardEncrypted(encPath, trackPath)
 	if err != nil {
 		return err
 	}
 
 	// Convert to AAC with temporary filename
-	tempAacPath := trackPath + ".tmp"
+	tempAacPath := fsutil.TempPathFor(trackPath)
 	err = TsToAac(decData, tempAacPath, ffmpegNameStr)
 	if err != nil {
 		return err
@@ -644,12 +800,12 @@ func (d *Downloader) HlsOnlyWithMetadata(trackPath, manUrl, ffmpegNameStr string
 	// Tag the AAC file with metadata
 	err = TagAudioFile(tempAacPath, trackPath, ffmpegNameStr, metadata)
 	if err != nil {
-		os.Remove(tempAacPath) // Clean up on error
+		d.removeTemp(tempAacPath) // Clean up on error
 		return err
 	}
 
 	// Remove temp file
-	err = os.Remove(tempAacPath)
+	err = d.removeTemp(tempAacPath)
 	if err != nil {
 		fmt.Printf("Warning: failed to remove temp file %s: %v\n", tempAacPath, err)
 	}
@@ -716,9 +872,9 @@ func parseDuration(dur string) (int, error) {
 	return int(rounded), nil
 }
 
-// WriteChapsFile writes chapter metadata to file
-func WriteChapsFile(chapters []interface{}, dur int) error {
-	f, err := fsutil.OpenFile("chapters_nugs_dl_tmp.txt", os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0)
+// WriteChapsFile writes chapter metadata to an ffmetadata file at path
+func WriteChapsFile(path string, chapters []interface{}, dur int) error {
+	f, err := fsutil.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0)
 	if err != nil {
 		return err
 	}
@@ -797,36 +953,102 @@ func getNextChapStart(chapters []interface{}, idx int) float64 {
 }
 
 // TsToMp4 converts TS to MP4 using ffmpeg
-func TsToMp4(VidPathTs, vidPath, ffmpegNameStr string, chapAvail bool) error {
-	var (
-		errBuffer bytes.Buffer
-		args      []string
-	)
-
-	if chapAvail {
-		args = []string{
-			"-hide_banner", "-i", VidPathTs, "-f", "ffmetadata",
-			"-i", "chapters_nugs_dl_tmp.txt", "-map_metadata", "1", "-c", "copy", vidPath,
-		}
-	} else {
-		args = []string{"-hide_banner", "-i", VidPathTs, "-c", "copy", vidPath}
+func TsToMp4(VidPathTs, vidPath, ffmpegNameStr, chapsPath string) error {
+	return TsToContainer(VidPathTs, vidPath, ffmpegNameStr, "mp4", chapsPath)
+}
+
+// TsToContainer remuxes a TS into the given container ("mp4" or "mkv") using
+// ffmpeg, adding the chapters in chapsPath unless it's empty
+func TsToContainer(VidPathTs, vidPath, ffmpegNameStr, container, chapsPath string) error {
+	cmd := exec.Command(ffmpegNameStr, remuxArgs(VidPathTs, vidPath, container, chapsPath)...)
+	stderr, err := runFfmpeg(cmd)
+	if err != nil {
+		errString := fmt.Sprintf("%s\n%s", err, stderr)
+		return errors.New(errString)
 	}
 
-	cmd := exec.Command(ffmpegNameStr, args...)
-	cmd.Stderr = &errBuffer
+	return nil
+}
 
-	err := cmd.Run()
+// remuxArgs builds the ffmpeg arguments for TsToContainer
+func remuxArgs(VidPathTs, vidPath, container, chapsPath string) []string {
+	args := []string{"-hide_banner", "-i", VidPathTs}
+	if chapsPath != "" {
+		args = append(args, "-f", "ffmetadata", "-i", chapsPath, "-map_metadata", "1")
+	}
+	args = append(args, "-c", "copy")
+	if container == "mkv" {
+		args = append(args, "-f", "matroska")
+	}
+	return append(args, vidPath)
+}
+
+// TsToAudio extracts the audio stream of a TS into an M4A without re-encoding
+func TsToAudio(VidPathTs, audioPath, ffmpegNameStr, chapsPath string) error {
+	cmd := exec.Command(ffmpegNameStr, tsToAudioArgs(VidPathTs, audioPath, chapsPath)...)
+	stderr, err := runFfmpeg(cmd)
 	if err != nil {
-		errString := fmt.Sprintf("%s\n%s", err, errBuffer.String())
+		errString := fmt.Sprintf("%s\n%s", err, stderr)
 		return errors.New(errString)
 	}
 
 	return nil
 }
 
+// tsToAudioArgs builds the ffmpeg arguments for TsToAudio
+func tsToAudioArgs(VidPathTs, audioPath, chapsPath string) []string {
+	args := []string{"-hide_banner", "-i", VidPathTs}
+	if chapsPath != "" {
+		args = append(args, "-f", "ffmetadata", "-i", chapsPath, "-map_metadata", "1")
+	}
+	return append(args, "-map", "0:a:0", "-vn", "-c:a", "copy", audioPath)
+}
+
+// ConvertAudio re-encodes a downloaded track to the given target format
+// ("wav") using ffmpeg, writing whatever tags the target container supports
+func ConvertAudio(inPath, outPath, ffmpegNameStr, target string, bitDepth int, metadata *models.TrackMetadata) error {
+	args, err := convertArgs(inPath, outPath, target, bitDepth, metadata)
+	if err != nil {
+		return err
+	}
+
+	cmd := exec.Command(ffmpegNameStr, args...)
+	stderr, err := runFfmpeg(cmd)
+	if err != nil {
+		errString := fmt.Sprintf("ffmpeg conversion failed: %s\n%s", err, stderr)
+		return errors.New(errString)
+	}
+
+	return nil
+}
+
+// convertArgs builds the ffmpeg arguments for ConvertAudio
+func convertArgs(inPath, outPath, target string, bitDepth int, metadata *models.TrackMetadata) ([]string, error) {
+	args := []string{"-hide_banner", "-i", inPath, "-map", "0:a:0"}
+	args = append(args, audioTagArgs(outPath, metadata)...)
+
+	switch target {
+	case "wav":
+		// Keep 24-bit sources at full depth, ffmpeg defaults WAV to 16-bit.
+		codec := "pcm_s16le"
+		if bitDepth > 16 {
+			codec = "pcm_s24le"
+		}
+		args = append(args, "-c:a", codec)
+	default:
+		return nil, fmt.Errorf("unsupported conversion target: %s", target)
+	}
+
+	return append(args, outPath), nil
+}
+
 // Sanitise sanitizes filename for filesystem
 func Sanitise(filename string) string {
 	san := regexp.MustCompile(`[\/:*?"><|]`).ReplaceAllString(filename, "_")
+	// A leading dash would be read as an option when the path is passed to ffmpeg
+	if strings.HasPrefix(san, "-") {
+		san = "_" + san[1:]
+	}
 	return strings.TrimSuffix(san, "\t")
 }
 
@@ -837,35 +1059,16 @@ func TagAudioFile(inputPath, outputPath, ffmpegNameStr string, metadata *models.
 	// Base arguments
 	args = append(args, "-hide_banner", "-i", inputPath)
 
-	// Add metadata flags (only if metadata is not nil)
-	if metadata != nil {
-		if metadata.Title != "" {
-			args = append(args, "-metadata", "title="+metadata.Title)
-		}
-		if metadata.Artist != "" {
-			args = append(args, "-metadata", "artist="+metadata.Artist)
-		}
-		if metadata.Album != "" {
-			args = append(args, "-metadata", "album="+metadata.Album)
-		}
-		if metadata.TrackNum > 0 {
-			args = append(args, "-metadata", fmt.Sprintf("track=%d", metadata.TrackNum))
-		}
-		if metadata.Year != "" {
-			args = append(args, "-metadata", "year="+metadata.Year)
-		}
-	}
+	// Add metadata flags
+	args = append(args, audioTagArgs(outputPath, metadata)...)
 
 	// Copy codecs without re-encoding
 	args = append(args, "-c", "copy", outputPath)
 
-	var errBuffer bytes.Buffer
 	cmd := exec.Command(ffmpegNameStr, args...)
-	cmd.Stderr = &errBuffer
-
-	err := cmd.Run()
+	stderr, err := runFfmpeg(cmd)
 	if err != nil {
-		errString := fmt.Sprintf("ffmpeg tagging failed: %s\n%s", err, errBuffer.String())
+		errString := fmt.Sprintf("ffmpeg tagging failed: %s\n%s", err, stderr)
 		return errors.New(errString)
 	}
 
@@ -895,24 +1098,86 @@ func TagVideoFile(inputPath, outputPath, ffmpegNameStr string, metadata *models.
 	// Copy codecs without re-encoding
 	args = append(args, "-c", "copy", outputPath)
 
-	var errBuffer bytes.Buffer
 	cmd := exec.Command(ffmpegNameStr, args...)
-	cmd.Stderr = &errBuffer
+	stderr, err := runFfmpeg(cmd)
+	if err != nil {
+		errString := fmt.Sprintf("ffmpeg video tagging failed: %s\n%s", err, stderr)
+		return errors.New(errString)
+	}
 
-	err := cmd.Run()
+	return nil
+}
+
+// EmbedArtwork embeds images in a tagged track as attached pictures, copying
+// the audio without re-encoding
+func EmbedArtwork(inputPath, outputPath, ffmpegNameStr string, artwork []models.Artwork) error {
+	cmd := exec.Command(ffmpegNameStr, artworkArgs(inputPath, outputPath, artwork)...)
+	stderr, err := runFfmpeg(cmd)
+	if err != nil {
+		errString := fmt.Sprintf("ffmpeg artwork embedding failed: %s\n%s", err, stderr)
+		return errors.New(errString)
+	}
+
+	return nil
+}
+
+// artworkArgs builds the ffmpeg arguments for EmbedArtwork. Each image is its
+// own input, mapped as an attached picture whose comment sets its picture type.
+func artworkArgs(inputPath, outputPath string, artwork []models.Artwork) []string {
+	args := []string{"-hide_banner", "-i", inputPath}
+	for _, art := range artwork {
+		args = append(args, "-i", art.Path)
+	}
+
+	args = append(args, "-map", "0:a")
+	for i, art := range artwork {
+		args = append(args,
+			"-map", strconv.Itoa(i+1),
+			fmt.Sprintf("-disposition:v:%d", i), "attached_pic",
+			fmt.Sprintf("-metadata:s:v:%d", i), "comment="+art.Type,
+		)
+	}
+
+	return append(args, "-c", "copy", outputPath)
+}
+
+// EmbedPoster adds a poster image to a video or audio file as its cover,
+// copying the other streams without re-encoding
+func EmbedPoster(inputPath, outputPath, posterPath, ffmpegNameStr string, audioOnly bool) error {
+	cmd := exec.Command(ffmpegNameStr, posterArgs(inputPath, outputPath, posterPath, audioOnly)...)
+	stderr, err := runFfmpeg(cmd)
 	if err != nil {
-		errString := fmt.Sprintf("ffmpeg video tagging failed: %s\n%s", err, errBuffer.String())
+		errString := fmt.Sprintf("ffmpeg poster embedding failed: %s\n%s", err, stderr)
 		return errors.New(errString)
 	}
 
 	return nil
 }
 
+// posterArgs builds the ffmpeg arguments for EmbedPoster. The poster goes
+// after the input's own streams, so it's the second video stream of a video
+// and the first of an audio file.
+func posterArgs(inputPath, outputPath, posterPath string, audioOnly bool) []string {
+	posterStream := 1
+	if audioOnly {
+		posterStream = 0
+	}
+	return []string{
+		"-hide_banner", "-i", inputPath, "-i", posterPath,
+		"-map", "0", "-map", "1", "-c", "copy",
+		fmt.Sprintf("-disposition:v:%d", posterStream), "attached_pic",
+		outputPath,
+	}
+}
+
 // DownloadTrackWithMetadata downloads a track, adds metadata, and supports automatic resume.
 // This function can resume interrupted downloads by detecting existing partial files
 // and sending Range requests for remaining bytes. Resume state is automatically
 // managed and cleaned up upon successful completion.
 func (d *Downloader) DownloadTrackWithMetadata(trackPath, url string, metadata *models.TrackMetadata, ffmpegNameStr string) error {
+	ctx, cancel := d.trackContext()
+	defer cancel()
+
 	// Check for existing resume state
 	resumeState, err := d.resumeManager.LoadState(trackPath)
 	if err != nil {
@@ -923,7 +1188,7 @@ func (d *Downloader) DownloadTrackWithMetadata(trackPath, url string, metadata *
 	if resumeState != nil {
 		if err := d.resumeManager.ValidatePartialDownload(resumeState); err == nil {
 			fmt.Printf("Resuming download from byte %d...\n", resumeState.DownloadedSize)
-			return d.resumeTrackDownload(trackPath, url, resumeState, metadata, ffmpegNameStr)
+			return d.trackTimedOut(ctx, d.resumeTrackDownload(ctx, trackPath, url, resumeState, metadata, ffmpegNameStr))
 		} else {
 			// Resume state is invalid, clean it up and start fresh
 			fmt.Printf("Resume state invalid (%v), starting fresh download...\n", err)
@@ -932,39 +1197,37 @@ func (d *Downloader) DownloadTrackWithMetadata(trackPath, url string, metadata *
 	}
 
 	// Start fresh download
-	return d.downloadTrackFresh(trackPath, url, metadata, ffmpegNameStr)
+	return d.trackTimedOut(ctx, d.downloadTrackFresh(ctx, trackPath, url, metadata, ffmpegNameStr))
 }
 
 // downloadTrackFresh performs a fresh track download without resume
-func (d *Downloader) downloadTrackFresh(trackPath, url string, metadata *models.TrackMetadata, ffmpegNameStr string) error {
+func (d *Downloader) downloadTrackFresh(ctx context.Context, trackPath, url string, metadata *models.TrackMetadata, ffmpegNameStr string) error {
 	// Download to temporary file first
-	tempPath := trackPath + ".tmp"
+	tempPath := fsutil.TempPathFor(trackPath)
 	f, err := fsutil.OpenFile(tempPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
 	if err != nil {
 		return models.NewDownloadError(models.ErrFileSystem, "Cannot create temporary file", "Check write permissions for the download directory", false, err)
 	}
 	defer f.Close()
 
-	resp, err := d.apiClient.DownloadFile(url, "https://play.nugs.net/")
+	resp, err := d.apiClient.DownloadFileContext(ctx, url, d.apiClient.PlayerReferer())
 	if err != nil {
 		os.Remove(tempPath) // Clean up on error
 		return err
 	}
 	defer resp.Body.Close()
+	d.recordLastModified(trackPath, resp.Header)
 
 	totalBytes := resp.ContentLength
 
 	// Create resume state for tracking
 	resumeState := d.resumeManager.CreateInitialState(trackPath, url, totalBytes, resp.Header.Get("ETag"))
+	resumeState.TempPath = tempPath
 	if err := d.resumeManager.SaveState(resumeState); err != nil {
 		fmt.Printf("Warning: failed to save resume state: %v\n", err)
 	}
 
-	counter := &models.WriteCounter{
-		Total:     totalBytes,
-		TotalStr:  humanize.Bytes(uint64(totalBytes)),
-		StartTime: time.Now().UnixMilli(),
-	}
+	counter := d.newWriteCounter(trackPath, totalBytes, 0)
 
 	// Download with progress tracking and resume state updates
 	buf := make([]byte, 32*1024) // 32KB buffer
@@ -981,7 +1244,11 @@ func (d *Downloader) downloadTrackFresh(trackPath, url string, metadata *models.
 			}
 
 			totalDownloaded += int64(n)
-			counter.Downloaded = totalDownloaded
+			if _, limitErr := counter.Write(buf[:n]); limitErr != nil {
+				f.Close()
+				d.resumeManager.DeleteState(trackPath)
+				return keepSample(tempPath, trackPath)
+			}
 
 			// Update resume state periodically (every 1MB)
 			if totalDownloaded%1024*1024 == 0 {
@@ -1016,13 +1283,13 @@ func (d *Downloader) downloadTrackFresh(trackPath, url string, metadata *models.
 	// Tag the file with metadata
 	err = TagAudioFile(tempPath, trackPath, ffmpegNameStr, metadata)
 	if err != nil {
-		os.Remove(tempPath) // Clean up on error
+		d.removeTemp(tempPath) // Clean up on error
 		d.resumeManager.DeleteState(trackPath)
 		return err
 	}
 
 	// Remove temp file and resume state (download complete)
-	err = os.Remove(tempPath)
+	err = d.removeTemp(tempPath)
 	if err != nil {
 		fmt.Printf("Warning: failed to remove temp file %s: %v\n", tempPath, err)
 	}
@@ -1033,8 +1300,12 @@ func (d *Downloader) downloadTrackFresh(trackPath, url string, metadata *models.
 }
 
 // resumeTrackDownload resumes a partial track download with enhanced error handling
-func (d *Downloader) resumeTrackDownload(trackPath, url string, resumeState *ResumeState, metadata *models.TrackMetadata, ffmpegNameStr string) error {
-	tempPath := trackPath + ".tmp"
+func (d *Downloader) resumeTrackDownload(ctx context.Context, trackPath, url string, resumeState *ResumeState, metadata *models.TrackMetadata, ffmpegNameStr string) error {
+	tempPath := resumeState.TempPath
+	if tempPath == "" {
+		// Saved before temporary files got names of their own
+		tempPath = trackPath + ".tmp"
+	}
 
 	// Check if temp file exists and is valid
 	if stat, err := os.Stat(tempPath); err != nil {
@@ -1042,7 +1313,7 @@ func (d *Downloader) resumeTrackDownload(trackPath, url string, resumeState *Res
 			// Temp file missing, start fresh
 			fmt.Println("Temporary file missing, starting fresh download...")
 			d.resumeManager.DeleteState(trackPath)
-			return d.downloadTrackFresh(trackPath, url, metadata, ffmpegNameStr)
+			return d.downloadTrackFresh(ctx, trackPath, url, metadata, ffmpegNameStr)
 		}
 		return models.NewDownloadError(models.ErrFileSystem, "Cannot access temporary file", "Check file permissions", false, err)
 	} else if stat.Size() != resumeState.DownloadedSize {
@@ -1051,7 +1322,7 @@ func (d *Downloader) resumeTrackDownload(trackPath, url string, resumeState *Res
 			resumeState.DownloadedSize, stat.Size())
 		os.Remove(tempPath)
 		d.resumeManager.DeleteState(trackPath)
-		return d.downloadTrackFresh(trackPath, url, metadata, ffmpegNameStr)
+		return d.downloadTrackFresh(ctx, trackPath, url, metadata, ffmpegNameStr)
 	}
 
 	// Check for file corruption using checksum if available
@@ -1061,7 +1332,7 @@ func (d *Downloader) resumeTrackDownload(trackPath, url string, resumeState *Res
 				fmt.Println("Partial file checksum mismatch, starting fresh download...")
 				os.Remove(tempPath)
 				d.resumeManager.DeleteState(trackPath)
-				return d.downloadTrackFresh(trackPath, url, metadata, ffmpegNameStr)
+				return d.downloadTrackFresh(ctx, trackPath, url, metadata, ffmpegNameStr)
 			}
 		}
 	}
@@ -1079,7 +1350,9 @@ func (d *Downloader) resumeTrackDownload(trackPath, url string, resumeState *Res
 		headers["If-Match"] = resumeState.ETag
 	}
 
-	resp, err := SendRangeRequest(d.apiClient.GetHTTPClient(), url, resumeState.DownloadedSize, headers)
+	resp, err := d.apiClient.LimitDownload(ctx, func() (*http.Response, error) {
+		return SendRangeRequest(ctx, d.apiClient.GetHTTPClient(), url, resumeState.DownloadedSize, headers)
+	})
 	if err != nil {
 		// Check if it's an ETag mismatch (file changed on server)
 		if strings.Contains(err.Error(), "412") || strings.Contains(err.Error(), "Precondition Failed") {
@@ -1087,7 +1360,7 @@ func (d *Downloader) resumeTrackDownload(trackPath, url string, resumeState *Res
 			f.Close()
 			os.Remove(tempPath)
 			d.resumeManager.DeleteState(trackPath)
-			return d.downloadTrackFresh(trackPath, url, metadata, ffmpegNameStr)
+			return d.downloadTrackFresh(ctx, trackPath, url, metadata, ffmpegNameStr)
 		}
 
 		// Range requests not supported, start fresh
@@ -1095,28 +1368,36 @@ func (d *Downloader) resumeTrackDownload(trackPath, url string, resumeState *Res
 		f.Close()
 		os.Remove(tempPath)
 		d.resumeManager.DeleteState(trackPath)
-		return d.downloadTrackFresh(trackPath, url, metadata, ffmpegNameStr)
+		return d.downloadTrackFresh(ctx, trackPath, url, metadata, ffmpegNameStr)
 	}
 	defer resp.Body.Close()
+	d.recordLastModified(trackPath, resp.Header)
 
 	// Validate response headers for file changes
 	if resp.Header.Get("ETag") != "" && resumeState.ETag != "" {
 		if resp.Header.Get("ETag") != resumeState.ETag {
 			fmt.Println("Remote file has changed (ETag mismatch), starting fresh download...")
+			// Free this download's slot before the fresh one takes another
+			resp.Body.Close()
 			f.Close()
 			os.Remove(tempPath)
 			d.resumeManager.DeleteState(trackPath)
-			return d.downloadTrackFresh(trackPath, url, metadata, ffmpegNameStr)
+			return d.downloadTrackFresh(ctx, trackPath, url, metadata, ffmpegNameStr)
 		}
 	}
 
-	counter := &models.WriteCounter{
-		Total:      resumeState.TotalSize,
-		TotalStr:   humanize.Bytes(uint64(resumeState.TotalSize)),
-		StartTime:  time.Now().UnixMilli(),
-		Downloaded: resumeState.DownloadedSize,
+	// A full response would be appended to the bytes already there
+	if resp.StatusCode == http.StatusOK {
+		fmt.Println("Server ignored the resume request, downloading the track again from the start...")
+		if _, err := resumeOffset(f, resumeState.DownloadedSize, resp.StatusCode); err != nil {
+			return models.NewDownloadError(models.ErrFileSystem, "Cannot reset temporary file", "Check file permissions", false, err)
+		}
+		resumeState.DownloadedSize = 0
+		resumeState.Checksum = ""
 	}
 
+	counter := d.newWriteCounter(trackPath, resumeState.TotalSize, resumeState.DownloadedSize)
+
 	// Download remaining bytes with progress tracking and disk space monitoring
 	buf := make([]byte, 32*1024) // 32KB buffer
 	totalDownloaded := resumeState.DownloadedSize
@@ -1127,7 +1408,7 @@ func (d *Downloader) resumeTrackDownload(trackPath, url string, resumeState *Res
 		if n > 0 {
 			// Check disk space periodically (every 5 seconds)
 			if time.Since(lastDiskCheck) > 5*time.Second {
-				if err := CheckDiskSpace(trackPath, resumeState.TotalSize-totalDownloaded); err != nil {
+				if err := CheckDiskSpace(trackPath, resumeState.TotalSize-totalDownloaded, d.diskMargin()); err != nil {
 					f.Close()
 					os.Remove(tempPath)
 					d.resumeManager.DeleteState(trackPath)
@@ -1145,7 +1426,11 @@ func (d *Downloader) resumeTrackDownload(trackPath, url string, resumeState *Res
 			}
 
 			totalDownloaded += int64(n)
-			counter.Downloaded = totalDownloaded
+			if _, limitErr := counter.Write(buf[:n]); limitErr != nil {
+				f.Close()
+				d.resumeManager.DeleteState(trackPath)
+				return keepSample(tempPath, trackPath)
+			}
 
 			// Update resume state periodically (every 1MB)
 			if totalDownloaded%1024*1024 == 0 {
@@ -1192,13 +1477,13 @@ func (d *Downloader) resumeTrackDownload(trackPath, url string, resumeState *Res
 	// Tag the file with metadata
 	err = TagAudioFile(tempPath, trackPath, ffmpegNameStr, metadata)
 	if err != nil {
-		os.Remove(tempPath) // Clean up on error
+		d.removeTemp(tempPath) // Clean up on error
 		d.resumeManager.DeleteState(trackPath)
 		return err
 	}
 
 	// Remove temp file and resume state (download complete)
-	err = os.Remove(tempPath)
+	err = d.removeTemp(tempPath)
 	if err != nil {
 		fmt.Printf("Warning: failed to remove temp file %s: %v\n", tempPath, err)
 	}
@@ -1219,48 +1504,45 @@ func FileExists(path string) (bool, error) {
 	return false, err
 }
 
-// CheckDiskSpace checks if there's enough disk space for a download
-func CheckDiskSpace(path string, requiredBytes int64) error {
+// CheckDiskSpace checks that the filesystem holding path has room for
+// requiredBytes plus a safety margin. Platforms that can't report free space
+// are let through.
+func CheckDiskSpace(path string, requiredBytes, marginBytes int64) error {
 	dir := filepath.Dir(path)
 
-	// Cross-platform disk space check - try to create a test file
-	testFile := filepath.Join(dir, ".disk_space_test.tmp")
-	f, err := os.Create(testFile)
+	available, err := fsutil.FreeSpace(dir)
+	if errors.Is(err, fsutil.ErrFreeSpaceUnsupported) {
+		return nil
+	}
 	if err != nil {
 		return models.NewDownloadError(models.ErrFileSystem, "Cannot check disk space", "Ensure the download directory is accessible and writable", false, err)
 	}
-	defer os.Remove(testFile)
-	defer f.Close()
-
-	// For large files, we can't easily check exact space, so we'll do a basic check
-	// This is a simplified approach that works cross-platform
-	if requiredBytes > 100*1024*1024 { // 100MB threshold
-		// Try to allocate some space as a basic check
-		testSize := 1024 * 1024 // 1MB test
-		data := make([]byte, testSize)
-		for i := range data {
-			data[i] = 0
-		}
 
-		if _, err := f.Write(data); err != nil {
-			return models.NewDownloadError(models.ErrDiskSpace, "Insufficient disk space for download", "Free up disk space or choose a different download location", false, err)
-		}
+	needed := uint64(requiredBytes + marginBytes)
+	if available < needed {
+		msg := fmt.Sprintf("Insufficient disk space for download: need %s, %s available", humanize.Bytes(needed), humanize.Bytes(available))
+		return models.NewDownloadError(models.ErrDiskSpace, msg, "Free up disk space or choose a different download location", false, nil)
 	}
 
 	return nil
 }
 
+// diskMargin returns the configured free space safety margin in bytes
+func (d *Downloader) diskMargin() int64 {
+	return int64(d.config.MinFreeSpace) * 1024 * 1024
+}
+
 // SafeDownloadTrack performs robust track download with error recovery
 func (d *Downloader) SafeDownloadTrack(trackPath, url string, expectedSize int64) error {
 	// Check disk space first
 	if expectedSize > 0 {
-		if err := CheckDiskSpace(trackPath, expectedSize); err != nil {
+		if err := CheckDiskSpace(trackPath, expectedSize, d.diskMargin()); err != nil {
 			return err
 		}
 	}
 
 	// Use temporary file for atomic writes
-	tempPath := trackPath + ".tmp"
+	tempPath := fsutil.TempPathFor(trackPath)
 	defer func() {
 		// Clean up temp file if it still exists
 		if _, err := os.Stat(tempPath); err == nil {
@@ -1275,12 +1557,16 @@ func (d *Downloader) SafeDownloadTrack(trackPath, url string, expectedSize int64
 	}
 	defer f.Close()
 
+	ctx, cancel := d.trackContext()
+	defer cancel()
+
 	// Download with retry logic
-	resp, err := d.downloadFileWithRetry(url, "https://play.nugs.net/")
+	resp, err := d.downloadFileWithRetry(ctx, url, d.apiClient.PlayerReferer())
 	if err != nil {
-		return err
+		return d.trackTimedOut(ctx, err)
 	}
 	defer resp.Body.Close()
+	d.recordLastModified(trackPath, resp.Header)
 
 	// Track download progress
 	totalBytes := resp.ContentLength
@@ -1288,17 +1574,20 @@ func (d *Downloader) SafeDownloadTrack(trackPath, url string, expectedSize int64
 		totalBytes = expectedSize
 	}
 
-	counter := &models.WriteCounter{
-		Total:     totalBytes,
-		TotalStr:  humanize.Bytes(uint64(totalBytes)),
-		StartTime: time.Now().UnixMilli(),
-	}
+	counter := d.newWriteCounter(trackPath, totalBytes, 0)
 
 	// Copy with error handling
 	_, err = io.Copy(f, io.TeeReader(resp.Body, counter))
+	if errors.Is(err, models.ErrSampleLimit) {
+		f.Close()
+		return keepSample(tempPath, trackPath)
+	}
 	fmt.Println("")
 
 	if err != nil {
+		if timeoutErr := d.trackTimedOut(ctx, err); timeoutErr != err {
+			return timeoutErr
+		}
 		// Check for specific error types
 		if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
 			return models.NewDownloadError(models.ErrTimeout, "Download timeout", "Check your internet connection and try again", true, err)
@@ -1332,7 +1621,7 @@ func (d *Downloader) SafeDownloadTrack(trackPath, url string, expectedSize int64
 }
 
 // downloadFileWithRetry downloads a file with retry logic
-func (d *Downloader) downloadFileWithRetry(url, referer string) (*http.Response, error) {
+func (d *Downloader) downloadFileWithRetry(ctx context.Context, url, referer string) (*http.Response, error) {
 	const maxRetries = 3
 	const baseDelay = time.Second
 
@@ -1345,14 +1634,23 @@ func (d *Downloader) downloadFileWithRetry(url, referer string) (*http.Response,
 			time.Sleep(delay)
 		}
 
-		resp, err := d.apiClient.DownloadFile(url, referer)
+		resp, err := d.apiClient.DownloadFileContext(ctx, url, referer)
 		if err == nil {
 			return resp, nil
 		}
 
 		lastErr = err
+		if ctx.Err() != nil {
+			// Out of time for this track
+			break
+		}
 
 		// Check if error is retryable
+		var statusErr *api.StatusError
+		if errors.As(err, &statusErr) && statusErr.StatusCode < http.StatusInternalServerError {
+			// Missing or forbidden files won't appear on a retry
+			break
+		}
 		if netErr, ok := err.(net.Error); ok {
 			if !netErr.Timeout() && !netErr.Temporary() {
 				// Non-retryable error
@@ -1428,29 +1726,60 @@ func ParseFFmpegError(err error, stderr string) (models.ErrorType, string, strin
 	return models.ErrFFmpeg, "FFmpeg processing failed", "Check FFmpeg installation and file integrity"
 }
 
-// CleanupTempFiles removes temporary files that may be left behind
-func CleanupTempFiles(basePath string) error {
-	patterns := []string{
-		"*.tmp",
-		"temp_enc.ts",
-		"chapters_nugs_dl_tmp.txt",
-		"*_tagged.*",
+// removeTemp deletes a temporary file, unless --no-clean keeps them
+func (d *Downloader) removeTemp(path string) error {
+	if d.config.NoClean {
+		return nil
+	}
+	return os.Remove(path)
+}
+
+// discardEncrypted deletes the encrypted TS of an HLS track once it's been
+// decrypted. --no-clean keeps it next to the track instead, where it's easy to
+// find, or in the temp folder if it can't be moved there.
+func (d *Downloader) discardEncrypted(encPath, trackPath string) error {
+	if d.config.NoClean {
+		if err := os.Rename(encPath, trackPath+".enc.ts"); err != nil {
+			fmt.Println("Kept encrypted TS:", encPath)
+		}
+		return nil
+	}
+	return os.Remove(encPath)
+}
+
+// TempPath returns a unique path in the temp folder for a temporary file
+// named after pattern, as with fsutil.TempPath
+func (d *Downloader) TempPath(pattern string) string {
+	return fsutil.TempPath(d.config.TempDir, pattern)
+}
+
+// CleanupTempFiles removes temporary files left behind by runs that were cut
+// short: partial downloads in albumPath, and encrypted TS and chapter files in
+// tempDir, where the downloader creates them. Files younger than their cutoff
+// may still be in use, or be resumed, so they're kept.
+func CleanupTempFiles(albumPath, tempDir string) error {
+	globs := []struct {
+		dir, pattern string
+		maxAge       time.Duration
+	}{
+		// Partial downloads can be resumed for as long as their resume state is valid
+		{albumPath, "*.tmp", 24 * time.Hour},
+		{albumPath, "*_tagged.*", time.Hour},
+		{tempDir, "temp_enc_*.ts", time.Hour},
+		{tempDir, "chapters_nugs_dl_*.txt", time.Hour},
 	}
 
 	var lastErr error
-	for _, pattern := range patterns {
-		matches, err := filepath.Glob(filepath.Join(filepath.Dir(basePath), pattern))
+	for _, glob := range globs {
+		matches, err := filepath.Glob(filepath.Join(glob.dir, glob.pattern))
 		if err != nil {
 			lastErr = err
 			continue
 		}
 
 		for _, match := range matches {
-			// Only remove files older than 1 hour to avoid deleting active downloads
-			if stat, err := os.Stat(match); err == nil {
-				if time.Since(stat.ModTime()) > time.Hour {
-					os.Remove(match)
-				}
+			if stat, err := os.Stat(match); err == nil && time.Since(stat.ModTime()) > glob.maxAge {
+				os.Remove(match)
 			}
 		}
 	}
@@ -1,17 +1,28 @@
 package downloader
 
 import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/hex"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/grafov/m3u8"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/suite"
 	"main/pkg/api"
 	"main/pkg/config"
+	"main/pkg/fsutil"
+	"main/pkg/logger"
 	"main/pkg/models"
 )
 
@@ -71,6 +82,14 @@ func (suite *DownloaderTestSuite) handleRequest(w http.ResponseWriter, r *http.R
 		suite.handleAudioM3U8Playlist(w, r)
 	case "/media.m3u8":
 		suite.handleMediaPlaylist(w, r)
+	case "/media_single.m3u8":
+		suite.handleSingleSegmentPlaylist(w, r)
+	case "/media_sample_aes.m3u8":
+		suite.handleSampleAesPlaylist(w, r)
+	case "/media_track.m3u8":
+		suite.handleTrackPlaylist(w, r)
+	case "/track_segment.ts":
+		suite.handleTrackSegment(w, r)
 	case "/key":
 		suite.handleKey(w, r)
 	case "/segment.ts":
@@ -126,6 +145,51 @@ segment2.ts
 	w.Write([]byte(playlist))
 }
 
+func (suite *DownloaderTestSuite) handleSingleSegmentPlaylist(w http.ResponseWriter, r *http.Request) {
+	playlist := `#EXTM3U
+#EXT-X-VERSION:3
+#EXT-X-TARGETDURATION:10
+#EXTINF:9.9,
+segment1.ts
+#EXT-X-ENDLIST
+`
+	w.Header().Set("Content-Type", "application/vnd.apple.mpegurl")
+	w.Write([]byte(playlist))
+}
+
+func (suite *DownloaderTestSuite) handleSampleAesPlaylist(w http.ResponseWriter, r *http.Request) {
+	playlist := `#EXTM3U
+#EXT-X-VERSION:5
+#EXT-X-TARGETDURATION:10
+#EXT-X-KEY:METHOD=SAMPLE-AES,URI="/key",IV=0x1234567890abcdef1234567890abcdef,KEYFORMAT="identity"
+#EXTINF:9.9,
+segment.ts
+#EXT-X-ENDLIST
+`
+	w.Header().Set("Content-Type", "application/vnd.apple.mpegurl")
+	w.Write([]byte(playlist))
+}
+
+// handleTrackPlaylist serves an HLS-only track whose segment is filled with
+// the track number in the query
+func (suite *DownloaderTestSuite) handleTrackPlaylist(w http.ResponseWriter, r *http.Request) {
+	playlist := `#EXTM3U
+#EXT-X-VERSION:3
+#EXT-X-TARGETDURATION:10
+#EXT-X-KEY:METHOD=AES-128,URI="key",IV=0x1234567890abcdef1234567890abcdef
+#EXTINF:9.9,
+track_segment.ts
+#EXT-X-ENDLIST
+`
+	w.Header().Set("Content-Type", "application/vnd.apple.mpegurl")
+	w.Write([]byte(playlist))
+}
+
+func (suite *DownloaderTestSuite) handleTrackSegment(w http.ResponseWriter, r *http.Request) {
+	track, _ := strconv.Atoi(r.URL.Query().Get("track"))
+	w.Write(bytes.Repeat([]byte{byte(track)}, 64*1024))
+}
+
 func (suite *DownloaderTestSuite) handleKey(w http.ResponseWriter, r *http.Request) {
 	// Return a 16-byte key
 	key := []byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0a, 0x0b, 0x0c, 0x0d, 0x0e, 0x0f, 0x10}
@@ -191,6 +255,30 @@ func (suite *DownloaderTestSuite) TestGetTrackQual() {
 	assert.Nil(suite.T(), result)
 }
 
+// TestGetTrackQualWithFallback tests falling back to the next best format
+func (suite *DownloaderTestSuite) TestGetTrackQualWithFallback() {
+	quals := []*models.Quality{
+		{Format: 2, Specs: "FLAC"},
+		{Format: 5, Specs: "AAC"},
+	}
+
+	// MQA falls back to FLAC
+	result, format := GetTrackQualWithFallback(quals, 3)
+	assert.NotNil(suite.T(), result)
+	assert.Equal(suite.T(), 2, format)
+	assert.Equal(suite.T(), "FLAC", result.Specs)
+
+	// Neither 360 RA nor any of its fallbacks are available, so this must stop
+	quals = []*models.Quality{{Format: 6, Specs: "Unknown"}}
+	result, _ = GetTrackQualWithFallback(quals, 4)
+	assert.Nil(suite.T(), result)
+
+	// Formats without a fallback entry stop immediately
+	result, format = GetTrackQualWithFallback(quals, 0)
+	assert.Nil(suite.T(), result)
+	assert.Equal(suite.T(), 0, format)
+}
+
 // TestCheckIfHlsOnly tests HLS-only detection
 func (suite *DownloaderTestSuite) TestCheckIfHlsOnly() {
 	// Test HLS-only qualities
@@ -220,6 +308,59 @@ func (suite *DownloaderTestSuite) TestParseHlsMaster() {
 	assert.Contains(suite.T(), quality.URL, "audio_256k_v1.m3u8")
 }
 
+// TestParseHlsMaster_AacBitrate tests picking the closest AAC bitrate at or below the wanted one
+func (suite *DownloaderTestSuite) TestParseHlsMaster_AacBitrate() {
+	suite.config.AacBitrate = 192
+	quality := &models.Quality{URL: suite.server.URL + "/audio_playlist.m3u8"}
+	suite.Require().NoError(suite.downloader.ParseHlsMaster(quality))
+	assert.Equal(suite.T(), "128 Kbps AAC", quality.Specs)
+	assert.Contains(suite.T(), quality.URL, "audio_128k_v1.m3u8")
+
+	variants := []*m3u8.Variant{{URI: "audio_256k_v1.m3u8"}, {URI: "audio_128k_v1.m3u8"}}
+	assert.Equal(suite.T(), "audio_256k_v1.m3u8", chooseAudioVariant(variants, 0).URI)
+	assert.Equal(suite.T(), "audio_256k_v1.m3u8", chooseAudioVariant(variants, 256).URI)
+	assert.Equal(suite.T(), "audio_128k_v1.m3u8", chooseAudioVariant(variants, 64).URI)
+}
+
+// TestParseHlsMaster_MinBitrate tests rejecting streams below the minimum bitrate
+func (suite *DownloaderTestSuite) TestParseHlsMaster_MinBitrate() {
+	suite.config.MinBitrate = 256
+	quality := &models.Quality{URL: suite.server.URL + "/audio_playlist.m3u8"}
+	suite.Require().NoError(suite.downloader.ParseHlsMaster(quality))
+	assert.Equal(suite.T(), "256 Kbps AAC", quality.Specs)
+
+	// The AAC bitrate cap picks 128 Kbps, which is below the minimum
+	suite.config.AacBitrate = 192
+	suite.config.MinBitrate = 160
+	quality = &models.Quality{URL: suite.server.URL + "/audio_playlist.m3u8"}
+	err := suite.downloader.ParseHlsMaster(quality)
+	assert.ErrorIs(suite.T(), err, models.ErrBelowMinBitrate)
+	assert.Contains(suite.T(), err.Error(), "128 Kbps AAC is below the 160 Kbps minimum")
+}
+
+// TestHlsOnly_SampleAes tests that SAMPLE-AES tracks fail clearly instead of
+// being decrypted as AES-128 into a corrupt file
+func (suite *DownloaderTestSuite) TestHlsOnly_SampleAes() {
+	trackPath := filepath.Join(suite.tempDir, "01. Track.m4a")
+	manUrl := suite.server.URL + "/media_sample_aes.m3u8"
+
+	err := suite.downloader.HlsOnly(trackPath, manUrl, "ffmpeg")
+	assert.ErrorIs(suite.T(), err, models.ErrUnsupportedFormat)
+	assert.Contains(suite.T(), err.Error(), "SAMPLE-AES")
+
+	err = suite.downloader.HlsOnlyWithMetadata(trackPath, manUrl, "ffmpeg", nil)
+	assert.ErrorIs(suite.T(), err, models.ErrUnsupportedFormat)
+	assert.NoFileExists(suite.T(), trackPath)
+}
+
+// TestCheckKeyMethod tests which HLS encryption methods are decrypted
+func (suite *DownloaderTestSuite) TestCheckKeyMethod() {
+	assert.NoError(suite.T(), checkKeyMethod(&m3u8.Key{Method: "AES-128"}))
+	assert.ErrorIs(suite.T(), checkKeyMethod(&m3u8.Key{Method: "SAMPLE-AES"}), models.ErrUnsupportedFormat)
+	assert.ErrorIs(suite.T(), checkKeyMethod(&m3u8.Key{Method: "NONE"}), models.ErrUnsupportedFormat)
+	assert.ErrorIs(suite.T(), checkKeyMethod(nil), models.ErrUnsupportedFormat)
+}
+
 // TestGetManifestBase tests manifest base URL extraction
 func (suite *DownloaderTestSuite) TestGetManifestBase() {
 	manifestURL := "https://stream.example.com/path/to/manifest.m3u8?param=value"
@@ -244,6 +385,14 @@ func (suite *DownloaderTestSuite) TestGetSegUrls() {
 	assert.Contains(suite.T(), segUrls[0], "?param=value")
 }
 
+// TestGetSegUrls_SingleSegment tests a short video whose playlist has one segment
+func (suite *DownloaderTestSuite) TestGetSegUrls_SingleSegment() {
+	segUrls, err := suite.downloader.GetSegUrls(suite.server.URL+"/media_single.m3u8", "?param=value")
+
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), []string{"segment1.ts?param=value"}, segUrls)
+}
+
 // TestChooseVariant tests video variant selection
 func (suite *DownloaderTestSuite) TestChooseVariant() {
 	manifestURL := suite.server.URL + "/playlist.m3u8"
@@ -283,6 +432,183 @@ func (suite *DownloaderTestSuite) TestGetKey() {
 	assert.Len(suite.T(), key, 16)
 }
 
+// TestDecryptTrack tests AES-128-CBC decryption against the mocked key and IV
+func (suite *DownloaderTestSuite) TestDecryptTrack() {
+	key, err := GetKey(suite.server.URL+"/key", suite.apiClient)
+	assert.NoError(suite.T(), err)
+	iv, err := hex.DecodeString("1234567890abcdef1234567890abcdef")
+	assert.NoError(suite.T(), err)
+
+	plaintext := bytes.Repeat([]byte("nugs test audio!"), 8)
+	block, err := aes.NewCipher(key)
+	assert.NoError(suite.T(), err)
+	encData := make([]byte, len(plaintext))
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(encData, plaintext)
+
+	decrypted, err := DecryptTrack(encData, key, iv)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), plaintext, decrypted)
+}
+
+// TestRemuxArgs tests the ffmpeg arguments for each video container
+func (suite *DownloaderTestSuite) TestRemuxArgs() {
+	args := remuxArgs("show.ts", "show.mp4", "mp4", "")
+	assert.Equal(suite.T(), []string{"-hide_banner", "-i", "show.ts", "-c", "copy", "show.mp4"}, args)
+
+	args = remuxArgs("show.ts", "show.mkv", "mkv", "chapters.txt")
+	assert.Equal(suite.T(), []string{
+		"-hide_banner", "-i", "show.ts", "-f", "ffmetadata", "-i", "chapters.txt",
+		"-map_metadata", "1", "-c", "copy", "-f", "matroska", "show.mkv",
+	}, args)
+}
+
+// TestTsToAudioArgs tests the ffmpeg arguments for audio extraction
+func (suite *DownloaderTestSuite) TestTsToAudioArgs() {
+	args := tsToAudioArgs("show.ts", "show.m4a", "")
+	assert.Equal(suite.T(), []string{
+		"-hide_banner", "-i", "show.ts", "-map", "0:a:0", "-vn", "-c:a", "copy", "show.m4a",
+	}, args)
+
+	args = tsToAudioArgs("show.ts", "show.m4a", "chapters.txt")
+	assert.Equal(suite.T(), []string{
+		"-hide_banner", "-i", "show.ts", "-f", "ffmetadata", "-i", "chapters.txt",
+		"-map_metadata", "1", "-map", "0:a:0", "-vn", "-c:a", "copy", "show.m4a",
+	}, args)
+}
+
+// TestConvertArgs tests the ffmpeg arguments for each conversion target
+func (suite *DownloaderTestSuite) TestConvertArgs() {
+	metadata := &models.TrackMetadata{Title: "Tweezer", TrackNum: 3}
+
+	args, err := convertArgs("03. Tweezer.flac", "03. Tweezer.wav", "wav", 16, metadata)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), []string{
+		"-hide_banner", "-i", "03. Tweezer.flac", "-map", "0:a:0",
+		"-metadata", "title=Tweezer", "-metadata", "track=3",
+		"-c:a", "pcm_s16le", "03. Tweezer.wav",
+	}, args)
+
+	args, err = convertArgs("03. Tweezer.flac", "03. Tweezer.wav", "wav", 24, nil)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), []string{
+		"-hide_banner", "-i", "03. Tweezer.flac", "-map", "0:a:0",
+		"-c:a", "pcm_s24le", "03. Tweezer.wav",
+	}, args)
+
+	_, err = convertArgs("03. Tweezer.flac", "03. Tweezer.mp3", "mp3", 16, nil)
+	assert.Error(suite.T(), err)
+}
+
+// TestAudioFilterArgs tests the filter chain for each combination of passes
+func (suite *DownloaderTestSuite) TestAudioFilterArgs() {
+	assert.Nil(suite.T(), audioFilterArgs(AudioFilters{}, 44100))
+
+	args := audioFilterArgs(AudioFilters{TrimSilence: true}, 44100)
+	assert.Equal(suite.T(), []string{
+		"-af", "silenceremove=start_periods=1:start_threshold=-60dB:stop_periods=-1:stop_threshold=-60dB:stop_duration=5",
+	}, args)
+
+	// loudnorm upsamples, so the source rate is kept
+	args = audioFilterArgs(AudioFilters{Normalize: true}, 48000)
+	assert.Equal(suite.T(), []string{"-af", "loudnorm=I=-16:TP=-1.5:LRA=11", "-ar", "48000"}, args)
+
+	args = audioFilterArgs(AudioFilters{TrimSilence: true, Normalize: true}, 0)
+	suite.Require().Len(args, 2)
+	assert.True(suite.T(), strings.HasPrefix(args[1], "silenceremove="))
+	assert.True(suite.T(), strings.HasSuffix(args[1], ",loudnorm=I=-16:TP=-1.5:LRA=11"))
+}
+
+// TestFilterCodecArgs tests that filtered tracks keep their format and depth
+func (suite *DownloaderTestSuite) TestFilterCodecArgs() {
+	tests := []struct {
+		ext      string
+		lossless bool
+		bitDepth int
+		want     []string
+	}{
+		{".flac", true, 16, []string{"-c:a", "flac", "-sample_fmt", "s16"}},
+		{".flac", true, 24, []string{"-c:a", "flac", "-sample_fmt", "s32"}},
+		{".flac", true, 0, []string{"-c:a", "flac", "-sample_fmt", "s32"}},
+		{".wav", true, 16, []string{"-c:a", "pcm_s16le"}},
+		{".wav", true, 24, []string{"-c:a", "pcm_s24le"}},
+		{".m4a", true, 16, []string{"-c:a", "alac", "-sample_fmt", "s16p"}},
+		{".m4a", false, 0, []string{"-c:a", "aac", "-b:a", "256k"}},
+	}
+	for _, tt := range tests {
+		args, ok := filterCodecArgs(tt.ext, tt.lossless, tt.bitDepth)
+		assert.True(suite.T(), ok, tt.ext)
+		assert.Equal(suite.T(), tt.want, args, "%s %d-bit", tt.ext, tt.bitDepth)
+	}
+
+	_, ok := filterCodecArgs(".mp4", false, 0)
+	assert.False(suite.T(), ok)
+
+	args := filterArgs("01. Intro.flac", "01. Intro.filtered.flac", AudioFilters{Normalize: true}, []string{"-c:a", "flac"}, 44100)
+	assert.Equal(suite.T(), []string{
+		"-hide_banner", "-i", "01. Intro.flac", "-map", "0:a:0", "-map_metadata", "0",
+		"-af", "loudnorm=I=-16:TP=-1.5:LRA=11", "-ar", "44100",
+		"-c:a", "flac", "01. Intro.filtered.flac",
+	}, args)
+}
+
+// TestConcatArgs tests joining tracks with the concat demuxer
+func (suite *DownloaderTestSuite) TestConcatArgs() {
+	list, err := concatList([]string{"/music/01. Intro.flac", "/music/02. Don't Stop.flac"})
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "file '/music/01. Intro.flac'\nfile '/music/02. Don'\\''t Stop.flac'\n", list)
+
+	metadata := &models.TrackMetadata{Artist: "Phish", Album: "Live"}
+	args := concatArgs("show.flac.concat.txt", "show.flac", metadata, "FILE \"show.flac\" WAVE")
+	assert.Equal(suite.T(), []string{
+		"-hide_banner", "-f", "concat", "-safe", "0", "-i", "show.flac.concat.txt", "-map", "0:a", "-map_metadata", "-1",
+		"-metadata", "ARTIST=Phish", "-metadata", "ALBUM=Live",
+		"-metadata", "CUESHEET=FILE \"show.flac\" WAVE",
+		"-c:a", "flac", "show.flac",
+	}, args)
+
+	args = concatArgs("show.flac.concat.txt", "show.flac", nil, "")
+	assert.NotContains(suite.T(), args, "CUESHEET=")
+	assert.Equal(suite.T(), "show.flac", args[len(args)-1])
+}
+
+// TestCheckDiskSpace tests the free space guard against the real filesystem
+func (suite *DownloaderTestSuite) TestCheckDiskSpace() {
+	trackPath := filepath.Join(suite.tempDir, "track.flac")
+
+	err := CheckDiskSpace(trackPath, 1024, 0)
+	assert.NoError(suite.T(), err)
+
+	// No filesystem has an exabyte free
+	err = CheckDiskSpace(trackPath, 1<<60, 1024*1024)
+	suite.Require().Error(err)
+	dlErr, ok := err.(*models.DownloadError)
+	suite.Require().True(ok)
+	assert.Equal(suite.T(), models.ErrDiskSpace, dlErr.Type)
+	assert.Contains(suite.T(), dlErr.Message, "available")
+
+	// An unreadable location is a filesystem error, not a space error
+	err = CheckDiskSpace(filepath.Join(suite.tempDir, "missing", "track.flac"), 1024, 0)
+	suite.Require().Error(err)
+	dlErr, ok = err.(*models.DownloadError)
+	suite.Require().True(ok)
+	assert.Equal(suite.T(), models.ErrFileSystem, dlErr.Type)
+}
+
+// TestDecryptTrack_InvalidInput tests that malformed input returns errors instead of panicking
+func (suite *DownloaderTestSuite) TestDecryptTrack_InvalidInput() {
+	key := make([]byte, 16)
+	iv := make([]byte, 16)
+
+	_, err := DecryptTrack(make([]byte, 20), key, iv)
+	assert.Error(suite.T(), err)
+
+	_, err = DecryptTrack(make([]byte, 32), key, iv[:8])
+	assert.Error(suite.T(), err)
+
+	_, err = DecryptTrack(make([]byte, 32), key[:5], iv)
+	assert.Error(suite.T(), err)
+}
+
 // TestSanitise tests filename sanitization
 func (suite *DownloaderTestSuite) TestSanitise() {
 	testCases := []struct {
@@ -293,6 +619,8 @@ func (suite *DownloaderTestSuite) TestSanitise() {
 		{"file:with*chars?.mp3", "file_with_chars_.mp3"},
 		{"file/with\\bad:chars*?.mp3", "file_with\\bad_chars__.mp3"},
 		{"file\t", "file"},
+		{"-i evil.mp3", "_i evil.mp3"},
+		{"../../etc/passwd", ".._.._etc_passwd"},
 	}
 
 	for _, tc := range testCases {
@@ -383,6 +711,240 @@ func (suite *DownloaderTestSuite) TestDownloadTrack_Basic() {
 	assert.NoError(suite.T(), err)
 }
 
+// TestDownloadTrack_ProgressFunc tests that progress goes to the callback when one is set
+func (suite *DownloaderTestSuite) TestDownloadTrack_ProgressFunc() {
+	testFile := filepath.Join(suite.tempDir, "test_track.m4a")
+	content := []byte("fake audio content")
+
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(content)
+	}))
+	defer testServer.Close()
+
+	var last models.ProgressEvent
+	suite.downloader.SetProgressFunc(func(event models.ProgressEvent) {
+		last = event
+	})
+
+	err := suite.downloader.DownloadTrack(testFile, testServer.URL)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), testFile, last.Path)
+	assert.Equal(suite.T(), int64(len(content)), last.Downloaded)
+	assert.Equal(suite.T(), 100, last.Percentage)
+}
+
+// TestDownloadTrack_LastModified tests the CDN's Last-Modified time is kept
+// for the track until it's taken
+func (suite *DownloaderTestSuite) TestDownloadTrack_LastModified() {
+	modified := time.Date(2019, 7, 14, 18, 30, 0, 0, time.UTC)
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/dated" {
+			w.Header().Set("Last-Modified", modified.Format(http.TimeFormat))
+		}
+		w.Write([]byte("fake audio content"))
+	}))
+	defer testServer.Close()
+
+	testFile := filepath.Join(suite.tempDir, "test_track.m4a")
+	suite.Require().NoError(suite.downloader.SafeDownloadTrack(testFile, testServer.URL+"/dated", 0))
+	got, ok := suite.downloader.TakeLastModified(testFile)
+	assert.True(suite.T(), ok)
+	assert.True(suite.T(), got.Equal(modified))
+
+	_, ok = suite.downloader.TakeLastModified(testFile)
+	assert.False(suite.T(), ok)
+
+	undated := filepath.Join(suite.tempDir, "undated.m4a")
+	suite.Require().NoError(suite.downloader.DownloadTrack(undated, testServer.URL))
+	_, ok = suite.downloader.TakeLastModified(undated)
+	assert.False(suite.T(), ok)
+}
+
+// TestSafeDownloadAsset tests only complete, valid images are moved into place
+func (suite *DownloaderTestSuite) TestSafeDownloadAsset() {
+	requests := 0
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		switch r.URL.Path {
+		case "/cover.jpg":
+			w.Write([]byte("\xff\xd8\xff\xe0cover"))
+		case "/cover.png":
+			w.Write([]byte("\x89PNG\r\n\x1a\ncover"))
+		case "/error.jpg":
+			w.Write([]byte("<html>Service Unavailable</html>"))
+		case "/truncated.jpg":
+			w.Header().Set("Content-Length", "1000")
+			w.Write([]byte("\xff\xd8\xff\xe0cov"))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer testServer.Close()
+
+	for _, name := range []string{"cover.jpg", "cover.png"} {
+		path := filepath.Join(suite.tempDir, name)
+		suite.Require().NoError(suite.downloader.SafeDownloadAsset(path, testServer.URL+"/"+name, ValidateImage))
+		assert.FileExists(suite.T(), path)
+	}
+
+	for _, name := range []string{"error.jpg", "truncated.jpg"} {
+		path := filepath.Join(suite.tempDir, name)
+		assert.Error(suite.T(), suite.downloader.SafeDownloadAsset(path, testServer.URL+"/"+name, ValidateImage))
+		assert.NoFileExists(suite.T(), path)
+		assert.NoFileExists(suite.T(), path+".tmp")
+	}
+
+	// Missing pictures aren't retried
+	requests = 0
+	missing := filepath.Join(suite.tempDir, "missing.jpg")
+	assert.Error(suite.T(), suite.downloader.SafeDownloadAsset(missing, testServer.URL+"/missing.jpg", nil))
+	assert.Equal(suite.T(), 1, requests)
+	assert.NoFileExists(suite.T(), missing)
+}
+
+// TestDownloadTrack_MaxBytesPerFile tests that a capped download is kept as a
+// sample instead of the track
+func (suite *DownloaderTestSuite) TestDownloadTrack_MaxBytesPerFile() {
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(make([]byte, 64*1024))
+	}))
+	defer testServer.Close()
+	suite.config.MaxBytesPerFile = 1024
+
+	for _, download := range []func(string) error{
+		func(path string) error { return suite.downloader.DownloadTrack(path, testServer.URL) },
+		func(path string) error { return suite.downloader.SafeDownloadTrack(path, testServer.URL, 0) },
+	} {
+		testFile := filepath.Join(suite.tempDir, "sample_track.flac")
+		os.Remove(SamplePath(testFile))
+
+		err := download(testFile)
+		assert.ErrorIs(suite.T(), err, models.ErrSampleLimit)
+
+		exists, _ := FileExists(testFile)
+		assert.False(suite.T(), exists)
+		stat, err := os.Stat(SamplePath(testFile))
+		assert.NoError(suite.T(), err)
+		assert.Less(suite.T(), stat.Size(), int64(64*1024))
+	}
+}
+
+// TestDownloadTrack_TrackTimeout tests that a stalled download is cut off
+// once it runs over the track timeout, and reported as retryable
+func (suite *DownloaderTestSuite) TestDownloadTrack_TrackTimeout() {
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", "65536")
+		w.Write(make([]byte, 1024))
+		w.(http.Flusher).Flush()
+		// Stall until the client gives up
+		<-r.Context().Done()
+	}))
+	defer testServer.Close()
+	suite.config.TrackTimeout = 1
+
+	for _, download := range []func(string) error{
+		func(path string) error { return suite.downloader.DownloadTrack(path, testServer.URL) },
+		func(path string) error { return suite.downloader.SafeDownloadTrack(path, testServer.URL, 0) },
+	} {
+		start := time.Now()
+		err := download(filepath.Join(suite.tempDir, "stalled_track.flac"))
+		assert.Less(suite.T(), time.Since(start), 10*time.Second)
+
+		var dlErr *models.DownloadError
+		suite.Require().ErrorAs(err, &dlErr)
+		assert.Equal(suite.T(), models.ErrTimeout, dlErr.Type)
+		assert.True(suite.T(), dlErr.Retryable)
+	}
+}
+
+// TestDownloadSubtitles tests subtitle renditions are found in the master
+// playlist and their segments joined into one WebVTT file
+func (suite *DownloaderTestSuite) TestDownloadSubtitles() {
+	var tokens []string
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tokens = append(tokens, r.URL.Query().Get("token"))
+		switch r.URL.Path {
+		case "/video/subs/en.m3u8":
+			w.Write([]byte("#EXTM3U\n#EXT-X-TARGETDURATION:60\n#EXTINF:60,\nen-1.vtt\n#EXTINF:60,\nen-2.vtt\n#EXT-X-ENDLIST\n"))
+		case "/video/subs/en-1.vtt":
+			w.Write([]byte("\ufeffWEBVTT\r\n\r\n00:00.000 --> 00:02.000\r\nHello\r\n"))
+		case "/video/subs/en-2.vtt":
+			w.Write([]byte("WEBVTT\nX-TIMESTAMP-MAP=LOCAL:00:00:00.000,MPEGTS:0\n\n01:00.000 --> 01:02.000\nGoodnight\n"))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer testServer.Close()
+
+	master, listType, err := m3u8.DecodeFrom(strings.NewReader("#EXTM3U\n"+
+		`#EXT-X-MEDIA:TYPE=SUBTITLES,GROUP-ID="subs",NAME="English",LANGUAGE="en",URI="subs/en.m3u8"`+"\n"+
+		`#EXT-X-MEDIA:TYPE=SUBTITLES,GROUP-ID="other",NAME="French",LANGUAGE="fr",URI="subs/fr.m3u8"`+"\n"+
+		`#EXT-X-STREAM-INF:BANDWIDTH=5000000,RESOLUTION=1920x1080,SUBTITLES="subs"`+"\n"+
+		"1080.m3u8\n"), true)
+	suite.Require().NoError(err)
+	suite.Require().Equal(m3u8.MASTER, listType)
+	renditions := SubtitleRenditions(master.(*m3u8.MasterPlaylist).Variants[0])
+	suite.Require().Len(renditions, 1)
+	assert.Equal(suite.T(), "en", renditions[0].Language)
+
+	vttPath := filepath.Join(suite.tempDir, "show.en.vtt")
+	suite.Require().NoError(suite.downloader.DownloadSubtitles(vttPath, testServer.URL+"/video/master.m3u8?token=abc", renditions[0]))
+	vtt, err := os.ReadFile(vttPath)
+	suite.Require().NoError(err)
+	assert.Equal(suite.T(), "WEBVTT\n\n00:00.000 --> 00:02.000\nHello\n\n01:00.000 --> 01:02.000\nGoodnight\n\n", string(vtt))
+	// The master playlist's auth token is carried over to every request
+	assert.Equal(suite.T(), []string{"abc", "abc", "abc"}, tokens)
+
+	// Variants without subtitles don't get any
+	assert.Empty(suite.T(), SubtitleRenditions(&m3u8.Variant{}))
+}
+
+// TestMuxSubtitleArgs tests subtitles are muxed in a format the container holds
+func (suite *DownloaderTestSuite) TestMuxSubtitleArgs() {
+	subtitles := []Subtitle{{Path: "show.en.vtt", Language: "en"}, {Path: "show.2.vtt"}}
+	assert.Equal(suite.T(), []string{
+		"-hide_banner", "-i", "show.mp4", "-i", "show.en.vtt", "-i", "show.2.vtt",
+		"-map", "0", "-map", "1", "-map", "2", "-c", "copy", "-c:s", "mov_text",
+		"-metadata:s:s:0", "language=en", "show.subtitles.mp4",
+	}, muxSubtitleArgs("show.mp4", "show.subtitles.mp4", "mp4", subtitles))
+
+	args := muxSubtitleArgs("show.mkv", "show.subtitles.mkv", "mkv", subtitles[:1])
+	assert.Contains(suite.T(), strings.Join(args, " "), "-c:s srt")
+}
+
+// TestParseAudioCodec tests reading the audio codec from ffmpeg's stream
+// descriptions of HLS TS segments
+func (suite *DownloaderTestSuite) TestParseAudioCodec() {
+	aacProbe := `Input #0, mpegts, from 'pipe:':
+  Duration: 00:05:12.34, start: 1.400000, bitrate: N/A
+  Program 1
+    Stream #0:0[0x101]: Audio: aac (LC) ([15][0][0][0] / 0x000F), 48000 Hz, stereo, fltp, 157 kb/s
+At least one output file must be specified`
+	assert.Equal(suite.T(), "aac", parseAudioCodec(aacProbe))
+
+	// Video-derived audio, with the video stream listed first
+	ac3Probe := `Input #0, mpegts, from 'pipe:':
+  Duration: 00:05:12.34, start: 1.400000, bitrate: N/A
+  Program 1
+    Stream #0:0[0x100]: Video: h264 (High) ([27][0][0][0] / 0x001B), yuv420p, 1920x1080, 29.97 fps
+    Stream #0:1[0x101](eng): Audio: ac3 ([129][0][0][0] / 0x0081), 48000 Hz, 5.1(side), fltp, 448 kb/s
+At least one output file must be specified`
+	assert.Equal(suite.T(), "ac3", parseAudioCodec(ac3Probe))
+
+	assert.Equal(suite.T(), "", parseAudioCodec("pipe:: Invalid data found when processing input"))
+}
+
+// TestTsToAacArgs tests AAC is copied and other codecs re-encoded
+func (suite *DownloaderTestSuite) TestTsToAacArgs() {
+	assert.Equal(suite.T(), []string{
+		"-hide_banner", "-i", "pipe:", "-vn", "-c:a", "copy", "-f", "ipod", "track.m4a.tmp",
+	}, tsToAacArgs("track.m4a.tmp", "aac"))
+
+	assert.Equal(suite.T(), []string{
+		"-hide_banner", "-i", "pipe:", "-vn", "-c:a", "aac", "-b:a", "256k", "-f", "ipod", "track.m4a.tmp",
+	}, tsToAacArgs("track.m4a.tmp", "ac3"))
+}
+
 // TestDownloadVideo tests video downloading (basic functionality)
 func (suite *DownloaderTestSuite) TestDownloadVideo_Basic() {
 	testFile := filepath.Join(suite.tempDir, "test_video.ts")
@@ -406,6 +968,129 @@ func (suite *DownloaderTestSuite) TestDownloadVideo_Basic() {
 	assert.NoError(suite.T(), err)
 }
 
+// TestDownloadVideo_Resume tests that a partial TS is resumed from its end
+func (suite *DownloaderTestSuite) TestDownloadVideo_Resume() {
+	testFile := filepath.Join(suite.tempDir, "resume_video.ts")
+	testContent := []byte("0123456789abcdefghij")
+	suite.Require().NoError(os.WriteFile(testFile, testContent[:8], 0644))
+
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(suite.T(), "bytes=8-", r.Header.Get("Range"))
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes 8-%d/%d", len(testContent)-1, len(testContent)))
+		w.WriteHeader(http.StatusPartialContent)
+		w.Write(testContent[8:])
+	}))
+	defer testServer.Close()
+
+	suite.Require().NoError(suite.downloader.DownloadVideo(testFile, testServer.URL))
+	data, err := os.ReadFile(testFile)
+	suite.Require().NoError(err)
+	assert.Equal(suite.T(), testContent, data)
+	// Only the bytes fetched this run count towards its total
+	assert.Equal(suite.T(), int64(len(testContent)-8), suite.downloader.BytesDownloaded())
+}
+
+// TestDownloadVideo_RangeIgnored tests that a server answering a resume with
+// the whole file replaces the partial TS instead of being appended to it
+func (suite *DownloaderTestSuite) TestDownloadVideo_RangeIgnored() {
+	testFile := filepath.Join(suite.tempDir, "ignored_range_video.ts")
+	testContent := []byte("0123456789abcdefghij")
+	suite.Require().NoError(os.WriteFile(testFile, []byte("stale partial data!!!!!!"), 0644))
+
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.NotEmpty(suite.T(), r.Header.Get("Range"))
+		w.Write(testContent)
+	}))
+	defer testServer.Close()
+
+	suite.Require().NoError(suite.downloader.DownloadVideo(testFile, testServer.URL))
+	data, err := os.ReadFile(testFile)
+	suite.Require().NoError(err)
+	assert.Equal(suite.T(), testContent, data)
+}
+
+// TestCleanupTempFiles tests leftovers are found where the downloader
+// creates them, and files that may still be in use are kept
+func (suite *DownloaderTestSuite) TestCleanupTempFiles() {
+	albumPath := filepath.Join(suite.tempDir, "Artist - Album")
+	tempDir := filepath.Join(suite.tempDir, "temp")
+	suite.Require().NoError(os.MkdirAll(albumPath, 0755))
+	suite.Require().NoError(os.MkdirAll(tempDir, 0755))
+
+	write := func(path string, age time.Duration) string {
+		suite.Require().NoError(os.WriteFile(path, []byte("x"), 0644))
+		modTime := time.Now().Add(-age)
+		suite.Require().NoError(os.Chtimes(path, modTime, modTime))
+		return path
+	}
+	staleTrack := write(fsutil.TempPathFor(filepath.Join(albumPath, "01. Intro.flac")), 25*time.Hour)
+	resumable := write(fsutil.TempPathFor(filepath.Join(albumPath, "02. Tweezer.flac")), 2*time.Hour)
+	staleEnc := write(fsutil.TempPath(tempDir, "temp_enc_*.ts"), 2*time.Hour)
+	staleChaps := write(fsutil.TempPath(tempDir, "chapters_nugs_dl_*.txt"), 2*time.Hour)
+	activeEnc := write(fsutil.TempPath(tempDir, "temp_enc_*.ts"), time.Minute)
+
+	suite.Require().NoError(CleanupTempFiles(albumPath, tempDir))
+	assert.NoFileExists(suite.T(), staleTrack)
+	assert.NoFileExists(suite.T(), staleEnc)
+	assert.NoFileExists(suite.T(), staleChaps)
+	assert.FileExists(suite.T(), resumable)
+	assert.FileExists(suite.T(), activeEnc)
+}
+
+// TestNoClean tests that --no-clean keeps temporary files, and moves the
+// encrypted TS of an HLS track next to it where it's easy to find
+func (suite *DownloaderTestSuite) TestNoClean() {
+	suite.config.TempDir = suite.tempDir
+	trackPath := filepath.Join(suite.tempDir, "01. Track.m4a")
+	tempPath := fsutil.TempPathFor(trackPath)
+	encPath := suite.downloader.TempPath("temp_enc_*.ts")
+	suite.Require().NoError(os.WriteFile(tempPath, []byte("temp"), 0644))
+	suite.Require().NoError(os.WriteFile(encPath, []byte("enc"), 0644))
+
+	suite.config.NoClean = true
+	suite.NoError(suite.downloader.removeTemp(tempPath))
+	suite.NoError(suite.downloader.discardEncrypted(encPath, trackPath))
+	suite.FileExists(tempPath)
+	suite.FileExists(trackPath + ".enc.ts")
+	suite.NoFileExists(encPath)
+
+	suite.config.NoClean = false
+	suite.Require().NoError(os.WriteFile(encPath, []byte("enc"), 0644))
+	suite.NoError(suite.downloader.removeTemp(tempPath))
+	suite.NoError(suite.downloader.discardEncrypted(encPath, trackPath))
+	suite.NoFileExists(tempPath)
+	suite.NoFileExists(encPath)
+}
+
+// TestHlsOnly_Concurrent tests that HLS-only tracks downloading at the same
+// time each decrypt their own encrypted TS
+func (suite *DownloaderTestSuite) TestHlsOnly_Concurrent() {
+	suite.config.TempDir = suite.tempDir
+	// --no-clean keeps each encrypted TS next to its track to check. ffmpeg
+	// isn't needed, as the TS is handled before the conversion fails.
+	suite.config.NoClean = true
+
+	var wg sync.WaitGroup
+	for track := 1; track <= 2; track++ {
+		wg.Add(1)
+		go func(track int) {
+			defer wg.Done()
+			trackPath := filepath.Join(suite.tempDir, fmt.Sprintf("%02d. Track.m4a", track))
+			manUrl := fmt.Sprintf("%s/media_track.m3u8?track=%d", suite.server.URL, track)
+			suite.downloader.HlsOnly(trackPath, manUrl, "ffmpeg_missing")
+		}(track)
+	}
+	wg.Wait()
+
+	for track := 1; track <= 2; track++ {
+		data, err := os.ReadFile(filepath.Join(suite.tempDir, fmt.Sprintf("%02d. Track.m4a.enc.ts", track)))
+		suite.Require().NoError(err)
+		suite.Equal(bytes.Repeat([]byte{byte(track)}, 64*1024), data)
+	}
+	matches, _ := filepath.Glob(filepath.Join(suite.tempDir, "temp_enc_*.ts"))
+	suite.Empty(matches)
+}
+
 // TestTagAudioFile tests audio file tagging
 func (suite *DownloaderTestSuite) TestTagAudioFile() {
 	// Create a temporary test file
@@ -437,6 +1122,149 @@ func (suite *DownloaderTestSuite) TestTagAudioFile() {
 	}
 }
 
+// TestAudioTagArgs tests the ffmpeg metadata flags built for a track
+func (suite *DownloaderTestSuite) TestAudioTagArgs() {
+	assert.Empty(suite.T(), audioTagArgs("01.flac", nil))
+	assert.Empty(suite.T(), audioTagArgs("01.flac", &models.TrackMetadata{}))
+	assert.Empty(suite.T(), audioTagArgs("01.mp3", &models.TrackMetadata{}))
+
+	args := audioTagArgs("03. Test Track.wav", &models.TrackMetadata{
+		Title:       "Test Track",
+		Album:       "Test Playlist",
+		AlbumArtist: "Various Artists",
+		TrackNum:    3,
+		Comment:     "Test Comment",
+		SourceID:    "123",
+	})
+	assert.Equal(suite.T(), []string{
+		"-metadata", "title=Test Track",
+		"-metadata", "album=Test Playlist",
+		"-metadata", "album_artist=Various Artists",
+		"-metadata", "track=3",
+		"-metadata", "comment=Test Comment",
+		"-metadata", "NUGS_SOURCE_ID=123",
+	}, args)
+
+	args = audioTagArgs("01.flac", &models.TrackMetadata{ContainerID: 23329, ArtistID: 1045, TrackID: 456789, SongID: 12})
+	assert.Equal(suite.T(), []string{
+		"-metadata", "NUGS_CONTAINER_ID=23329",
+		"-metadata", "NUGS_ARTIST_ID=1045",
+		"-metadata", "NUGS_TRACK_ID=456789",
+		"-metadata", "NUGS_SONG_ID=12",
+	}, args)
+}
+
+// TestAudioTagArgs_Formats tests each container gets its own tag names
+func (suite *DownloaderTestSuite) TestAudioTagArgs_Formats() {
+	metadata := &models.TrackMetadata{
+		Title:           "Tweezer",
+		Artist:          "Phish",
+		AlbumArtist:     "Phish",
+		TrackNum:        3,
+		TrackTotal:      12,
+		DiscNum:         2,
+		DiscTotal:       3,
+		Year:            "1997",
+		Date:            time.Date(1997, 12, 31, 0, 0, 0, 0, time.UTC),
+		Comment:         "Downloaded from nugs.net",
+		ArtistSort:      "Phish",
+		AlbumArtistSort: "Phish",
+	}
+
+	assert.Equal(suite.T(), []string{
+		"-metadata", "TITLE=Tweezer",
+		"-metadata", "ARTIST=Phish",
+		"-metadata", "ALBUMARTIST=Phish",
+		"-metadata", "TRACKNUMBER=3",
+		"-metadata", "TRACKTOTAL=12",
+		"-metadata", "DISCNUMBER=2",
+		"-metadata", "DISCTOTAL=3",
+		"-metadata", "DATE=1997-12-31",
+		"-metadata", "COMMENT=Downloaded from nugs.net",
+		"-metadata", "ARTISTSORT=Phish",
+		"-metadata", "ALBUMARTISTSORT=Phish",
+	}, audioTagArgs("03. Tweezer.flac", metadata))
+
+	assert.Equal(suite.T(), []string{
+		"-metadata", "title=Tweezer",
+		"-metadata", "artist=Phish",
+		"-metadata", "album_artist=Phish",
+		"-metadata", "track=3/12",
+		"-metadata", "disc=2/3",
+		"-metadata", "date=1997-12-31",
+		"-metadata", "comment=Downloaded from nugs.net",
+		"-metadata", "sort_artist=Phish",
+		"-metadata", "sort_album_artist=Phish",
+	}, audioTagArgs("03. Tweezer.M4A", metadata))
+
+	assert.Equal(suite.T(), []string{
+		"-metadata", "TIT2=Tweezer",
+		"-metadata", "TPE1=Phish",
+		"-metadata", "TPE2=Phish",
+		"-metadata", "TRCK=3/12",
+		"-metadata", "TPOS=2/3",
+		"-metadata", "TDRC=1997-12-31",
+		"-metadata", "comment=Downloaded from nugs.net",
+		"-metadata", "TSOP=Phish",
+		"-metadata", "ALBUMARTISTSORT=Phish",
+		"-id3v2_version", "4",
+	}, audioTagArgs("03. Tweezer.mp3", metadata))
+
+	// Without a date, the year is tagged instead
+	args := audioTagArgs("03. Tweezer.flac", &models.TrackMetadata{Year: "1997", TrackNum: 3})
+	assert.Equal(suite.T(), []string{"-metadata", "TRACKNUMBER=3", "-metadata", "DATE=1997"}, args)
+}
+
+// TestArtworkArgs tests each image is mapped as a typed attached picture
+func (suite *DownloaderTestSuite) TestArtworkArgs() {
+	args := artworkArgs("in.flac", "out.flac", []models.Artwork{
+		{Path: "front.jpg", Type: "Cover (front)"},
+		{Path: "back.jpg", Type: "Cover (back)"},
+	})
+	assert.Equal(suite.T(), []string{
+		"-hide_banner", "-i", "in.flac", "-i", "front.jpg", "-i", "back.jpg",
+		"-map", "0:a",
+		"-map", "1", "-disposition:v:0", "attached_pic", "-metadata:s:v:0", "comment=Cover (front)",
+		"-map", "2", "-disposition:v:1", "attached_pic", "-metadata:s:v:1", "comment=Cover (back)",
+		"-c", "copy", "out.flac",
+	}, args)
+}
+
+// TestPosterArgs tests the poster is marked as the cover after the input's streams
+func (suite *DownloaderTestSuite) TestPosterArgs() {
+	args := posterArgs("in.mp4", "out.mp4", "poster.jpg", false)
+	assert.Equal(suite.T(), []string{
+		"-hide_banner", "-i", "in.mp4", "-i", "poster.jpg",
+		"-map", "0", "-map", "1", "-c", "copy",
+		"-disposition:v:1", "attached_pic",
+		"out.mp4",
+	}, args)
+
+	args = posterArgs("in.m4a", "out.m4a", "poster.jpg", true)
+	assert.Contains(suite.T(), args, "-disposition:v:0")
+}
+
+// TestFfmpegLogWriter tests ffmpeg output is logged a line at a time
+func (suite *DownloaderTestSuite) TestFfmpegLogWriter() {
+	var logBuf bytes.Buffer
+	logger.GetLogger().SetOutput(&logBuf)
+	defer logger.GetLogger().SetOutput(os.Stdout)
+
+	w := &ffmpegLogWriter{output: "out.mp4"}
+	w.Write([]byte("Input #0, mpegts\nframe=  10 "))
+	w.Write([]byte("speed=2x\rframe=  20 speed=3x\r\n"))
+	w.Write([]byte("video:100kB"))
+
+	lines := strings.Split(strings.TrimSpace(logBuf.String()), "\n")
+	suite.Require().Len(lines, 3)
+	assert.Contains(suite.T(), lines[0], "ffmpeg: Input #0, mpegts")
+	assert.Contains(suite.T(), lines[1], "ffmpeg: frame=  10 speed=2x")
+	assert.Contains(suite.T(), lines[2], "out.mp4")
+
+	w.flush()
+	assert.Contains(suite.T(), logBuf.String(), "ffmpeg: video:100kB")
+}
+
 // TestTagVideoFile tests video file tagging
 func (suite *DownloaderTestSuite) TestTagVideoFile() {
 	// Create a temporary test file
@@ -0,0 +1,69 @@
+package downloader
+
+import (
+	"bytes"
+	"io"
+	"os/exec"
+	"strings"
+
+	"main/pkg/logger"
+)
+
+// verboseFfmpeg logs ffmpeg's output as it runs rather than only on failure
+var verboseFfmpeg bool
+
+// SetVerboseFfmpeg turns logging of ffmpeg's progress and messages on or off,
+// for diagnosing slow remuxes with --verbose
+func SetVerboseFfmpeg(enabled bool) {
+	verboseFfmpeg = enabled
+}
+
+// runFfmpeg runs an ffmpeg command and returns its stderr for error messages.
+// With verbose ffmpeg output each line is also logged as it's written.
+func runFfmpeg(cmd *exec.Cmd) (string, error) {
+	var errBuffer bytes.Buffer
+	cmd.Stderr = &errBuffer
+	if verboseFfmpeg {
+		lw := &ffmpegLogWriter{output: cmd.Args[len(cmd.Args)-1]}
+		cmd.Stderr = io.MultiWriter(&errBuffer, lw)
+		defer lw.flush()
+	}
+
+	err := cmd.Run()
+	return errBuffer.String(), err
+}
+
+// ffmpegLogWriter logs ffmpeg's stderr line by line. Progress stats are
+// redrawn with carriage returns, so those end a line too.
+type ffmpegLogWriter struct {
+	output string
+	buf    []byte
+}
+
+// Write implements io.Writer, logging each complete line
+func (w *ffmpegLogWriter) Write(p []byte) (int, error) {
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexAny(w.buf, "\r\n")
+		if i < 0 {
+			break
+		}
+		w.log(string(w.buf[:i]))
+		w.buf = w.buf[i+1:]
+	}
+	return len(p), nil
+}
+
+// flush logs whatever's left after ffmpeg exits
+func (w *ffmpegLogWriter) flush() {
+	w.log(string(w.buf))
+	w.buf = nil
+}
+
+func (w *ffmpegLogWriter) log(line string) {
+	line = strings.TrimSpace(line)
+	if line == "" {
+		return
+	}
+	logger.GetLogger().WithField("output", w.output).Info("ffmpeg: " + line)
+}
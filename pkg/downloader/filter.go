@@ -0,0 +1,121 @@
+package downloader
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Filter settings for the optional audio clean-up passes
+const (
+	// silenceThreshold is the level below which audio counts as silence
+	silenceThreshold = "-60dB"
+	// trailingSilence is how long silence has to last before it's removed.
+	// silenceremove can't single out the end of a track without buffering
+	// all of it, so longer gaps mid-track go too, which live recordings
+	// practically never have.
+	trailingSilence = "5"
+	// loudnormTarget is the EBU R128 single-pass target used by streaming
+	// services
+	loudnormTarget = "loudnorm=I=-16:TP=-1.5:LRA=11"
+	// filteredAacBitrate re-encodes AAC above any Nugs stream's bitrate, so
+	// the second generation loses as little as possible
+	filteredAacBitrate = "256k"
+)
+
+// ErrUnfilterable is returned by FilterAudio for formats it can't re-encode
+var ErrUnfilterable = errors.New("audio filters aren't supported for this format")
+
+// AudioFilters are the optional clean-up passes run on downloaded audio
+type AudioFilters struct {
+	TrimSilence bool
+	Normalize   bool
+}
+
+// Enabled reports whether any pass is turned on
+func (f AudioFilters) Enabled() bool {
+	return f.TrimSilence || f.Normalize
+}
+
+// audioFilterArgs builds the -af chain for the enabled passes. loudnorm
+// resamples to 192 kHz, so the sample rate is pinned back to the source's.
+// The arguments can be added to any ffmpeg command that re-encodes audio.
+func audioFilterArgs(filters AudioFilters, sampleRate int) []string {
+	var chain []string
+	if filters.TrimSilence {
+		chain = append(chain, fmt.Sprintf(
+			"silenceremove=start_periods=1:start_threshold=%[1]s:stop_periods=-1:stop_threshold=%[1]s:stop_duration=%[2]s",
+			silenceThreshold, trailingSilence))
+	}
+	if filters.Normalize {
+		chain = append(chain, loudnormTarget)
+	}
+	if len(chain) == 0 {
+		return nil
+	}
+
+	args := []string{"-af", strings.Join(chain, ",")}
+	if filters.Normalize && sampleRate > 0 {
+		args = append(args, "-ar", strconv.Itoa(sampleRate))
+	}
+	return args
+}
+
+// filterCodecArgs picks the encoder for a filtered track so it keeps its
+// format and bit depth. The filters output floats, so lossless tracks only
+// go back to 16-bit when the source is known to be; an unknown depth (0) is
+// kept at 24-bit rather than risk truncating it. ok is false for files that
+// can't be filtered, like 360 Reality Audio.
+func filterCodecArgs(ext string, lossless bool, bitDepth int) (args []string, ok bool) {
+	wide := bitDepth != 16
+	switch strings.ToLower(ext) {
+	case ".flac":
+		if wide {
+			return []string{"-c:a", "flac", "-sample_fmt", "s32"}, true
+		}
+		return []string{"-c:a", "flac", "-sample_fmt", "s16"}, true
+	case ".wav":
+		if wide {
+			return []string{"-c:a", "pcm_s24le"}, true
+		}
+		return []string{"-c:a", "pcm_s16le"}, true
+	case ".m4a":
+		if !lossless {
+			return []string{"-c:a", "aac", "-b:a", filteredAacBitrate}, true
+		}
+		if wide {
+			return []string{"-c:a", "alac", "-sample_fmt", "s32p"}, true
+		}
+		return []string{"-c:a", "alac", "-sample_fmt", "s16p"}, true
+	}
+	return nil, false
+}
+
+// filterArgs builds the ffmpeg arguments for FilterAudio, keeping the
+// input's tags
+func filterArgs(inPath, outPath string, filters AudioFilters, codecArgs []string, sampleRate int) []string {
+	args := []string{"-hide_banner", "-i", inPath, "-map", "0:a:0", "-map_metadata", "0"}
+	args = append(args, audioFilterArgs(filters, sampleRate)...)
+	args = append(args, codecArgs...)
+	return append(args, outPath)
+}
+
+// FilterAudio re-encodes a track through the enabled clean-up passes into
+// outPath, in the same format. ErrUnfilterable is returned for formats
+// without a suitable encoder.
+func FilterAudio(inPath, outPath, ffmpegNameStr string, filters AudioFilters, lossless bool, bitDepth, sampleRate int) error {
+	codecArgs, ok := filterCodecArgs(filepath.Ext(outPath), lossless, bitDepth)
+	if !ok {
+		return ErrUnfilterable
+	}
+
+	cmd := exec.Command(ffmpegNameStr, filterArgs(inPath, outPath, filters, codecArgs, sampleRate)...)
+	stderr, err := runFfmpeg(cmd)
+	if err != nil {
+		return fmt.Errorf("ffmpeg filtering failed: %s\n%s", err, stderr)
+	}
+	return nil
+}
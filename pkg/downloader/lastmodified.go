@@ -0,0 +1,26 @@
+package downloader
+
+import (
+	"net/http"
+	"time"
+)
+
+// recordLastModified notes the Last-Modified time the CDN sent for a
+// download, for --mtime-from-header
+func (d *Downloader) recordLastModified(path string, header http.Header) {
+	modified, err := http.ParseTime(header.Get("Last-Modified"))
+	if err != nil {
+		return
+	}
+	d.lastModified.Store(path, modified)
+}
+
+// TakeLastModified returns the Last-Modified time sent with the download to
+// path and forgets it. ok is false when the CDN didn't send a usable one.
+func (d *Downloader) TakeLastModified(path string) (modified time.Time, ok bool) {
+	v, ok := d.lastModified.LoadAndDelete(path)
+	if !ok {
+		return time.Time{}, false
+	}
+	return v.(time.Time), true
+}
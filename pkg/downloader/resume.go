@@ -1,6 +1,7 @@
 package downloader
 
 import (
+	"context"
 	"crypto/md5"
 	"encoding/hex"
 	"encoding/json"
@@ -11,11 +12,14 @@ import (
 	"path/filepath"
 	"strings"
 	"time"
+
+	"main/pkg/fsutil"
 )
 
 // ResumeState represents the state of a resumable download
 type ResumeState struct {
 	FilePath       string         `json:"file_path"`
+	TempPath       string         `json:"temp_path,omitempty"` // where the partial download is written
 	URL            string         `json:"url"`
 	TotalSize      int64          `json:"total_size"`
 	DownloadedSize int64          `json:"downloaded_size"`
@@ -71,7 +75,7 @@ func (rm *ResumeManager) SaveState(state *ResumeState) error {
 	}
 
 	// Write to temporary file first for atomicity
-	tempFile := stateFile + ".tmp"
+	tempFile := fsutil.TempPathFor(stateFile)
 	if err := os.WriteFile(tempFile, data, 0644); err != nil {
 		return fmt.Errorf("failed to write resume state: %w", err)
 	}
@@ -164,8 +168,8 @@ func (rm *ResumeManager) UpdateProgress(state *ResumeState, downloadedSize int64
 }
 
 // SendRangeRequest sends an HTTP request with Range header for resuming downloads
-func SendRangeRequest(client *http.Client, url string, startByte int64, headers map[string]string) (*http.Response, error) {
-	req, err := http.NewRequest(http.MethodGet, url, nil)
+func SendRangeRequest(ctx context.Context, client *http.Client, url string, startByte int64, headers map[string]string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
 		return nil, err
 	}
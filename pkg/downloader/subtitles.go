@@ -0,0 +1,156 @@
+package downloader
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	urlPkg "net/url"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/grafov/m3u8"
+)
+
+// Subtitle is a downloaded subtitle track, for muxing into a video
+type Subtitle struct {
+	Path     string
+	Language string
+}
+
+// SubtitleRenditions returns the subtitle renditions in a video's master
+// playlist that the variant can be played with. Variants that don't name a
+// subtitle group get all of them.
+func SubtitleRenditions(variant *m3u8.Variant) []*m3u8.Alternative {
+	var renditions []*m3u8.Alternative
+	for _, alt := range variant.Alternatives {
+		if alt == nil || alt.Type != "SUBTITLES" || alt.URI == "" {
+			continue
+		}
+		if variant.Subtitles != "" && alt.GroupId != variant.Subtitles {
+			continue
+		}
+		renditions = append(renditions, alt)
+	}
+	return renditions
+}
+
+// resolveURL resolves a playlist URI against the playlist that listed it.
+// The CDN's auth tokens are in the query, so a URI without one inherits it.
+func resolveURL(base, ref string) (string, error) {
+	baseUrl, err := urlPkg.Parse(base)
+	if err != nil {
+		return "", err
+	}
+	refUrl, err := urlPkg.Parse(ref)
+	if err != nil {
+		return "", err
+	}
+	resolved := baseUrl.ResolveReference(refUrl)
+	if resolved.RawQuery == "" {
+		resolved.RawQuery = baseUrl.RawQuery
+	}
+	return resolved.String(), nil
+}
+
+// DownloadSubtitles downloads a subtitle rendition of the master playlist at
+// masterUrl into one WebVTT file at vttPath
+func (d *Downloader) DownloadSubtitles(vttPath, masterUrl string, rendition *m3u8.Alternative) error {
+	playlistUrl, err := resolveURL(masterUrl, rendition.URI)
+	if err != nil {
+		return err
+	}
+	media, err := d.apiClient.GetMediaPlaylist(playlistUrl)
+	if err != nil {
+		return err
+	}
+
+	var segments [][]byte
+	for _, seg := range media.Segments {
+		if seg == nil {
+			break
+		}
+		segUrl, err := resolveURL(playlistUrl, seg.URI)
+		if err != nil {
+			return err
+		}
+		resp, err := d.apiClient.DownloadFile(segUrl, d.apiClient.PlayerReferer())
+		if err != nil {
+			return err
+		}
+		data, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return err
+		}
+		segments = append(segments, data)
+	}
+	if len(segments) == 0 {
+		return errors.New("the subtitle playlist didn't contain any segments")
+	}
+
+	return os.WriteFile(vttPath, mergeWebVTT(segments), 0644)
+}
+
+// mergeWebVTT joins WebVTT segments into one file, keeping only the first
+// segment's header
+func mergeWebVTT(segments [][]byte) []byte {
+	var merged bytes.Buffer
+	for i, seg := range segments {
+		text := strings.ReplaceAll(string(bytes.TrimPrefix(seg, []byte("\ufeff"))), "\r\n", "\n")
+		if i > 0 {
+			// The header runs up to the first blank line
+			_, cues, found := strings.Cut(text, "\n\n")
+			if !found {
+				continue
+			}
+			text = cues
+		}
+		text = strings.TrimRight(text, "\n")
+		if text == "" {
+			continue
+		}
+		merged.WriteString(text)
+		merged.WriteString("\n\n")
+	}
+	return merged.Bytes()
+}
+
+// subtitleCodec picks the subtitle format a container can hold
+func subtitleCodec(container string) string {
+	if container == "mkv" {
+		return "srt"
+	}
+	return "mov_text"
+}
+
+// muxSubtitleArgs builds the ffmpeg arguments for MuxSubtitles
+func muxSubtitleArgs(inputPath, outputPath, container string, subtitles []Subtitle) []string {
+	args := []string{"-hide_banner", "-i", inputPath}
+	for _, sub := range subtitles {
+		args = append(args, "-i", sub.Path)
+	}
+	args = append(args, "-map", "0")
+	for i := range subtitles {
+		args = append(args, "-map", fmt.Sprint(i+1))
+	}
+	args = append(args, "-c", "copy", "-c:s", subtitleCodec(container))
+	for i, sub := range subtitles {
+		if sub.Language != "" {
+			args = append(args, fmt.Sprintf("-metadata:s:s:%d", i), "language="+sub.Language)
+		}
+	}
+	return append(args, outputPath)
+}
+
+// MuxSubtitles copies a video into outputPath with the subtitles added as
+// soft subtitle tracks
+func MuxSubtitles(inputPath, outputPath, ffmpegNameStr, container string, subtitles []Subtitle) error {
+	cmd := exec.Command(ffmpegNameStr, muxSubtitleArgs(inputPath, outputPath, container, subtitles)...)
+	stderr, err := runFfmpeg(cmd)
+	if err != nil {
+		return fmt.Errorf("ffmpeg subtitle muxing failed: %s\n%s", err, stderr)
+	}
+	return nil
+}
@@ -0,0 +1,131 @@
+package downloader
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"main/pkg/models"
+)
+
+// tagKeys names the tags of one container format. ffmpeg's generic keys map
+// to different frames across muxers and versions, so each format gets the
+// names its readers look for. Formats without total keys get the track and
+// disc totals with the number, as N/M. Genre isn't mapped, as the API has no
+// genre for a release.
+type tagKeys struct {
+	title, artist, album, albumArtist string
+	track, trackTotal                 string
+	disc, discTotal                   string
+	date, comment                     string
+	artistSort, albumSort             string
+	albumArtistSort                   string
+}
+
+var (
+	// genericTags are ffmpeg's own keys, for WAV and unknown containers
+	genericTags = tagKeys{
+		title: "title", artist: "artist", album: "album", albumArtist: "album_artist",
+		track: "track", disc: "disc", date: "date", comment: "comment",
+		artistSort: "sort_artist", albumSort: "sort_album", albumArtistSort: "sort_album_artist",
+	}
+	// vorbisTags are the Vorbis comment fields of FLAC, with the totals in
+	// their own fields as players misread TRACKNUMBER=N/M
+	vorbisTags = tagKeys{
+		title: "TITLE", artist: "ARTIST", album: "ALBUM", albumArtist: "ALBUMARTIST",
+		track: "TRACKNUMBER", trackTotal: "TRACKTOTAL", disc: "DISCNUMBER", discTotal: "DISCTOTAL",
+		date: "DATE", comment: "COMMENT",
+		artistSort: "ARTISTSORT", albumSort: "ALBUMSORT", albumArtistSort: "ALBUMARTISTSORT",
+	}
+	// mp4Tags are ffmpeg's own keys too, as its mp4 muxer maps each of them
+	// to its iTunes atom itself, track and disc as N/M included
+	mp4Tags = genericTags
+	// id3Tags are ID3v2.4 frame ids, which ffmpeg writes as they are. ID3 has
+	// no album artist sort frame, so it's a TXXX frame as beets writes it.
+	id3Tags = tagKeys{
+		title: "TIT2", artist: "TPE1", album: "TALB", albumArtist: "TPE2",
+		track: "TRCK", disc: "TPOS", date: "TDRC", comment: "comment",
+		artistSort: "TSOP", albumSort: "TSOA", albumArtistSort: "ALBUMARTISTSORT",
+	}
+)
+
+// containerTags returns the tag keys for the container at path, by extension
+func containerTags(path string) tagKeys {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".flac":
+		return vorbisTags
+	case ".m4a", ".mp4":
+		return mp4Tags
+	case ".mp3":
+		return id3Tags
+	default:
+		return genericTags
+	}
+}
+
+// audioTagArgs builds the ffmpeg -metadata flags for a track written to
+// outPath, named for its container and skipping empty fields
+func audioTagArgs(outPath string, metadata *models.TrackMetadata) []string {
+	var args []string
+	if metadata == nil {
+		return args
+	}
+	keys := containerTags(outPath)
+
+	tag := func(key, value string) {
+		if value != "" {
+			args = append(args, "-metadata", key+"="+value)
+		}
+	}
+	position := func(key, totalKey string, num, total int) {
+		switch {
+		case num <= 0:
+		case total > 0 && totalKey != "":
+			tag(key, fmt.Sprint(num))
+			tag(totalKey, fmt.Sprint(total))
+		case total > 0:
+			tag(key, fmt.Sprintf("%d/%d", num, total))
+		default:
+			tag(key, fmt.Sprint(num))
+		}
+	}
+
+	tag(keys.title, metadata.Title)
+	tag(keys.artist, metadata.Artist)
+	tag(keys.album, metadata.Album)
+	tag(keys.albumArtist, metadata.AlbumArtist)
+	position(keys.track, keys.trackTotal, metadata.TrackNum, metadata.TrackTotal)
+	position(keys.disc, keys.discTotal, metadata.DiscNum, metadata.DiscTotal)
+	if !metadata.Date.IsZero() {
+		tag(keys.date, metadata.Date.Format("2006-01-02"))
+	} else {
+		tag(keys.date, metadata.Year)
+	}
+	tag(keys.comment, metadata.Comment)
+	tag("NUGS_SOURCE_ID", metadata.SourceID)
+	tag(keys.artistSort, metadata.ArtistSort)
+	tag(keys.albumSort, metadata.AlbumSort)
+	tag(keys.albumArtistSort, metadata.AlbumArtistSort)
+
+	ids := []struct {
+		key string
+		id  int
+	}{
+		{"NUGS_CONTAINER_ID", metadata.ContainerID},
+		{"NUGS_ARTIST_ID", metadata.ArtistID},
+		{"NUGS_TRACK_ID", metadata.TrackID},
+		{"NUGS_SONG_ID", metadata.SongID},
+	}
+	for _, id := range ids {
+		if id.id > 0 {
+			tag(id.key, fmt.Sprint(id.id))
+		}
+	}
+
+	// Pinned to the ID3 version the frame ids are from, rather than left to
+	// ffmpeg's default
+	if len(args) > 0 && keys == id3Tags {
+		args = append(args, "-id3v2_version", "4")
+	}
+	return args
+}
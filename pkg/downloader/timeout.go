@@ -0,0 +1,32 @@
+package downloader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"main/pkg/models"
+)
+
+// trackContext bounds a track download by trackTimeout, so a connection that
+// stalls mid-download can't hold up the rest of a release. It's only
+// cancelled by the deadline.
+func (d *Downloader) trackContext() (context.Context, context.CancelFunc) {
+	if d.config.TrackTimeout <= 0 {
+		return context.WithCancel(context.Background())
+	}
+	return context.WithTimeout(context.Background(), time.Duration(d.config.TrackTimeout)*time.Second)
+}
+
+// trackTimedOut replaces a download error caused by ctx's deadline with a
+// retryable timeout error, so the track is reported as timed out rather than
+// as whatever read or request happened to be cut off
+func (d *Downloader) trackTimedOut(ctx context.Context, err error) error {
+	if err == nil || !errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return err
+	}
+	return models.NewDownloadError(models.ErrTimeout,
+		fmt.Sprintf("Track download timed out after %d seconds", d.config.TrackTimeout),
+		"The CDN may have stalled. Try again, or raise trackTimeout for slow connections", true, err)
+}
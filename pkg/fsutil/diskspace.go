@@ -0,0 +1,7 @@
+package fsutil
+
+import "errors"
+
+// ErrFreeSpaceUnsupported is returned by FreeSpace on platforms where free
+// space can't be queried
+var ErrFreeSpaceUnsupported = errors.New("free space query not supported on this platform")
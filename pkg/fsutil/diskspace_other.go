@@ -0,0 +1,8 @@
+//go:build !unix && !windows
+
+package fsutil
+
+// FreeSpace isn't available on this platform
+func FreeSpace(path string) (uint64, error) {
+	return 0, ErrFreeSpaceUnsupported
+}
@@ -2,6 +2,9 @@ package fsutil
 
 import (
 	"bufio"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
 	"os"
 	"path/filepath"
 	"runtime"
@@ -47,6 +50,29 @@ func SafeJoin(elem ...string) string {
 	return filepath.Clean(path)
 }
 
+// ErrPathEscapesRoot is returned when a path resolves outside the folder it
+// should be written under
+var ErrPathEscapesRoot = errors.New("path escapes the output folder")
+
+// EnsureWithin returns ErrPathEscapesRoot unless path is root or inside it
+func EnsureWithin(root, path string) error {
+	absRoot, err := filepath.Abs(root)
+	if err != nil {
+		return err
+	}
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+
+	// Rel fails when the paths are on different Windows volumes
+	rel, err := filepath.Rel(absRoot, absPath)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return ErrPathEscapesRoot
+	}
+	return nil
+}
+
 // MakeDirs creates directories with cross-platform permissions
 func MakeDirs(path string) error {
 	return os.MkdirAll(path, GetDirMode())
@@ -75,6 +101,27 @@ func AppendFile(name string) (*os.File, error) {
 	return OpenFile(name, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0)
 }
 
+// TempPath returns a unique path for a temporary file in dir, made from
+// pattern with its last "*" replaced by a random string, like os.CreateTemp.
+// Downloads running at the same time never get the same path. An empty dir
+// is the current directory.
+func TempPath(dir, pattern string) string {
+	var b [8]byte
+	// crypto/rand doesn't fail
+	rand.Read(b[:])
+	suffix := hex.EncodeToString(b[:])
+	if i := strings.LastIndex(pattern, "*"); i >= 0 {
+		return filepath.Join(dir, pattern[:i]+suffix+pattern[i+1:])
+	}
+	return filepath.Join(dir, pattern+suffix)
+}
+
+// TempPathFor returns a unique temporary path next to path, so the file can
+// be renamed into place once it's complete
+func TempPathFor(path string) string {
+	return TempPath(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+}
+
 // ReadTxtFile reads a text file and returns non-empty lines
 func ReadTxtFile(path string) ([]string, error) {
 	var lines []string
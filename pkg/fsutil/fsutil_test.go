@@ -4,6 +4,7 @@ import (
 	"os"
 	"path/filepath"
 	"runtime"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -96,6 +97,39 @@ func (suite *FsutilTestSuite) TestSafeJoin() {
 	assert.Equal(suite.T(), ".", result)
 }
 
+// TestEnsureWithin tests that paths built from untrusted names stay in the root
+func (suite *FsutilTestSuite) TestEnsureWithin() {
+	root := suite.tempDir
+
+	assert.NoError(suite.T(), EnsureWithin(root, root))
+	assert.NoError(suite.T(), EnsureWithin(root, filepath.Join(root, "Artist - Show", "01. Track.flac")))
+	assert.NoError(suite.T(), EnsureWithin(root, filepath.Join(root, "..hidden")))
+	assert.NoError(suite.T(), EnsureWithin("", "01. Track.flac"))
+
+	assert.ErrorIs(suite.T(), EnsureWithin(root, filepath.Join(root, "..")), ErrPathEscapesRoot)
+	assert.ErrorIs(suite.T(), EnsureWithin(root, filepath.Join(root, "..", "..", "etc", "passwd")), ErrPathEscapesRoot)
+	assert.ErrorIs(suite.T(), EnsureWithin(root, filepath.Join(root, "Show", "..", "..", "evil.flac")), ErrPathEscapesRoot)
+}
+
+// TestTempPath tests temporary paths are unique and keep the pattern's
+// prefix and suffix
+func (suite *FsutilTestSuite) TestTempPath() {
+	path := TempPath(suite.tempDir, "temp_enc_*.ts")
+	assert.Equal(suite.T(), suite.tempDir, filepath.Dir(path))
+	assert.True(suite.T(), strings.HasPrefix(filepath.Base(path), "temp_enc_"))
+	assert.True(suite.T(), strings.HasSuffix(path, ".ts"))
+	assert.NotEqual(suite.T(), path, TempPath(suite.tempDir, "temp_enc_*.ts"))
+
+	assert.Equal(suite.T(), "chapters", filepath.Dir(TempPath("chapters", "file")))
+	assert.True(suite.T(), strings.HasPrefix(TempPath("", "file"), "file"))
+
+	trackPath := filepath.Join(suite.tempDir, "01. Track.flac")
+	tempPath := TempPathFor(trackPath)
+	assert.Equal(suite.T(), suite.tempDir, filepath.Dir(tempPath))
+	assert.True(suite.T(), strings.HasPrefix(tempPath, trackPath+"."))
+	assert.True(suite.T(), strings.HasSuffix(tempPath, ".tmp"))
+}
+
 // TestMakeDirs tests directory creation with cross-platform permissions
 func (suite *FsutilTestSuite) TestMakeDirs() {
 	testPath := filepath.Join(suite.tempDir, "test", "nested", "dirs")
@@ -351,6 +385,19 @@ func (suite *FsutilTestSuite) TestOpenFile_CustomPerms() {
 	}
 }
 
+// TestFreeSpace tests that free space can be queried for a real directory
+func (suite *FsutilTestSuite) TestFreeSpace() {
+	free, err := FreeSpace(suite.tempDir)
+	if err == ErrFreeSpaceUnsupported {
+		suite.T().Skip("free space query not supported on this platform")
+	}
+	assert.NoError(suite.T(), err)
+	assert.Greater(suite.T(), free, uint64(0))
+
+	_, err = FreeSpace(filepath.Join(suite.tempDir, "missing"))
+	assert.Error(suite.T(), err)
+}
+
 // Helper function to check if path is a directory
 func isDir(path string) bool {
 	info, err := os.Stat(path)
@@ -3,11 +3,13 @@ package models
 import (
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"reflect"
 	"regexp"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/dustin/go-humanize"
@@ -21,6 +23,18 @@ type Quality struct {
 	Extension string
 }
 
+// ProgressEvent describes the progress of a file download
+type ProgressEvent struct {
+	Path       string
+	Downloaded int64
+	Total      int64 // 0 or less when the size is unknown
+	Percentage int
+	Speed      int64 // bytes per second
+}
+
+// ProgressFunc receives progress events in place of the terminal progress line
+type ProgressFunc func(ProgressEvent)
+
 // WriteCounter tracks download progress
 type WriteCounter struct {
 	Total      int64
@@ -28,13 +42,39 @@ type WriteCounter struct {
 	Downloaded int64
 	Percentage int
 	StartTime  int64
+	Path       string
+	OnProgress ProgressFunc
+	Limit      int64         // stop with ErrSampleLimit after this many bytes, 0 = no limit
+	Tally      *atomic.Int64 // run-wide byte total the download is added to, if set
 }
 
+// ErrFormatUnavailable is returned for tracks skipped with --no-fallback
+// because the requested format isn't available
+var ErrFormatUnavailable = errors.New("unavailable in the requested format")
+
+// ErrUnsupportedFormat is returned for tracks whose stream URLs match none
+// of the known formats, which usually means Nugs has added a new one
+var ErrUnsupportedFormat = errors.New("unsupported stream format")
+
+// ErrBelowMinBitrate is returned for HLS-only tracks whose best AAC stream
+// is below --min-bitrate
+var ErrBelowMinBitrate = errors.New("below minimum quality")
+
+// ErrNotAvailable is returned for releases and videos that can't be
+// downloaded, like pre-releases marked COMING SOON or ones taken down
+var ErrNotAvailable = errors.New("not available for download")
+
+// ErrSampleLimit is returned by WriteCounter once its byte limit is reached
+var ErrSampleLimit = errors.New("sample byte limit reached")
+
 // Write implements io.Writer interface for progress tracking
 func (wc *WriteCounter) Write(p []byte) (int, error) {
 	var speed int64 = 0
 	n := len(p)
 	wc.Downloaded += int64(n)
+	if wc.Tally != nil {
+		wc.Tally.Add(int64(n))
+	}
 
 	// Calculate percentage, handling division by zero
 	var percentage float64
@@ -47,15 +87,109 @@ func (wc *WriteCounter) Write(p []byte) (int, error) {
 	if toDivideBy != 0 {
 		speed = int64(wc.Downloaded) / toDivideBy * 1000
 	}
-	fmt.Printf("\r%d%% @ %s/s, %s/%s ", wc.Percentage,
-		humanize.Bytes(uint64(speed)),
-		humanize.Bytes(uint64(wc.Downloaded)), wc.TotalStr)
+	if wc.OnProgress != nil {
+		wc.OnProgress(ProgressEvent{
+			Path:       wc.Path,
+			Downloaded: wc.Downloaded,
+			Total:      wc.Total,
+			Percentage: wc.Percentage,
+			Speed:      speed,
+		})
+	} else {
+		fmt.Printf("\r%d%% @ %s/s, %s/%s%s ", wc.Percentage,
+			humanize.Bytes(uint64(speed)),
+			humanize.Bytes(uint64(wc.Downloaded)), wc.TotalStr,
+			FormatETA(wc.Total-wc.Downloaded, speed))
+	}
+
+	if wc.Limit > 0 && wc.Downloaded >= wc.Limit {
+		return n, ErrSampleLimit
+	}
 	return n, nil
 }
 
+// QualityLabel derives a short folder label from a quality's specs, e.g.
+// "16-bit / 44.1 kHz FLAC" becomes "FLAC16" and "150 Kbps AAC" becomes "AAC150"
+func QualityLabel(specs string) string {
+	fields := strings.Fields(specs)
+	if len(fields) == 0 {
+		return ""
+	}
+	if fields[0] == "360" {
+		return "360RA"
+	}
+
+	codec := fields[len(fields)-1]
+	if depth, ok := strings.CutSuffix(fields[0], "-bit"); ok {
+		return codec + depth
+	}
+	if len(fields) == 3 && fields[1] == "Kbps" {
+		return codec + fields[0]
+	}
+	return codec
+}
+
+// IsLossless reports whether a track format is a lossless source (ALAC, FLAC or MQA)
+func IsLossless(format int) bool {
+	return format >= 1 && format <= 3
+}
+
+// DefaultSortArticles are the leading articles stripped for sort tags when
+// the config doesn't list its own
+var DefaultSortArticles = []string{"The", "A", "An"}
+
+// SortName returns the name to sort by, with any leading article removed, so
+// "The Band" sorts as "Band". Articles match case-insensitively and only as a
+// whole word, and a name that's nothing but an article is kept as it is.
+func SortName(name string, articles []string) string {
+	for _, article := range articles {
+		if len(name) <= len(article)+1 || !strings.EqualFold(name[:len(article)], article) {
+			continue
+		}
+		if name[len(article)] != ' ' {
+			continue
+		}
+		if rest := strings.TrimLeft(name[len(article):], " "); rest != "" {
+			return rest
+		}
+	}
+	return name
+}
+
+// FormatETA returns a ", ~2m30s left" suffix for the progress line, or an
+// empty string when the total is unknown, the speed is zero, or the download
+// is complete
+func FormatETA(remaining, speed int64) string {
+	if remaining <= 0 || speed <= 0 {
+		return ""
+	}
+	eta := time.Duration(float64(remaining) / float64(speed) * float64(time.Second))
+	if eta < time.Second {
+		return ", <1s left"
+	}
+	return fmt.Sprintf(", ~%s left", eta.Round(time.Second))
+}
+
 // AuthResponse represents authentication response
 type AuthResponse struct {
-	AccessToken string `json:"access_token"`
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int    `json:"expires_in"`
+}
+
+// DeviceAuthResponse represents a device authorization response
+type DeviceAuthResponse struct {
+	DeviceCode              string `json:"device_code"`
+	UserCode                string `json:"user_code"`
+	VerificationURI         string `json:"verification_uri"`
+	VerificationURIComplete string `json:"verification_uri_complete"`
+	ExpiresIn               int    `json:"expires_in"`
+	Interval                int    `json:"interval"`
+}
+
+// TokenErrorResponse represents an OAuth error from the token endpoint
+type TokenErrorResponse struct {
+	Error string `json:"error"`
 }
 
 // UserInfo represents user information
@@ -117,31 +251,78 @@ type AlbumMeta struct {
 
 // AlbArtResp represents album/artist response
 type AlbArtResp struct {
+	ArtistID            int                  `json:"artistId"`
 	ArtistName          string               `json:"artistName"`
 	ContainerInfo       string               `json:"containerInfo"`
 	ContainerID         int                  `json:"containerId"`
 	ContainerTypeStr    string               `json:"containerTypeStr"`
+	PerformanceDate     string               `json:"performanceDate"`
 	AvailabilityTypeStr string               `json:"availabilityTypeStr"`
 	Tracks              []Track              `json:"tracks"`
 	Songs               []Track              `json:"songs"`
 	Products            []Product            `json:"products"`
 	ProductFormatList   []*ProductFormatList `json:"productFormatList"`
 	VideoChapters       []interface{}        `json:"videoChapters"`
+	Img                 Picture              `json:"img"`
+	Pics                []Picture            `json:"pics"`
+	Notes               string               `json:"notes"`
+}
+
+// Picture represents release artwork. The URL may be relative to the image host.
+type Picture struct {
+	URL     string `json:"url"`
+	Caption string `json:"caption"`
+}
+
+// Artwork is a downloaded image to embed in tracks. Type is the picture type
+// ffmpeg maps to an APIC or FLAC picture block, e.g. "Cover (front)".
+type Artwork struct {
+	Path string
+	Type string
 }
 
-// Track represents a music track
+// Track represents a music track. The artist and container fields are only
+// populated for playlist items, where tracks come from many releases.
 type Track struct {
-	TrackID   int    `json:"trackId"`
-	SongTitle string `json:"songTitle"`
+	TrackID       int    `json:"trackId"`
+	SongID        int    `json:"songId"`
+	SongTitle     string `json:"songTitle"`
+	TrackNum      int    `json:"trackNum"`
+	DiscNum       int    `json:"discNum"`
+	SetNum        int    `json:"setNum"`
+	RunningTime   int    `json:"totalRunningTime"` // seconds
+	ArtistID      int    `json:"artistId"`
+	ArtistName    string `json:"artistName"`
+	ContainerID   int    `json:"containerId"`
+	ContainerInfo string `json:"containerInfo"`
 }
 
 // TrackMetadata represents metadata for tagging audio files
 type TrackMetadata struct {
-	Title    string
-	Artist   string
-	Album    string
-	TrackNum int
-	Year     string
+	Title       string
+	Artist      string
+	Album       string
+	AlbumArtist string
+	TrackNum    int
+	TrackTotal  int // tagged as N/M, or in its own field for FLAC
+	DiscNum     int // only set for releases on more than one disc
+	DiscTotal   int
+	Year        string
+	Comment     string
+	SourceID    string
+	Date        time.Time // show or release date, zero when unknown
+
+	// Nugs ids written as custom tags with idTags, as stable hooks for Picard or beets
+	ContainerID int
+	ArtistID    int
+	TrackID     int
+	SongID      int
+
+	// Names without leading articles, tagged with sortTags. Empty when the
+	// name doesn't start with one.
+	ArtistSort      string
+	AlbumSort       string
+	AlbumArtistSort string
 }
 
 // Error types for better error classification
@@ -222,10 +403,11 @@ type ArtistResp struct {
 type Payload struct {
 	LegacyToken string `json:"legacyToken"`
 	LegacyUguid string `json:"legacyUguid"`
+	Exp         int64  `json:"exp"`
 }
 
 // URL patterns for different content types
-var RegexStrings = [11]string{
+var RegexStrings = [12]string{
 	`^https://play.nugs.net/release/(\d+)$`,
 	`^https://play.nugs.net/#/playlists/playlist/(\d+)$`,
 	`^https://play.nugs.net/library/playlist/(\d+)$`,
@@ -236,8 +418,9 @@ var RegexStrings = [11]string{
 	`^https://play.nugs.net/watch/livestreams/exclusive/(\d+)$`,
 	`^https://play.nugs.net/#/my-webcasts/\d+-(\d+)-\d+-\d+$`,
 	`^https://www.nugs.net/on/demandware.store/Sites-NugsNet-Site/d` +
-		`efault/(?:Stash-QueueVideo|NugsVideo-GetStashVideo)\?([a-zA-Z0-9=%&-]+$)`,
+		`efault/(?:Stash-QueueVideo|NugsVideo-GetStashVideo)\?([^\s#]+)$`,
 	`^https://play.nugs.net/library/webcast/(\d+)$`,
+	`^https://play.nugs.net/release/(\d+/track/\d+)$`,
 }
 
 // Quality mappings
@@ -305,26 +488,142 @@ func GetItemTypeName(mediaType int) string {
 		return "livestream"
 	case 9:
 		return "paid_livestream"
+	case 11:
+		return "track"
 	default:
 		return "unknown"
 	}
 }
 
+// containerDateRegexes match show dates embedded in ContainerInfo strings
+var containerDateRegexes = []*regexp.Regexp{
+	regexp.MustCompile(`\b(\d{4}-\d{2}-\d{2})\b`),
+	regexp.MustCompile(`\b(\d{1,2}/\d{1,2}/\d{2}(?:\d{2})?)\b`),
+}
+
+// ParseContainerDate returns the performance/release date of a container,
+// falling back to a date embedded in ContainerInfo
+func ParseContainerDate(c *AlbArtResp) (time.Time, bool) {
+	if date, ok := parseShowDate(c.PerformanceDate); ok {
+		return date, true
+	}
+	for _, regex := range containerDateRegexes {
+		match := regex.FindStringSubmatch(c.ContainerInfo)
+		if match == nil {
+			continue
+		}
+		if date, ok := parseShowDate(match[1]); ok {
+			return date, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// Recording sources of live releases, from best to worst
+const (
+	SourceSoundboard = "soundboard"
+	SourceMatrix     = "matrix"
+	SourceAudience   = "audience"
+)
+
+// Sources lists the recording sources from best to worst
+var Sources = []string{SourceSoundboard, SourceMatrix, SourceAudience}
+
+// sourceRegexes match the recording source named in a release title. Matrix
+// comes first as matrix mixes are often described as soundboard plus audience.
+var sourceRegexes = []struct {
+	source string
+	regex  *regexp.Regexp
+}{
+	{SourceMatrix, regexp.MustCompile(`(?i)\b(matrix|mtx)\b`)},
+	{SourceSoundboard, regexp.MustCompile(`(?i)\b(soundboard|sbd)\b`)},
+	{SourceAudience, regexp.MustCompile(`(?i)\b(audience|aud)\b`)},
+}
+
+// ContainerSource returns the recording source a container is labelled with
+// in its title or product formats, or "" if it doesn't say
+func ContainerSource(c *AlbArtResp) string {
+	labels := []string{c.ContainerInfo}
+	for _, product := range c.Products {
+		labels = append(labels, product.FormatStr)
+	}
+	for _, label := range labels {
+		for _, sr := range sourceRegexes {
+			if sr.regex.MatchString(label) {
+				return sr.source
+			}
+		}
+	}
+	return ""
+}
+
+// parseShowDate parses the date layouts used by the Nugs API
+func parseShowDate(dateStr string) (time.Time, bool) {
+	dateStr = strings.TrimSpace(dateStr)
+	if dateStr == "" {
+		return time.Time{}, false
+	}
+	for _, layout := range []string{"2006-01-02", "1/2/2006", "1/2/06"} {
+		date, err := time.Parse(layout, dateStr)
+		if err == nil {
+			return date, true
+		}
+	}
+	return time.Time{}, false
+}
+
 // ExtractLegToken extracts legacy token from JWT
 func ExtractLegToken(tokenStr string) (string, string, error) {
-	payload := strings.SplitN(tokenStr, ".", 3)[1]
-	decoded, err := base64.RawURLEncoding.DecodeString(payload)
+	obj, err := decodePayload(tokenStr)
 	if err != nil {
 		return "", "", err
 	}
 
+	return obj.LegacyToken, obj.LegacyUguid, nil
+}
+
+// TokenExpiry returns when a JWT expires according to its exp claim
+func TokenExpiry(tokenStr string) (time.Time, error) {
+	obj, err := decodePayload(tokenStr)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if obj.Exp == 0 {
+		return time.Time{}, errors.New("token has no exp claim")
+	}
+
+	return time.Unix(obj.Exp, 0), nil
+}
+
+// IsTokenExpired reports whether a JWT's exp claim is in the past. Tokens
+// without a readable exp claim are treated as unexpired.
+func IsTokenExpired(tokenStr string) bool {
+	expiry, err := TokenExpiry(tokenStr)
+	if err != nil {
+		return false
+	}
+	return time.Now().After(expiry)
+}
+
+// decodePayload decodes the payload segment of a JWT
+func decodePayload(tokenStr string) (*Payload, error) {
+	parts := strings.SplitN(tokenStr, ".", 3)
+	if len(parts) < 2 {
+		return nil, errors.New("token isn't a JWT")
+	}
+
+	decoded, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, err
+	}
+
 	var obj Payload
 	err = json.Unmarshal(decoded, &obj)
 	if err != nil {
-		return "", "", err
+		return nil, err
 	}
 
-	return obj.LegacyToken, obj.LegacyUguid, nil
+	return &obj, nil
 }
 
 // ParseTimestamps parses start and end timestamps
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"regexp"
 	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -64,6 +65,164 @@ func (suite *ModelsTestSuite) TestWriteCounter_Write_ZeroTotal() {
 	assert.Equal(suite.T(), 0, wc.Percentage)
 }
 
+// TestWriteCounter_OnProgress tests that a progress callback receives events
+func (suite *ModelsTestSuite) TestWriteCounter_OnProgress() {
+	var events []ProgressEvent
+	wc := &WriteCounter{
+		Total:      20,
+		TotalStr:   "20 B",
+		StartTime:  time.Now().UnixMilli(),
+		Path:       "01. Track.flac",
+		OnProgress: func(event ProgressEvent) { events = append(events, event) },
+	}
+
+	wc.Write(make([]byte, 5))
+	wc.Write(make([]byte, 5))
+
+	assert.Len(suite.T(), events, 2)
+	assert.Equal(suite.T(), "01. Track.flac", events[1].Path)
+	assert.Equal(suite.T(), int64(10), events[1].Downloaded)
+	assert.Equal(suite.T(), int64(20), events[1].Total)
+	assert.Equal(suite.T(), 50, events[1].Percentage)
+}
+
+// TestWriteCounter_Limit tests that writes stop with ErrSampleLimit at the byte limit
+func (suite *ModelsTestSuite) TestWriteCounter_Limit() {
+	wc := &WriteCounter{
+		Total:      100,
+		StartTime:  time.Now().UnixMilli(),
+		OnProgress: func(ProgressEvent) {},
+		Limit:      8,
+	}
+
+	_, err := wc.Write(make([]byte, 5))
+	assert.NoError(suite.T(), err)
+	n, err := wc.Write(make([]byte, 5))
+	assert.ErrorIs(suite.T(), err, ErrSampleLimit)
+	assert.Equal(suite.T(), 5, n)
+}
+
+// TestWriteCounter_Tally tests that writes are added to the run-wide total
+// as well as the counter's own
+func (suite *ModelsTestSuite) TestWriteCounter_Tally() {
+	var tally atomic.Int64
+	tally.Store(10)
+	wc := &WriteCounter{
+		Total:      100,
+		Downloaded: 50,
+		StartTime:  time.Now().UnixMilli(),
+		OnProgress: func(ProgressEvent) {},
+		Tally:      &tally,
+	}
+
+	wc.Write(make([]byte, 5))
+	wc.Write(make([]byte, 7))
+	assert.Equal(suite.T(), int64(62), wc.Downloaded)
+	assert.Equal(suite.T(), int64(22), tally.Load())
+}
+
+// TestQualityLabel tests folder labels derived from quality specs
+func (suite *ModelsTestSuite) TestQualityLabel() {
+	tests := map[string]string{
+		"16-bit / 44.1 kHz ALAC": "ALAC16",
+		"16-bit / 44.1 kHz FLAC": "FLAC16",
+		"24-bit / 48 kHz MQA":    "MQA24",
+		"360 Reality Audio":      "360RA",
+		"150 Kbps AAC":           "AAC150",
+		"FLAC":                   "FLAC",
+		"":                       "",
+	}
+
+	for specs, want := range tests {
+		assert.Equal(suite.T(), want, QualityLabel(specs), specs)
+	}
+}
+
+// TestIsLossless tests which track formats count as lossless sources
+func (suite *ModelsTestSuite) TestIsLossless() {
+	for _, format := range []int{1, 2, 3} {
+		assert.True(suite.T(), IsLossless(format), format)
+	}
+	for _, format := range []int{0, 4, 5} {
+		assert.False(suite.T(), IsLossless(format), format)
+	}
+}
+
+// TestSortName tests leading articles being stripped for sort tags
+func (suite *ModelsTestSuite) TestSortName() {
+	tests := map[string]string{
+		"The Grateful Dead": "Grateful Dead",
+		"the band":          "band",
+		"A Perfect Circle":  "Perfect Circle",
+		"An Evening With":   "Evening With",
+		"Theatre Brook":     "Theatre Brook",
+		"Phish":             "Phish",
+		"The":               "The",
+		"":                  "",
+	}
+	for name, want := range tests {
+		assert.Equal(suite.T(), want, SortName(name, DefaultSortArticles), name)
+	}
+
+	assert.Equal(suite.T(), "Beatles", SortName("Die Beatles", []string{"Die"}))
+	assert.Equal(suite.T(), "The Band", SortName("The Band", nil))
+}
+
+// TestFormatETA tests the remaining-time suffix of the progress line
+func (suite *ModelsTestSuite) TestFormatETA() {
+	assert.Equal(suite.T(), ", ~2m30s left", FormatETA(150*1000, 1000))
+	assert.Equal(suite.T(), ", ~1h0m0s left", FormatETA(3600, 1))
+	assert.Equal(suite.T(), ", <1s left", FormatETA(10, 1000))
+
+	// Unknown total, stalled, or finished downloads have no ETA
+	assert.Equal(suite.T(), "", FormatETA(-500, 1000))
+	assert.Equal(suite.T(), "", FormatETA(1000, 0))
+	assert.Equal(suite.T(), "", FormatETA(0, 1000))
+}
+
+// TestParseContainerDate tests container date parsing
+func (suite *ModelsTestSuite) TestParseContainerDate() {
+	testCases := []struct {
+		container *AlbArtResp
+		expected  string
+		ok        bool
+	}{
+		{&AlbArtResp{PerformanceDate: "4/26/2023"}, "2023-04-26", true},
+		{&AlbArtResp{ContainerInfo: "04/26/23 Red Rocks Amphitheatre"}, "2023-04-26", true},
+		{&AlbArtResp{ContainerInfo: "Live at Red Rocks 2023-04-26"}, "2023-04-26", true},
+		{&AlbArtResp{PerformanceDate: "bogus", ContainerInfo: "12/31/1999 Show"}, "1999-12-31", true},
+		{&AlbArtResp{ContainerInfo: "Studio Album"}, "", false},
+	}
+
+	for _, tc := range testCases {
+		date, ok := ParseContainerDate(tc.container)
+		assert.Equal(suite.T(), tc.ok, ok, "Failed for container: %+v", tc.container)
+		if tc.ok {
+			assert.Equal(suite.T(), tc.expected, date.Format("2006-01-02"))
+		}
+	}
+}
+
+// TestContainerSource tests recording source labels
+func (suite *ModelsTestSuite) TestContainerSource() {
+	testCases := []struct {
+		container *AlbArtResp
+		expected  string
+	}{
+		{&AlbArtResp{ContainerInfo: "12/31/99 Show (Soundboard)"}, SourceSoundboard},
+		{&AlbArtResp{ContainerInfo: "12/31/99 Show [SBD]"}, SourceSoundboard},
+		{&AlbArtResp{ContainerInfo: "12/31/99 Show - SBD/AUD Matrix"}, SourceMatrix},
+		{&AlbArtResp{ContainerInfo: "12/31/99 Show (AUD)"}, SourceAudience},
+		{&AlbArtResp{ContainerInfo: "12/31/99 Show", Products: []Product{{FormatStr: "Audience Recording"}}}, SourceAudience},
+		{&AlbArtResp{ContainerInfo: "Audio Sampler"}, ""},
+		{&AlbArtResp{ContainerInfo: "Studio Album"}, ""},
+	}
+
+	for _, tc := range testCases {
+		assert.Equal(suite.T(), tc.expected, ContainerSource(tc.container), tc.container.ContainerInfo)
+	}
+}
+
 // TestCheckUrl_Album tests URL pattern matching for albums
 func (suite *ModelsTestSuite) TestCheckUrl_Album() {
 	url := "https://play.nugs.net/release/12345"
@@ -99,6 +258,63 @@ func (suite *ModelsTestSuite) TestCheckUrl_Artist() {
 	assert.Equal(suite.T(), 5, mediaType2)
 }
 
+// TestCheckUrl_MyWebcast tests the show id is taken from My Webcasts URLs
+func (suite *ModelsTestSuite) TestCheckUrl_MyWebcast() {
+	id, mediaType := CheckUrl("https://play.nugs.net/#/my-webcasts/5826189-30486-0-624602")
+
+	assert.Equal(suite.T(), "30486", id)
+	assert.Equal(suite.T(), 8, mediaType)
+}
+
+// TestCheckUrl_Track tests URL pattern matching for single tracks
+func (suite *ModelsTestSuite) TestCheckUrl_Track() {
+	id, mediaType := CheckUrl("https://play.nugs.net/release/12345/track/678")
+
+	assert.Equal(suite.T(), "12345/track/678", id)
+	assert.Equal(suite.T(), 11, mediaType)
+	assert.Equal(suite.T(), "track", GetItemTypeName(mediaType))
+}
+
+// TestCheckUrl_Stash tests both purchased livestream URL shapes capture the query
+func (suite *ModelsTestSuite) TestCheckUrl_Stash() {
+	base := "https://www.nugs.net/on/demandware.store/Sites-NugsNet-Site/default/"
+	tests := []struct {
+		endpoint string
+		query    string
+	}{
+		{"Stash-QueueVideo", "skuID=624598&showID=30367&perfDate=10-29-2022&artistName=Billy%20Strings&format=liveHdStream"},
+		{"NugsVideo-GetStashVideo", "containerId=30367&skuId=624598&artist_name=Billy+Strings&venue=Exploreasheville.com"},
+	}
+
+	for _, test := range tests {
+		query, mediaType := CheckUrl(base + test.endpoint + "?" + test.query)
+		assert.Equal(suite.T(), test.query, query, test.endpoint)
+		assert.Equal(suite.T(), 9, mediaType, test.endpoint)
+	}
+}
+
+// TestCheckUrl_ArtistVideo tests that artist video URLs capture the final id
+// even when the title contains numbers or slashes
+func (suite *ModelsTestSuite) TestCheckUrl_ArtistVideo() {
+	tests := map[string]string{
+		"https://play.nugs.net/#/videos/artist/1045/Dead and Company/27323":       "27323",
+		"https://play.nugs.net/#/videos/artist/1045/Summer Tour 2023/27323":       "27323",
+		"https://play.nugs.net/#/videos/artist/1045/Night 2/Set 1/27323":          "27323",
+		"https://play.nugs.net/#/videos/artist/1045/1999/27323":                   "27323",
+		"https://play.nugs.net/#/videos/artist/1045/Live at Red Rocks 6/20/27323": "27323",
+	}
+
+	for url, want := range tests {
+		id, mediaType := CheckUrl(url)
+		assert.Equal(suite.T(), want, id, url)
+		assert.Equal(suite.T(), 4, mediaType, url)
+	}
+
+	// No title segment, so there's nothing to tell the id apart from
+	id, _ := CheckUrl("https://play.nugs.net/#/videos/artist/1045/27323")
+	assert.Equal(suite.T(), "", id)
+}
+
 // TestCheckUrl_Invalid tests invalid URL
 func (suite *ModelsTestSuite) TestCheckUrl_Invalid() {
 	url := "https://invalid-url.com"
@@ -175,6 +391,36 @@ func (suite *ModelsTestSuite) TestExtractLegToken_InvalidJSON() {
 	assert.Error(suite.T(), err)
 }
 
+// TestTokenExpiry tests reading the exp claim from a JWT
+func (suite *ModelsTestSuite) TestTokenExpiry() {
+	payloadBytes, _ := json.Marshal(Payload{LegacyToken: "test-legacy-token", Exp: 1700000000})
+	token := "header." + base64.RawURLEncoding.EncodeToString(payloadBytes) + ".signature"
+
+	expiry, err := TokenExpiry(token)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), int64(1700000000), expiry.Unix())
+
+	payloadBytes, _ = json.Marshal(Payload{LegacyToken: "test-legacy-token"})
+	_, err = TokenExpiry("header." + base64.RawURLEncoding.EncodeToString(payloadBytes) + ".signature")
+	assert.Error(suite.T(), err)
+
+	_, err = TokenExpiry("not-a-jwt")
+	assert.Error(suite.T(), err)
+}
+
+// TestIsTokenExpired tests expiry checks on pasted tokens
+func (suite *ModelsTestSuite) TestIsTokenExpired() {
+	makeToken := func(exp int64) string {
+		payloadBytes, _ := json.Marshal(Payload{LegacyToken: "test-legacy-token", Exp: exp})
+		return "header." + base64.RawURLEncoding.EncodeToString(payloadBytes) + ".signature"
+	}
+
+	assert.True(suite.T(), IsTokenExpired(makeToken(time.Now().Add(-time.Hour).Unix())))
+	assert.False(suite.T(), IsTokenExpired(makeToken(time.Now().Add(time.Hour).Unix())))
+	assert.False(suite.T(), IsTokenExpired(makeToken(0)))
+	assert.False(suite.T(), IsTokenExpired("not-a-jwt"))
+}
+
 // TestParseTimestamps tests timestamp parsing
 func (suite *ModelsTestSuite) TestParseTimestamps() {
 	start := "01/15/2024 10:30:00"
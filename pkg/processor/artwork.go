@@ -0,0 +1,176 @@
+package processor
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"main/pkg/downloader"
+	"main/pkg/logger"
+	"main/pkg/models"
+)
+
+// imageHost serves the relative image URLs in release metadata
+const imageHost = "https://secure.livedownloads.com"
+
+// Picture types ffmpeg maps to APIC and FLAC picture blocks
+const (
+	pictureFront = "Cover (front)"
+	pictureBack  = "Cover (back)"
+	pictureDisc  = "Media (e.g. label side of CD)"
+)
+
+// artworkFormats are the track extensions that can hold attached pictures
+var artworkFormats = map[string]bool{
+	".flac": true,
+	".m4a":  true,
+}
+
+// releasePictures picks the images to embed for a release, keyed by picture
+// type. The front cover is the release image, and with mode "all" the back
+// cover and disc art are picked from the extra pictures by their captions.
+func releasePictures(meta *models.AlbArtResp, mode string) map[string]string {
+	pics := map[string]string{}
+	if meta == nil || mode == "" {
+		return pics
+	}
+	if meta.Img.URL != "" {
+		pics[pictureFront] = meta.Img.URL
+	}
+	if mode != "all" {
+		return pics
+	}
+
+	for _, pic := range meta.Pics {
+		if pic.URL == "" {
+			continue
+		}
+		caption := strings.ToLower(pic.Caption)
+		var picType string
+		switch {
+		case strings.Contains(caption, "back"):
+			picType = pictureBack
+		case strings.Contains(caption, "disc"):
+			picType = pictureDisc
+		case strings.Contains(caption, "front"), strings.Contains(caption, "cover"):
+			picType = pictureFront
+		default:
+			continue
+		}
+		if _, ok := pics[picType]; !ok {
+			pics[picType] = pic.URL
+		}
+	}
+	return pics
+}
+
+// imageURL resolves a picture URL against the image host
+func imageURL(picUrl string) string {
+	if strings.HasPrefix(picUrl, "http://") || strings.HasPrefix(picUrl, "https://") {
+		return picUrl
+	}
+	return imageHost + "/" + strings.TrimPrefix(picUrl, "/")
+}
+
+// fetchArtwork downloads the release artwork chosen with the artwork option
+// into a temp folder. Images that fail to download are skipped. The returned
+// cleanup func removes the folder.
+func (p *Processor) fetchArtwork(meta *models.AlbArtResp) ([]models.Artwork, func()) {
+	noop := func() {}
+	pics := releasePictures(meta, p.config.Artwork)
+	if len(pics) == 0 {
+		return nil, noop
+	}
+
+	dir, err := os.MkdirTemp("", "nugs-artwork-")
+	if err != nil {
+		logger.GetLogger().WithError(err).Warn("Failed to create artwork folder")
+		return nil, noop
+	}
+
+	var artwork []models.Artwork
+	// Fixed order so the front cover is always the first picture
+	for i, picType := range []string{pictureFront, pictureBack, pictureDisc} {
+		picUrl, ok := pics[picType]
+		if !ok {
+			continue
+		}
+		path := filepath.Join(dir, fmt.Sprintf("%d%s", i, filepath.Ext(picUrl)))
+		if err := p.downloadImage(imageURL(picUrl), path); err != nil {
+			logger.GetLogger().WithFields(map[string]interface{}{
+				"url":  picUrl,
+				"type": picType,
+			}).WithError(err).Warn("Failed to download artwork")
+			continue
+		}
+		artwork = append(artwork, models.Artwork{Path: path, Type: picType})
+	}
+	return artwork, func() { os.RemoveAll(dir) }
+}
+
+// pendingArtwork is release artwork being downloaded in the background
+type pendingArtwork struct {
+	done    chan struct{}
+	artwork []models.Artwork
+	remove  func()
+}
+
+// wait blocks until the artwork has downloaded and returns it
+func (a *pendingArtwork) wait() []models.Artwork {
+	<-a.done
+	return a.artwork
+}
+
+// useArtwork starts fetching a release's artwork for the tracks downloaded
+// next, so it downloads alongside the first track rather than before it. The
+// returned func clears and removes it once the release is done.
+func (p *Processor) useArtwork(meta *models.AlbArtResp) func() {
+	if len(releasePictures(meta, p.config.Artwork)) == 0 {
+		return func() {}
+	}
+
+	pending := &pendingArtwork{done: make(chan struct{})}
+	go func() {
+		defer close(pending.done)
+		pending.artwork, pending.remove = p.fetchArtwork(meta)
+	}()
+	p.artwork = pending
+
+	return func() {
+		pending.wait()
+		p.artwork = nil
+		pending.remove()
+	}
+}
+
+// downloadImage saves an image to path, leaving nothing there if the
+// download is cut short or isn't an image
+func (p *Processor) downloadImage(url, path string) error {
+	return p.downloader.SafeDownloadAsset(path, url, downloader.ValidateImage)
+}
+
+// embedArtwork embeds the current release's artwork in a finished track,
+// waiting for it to finish downloading first. Artwork is a nice-to-have, so
+// failures are logged and the track is kept as is.
+func (p *Processor) embedArtwork(trackPath string) {
+	if p.artwork == nil || !artworkFormats[strings.ToLower(filepath.Ext(trackPath))] {
+		return
+	}
+	artwork := p.artwork.wait()
+	if len(artwork) == 0 {
+		return
+	}
+
+	ext := filepath.Ext(trackPath)
+	tempPath := strings.TrimSuffix(trackPath, ext) + ".artwork" + ext
+	err := downloader.EmbedArtwork(trackPath, tempPath, p.config.FfmpegNameStr, artwork)
+	if err == nil {
+		err = os.Rename(tempPath, trackPath)
+	}
+	if err != nil {
+		os.Remove(tempPath)
+		logger.GetLogger().WithError(err).WithField("path", trackPath).Warn("Failed to embed artwork")
+		fmt.Println("Failed to embed artwork, keeping the track without it.")
+	}
+}
@@ -0,0 +1,144 @@
+package processor
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"main/pkg/fsutil"
+)
+
+// CheckpointState holds the URLs of a batch that have completed
+type CheckpointState struct {
+	Completed []string  `json:"completed"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// BatchCheckpoint records which URLs of a URL list have completed, so an
+// interrupted run of the same list can skip them without fetching their
+// metadata again. A nil checkpoint records nothing.
+type BatchCheckpoint struct {
+	path  string
+	urls  []string
+	state CheckpointState
+	done  map[string]bool
+	mu    sync.Mutex
+}
+
+// batchHash identifies a URL list by its contents, so editing the list
+// starts a new batch
+func batchHash(urls []string) string {
+	sum := sha256.Sum256([]byte(strings.Join(urls, "\n")))
+	return hex.EncodeToString(sum[:])
+}
+
+// OpenBatchCheckpoint loads the checkpoint of a URL list from dir, or starts
+// an empty one if the list hasn't been run before
+func OpenBatchCheckpoint(dir string, urls []string) (*BatchCheckpoint, error) {
+	c := &BatchCheckpoint{
+		path: filepath.Join(dir, batchHash(urls)+".json"),
+		urls: urls,
+		done: map[string]bool{},
+	}
+
+	data, err := os.ReadFile(c.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return c, nil
+		}
+		return nil, fmt.Errorf("failed to read batch checkpoint: %w", err)
+	}
+	if err := json.Unmarshal(data, &c.state); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal batch checkpoint: %w", err)
+	}
+	for _, url := range c.state.Completed {
+		c.done[url] = true
+	}
+
+	return c, nil
+}
+
+// Completed returns how many URLs of the list completed in earlier runs
+func (c *BatchCheckpoint) Completed() int {
+	if c == nil {
+		return 0
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return len(c.done)
+}
+
+// Done reports whether a URL has completed
+func (c *BatchCheckpoint) Done(url string) bool {
+	if c == nil {
+		return false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.done[url]
+}
+
+// MarkDone records a URL as completed and saves the checkpoint
+func (c *BatchCheckpoint) MarkDone(url string) error {
+	if c == nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.done[url] {
+		return nil
+	}
+	c.done[url] = true
+	c.state.Completed = append(c.state.Completed, url)
+	c.state.UpdatedAt = time.Now()
+
+	if err := os.MkdirAll(filepath.Dir(c.path), 0755); err != nil {
+		return fmt.Errorf("failed to create batch checkpoint directory: %w", err)
+	}
+
+	data, err := json.MarshalIndent(c.state, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal batch checkpoint: %w", err)
+	}
+
+	// Write to temporary file first for atomicity
+	tempFile := fsutil.TempPathFor(c.path)
+	if err := os.WriteFile(tempFile, data, 0644); err != nil {
+		return fmt.Errorf("failed to write batch checkpoint: %w", err)
+	}
+	if err := os.Rename(tempFile, c.path); err != nil {
+		os.Remove(tempFile)
+		return fmt.Errorf("failed to save batch checkpoint: %w", err)
+	}
+
+	return nil
+}
+
+// Finish deletes the checkpoint once every URL in the list has completed, so
+// running the list again starts from the top
+func (c *BatchCheckpoint) Finish() error {
+	if c == nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, url := range c.urls {
+		if !c.done[url] {
+			return nil
+		}
+	}
+	if err := os.Remove(c.path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to delete batch checkpoint: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package processor
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type CheckpointTestSuite struct {
+	suite.Suite
+	tempDir string
+	urls    []string
+}
+
+func (suite *CheckpointTestSuite) SetupTest() {
+	tempDir, err := os.MkdirTemp("", "checkpoint_test_*")
+	suite.Require().NoError(err)
+	suite.tempDir = tempDir
+
+	suite.urls = []string{
+		"https://play.nugs.net/release/23329",
+		"https://play.nugs.net/release/23330",
+	}
+}
+
+func (suite *CheckpointTestSuite) TearDownTest() {
+	os.RemoveAll(suite.tempDir)
+}
+
+func (suite *CheckpointTestSuite) TestMarkDone_Persists() {
+	checkpoint, err := OpenBatchCheckpoint(suite.tempDir, suite.urls)
+	suite.Require().NoError(err)
+	suite.Equal(0, checkpoint.Completed())
+	suite.NoError(checkpoint.MarkDone(suite.urls[0]))
+
+	// Reopening the same list resumes it
+	checkpoint, err = OpenBatchCheckpoint(suite.tempDir, suite.urls)
+	suite.Require().NoError(err)
+	suite.Equal(1, checkpoint.Completed())
+	suite.True(checkpoint.Done(suite.urls[0]))
+	suite.False(checkpoint.Done(suite.urls[1]))
+}
+
+func (suite *CheckpointTestSuite) TestOpen_DifferentList() {
+	checkpoint, err := OpenBatchCheckpoint(suite.tempDir, suite.urls)
+	suite.Require().NoError(err)
+	suite.NoError(checkpoint.MarkDone(suite.urls[0]))
+
+	checkpoint, err = OpenBatchCheckpoint(suite.tempDir, append(suite.urls, "https://play.nugs.net/release/23331"))
+	suite.Require().NoError(err)
+	suite.Equal(0, checkpoint.Completed())
+}
+
+func (suite *CheckpointTestSuite) TestFinish() {
+	checkpoint, err := OpenBatchCheckpoint(suite.tempDir, suite.urls)
+	suite.Require().NoError(err)
+	suite.NoError(checkpoint.MarkDone(suite.urls[0]))
+
+	// Unfinished lists keep their checkpoint
+	suite.NoError(checkpoint.Finish())
+	suite.FileExists(checkpoint.path)
+
+	suite.NoError(checkpoint.MarkDone(suite.urls[1]))
+	suite.NoError(checkpoint.Finish())
+	suite.NoFileExists(checkpoint.path)
+}
+
+func (suite *CheckpointTestSuite) TestOpen_Corrupt() {
+	path := filepath.Join(suite.tempDir, batchHash(suite.urls)+".json")
+	suite.Require().NoError(os.WriteFile(path, []byte("{not json"), 0644))
+
+	_, err := OpenBatchCheckpoint(suite.tempDir, suite.urls)
+	suite.Error(err)
+}
+
+func (suite *CheckpointTestSuite) TestNilCheckpoint() {
+	var checkpoint *BatchCheckpoint
+	suite.False(checkpoint.Done(suite.urls[0]))
+	suite.NoError(checkpoint.MarkDone(suite.urls[0]))
+	suite.NoError(checkpoint.Finish())
+	suite.Equal(0, checkpoint.Completed())
+}
+
+func TestCheckpointTestSuite(t *testing.T) {
+	suite.Run(t, new(CheckpointTestSuite))
+}
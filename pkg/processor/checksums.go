@@ -0,0 +1,41 @@
+package processor
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"main/pkg/downloader"
+)
+
+// checksumsFile is the album manifest written with albumChecksums
+const checksumsFile = "checksums.md5"
+
+// recordTrack notes a finished track for the album's checksum manifest, CUE
+// sheet or remote move
+func (p *Processor) recordTrack(path string) {
+	if p.trackPaths != nil {
+		*p.trackPaths = append(*p.trackPaths, path)
+	}
+}
+
+// writeChecksums writes an md5sum-compatible manifest of the given tracks to
+// path, listing them relative to its folder
+func writeChecksums(path string, trackPaths []string) error {
+	albumPath := filepath.Dir(path)
+	var manifest strings.Builder
+	for _, trackPath := range trackPaths {
+		sum, err := downloader.CalculateChecksum(trackPath)
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(albumPath, trackPath)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(&manifest, "%s  %s\n", sum, filepath.ToSlash(rel))
+	}
+
+	return os.WriteFile(path, []byte(manifest.String()), 0644)
+}
@@ -0,0 +1,80 @@
+package processor
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"main/pkg/downloader"
+	"main/pkg/models"
+)
+
+// convertedPath returns where a track converted to the given target is saved
+func convertedPath(trackPath, target string) string {
+	return strings.TrimSuffix(trackPath, filepath.Ext(trackPath)) + "." + target
+}
+
+// wantsConversion reports whether a track in the given quality should be
+// converted after download. Only lossless sources are converted.
+func (p *Processor) wantsConversion(qual *models.Quality) bool {
+	return p.config.ConvertTo != "" && models.IsLossless(qual.Format)
+}
+
+// convertTrack converts a downloaded lossless track to the configured target
+// format and removes the original, returning the path of the converted file
+func (p *Processor) convertTrack(trackPath string, qual *models.Quality, metadata *models.TrackMetadata) (string, error) {
+	if !p.wantsConversion(qual) {
+		if p.config.ConvertTo != "" {
+			fmt.Println("Lossy source, skipping conversion.")
+		}
+		return trackPath, nil
+	}
+
+	outPath := convertedPath(trackPath, p.config.ConvertTo)
+	fmt.Printf("Converting to %s...\n", strings.ToUpper(p.config.ConvertTo))
+	err := downloader.ConvertAudio(trackPath, outPath, p.config.FfmpegNameStr, p.config.ConvertTo, bitDepth(qual.Specs), metadata)
+	if err != nil {
+		os.Remove(outPath)
+		fmt.Println("Failed to convert track.")
+		return "", err
+	}
+
+	if err := os.Remove(trackPath); err != nil {
+		return "", fmt.Errorf("failed to remove original after conversion: %w", err)
+	}
+	return outPath, nil
+}
+
+// bitDepth returns the bit depth from quality specs like "24-bit / 48 kHz MQA",
+// or 0 if the specs don't say
+func bitDepth(specs string) int {
+	fields := strings.Fields(specs)
+	if len(fields) == 0 {
+		return 0
+	}
+	depth, ok := strings.CutSuffix(fields[0], "-bit")
+	if !ok {
+		return 0
+	}
+	n, _ := strconv.Atoi(depth)
+	return n
+}
+
+// sampleRate returns the sample rate in Hz from quality specs like
+// "16-bit / 44.1 kHz FLAC", or 0 if the specs don't say
+func sampleRate(specs string) int {
+	fields := strings.Fields(specs)
+	for i := 1; i < len(fields); i++ {
+		if fields[i] != "kHz" {
+			continue
+		}
+		khz, err := strconv.ParseFloat(fields[i-1], 64)
+		if err != nil {
+			return 0
+		}
+		return int(khz * 1000)
+	}
+	return 0
+}
@@ -0,0 +1,173 @@
+package processor
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"main/pkg/downloader"
+	"main/pkg/logger"
+	"main/pkg/models"
+)
+
+// cueFramesPerSecond is the resolution of CUE sheet timestamps, in CD frames
+const cueFramesPerSecond = 75
+
+// joinedAlbumPath is where --cue-sheet joins an album's tracks
+func (p *Processor) joinedAlbumPath(albumPath, albumFolder string) string {
+	return filepath.Join(albumPath, downloader.Sanitise(albumFolder)+".flac")
+}
+
+// joinAlbum joins an album's tracks into one FLAC file with a CUE sheet of
+// the track offsets, kept next to it, embedded or both, and removes the
+// tracks unless --keep-tracks is set. Albums with missing or non-FLAC tracks
+// are left as they are. It returns the album's files for the checksum manifest.
+func (p *Processor) joinAlbum(albumPath, albumFolder string, meta *models.AlbArtResp, tracks []models.Track, trackPaths []string) []string {
+	if len(trackPaths) != len(tracks) {
+		fmt.Println("Not every track was downloaded, so the album wasn't joined into one file.")
+		return trackPaths
+	}
+	for _, trackPath := range trackPaths {
+		if !strings.EqualFold(filepath.Ext(trackPath), ".flac") {
+			fmt.Println("Only FLAC albums can be joined into one file, kept as tracks.")
+			return trackPaths
+		}
+	}
+
+	offsets, err := trackOffsets(trackPaths)
+	if err != nil {
+		logger.GetLogger().WithError(err).WithField("path", albumPath).Warn("Failed to read track lengths")
+		fmt.Println("Failed to read track lengths, kept as tracks.")
+		return trackPaths
+	}
+
+	outPath := p.joinedAlbumPath(albumPath, albumFolder)
+	cue := formatCueSheet(meta, tracks, filepath.Base(outPath), offsets)
+	embedded := ""
+	if p.config.CueSheet == "embed" || p.config.CueSheet == "both" {
+		embedded = cue
+	}
+	metadata := &models.TrackMetadata{
+		Artist:   meta.ArtistName,
+		Album:    meta.ContainerInfo,
+		Comment:  p.trackComment(),
+		SourceID: strconv.Itoa(meta.ContainerID),
+	}
+	metadata.Date, _ = models.ParseContainerDate(meta)
+
+	fmt.Println("Joining tracks into one file...")
+	if err := downloader.ConcatFlac(trackPaths, outPath, p.config.FfmpegNameStr, metadata, embedded); err != nil {
+		logger.GetLogger().WithError(err).WithField("path", outPath).Warn("Failed to join album tracks")
+		fmt.Println("Failed to join tracks into one file, kept as tracks.")
+		return trackPaths
+	}
+	p.embedArtwork(outPath)
+
+	files := []string{outPath}
+	if p.config.CueSheet == "sidecar" || p.config.CueSheet == "both" {
+		cuePath := strings.TrimSuffix(outPath, filepath.Ext(outPath)) + ".cue"
+		if err := os.WriteFile(cuePath, []byte(cue), 0644); err != nil {
+			logger.GetLogger().WithError(err).WithField("path", cuePath).Warn("Failed to write CUE sheet")
+			fmt.Println("Failed to write CUE sheet.")
+		}
+	}
+
+	if p.config.KeepTracks {
+		return append(trackPaths, files...)
+	}
+	for _, trackPath := range trackPaths {
+		os.Remove(trackPath)
+	}
+	// Disc and format subfolders are left empty once their tracks are gone.
+	// Only empty folders can be removed, so the album folder is safe.
+	for _, trackPath := range trackPaths {
+		if dir := filepath.Dir(trackPath); dir != albumPath {
+			os.Remove(dir)
+			if p.discFolders && filepath.Dir(dir) != albumPath {
+				os.Remove(filepath.Dir(dir))
+			}
+		}
+	}
+	return files
+}
+
+// trackOffsets returns where each track starts in the joined file, in CUE
+// frames, from the sample counts in their FLAC headers. The tracks have to
+// share a sample rate to be joined.
+func trackOffsets(trackPaths []string) ([]int64, error) {
+	offsets := make([]int64, len(trackPaths))
+	var samples int64
+	var rate int
+	for i, trackPath := range trackPaths {
+		trackRate, trackSamples, err := flacSamples(trackPath)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", filepath.Base(trackPath), err)
+		}
+		if i == 0 {
+			rate = trackRate
+		} else if trackRate != rate {
+			return nil, fmt.Errorf("%s: sample rate %d doesn't match %d", filepath.Base(trackPath), trackRate, rate)
+		}
+		offsets[i] = samples * cueFramesPerSecond / int64(rate)
+		samples += trackSamples
+	}
+	return offsets, nil
+}
+
+// flacSamples reads a FLAC file's sample rate and total sample count from
+// its STREAMINFO block, which the format requires to come first
+func flacSamples(path string) (rate int, samples int64, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer f.Close()
+
+	// "fLaC", the block header, then 10 bytes of block and frame sizes
+	// before the packed sample rate, channels, bit depth and sample count
+	header := make([]byte, 26)
+	if _, err := io.ReadFull(f, header); err != nil {
+		return 0, 0, errors.New("not a FLAC file")
+	}
+	if string(header[:4]) != "fLaC" || header[4]&0x7f != 0 {
+		return 0, 0, errors.New("not a FLAC file")
+	}
+	packed := binary.BigEndian.Uint64(header[18:26])
+	rate = int(packed >> 44)
+	samples = int64(packed & (1<<36 - 1))
+	if rate == 0 || samples == 0 {
+		return 0, 0, errors.New("FLAC header has no sample count")
+	}
+	return rate, samples, nil
+}
+
+// formatCueSheet renders a CUE sheet for the joined file fileName, with each
+// track starting at its offset in CUE frames
+func formatCueSheet(meta *models.AlbArtResp, tracks []models.Track, fileName string, offsets []int64) string {
+	// CUE values are double-quoted with no way to escape quotes
+	quote := func(s string) string {
+		return `"` + strings.ReplaceAll(strings.TrimSpace(s), `"`, "'") + `"`
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "PERFORMER %s\n", quote(meta.ArtistName))
+	fmt.Fprintf(&b, "TITLE %s\n", quote(meta.ContainerInfo))
+	if date, ok := models.ParseContainerDate(meta); ok {
+		fmt.Fprintf(&b, "REM DATE %s\n", date.Format("2006-01-02"))
+	}
+	fmt.Fprintf(&b, "FILE %s WAVE\n", quote(fileName))
+	for i, track := range tracks {
+		frames := offsets[i]
+		fmt.Fprintf(&b, "  TRACK %02d AUDIO\n", i+1)
+		fmt.Fprintf(&b, "    TITLE %s\n", quote(track.SongTitle))
+		fmt.Fprintf(&b, "    PERFORMER %s\n", quote(meta.ArtistName))
+		fmt.Fprintf(&b, "    INDEX 01 %02d:%02d:%02d\n",
+			frames/(60*cueFramesPerSecond), frames/cueFramesPerSecond%60, frames%cueFramesPerSecond)
+	}
+	return b.String()
+}
@@ -0,0 +1,62 @@
+package processor
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"main/pkg/models"
+)
+
+// jsonMetaOutput is where --json-meta prints, kept on stdout for piping into
+// tools like jq while everything else goes to stderr
+var jsonMetaOutput io.Writer = os.Stdout
+
+// SetJSONMetaOutput sets where --json-meta prints the metadata
+func SetJSONMetaOutput(w io.Writer) {
+	jsonMetaOutput = w
+}
+
+// DumpMeta fetches the metadata for a URL with --json-meta and prints it as
+// indented JSON instead of downloading. It's the parsed API response, so
+// fields the models don't know about are left out.
+func (p *Processor) DumpMeta(itemId string, mediaType int, legacyToken string) error {
+	var (
+		meta interface{}
+		err  error
+	)
+	switch mediaType {
+	case 0, 4, 6, 7, 8, 10:
+		meta, err = p.apiClient.GetAlbumMeta(itemId)
+	case 1, 2:
+		meta, err = p.apiClient.GetPlistMeta(itemId, p.config.Email, legacyToken, false)
+	case 3:
+		plistId, resolveErr := p.resolveCatPlistId(itemId)
+		if resolveErr != nil {
+			return resolveErr
+		}
+		meta, err = p.apiClient.GetPlistMeta(plistId, p.config.Email, legacyToken, true)
+	case 5:
+		meta, err = p.apiClient.GetArtistMeta(itemId)
+	case 9:
+		showId, idErr := stashShowID(itemId)
+		if idErr != nil {
+			return idErr
+		}
+		meta, err = p.apiClient.GetAlbumMeta(showId)
+	case 11:
+		releaseId, _, _ := strings.Cut(itemId, "/track/")
+		meta, err = p.apiClient.GetAlbumMeta(releaseId)
+	default:
+		return fmt.Errorf("metadata dumps aren't supported for %s URLs", models.GetItemTypeName(mediaType))
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get %s metadata: %w", models.GetItemTypeName(mediaType), err)
+	}
+
+	enc := json.NewEncoder(jsonMetaOutput)
+	enc.SetIndent("", "  ")
+	return enc.Encode(meta)
+}
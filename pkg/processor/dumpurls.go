@@ -0,0 +1,46 @@
+package processor
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"main/pkg/logger"
+	"main/pkg/models"
+)
+
+// dumpOutput is where --dump-urls prints, kept apart from progress output so
+// the URLs can be redirected on their own
+var dumpOutput io.Writer = os.Stderr
+
+// dumpTrackURLs resolves the stream URLs of tracks with --dump-urls and prints
+// them instead of downloading. Tracks that fail to resolve are reported and
+// skipped.
+func (p *Processor) dumpTrackURLs(tracks []models.Track, streamParams *models.StreamParams) error {
+	var failures int
+	for i, track := range tracks {
+		qual, _, _, err := p.chooseTrackQuality(&track, streamParams)
+		if errors.Is(err, models.ErrFormatUnavailable) {
+			continue
+		}
+		if err != nil {
+			failures++
+			logger.GetLogger().WithError(err).WithField("track", track.SongTitle).Error("Failed to resolve track stream URL")
+			fmt.Printf("Failed to resolve track %d: %v\n", i+1, err)
+			continue
+		}
+		fmt.Fprintf(dumpOutput, "Track %d: %s (%s)\n%s\n", i+1, track.SongTitle, qual.Specs, qual.URL)
+	}
+
+	if failures > 0 {
+		return fmt.Errorf("%d of %d track URLs failed to resolve", failures, len(tracks))
+	}
+	return nil
+}
+
+// dumpVideoURLs prints a video's manifest URL and the variant playlist chosen
+// from it with --dump-urls
+func dumpVideoURLs(manifestUrl, variantUrl, res string) {
+	fmt.Fprintf(dumpOutput, "Manifest: %s\nVariant (%s): %s\n", manifestUrl, res, variantUrl)
+}
@@ -0,0 +1,58 @@
+package processor
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"main/pkg/downloader"
+	"main/pkg/logger"
+	"main/pkg/models"
+)
+
+// fallbackSampleRate is used for filtered tracks whose specs don't give a
+// rate, which is what most Nugs audio is recorded at
+const fallbackSampleRate = 44100
+
+// audioFilters returns the clean-up passes turned on in the config
+func (p *Processor) audioFilters() downloader.AudioFilters {
+	return downloader.AudioFilters{
+		TrimSilence: p.config.TrimSilence,
+		Normalize:   p.config.Normalize,
+	}
+}
+
+// filterTrack runs a downloaded track through --trim-silence and --normalize,
+// replacing it with the filtered copy. The filters are a nicety, so if they
+// fail the original is kept and a warning printed.
+func (p *Processor) filterTrack(trackPath string, qual *models.Quality) {
+	filters := p.audioFilters()
+	if !filters.Enabled() {
+		return
+	}
+
+	rate := sampleRate(qual.Specs)
+	if rate == 0 {
+		rate = fallbackSampleRate
+	}
+	ext := filepath.Ext(trackPath)
+	tempPath := strings.TrimSuffix(trackPath, ext) + ".filtered" + ext
+	defer os.Remove(tempPath)
+
+	fmt.Println("Applying audio filters...")
+	err := downloader.FilterAudio(trackPath, tempPath, p.config.FfmpegNameStr, filters,
+		models.IsLossless(qual.Format), bitDepth(qual.Specs), rate)
+	if err == nil {
+		err = os.Rename(tempPath, trackPath)
+	}
+	if errors.Is(err, downloader.ErrUnfilterable) {
+		fmt.Println("Audio filters aren't supported for this format, skipped.")
+		return
+	}
+	if err != nil {
+		logger.GetLogger().WithError(err).WithField("path", trackPath).Warn("Failed to apply audio filters")
+		fmt.Println("Warning: failed to apply audio filters, keeping the unfiltered track.")
+	}
+}
@@ -0,0 +1,75 @@
+package processor
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+
+	"main/pkg/logger"
+	"main/pkg/models"
+)
+
+// runPostDownloadHook runs the configured hook command after a track or album
+// finishes. The hook gets the event ("track" or "album") and the path as
+// arguments, and the metadata as NUGS_* environment variables. A failing hook
+// is logged, and only fails the download when PostDownloadHookRequired is set.
+func (p *Processor) runPostDownloadHook(event, path string, metadata *models.TrackMetadata) error {
+	if p.config.PostDownloadHook == "" {
+		return nil
+	}
+
+	var output bytes.Buffer
+	cmd := exec.Command(p.config.PostDownloadHook, event, path)
+	cmd.Env = append(os.Environ(), hookEnv(event, path, metadata)...)
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+
+	err := cmd.Run()
+	exitCode := -1
+	if cmd.ProcessState != nil {
+		exitCode = cmd.ProcessState.ExitCode()
+	}
+
+	fields := map[string]interface{}{
+		"hook":      p.config.PostDownloadHook,
+		"event":     event,
+		"path":      path,
+		"exit_code": exitCode,
+	}
+	if err != nil {
+		fields["output"] = output.String()
+		logger.GetLogger().WithFields(fields).WithError(err).Warn("Post-download hook failed")
+		fmt.Printf("Post-download hook failed with exit code %d.\n", exitCode)
+		if p.config.PostDownloadHookRequired {
+			return fmt.Errorf("post-download hook failed: %w", err)
+		}
+		return nil
+	}
+
+	if p.config.Verbose {
+		logger.GetLogger().WithFields(fields).Info("Post-download hook finished")
+	}
+	return nil
+}
+
+// hookEnv builds the NUGS_* environment variables passed to the hook
+func hookEnv(event, path string, metadata *models.TrackMetadata) []string {
+	env := []string{
+		"NUGS_EVENT=" + event,
+		"NUGS_PATH=" + path,
+	}
+	if metadata == nil {
+		return env
+	}
+
+	return append(env,
+		"NUGS_TITLE="+metadata.Title,
+		"NUGS_ARTIST="+metadata.Artist,
+		"NUGS_ALBUM="+metadata.Album,
+		"NUGS_ALBUM_ARTIST="+metadata.AlbumArtist,
+		"NUGS_TRACK_NUM="+strconv.Itoa(metadata.TrackNum),
+		"NUGS_SOURCE_ID="+metadata.SourceID,
+	)
+}
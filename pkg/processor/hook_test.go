@@ -0,0 +1,82 @@
+package processor
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"main/pkg/config"
+	"main/pkg/models"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type HookTestSuite struct {
+	suite.Suite
+	tempDir   string
+	processor *Processor
+}
+
+func (suite *HookTestSuite) SetupTest() {
+	if runtime.GOOS == "windows" {
+		suite.T().Skip("hook scripts are shell scripts")
+	}
+
+	tempDir, err := os.MkdirTemp("", "hook_test_*")
+	suite.Require().NoError(err)
+	suite.tempDir = tempDir
+
+	suite.processor = &Processor{config: &config.Config{}}
+}
+
+func (suite *HookTestSuite) TearDownTest() {
+	os.RemoveAll(suite.tempDir)
+}
+
+// writeHook writes an executable shell script hook and configures it
+func (suite *HookTestSuite) writeHook(body string) {
+	hookPath := filepath.Join(suite.tempDir, "hook.sh")
+	suite.Require().NoError(os.WriteFile(hookPath, []byte("#!/bin/sh\n"+body), 0755))
+	suite.processor.config.PostDownloadHook = hookPath
+}
+
+func (suite *HookTestSuite) TestRunPostDownloadHook_NotConfigured() {
+	suite.NoError(suite.processor.runPostDownloadHook("track", "/tmp/01. Intro.flac", nil))
+}
+
+func (suite *HookTestSuite) TestRunPostDownloadHook_PassesArgsAndEnv() {
+	outPath := filepath.Join(suite.tempDir, "out.txt")
+	suite.writeHook(`echo "$1|$2|$NUGS_TITLE|$NUGS_ARTIST|$NUGS_TRACK_NUM|$NUGS_SOURCE_ID" > "` + outPath + `"` + "\n")
+
+	metadata := &models.TrackMetadata{
+		Title:    "Tweezer",
+		Artist:   "Phish",
+		TrackNum: 3,
+		SourceID: "12345",
+	}
+	err := suite.processor.runPostDownloadHook("track", "/music/03. Tweezer.flac", metadata)
+	suite.Require().NoError(err)
+
+	data, err := os.ReadFile(outPath)
+	suite.Require().NoError(err)
+	suite.Equal("track|/music/03. Tweezer.flac|Tweezer|Phish|3|12345\n", string(data))
+}
+
+func (suite *HookTestSuite) TestRunPostDownloadHook_FailureIgnoredByDefault() {
+	suite.writeHook("exit 3\n")
+	suite.NoError(suite.processor.runPostDownloadHook("album", suite.tempDir, nil))
+}
+
+func (suite *HookTestSuite) TestRunPostDownloadHook_FailureRequired() {
+	suite.writeHook("exit 3\n")
+	suite.processor.config.PostDownloadHookRequired = true
+
+	err := suite.processor.runPostDownloadHook("album", suite.tempDir, nil)
+	suite.Error(err)
+	suite.Contains(err.Error(), "post-download hook failed")
+}
+
+func TestHookTestSuite(t *testing.T) {
+	suite.Run(t, new(HookTestSuite))
+}
@@ -0,0 +1,57 @@
+package processor
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"main/pkg/downloader"
+	"main/pkg/logger"
+	"main/pkg/models"
+)
+
+// posterPath returns where the poster for a video is saved
+func posterPath(vidPath string) string {
+	return strings.TrimSuffix(vidPath, filepath.Ext(vidPath)) + "-poster.jpg"
+}
+
+// savePoster saves the release image next to a finished video with --poster,
+// and embeds it as the cover with "embed". Posters are extras, so failures
+// are logged and the video is kept as is.
+func (p *Processor) savePoster(meta *models.AlbArtResp, vidPath string) {
+	if p.config.Poster == "" {
+		return
+	}
+	if meta == nil || meta.Img.URL == "" {
+		fmt.Println("No poster available for this video.")
+		return
+	}
+
+	poster := posterPath(vidPath)
+	if err := p.downloadImage(imageURL(meta.Img.URL), poster); err != nil {
+		logger.GetLogger().WithError(err).WithField("path", poster).Warn("Failed to download poster")
+		fmt.Println("Failed to download poster.")
+		return
+	}
+	if p.config.Poster != "embed" {
+		return
+	}
+
+	ext := filepath.Ext(vidPath)
+	if ext != ".mp4" && ext != ".m4a" {
+		fmt.Println("Posters can only be embedded in MP4 and M4A files, keeping it alongside.")
+		return
+	}
+
+	tempPath := strings.TrimSuffix(vidPath, ext) + ".poster" + ext
+	err := downloader.EmbedPoster(vidPath, tempPath, poster, p.config.FfmpegNameStr, p.config.AudioOnly)
+	if err == nil {
+		err = os.Rename(tempPath, vidPath)
+	}
+	if err != nil {
+		os.Remove(tempPath)
+		logger.GetLogger().WithError(err).WithField("path", vidPath).Warn("Failed to embed poster")
+		fmt.Println("Failed to embed poster, keeping it alongside the video.")
+	}
+}
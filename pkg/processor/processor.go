@@ -3,13 +3,20 @@ package processor
 import (
 	"errors"
 	"fmt"
+	"net"
 	"net/http"
 	"net/url"
 	"os"
+	"path"
 	"path/filepath"
 	"reflect"
+	"regexp"
+	"runtime"
+	"sort"
 	"strconv"
 	"strings"
+	"time"
+	"unicode/utf8"
 
 	"main/pkg/api"
 	"main/pkg/config"
@@ -17,15 +24,33 @@ import (
 	"main/pkg/fsutil"
 	"main/pkg/logger"
 	"main/pkg/models"
+	"main/pkg/remote"
 )
 
+// Name length limits, used unless maxFolderNameLength or maxFilenameLength
+// are set. Windows caps whole paths at 260 characters by default, so names
+// there are kept shorter than on other platforms.
 const (
-	MaxFolderNameLen   = 100
+	MaxFolderNameLen    = 100
 	MaxVideoFilenameLen = 200
+
+	WindowsMaxFolderNameLen    = 80
+	WindowsMaxVideoFilenameLen = 120
+
+	// folderOwnerFile records which release a chopped folder name belongs to
+	folderOwnerFile = ".nugs_id"
+
+	// VariousArtists is the album artist tag used for playlist tracks
+	VariousArtists = "Various Artists"
 )
 
 var (
 	streamMetaIndices = [4]int{1, 4, 7, 10}
+
+	// lstreamPollInterval is how often --wait-for-available checks whether a
+	// livestream has started, giving up after lstreamMaxWait
+	lstreamPollInterval = time.Minute
+	lstreamMaxWait      = 24 * time.Hour
 )
 
 // Processor handles content processing and downloading
@@ -33,14 +58,55 @@ type Processor struct {
 	apiClient  *api.Client
 	downloader *downloader.Downloader
 	config     *config.Config
+	syncStore  *SyncStore
+	stats      *runStats          // what the run has downloaded, across forks
+	remote     remote.Destination // where finished releases are moved to, if anywhere
+	artwork    *pendingArtwork    // release artwork embedded in the tracks being downloaded
+	trackPaths *[]string          // collects the paths of finished tracks while set
+	// trackPrefix starts track filenames with --no-folder, so releases sharing
+	// the output folder don't collide
+	trackPrefix string
+	discFolders bool // tracks go in a subfolder per disc
+	incomplete  bool // part of the current item failed without failing it
 }
 
 // NewProcessor creates a new processor instance
 func NewProcessor(apiClient *api.Client, dl *downloader.Downloader, cfg *config.Config) *Processor {
+	// Sync watermarks live next to the downloader's resume state
+	syncPath := filepath.Join(os.Getenv("HOME"), ".nugs-downloader", "sync.json")
+
 	return &Processor{
 		apiClient:  apiClient,
 		downloader: dl,
 		config:     cfg,
+		syncStore:  NewSyncStore(syncPath),
+		stats:      &runStats{},
+	}
+}
+
+// ResetIncomplete clears the incomplete flag before processing an item
+func (p *Processor) ResetIncomplete() {
+	p.incomplete = false
+}
+
+// Incomplete reports whether part of the item processed since the last
+// ResetIncomplete failed while the item itself succeeded, like an album with
+// failed tracks outside --strict. Such items aren't done yet.
+func (p *Processor) Incomplete() bool {
+	return p.incomplete
+}
+
+// Fork returns a processor sharing p's clients, config, sync store and run
+// totals, for processing another item at the same time. Per-release state
+// isn't shared.
+func (p *Processor) Fork() *Processor {
+	return &Processor{
+		apiClient:  p.apiClient,
+		downloader: p.downloader,
+		config:     p.config,
+		syncStore:  p.syncStore,
+		stats:      p.stats,
+		remote:     p.remote,
 	}
 }
 
@@ -53,7 +119,6 @@ func (p *Processor) ProcessAlbum(albumID string, streamParams *models.StreamPara
 
 	if albumID == "" {
 		meta = artResp
-		tracks = meta.Songs
 	} else {
 		_meta, err := p.apiClient.GetAlbumMeta(albumID)
 		if err != nil {
@@ -61,8 +126,11 @@ func (p *Processor) ProcessAlbum(albumID string, streamParams *models.StreamPara
 			return models.NewDownloadError(models.ErrNetwork, "Failed to get album metadata", "Check your internet connection and try again", true, err)
 		}
 		meta = _meta.Response
-		tracks = meta.Tracks
 	}
+	if err := checkAvailable(meta); err != nil {
+		return err
+	}
+	tracks = releaseTracks(meta)
 
 	trackTotal := len(tracks)
 	skuID := getVideoSku(meta.Products)
@@ -77,37 +145,83 @@ func (p *Processor) ProcessAlbum(albumID string, streamParams *models.StreamPara
 			return nil
 		}
 		if p.config.ForceVideo || trackTotal < 1 {
+			// Hand over the metadata we already have so it isn't fetched twice
 			return p.ProcessVideo(albumID, "", streamParams, meta, false)
 		}
 	}
 
-	albumFolder := meta.ArtistName + " - " + strings.TrimRight(meta.ContainerInfo, " ")
+	return p.downloadAlbumTracks(meta, tracks, streamParams)
+}
+
+// downloadAlbumTracks downloads a release's tracks into its album folder
+func (p *Processor) downloadAlbumTracks(meta *models.AlbArtResp, tracks []models.Track, streamParams *models.StreamParams) error {
+	trackTotal := len(tracks)
+	albumFolder := p.albumFolderName(meta)
 	fmt.Println(albumFolder)
 
-	if len(albumFolder) > MaxFolderNameLen {
-		albumFolder = albumFolder[:MaxFolderNameLen]
-		fmt.Printf("Album folder name was chopped because it exceeds %d characters.", MaxFolderNameLen)
+	if p.config.DumpURLs {
+		return p.dumpTrackURLs(tracks, streamParams)
+	}
+	if p.config.SimulateQuality {
+		return p.simulateTrackQuality(tracks, streamParams)
+	}
+
+	albumFolder, chopped := truncateName(albumFolder, p.folderNameLimit())
+	if chopped {
+		fmt.Printf("Album folder name was chopped because it exceeds %d characters.\n", p.folderNameLimit())
 	}
 
 	albumPath := filepath.Join(p.config.OutPath, downloader.Sanitise(albumFolder))
-	err := fsutil.MakeDirs(albumPath)
+	if err := checkPathWithin(p.config.OutPath, albumPath); err != nil {
+		return err
+	}
+	var err error
+	if p.config.NoFolder {
+		albumPath = p.config.OutPath
+		defer p.useTrackPrefix(albumFolder)()
+	} else if chopped {
+		albumPath, err = claimFolder(albumPath, strconv.Itoa(meta.ContainerID))
+	} else {
+		err = fsutil.MakeDirs(albumPath)
+	}
 	if err != nil {
 		return models.NewDownloadError(models.ErrFileSystem, "Failed to create album folder", "Check write permissions for the download directory", false, err)
 	}
 
 	// Clean up any leftover temp files from previous runs
-	downloader.CleanupTempFiles(albumPath)
+	if !p.config.NoClean {
+		downloader.CleanupTempFiles(albumPath, p.config.TempDir)
+	}
+
+	defer p.useArtwork(meta)()
+	defer p.useDiscFolders(tracks)()
+
+	joinedPath := p.joinedAlbumPath(albumPath, albumFolder)
+	if p.config.CueSheet != "" && !p.config.KeepTracks {
+		if exists, _ := downloader.FileExists(joinedPath); exists {
+			fmt.Println("Album already exists locally as one file.")
+			return nil
+		}
+	}
+
+	var trackPaths []string
+	if p.config.AlbumChecksums || p.config.CueSheet != "" || p.remote != nil {
+		p.trackPaths = &trackPaths
+		defer func() { p.trackPaths = nil }()
+	}
 
 	// Track download results for summary
 	var successCount, failureCount int
-	var failures []string
+	var failures, unavailable []string
 
 	for trackNum, track := range tracks {
 		trackNum++
 		fmt.Printf("Processing track %d of %d: %s\n", trackNum, trackTotal, track.SongTitle)
 
 		err := p.ProcessTrackWithMetadata(albumPath, trackNum, trackTotal, &track, streamParams, meta)
-		if err != nil {
+		if errors.Is(err, models.ErrFormatUnavailable) {
+			unavailable = append(unavailable, fmt.Sprintf("Track %d (%s)", trackNum, track.SongTitle))
+		} else if err != nil {
 			failureCount++
 			failureMsg := fmt.Sprintf("Track %d (%s): %v", trackNum, track.SongTitle, err)
 			failures = append(failures, failureMsg)
@@ -134,9 +248,39 @@ func (p *Processor) ProcessAlbum(albumID string, streamParams *models.StreamPara
 		}
 	}
 
+	if p.config.Setlist {
+		if err := writeSetlist(filepath.Join(albumPath, p.trackPrefix+setlistFile), meta, tracks); err != nil {
+			logger.GetLogger().WithError(err).WithField("path", albumPath).Warn("Failed to write setlist")
+			fmt.Println("Failed to write setlist.")
+		}
+	}
+
+	if p.config.CueSheet != "" && len(trackPaths) > 0 {
+		trackPaths = p.joinAlbum(albumPath, albumFolder, meta, tracks, trackPaths)
+	}
+
+	if p.config.AlbumChecksums && len(trackPaths) > 0 {
+		if err := writeChecksums(filepath.Join(albumPath, p.trackPrefix+checksumsFile), trackPaths); err != nil {
+			logger.GetLogger().WithError(err).WithField("path", albumPath).Warn("Failed to write checksum manifest")
+			fmt.Println("Failed to write checksum manifest.")
+		}
+	}
+
+	// Every track went into a format subfolder, so drop the unlabelled one if it's empty
+	if p.config.FormatSubfolder && !p.config.NoFolder {
+		os.Remove(albumPath)
+	}
+
 	// Provide summary
 	fmt.Printf("\nAlbum download summary: %d/%d tracks successful\n", successCount, trackTotal)
 
+	if len(unavailable) > 0 {
+		fmt.Printf("%d tracks skipped, unavailable in the requested format:\n", len(unavailable))
+		for _, skipped := range unavailable {
+			fmt.Printf("   - %s\n", skipped)
+		}
+	}
+
 	if failureCount > 0 {
 		fmt.Printf("%d tracks failed:\n", failureCount)
 		for _, failure := range failures {
@@ -145,14 +289,111 @@ func (p *Processor) ProcessAlbum(albumID string, streamParams *models.StreamPara
 
 		if successCount > 0 {
 			fmt.Println("Partial download completed. Failed tracks can be retried individually.")
-			return nil // Don't fail the entire album if some tracks succeeded
+			// Don't fail the entire album if some tracks succeeded, unless --strict is set
+			return p.partialFailureError(failureCount, trackTotal)
 		} else {
 			return models.NewDownloadError(models.ErrUnknown, "All tracks failed to download", "Check your internet connection and try again", true, nil)
 		}
 	}
 
 	fmt.Println("Album download completed successfully!")
-	return nil
+
+	albumMetadata := &models.TrackMetadata{
+		Artist:   meta.ArtistName,
+		Album:    meta.ContainerInfo,
+		SourceID: strconv.Itoa(meta.ContainerID),
+	}
+	if err := p.runPostDownloadHook("album", albumPath, albumMetadata); err != nil {
+		return err
+	}
+
+	// With --no-folder the album path is the output folder, which isn't moved
+	remotePaths := trackPaths
+	if _, err := os.Stat(albumPath); err == nil {
+		remotePaths = append(remotePaths, albumPath)
+	}
+	return p.moveToRemote(outputRoots(p.config.OutPath, remotePaths)...)
+}
+
+// useTrackPrefix names tracks after their release until the returned func is
+// called, for releases saved straight into the output folder
+func (p *Processor) useTrackPrefix(release string) func() {
+	p.trackPrefix = downloader.Sanitise(release) + " - "
+	return func() { p.trackPrefix = "" }
+}
+
+// setTrackMtime sets a finished track's modification time from the first
+// source available: the show or release date with --set-mtime, then the
+// CDN's Last-Modified time with --mtime-from-header. Otherwise the track
+// keeps the download time.
+func (p *Processor) setTrackMtime(path string, metadata *models.TrackMetadata, lastModified time.Time) {
+	if p.setReleaseMtime(path, metadata) {
+		return
+	}
+	if !p.config.MtimeFromHeader || lastModified.IsZero() {
+		return
+	}
+	if err := os.Chtimes(path, lastModified, lastModified); err != nil {
+		logger.GetLogger().WithError(err).WithField("path", path).Warn("Failed to set file modification time")
+	}
+}
+
+// setReleaseMtime sets a finished track's modification time to its show or
+// release date with --set-mtime, so files sort chronologically. It reports
+// whether the date was set; tracks without a known date are left alone.
+func (p *Processor) setReleaseMtime(path string, metadata *models.TrackMetadata) bool {
+	if !p.config.SetMtime || metadata == nil || metadata.Date.IsZero() {
+		return false
+	}
+	if err := os.Chtimes(path, metadata.Date, metadata.Date); err != nil {
+		logger.GetLogger().WithError(err).WithField("path", path).Warn("Failed to set file modification time")
+		return false
+	}
+	return true
+}
+
+// addIDTags fills in the Nugs ids tagged with idTags. They're left out by
+// default since some users dislike extra tags.
+func (p *Processor) addIDTags(metadata *models.TrackMetadata, track *models.Track, containerID, artistID int) {
+	if !p.config.IDTags {
+		return
+	}
+	metadata.ContainerID = containerID
+	metadata.ArtistID = artistID
+	metadata.TrackID = track.TrackID
+	metadata.SongID = track.SongID
+}
+
+// addSortTags fills in sort names for the artist, album and album artist
+// with sortTags, for the names that start with an article
+func (p *Processor) addSortTags(metadata *models.TrackMetadata) {
+	if !p.config.SortTags {
+		return
+	}
+	articles := p.config.SortArticles
+	if len(articles) == 0 {
+		articles = models.DefaultSortArticles
+	}
+	sortName := func(name string) string {
+		if sorted := models.SortName(name, articles); sorted != name {
+			return sorted
+		}
+		return ""
+	}
+	metadata.ArtistSort = sortName(metadata.Artist)
+	metadata.AlbumSort = sortName(metadata.Album)
+	metadata.AlbumArtistSort = sortName(metadata.AlbumArtist)
+}
+
+// partialFailureError returns the error for a release where only some tracks
+// failed, which is nil unless --strict asks for all-or-nothing downloads. The
+// item is marked incomplete either way.
+func (p *Processor) partialFailureError(failureCount, trackTotal int) error {
+	p.incomplete = true
+	if !p.config.Strict {
+		return nil
+	}
+	return models.NewDownloadError(models.ErrUnknown, fmt.Sprintf("%d of %d tracks failed to download", failureCount, trackTotal), "Run the download again to retry the failed tracks", true, nil)
 }
 
 // ProcessArtist processes an artist discography
@@ -163,38 +404,222 @@ func (p *Processor) ProcessArtist(artistId string, streamParams *models.StreamPa
 		return err
 	}
 
-	if len(meta) == 0 {
-		return fmt.Errorf("the API didn't return any artist metadata")
+	containers := artistContainers(meta)
+	if len(containers) == 0 {
+		fmt.Println("No available releases for this artist.")
+		return nil
 	}
+	fmt.Println(containers[0].ArtistName)
 
-	fmt.Println(meta[0].Response.Containers[0].ArtistName)
-	albumTotal := getAlbumTotal(meta)
-
-	for _, _meta := range meta {
-		for albumNum, container := range _meta.Response.Containers {
-			fmt.Printf("Item %d of %d:\n", albumNum+1, albumTotal)
-			if p.config.SkipVideos {
-				err = p.ProcessAlbum("", streamParams, container)
-			} else {
-				// Can't re-use this metadata as it doesn't have any product info for videos.
-				err = p.ProcessAlbum(strconv.Itoa(container.ContainerID), streamParams, nil)
+	containers = p.filterArtistContainers(containers)
+	containers = p.preferSources(containers)
+	if p.config.Limit > 0 && len(containers) > p.config.Limit {
+		fmt.Printf("Limiting to %d of %d items.\n", p.config.Limit, len(containers))
+		containers = containers[:p.config.Limit]
+	}
+	albumTotal := len(containers)
+
+	var unavailable int
+	for albumNum, container := range containers {
+		fmt.Printf("Item %d of %d:\n", albumNum+1, albumTotal)
+		p.pauseCrawl(albumNum)
+		err = p.processArtistContainer(container, streamParams)
+		if errors.Is(err, models.ErrNotAvailable) {
+			unavailable++
+		} else if err != nil {
+			p.incomplete = true
+			context := map[string]interface{}{
+				"item_type": "artist",
+				"artist_id": artistId,
+				"item_num":  albumNum + 1,
+				"total":     albumTotal,
 			}
-			if err != nil {
-				context := map[string]interface{}{
-					"item_type": "artist",
-					"artist_id": artistId,
-					"item_num":  albumNum + 1,
-					"total":     albumTotal,
-				}
-				logger.WrapError(err, context)
-				logger.GetLogger().Error("Artist item failed", "item", albumNum+1, "total", albumTotal)
+			logger.WrapError(err, context)
+			logger.GetLogger().Error("Artist item failed", "item", albumNum+1, "total", albumTotal)
+		}
+	}
+
+	if unavailable > 0 {
+		fmt.Printf("%d items skipped, not available for download.\n", unavailable)
+	}
+	return nil
+}
+
+// filterArtistContainers drops containers whose artist doesn't match
+// --artist-filter. A numeric filter matches the artist id, anything else is a
+// case-insensitive regex on the artist name.
+func (p *Processor) filterArtistContainers(containers []*models.AlbArtResp) []*models.AlbArtResp {
+	filter := p.config.ArtistFilter
+	if filter == "" {
+		return containers
+	}
+
+	artistId, idErr := strconv.Atoi(filter)
+	nameRegex, _ := regexp.Compile("(?i)" + filter)
+
+	var kept []*models.AlbArtResp
+	for _, container := range containers {
+		var match bool
+		if idErr == nil {
+			match = container.ArtistID == artistId
+		} else {
+			match = nameRegex != nil && nameRegex.MatchString(container.ArtistName)
+		}
+
+		if !match {
+			logger.GetLogger().WithFields(map[string]interface{}{
+				"container_id": container.ContainerID,
+				"artist":       container.ArtistName,
+				"filter":       filter,
+			}).Debug("Skipping container that doesn't match artist filter")
+			continue
+		}
+		kept = append(kept, container)
+	}
+
+	if skipped := len(containers) - len(kept); skipped > 0 {
+		fmt.Printf("Skipped %d items that don't match the artist filter.\n", skipped)
+	}
+	return kept
+}
+
+// filterPendingContainers is filterArtistContainers for the shows an artist
+// sync has yet to download
+func (p *Processor) filterPendingContainers(pending []datedContainer) []datedContainer {
+	containers := make([]*models.AlbArtResp, len(pending))
+	for i, item := range pending {
+		containers[i] = item.container
+	}
+	matched := make(map[*models.AlbArtResp]bool)
+	for _, container := range p.filterArtistContainers(containers) {
+		matched[container] = true
+	}
+
+	var kept []datedContainer
+	for _, item := range pending {
+		if matched[item.container] {
+			kept = append(kept, item)
+		}
+	}
+	return kept
+}
+
+// pauseCrawl waits out --artist-crawl-delay before each artist container
+// after the first, since each one starts with a metadata request
+func (p *Processor) pauseCrawl(albumNum int) {
+	if albumNum > 0 {
+		time.Sleep(p.apiClient.ArtistCrawlDelay)
+	}
+}
+
+// processArtistContainer downloads one container from an artist's discography
+func (p *Processor) processArtistContainer(container *models.AlbArtResp, streamParams *models.StreamParams) error {
+	if p.config.SkipVideos {
+		return p.ProcessAlbum("", streamParams, container)
+	}
+	// Can't re-use this metadata as it doesn't have any product info for videos.
+	return p.ProcessAlbum(strconv.Itoa(container.ContainerID), streamParams, nil)
+}
+
+// SyncArtist downloads only the containers newer than the artist's last synced
+// date and advances the stored watermark. Containers are processed oldest
+// first, and the watermark stops advancing at the first failure so failed
+// shows are retried on the next run.
+func (p *Processor) SyncArtist(artistId string, streamParams *models.StreamParams) error {
+	meta, err := p.apiClient.GetArtistMeta(artistId)
+	if err != nil {
+		logger.GetLogger().WithError(err).WithField("artist_id", artistId).Error("Failed to get artist metadata")
+		return err
+	}
+
+	watermark, synced, err := p.syncStore.GetWatermark(artistId)
+	if err != nil {
+		return err
+	}
+
+	containers := artistContainers(meta)
+	if len(containers) == 0 {
+		fmt.Println("No available releases for this artist.")
+		return nil
+	}
+	fmt.Println(containers[0].ArtistName)
+	if synced {
+		fmt.Printf("Last synced show: %s\n", watermark.Format("2006-01-02"))
+	}
+
+	pending := newContainersSince(meta, watermark, synced)
+	pending = p.filterPendingContainers(pending)
+	pending = p.preferPendingSources(pending)
+	if len(pending) == 0 {
+		fmt.Println("No new shows since last sync.")
+		return nil
+	}
+	if p.config.Limit > 0 && len(pending) > p.config.Limit {
+		fmt.Printf("Limiting to %d of %d new items.\n", p.config.Limit, len(pending))
+		pending = pending[:p.config.Limit]
+	}
+
+	newest := watermark
+	advancing := true
+	for albumNum, item := range pending {
+		fmt.Printf("Item %d of %d:\n", albumNum+1, len(pending))
+		p.pauseCrawl(albumNum)
+		err = p.processArtistContainer(item.container, streamParams)
+		if err != nil {
+			advancing = false
+			p.incomplete = true
+			context := map[string]interface{}{
+				"item_type": "artist_sync",
+				"artist_id": artistId,
+				"item_num":  albumNum + 1,
+				"total":     len(pending),
 			}
+			logger.WrapError(err, context)
+			logger.GetLogger().Error("Artist sync item failed", "item", albumNum+1, "total", len(pending))
+			continue
+		}
+		if advancing && item.hasDate && item.date.After(newest) {
+			newest = item.date
+		}
+	}
+
+	if newest.After(watermark) {
+		err = p.syncStore.SetWatermark(artistId, newest)
+		if err != nil {
+			fmt.Println("Failed to save sync state.")
+			return err
 		}
 	}
 
 	return nil
 }
 
+// datedContainer pairs a container with its parsed show date
+type datedContainer struct {
+	container *models.AlbArtResp
+	date      time.Time
+	hasDate   bool
+}
+
+// newContainersSince returns the containers newer than the watermark, oldest
+// first. Undated containers can't be compared, so they're only included on
+// an artist's first sync.
+func newContainersSince(meta []*models.ArtistMeta, watermark time.Time, synced bool) []datedContainer {
+	var pending []datedContainer
+	for _, container := range artistContainers(meta) {
+		date, ok := models.ParseContainerDate(container)
+		if synced && (!ok || !date.After(watermark)) {
+			continue
+		}
+		pending = append(pending, datedContainer{container: container, date: date, hasDate: ok})
+	}
+
+	sort.SliceStable(pending, func(i, j int) bool {
+		return pending[i].date.Before(pending[j].date)
+	})
+	return pending
+}
+
 // ProcessPlaylist processes a playlist
 func (p *Processor) ProcessPlaylist(plistId, legacyToken string, streamParams *models.StreamParams, cat bool) error {
 	_meta, err := p.apiClient.GetPlistMeta(plistId, p.config.Email, legacyToken, cat)
@@ -207,23 +632,65 @@ func (p *Processor) ProcessPlaylist(plistId, legacyToken string, streamParams *m
 	plistName := meta.PlayListName
 	fmt.Println(plistName)
 
-	if len(plistName) > MaxFolderNameLen {
-		plistName = plistName[:MaxFolderNameLen]
-		fmt.Printf("Playlist folder name was chopped because it exceeds %d characters.", MaxFolderNameLen)
+	if p.config.DumpURLs || p.config.SimulateQuality {
+		tracks := make([]models.Track, len(meta.Items))
+		for i, item := range meta.Items {
+			tracks[i] = item.Track
+		}
+		if p.config.SimulateQuality {
+			return p.simulateTrackQuality(tracks, streamParams)
+		}
+		return p.dumpTrackURLs(tracks, streamParams)
+	}
+
+	plistName, chopped := truncateName(plistName, p.folderNameLimit())
+	if chopped {
+		fmt.Printf("Playlist folder name was chopped because it exceeds %d characters.\n", p.folderNameLimit())
 	}
 
 	plistPath := filepath.Join(p.config.OutPath, downloader.Sanitise(plistName))
-	err = fsutil.MakeDirs(plistPath)
+	if err := checkPathWithin(p.config.OutPath, plistPath); err != nil {
+		return err
+	}
+	if p.config.NoFolder {
+		plistPath = p.config.OutPath
+		defer p.useTrackPrefix(plistName)()
+	} else if chopped {
+		plistPath, err = claimFolder(plistPath, plistId)
+	} else {
+		err = fsutil.MakeDirs(plistPath)
+	}
 	if err != nil {
 		fmt.Println("Failed to make playlist folder.")
 		return err
 	}
 
-	trackTotal := len(meta.Items)
-	for trackNum, track := range meta.Items {
+	items := meta.Items
+	if p.config.Dedup {
+		items = dedupPlaylistItems(items)
+	}
+
+	var trackPaths []string
+	if p.remote != nil {
+		p.trackPaths = &trackPaths
+		defer func() { p.trackPaths = nil }()
+	}
+
+	trackTotal := len(items)
+	var unavailable, failed int
+	for trackNum, track := range items {
 		trackNum++
-		err := p.ProcessTrack(plistPath, trackNum, trackTotal, &track.Track, streamParams)
+		trackDir, err := p.playlistTrackDir(plistPath, &track.Track)
 		if err != nil {
+			fmt.Println("Failed to make playlist artist folder.")
+			return err
+		}
+		metadata := p.playlistTrackMetadata(plistId, meta.PlayListName, trackNum, trackTotal, &track.Track)
+		err = p.processTrackWithTags(trackDir, trackNum, trackTotal, &track.Track, streamParams, metadata)
+		if errors.Is(err, models.ErrFormatUnavailable) {
+			unavailable++
+		} else if err != nil {
+			failed++
 			context := map[string]interface{}{
 				"playlist":  meta.PlayListName,
 				"track":     track.Track.SongTitle,
@@ -235,21 +702,117 @@ func (p *Processor) ProcessPlaylist(plistId, legacyToken string, streamParams *m
 		}
 	}
 
-	return nil
+	if unavailable > 0 {
+		fmt.Printf("%d tracks skipped, unavailable in the requested format.\n", unavailable)
+	}
+
+	// Like an album, a playlist with failed tracks stays local so running it
+	// again fills in the gaps
+	if failed > 0 {
+		p.incomplete = true
+		if p.remote != nil {
+			fmt.Printf("%d tracks failed, kept the playlist local.\n", failed)
+		}
+		return nil
+	}
+
+	// With --no-folder the playlist path is the output folder, which isn't moved
+	remotePaths := trackPaths
+	if _, err := os.Stat(plistPath); err == nil {
+		remotePaths = append(remotePaths, plistPath)
+	}
+	return p.moveToRemote(outputRoots(p.config.OutPath, remotePaths)...)
+}
+
+// dedupPlaylistItems drops repeats of a track already in the playlist, so
+// tracks are numbered by their first appearance without gaps
+func dedupPlaylistItems(items []models.PlistItem) []models.PlistItem {
+	seen := make(map[int]bool, len(items))
+	deduped := make([]models.PlistItem, 0, len(items))
+	for i, item := range items {
+		if seen[item.Track.TrackID] {
+			fmt.Printf("Skipping repeated track: %s\n", item.Track.SongTitle)
+			logger.GetLogger().WithFields(map[string]interface{}{
+				"track":    item.Track.SongTitle,
+				"track_id": item.Track.TrackID,
+				"position": i + 1,
+			}).Info("Skipped duplicate playlist track")
+			continue
+		}
+		seen[item.Track.TrackID] = true
+		deduped = append(deduped, item)
+	}
+	return deduped
+}
+
+// playlistTrackDir returns the folder a playlist track is saved to. With
+// PlaylistByArtist set, tracks go in per-artist subfolders, and tracks with
+// an unknown artist stay in the playlist root.
+func (p *Processor) playlistTrackDir(plistPath string, track *models.Track) (string, error) {
+	if !p.config.PlaylistByArtist || track.ArtistName == "" {
+		return plistPath, nil
+	}
+
+	artistPath := filepath.Join(plistPath, downloader.Sanitise(track.ArtistName))
+	if err := checkPathWithin(plistPath, artistPath); err != nil {
+		return "", err
+	}
+	err := fsutil.MakeDirs(artistPath)
+	if err != nil {
+		return "", err
+	}
+	return artistPath, nil
+}
+
+// playlistTrackMetadata builds tags for a playlist item. Tracks are tagged
+// with their own artist and release when the API provides them; otherwise
+// they're grouped under the playlist as a Various Artists compilation. Either
+// way they're numbered by their place in the playlist, as their files are.
+func (p *Processor) playlistTrackMetadata(plistId, plistName string, trackNum, trackTotal int, track *models.Track) *models.TrackMetadata {
+	metadata := &models.TrackMetadata{
+		Title:       track.SongTitle,
+		Artist:      track.ArtistName,
+		Album:       plistName,
+		AlbumArtist: VariousArtists,
+		TrackNum:    trackNum,
+		TrackTotal:  trackTotal,
+		Comment:     p.trackComment(),
+		SourceID:    plistId,
+	}
+
+	p.addIDTags(metadata, track, track.ContainerID, track.ArtistID)
+
+	metadata.Date, _ = models.ParseContainerDate(&models.AlbArtResp{ContainerInfo: track.ContainerInfo})
+
+	if track.ContainerInfo != "" {
+		metadata.Album = strings.TrimRight(track.ContainerInfo, " ")
+		metadata.AlbumArtist = track.ArtistName
+	}
+	if track.ContainerID != 0 {
+		metadata.SourceID = strconv.Itoa(track.ContainerID)
+	}
+	p.addSortTags(metadata)
+
+	return metadata
 }
 
-// ProcessVideo processes a video
+// ProcessVideo processes a video. Callers that already hold the container's
+// metadata should pass it as _meta; the album metadata is only fetched when
+// it's nil.
 func (p *Processor) ProcessVideo(videoID, uguID string, streamParams *models.StreamParams, _meta *models.AlbArtResp, isLstream bool) error {
 	var (
 		chapsAvail bool
-		skuID      int
-		manifestUrl string
 		meta       *models.AlbArtResp
-		err        error
 	)
 
 	if _meta != nil {
 		meta = _meta
+	} else if isLstream && p.config.WaitForAvailable {
+		m, err := p.waitForLstream(videoID)
+		if err != nil {
+			return err
+		}
+		meta = m
 	} else {
 		m, err := p.apiClient.GetAlbumMeta(videoID)
 		if err != nil {
@@ -257,29 +820,81 @@ func (p *Processor) ProcessVideo(videoID, uguID string, streamParams *models.Str
 			return err
 		}
 		meta = m.Response
+		if err := checkAvailable(meta); err != nil {
+			if isLstream {
+				fmt.Println("Use --wait-for-available to wait for it to start.")
+			}
+			return err
+		}
 	}
 
 	if !p.config.SkipChapters {
 		chapsAvail = !reflect.ValueOf(meta.VideoChapters).IsZero()
 	}
 
-	videoFname := meta.ArtistName + " - " + strings.TrimRight(meta.ContainerInfo, " ")
-	fmt.Println(videoFname)
+	fmt.Println(meta.ArtistName + " - " + strings.TrimRight(meta.ContainerInfo, " "))
+
+	products, err := p.videoProducts(meta, isLstream)
+	if err != nil {
+		return err
+	}
+	err = p.downloadVideoProducts(videoID, uguID, streamParams, meta, products, chapsAvail)
+	if !p.config.AlsoAudio || isLstream {
+		return err
+	}
+
+	// The audio is downloaded even if the video failed, and a video failure
+	// is reported over an audio one
+	audioErr := p.downloadVideoAudio(meta, streamParams)
+	if err != nil {
+		return err
+	}
+	return audioErr
+}
 
-	if len(videoFname) > MaxVideoFilenameLen {
-		videoFname = videoFname[:MaxVideoFilenameLen]
-		fmt.Printf("Video filename was chopped because it exceeds %d characters.", MaxVideoFilenameLen)
+// downloadVideoProducts downloads the chosen video products of a container.
+// A single product goes straight into the output folder, and several, with
+// --all-products, each into their own subfolder.
+func (p *Processor) downloadVideoProducts(videoID, uguID string, streamParams *models.StreamParams, meta *models.AlbArtResp, products []models.Product, chapsAvail bool) error {
+	if len(products) == 1 {
+		return p.downloadVideoSku(videoID, uguID, streamParams, meta, products[0].SkuID, p.config.OutPath, chapsAvail)
 	}
 
-	if isLstream {
-		skuID = getLstreamSku(meta.ProductFormatList)
-	} else {
-		skuID = getVideoSku(meta.Products)
+	var lastErr error
+	for _, product := range products {
+		fmt.Printf("Product: %s\n", product.FormatStr)
+		outDir := filepath.Join(p.config.OutPath, downloader.Sanitise(product.FormatStr))
+		if err := fsutil.MakeDirs(outDir); err != nil {
+			return models.NewDownloadError(models.ErrFileSystem, "Failed to create product folder", "Check write permissions for the download directory", false, err)
+		}
+		err := p.downloadVideoSku(videoID, uguID, streamParams, meta, product.SkuID, outDir, chapsAvail)
+		if err != nil {
+			logger.GetLogger().WithError(err).WithField("product", product.FormatStr).Error("Video product failed")
+			lastErr = err
+		}
 	}
+	return lastErr
+}
 
-	if skuID == 0 {
-		return fmt.Errorf("no video available")
+// downloadVideoAudio downloads the tracks of a video's audio-only product
+// for --also-audio, the same way as an album's
+func (p *Processor) downloadVideoAudio(meta *models.AlbArtResp, streamParams *models.StreamParams) error {
+	tracks := releaseTracks(meta)
+	if getAudioSku(meta.Products) == 0 || len(tracks) == 0 {
+		fmt.Println("No audio-only product to download with the video.")
+		return nil
 	}
+	fmt.Println("Downloading the audio-only product...")
+	return p.downloadAlbumTracks(meta, tracks, streamParams)
+}
+
+// downloadVideoSku downloads one video product of a container into outDir
+func (p *Processor) downloadVideoSku(videoID, uguID string, streamParams *models.StreamParams, meta *models.AlbArtResp, skuID int, outDir string, chapsAvail bool) error {
+	var (
+		manifestUrl string
+		isLstream   bool
+		err         error
+	)
 
 	if uguID == "" {
 		manifestUrl, err = p.apiClient.GetStreamMeta(meta.ContainerID, skuID, 0, streamParams)
@@ -299,10 +914,32 @@ func (p *Processor) ProcessVideo(videoID, uguID string, streamParams *models.Str
 		fmt.Println("Failed to get video master manifest.")
 		return err
 	}
+	if p.config.DumpURLs {
+		manBaseUrl, _, err := p.downloader.GetManifestBase(manifestUrl)
+		if err != nil {
+			return err
+		}
+		dumpVideoURLs(manifestUrl, manBaseUrl+variant.URI, retRes)
+		return nil
+	}
+	if p.config.SimulateQuality {
+		fmt.Fprintf(simulateOutput, "Video: wanted %sp, chosen %sp\n", p.config.WantRes, retRes)
+		return nil
+	}
 
-	vidPathNoExt := filepath.Join(p.config.OutPath, downloader.Sanitise(videoFname+"_"+retRes))
+	vidPathNoExt := filepath.Join(outDir, p.videoName(meta, retRes, variant.FrameRate))
 	VidPathTs := vidPathNoExt + ".ts"
-	vidPath := vidPathNoExt + ".mp4"
+	container := p.config.VideoContainer
+	if container == "" {
+		container = "mp4"
+	}
+	vidPath := vidPathNoExt + "." + container
+	if p.config.AudioOnly {
+		vidPath = vidPathNoExt + ".m4a"
+	}
+	if err := checkPathWithin(p.config.OutPath, vidPath); err != nil {
+		return err
+	}
 
 	exists, err := downloader.FileExists(vidPath)
 	if err != nil {
@@ -327,8 +964,10 @@ func (p *Processor) ProcessVideo(videoID, uguID string, streamParams *models.Str
 		return err
 	}
 
-	// Player album page videos aren't always only the first seg for the entire vid.
-	isLstream = segUrls[0] != segUrls[1]
+	if len(segUrls) == 0 {
+		return fmt.Errorf("the video manifest didn't contain any segments")
+	}
+	isLstream = isSegmentedVideo(segUrls)
 
 	if !isLstream {
 		fmt.Printf("%.3f FPS, ", variant.FrameRate)
@@ -340,45 +979,70 @@ func (p *Processor) ProcessVideo(videoID, uguID string, streamParams *models.Str
 	} else {
 		err = p.downloader.DownloadVideo(VidPathTs, manBaseUrl+segUrls[0])
 	}
+	if errors.Is(err, models.ErrSampleLimit) {
+		return nil
+	}
 
 	if err != nil {
 		fmt.Println("Failed to download video segments.")
 		return err
 	}
 
+	var chapsPath string
 	if chapsAvail {
 		dur, err := downloader.GetDuration(VidPathTs, p.config.FfmpegNameStr)
 		if err != nil {
 			fmt.Println("Failed to get TS duration.")
 			return err
 		}
-		err = downloader.WriteChapsFile(meta.VideoChapters, dur)
+		chapsPath = p.downloader.TempPath("chapters_nugs_dl_*.txt")
+		err = downloader.WriteChapsFile(chapsPath, meta.VideoChapters, dur)
 		if err != nil {
 			fmt.Println("Failed to write chapters file.")
 			return err
 		}
 	}
 
-	fmt.Println("Putting into MP4 container...")
-	err = downloader.TsToMp4(VidPathTs, vidPath, p.config.FfmpegNameStr, chapsAvail)
-	if err != nil {
-		fmt.Println("Failed to put TS into MP4 container.")
-		return err
-	}
-
-	if chapsAvail {
-		err = os.Remove("chapters_nugs_dl_tmp.txt")
+	if p.config.AudioOnly {
+		fmt.Println("Extracting audio...")
+		err = downloader.TsToAudio(VidPathTs, vidPath, p.config.FfmpegNameStr, chapsPath)
 		if err != nil {
-			fmt.Println("Failed to delete chapters file.")
+			fmt.Println("Failed to extract audio from TS.")
+			return err
+		}
+	} else {
+		containerName := strings.ToUpper(container)
+		fmt.Printf("Putting into %s container...\n", containerName)
+		err = downloader.TsToContainer(VidPathTs, vidPath, p.config.FfmpegNameStr, container, chapsPath)
+		if err != nil {
+			fmt.Printf("Failed to put TS into %s container.\n", containerName)
+			return err
 		}
 	}
 
-	err = os.Remove(VidPathTs)
-	if err != nil {
-		fmt.Println("Failed to delete TS.")
-	}
-
-	return nil
+	if p.config.NoClean {
+		fmt.Println("Kept TS:", VidPathTs)
+		if chapsPath != "" {
+			fmt.Println("Kept chapters:", chapsPath)
+		}
+	} else {
+		if chapsPath != "" {
+			err = os.Remove(chapsPath)
+			if err != nil {
+				fmt.Println("Failed to delete chapters file.")
+			}
+		}
+
+		err = os.Remove(VidPathTs)
+		if err != nil {
+			fmt.Println("Failed to delete TS.")
+		}
+	}
+
+	p.stats.videos.Add(1)
+	p.saveSubtitles(manifestUrl, variant, vidPath, container)
+	p.savePoster(meta, vidPath)
+	return p.moveToRemote(videoFiles(vidPath)...)
 }
 
 // ProcessTrack processes a single track
@@ -388,11 +1052,98 @@ func (p *Processor) ProcessTrack(folPath string, trackNum, trackTotal int, track
 
 // ProcessTrackWithMetadata processes a single track with metadata
 func (p *Processor) ProcessTrackWithMetadata(folPath string, trackNum, trackTotal int, track *models.Track, streamParams *models.StreamParams, albumMeta *models.AlbArtResp) error {
-	origWantFmt := p.config.Format
-	wantFmt := origWantFmt
+	// Create metadata for the track
+	var metadata *models.TrackMetadata
+	if albumMeta != nil {
+		metadata = p.albumTrackMetadata(albumMeta, track, trackNum, trackTotal)
+	}
+
+	return p.processTrackWithTags(folPath, trackNum, trackTotal, track, streamParams, metadata)
+}
+
+// checkAvailable returns ErrNotAvailable for containers the API doesn't mark
+// as available, like pre-releases and ones that have been taken down, so they
+// aren't attempted only to fail with a confusing error. Containers without an
+// availability are assumed to be available.
+func checkAvailable(meta *models.AlbArtResp) error {
+	status := meta.AvailabilityTypeStr
+	if status == "" || status == "AVAILABLE" {
+		return nil
+	}
+	upper := strings.ToUpper(status)
+	if strings.Contains(upper, "COMING") || strings.Contains(upper, "PRE") {
+		fmt.Printf("Not available yet (%s), skipped.\n", status)
+	} else {
+		fmt.Printf("Unavailable (%s), skipped.\n", status)
+	}
+	return fmt.Errorf("%w: %s", models.ErrNotAvailable, status)
+}
+
+// albumFolderName names a release's album folder, starting with the show
+// date with --date-prefix. Releases without a date keep the plain name.
+func (p *Processor) albumFolderName(meta *models.AlbArtResp) string {
+	name := meta.ArtistName + " - " + strings.TrimRight(meta.ContainerInfo, " ")
+	if !p.config.DatePrefix {
+		return name
+	}
+	if date, ok := models.ParseContainerDate(meta); ok {
+		return date.Format("2006-01-02") + " " + name
+	}
+	return name
+}
+
+// releaseTracks returns a release's tracks. Release metadata lists them as
+// tracks, while containers from an artist's discography list them as songs.
+func releaseTracks(meta *models.AlbArtResp) []models.Track {
+	if len(meta.Tracks) > 0 {
+		return meta.Tracks
+	}
+	return meta.Songs
+}
+
+// albumTrackMetadata builds tags for a release track, numbered by its
+// position in the release so both track lists are tagged the same way
+func (p *Processor) albumTrackMetadata(albumMeta *models.AlbArtResp, track *models.Track, trackNum, trackTotal int) *models.TrackMetadata {
+	metadata := &models.TrackMetadata{
+		Title:      track.SongTitle,
+		Artist:     albumMeta.ArtistName,
+		Album:      albumMeta.ContainerInfo,
+		TrackNum:   trackNum,
+		TrackTotal: trackTotal,
+		Comment:    p.trackComment(),
+		SourceID:   strconv.Itoa(albumMeta.ContainerID),
+	}
+	p.addIDTags(metadata, track, albumMeta.ContainerID, albumMeta.ArtistID)
+	p.addSortTags(metadata)
+	metadata.Date, _ = models.ParseContainerDate(albumMeta)
+	if discs := discCount(releaseTracks(albumMeta)); discs > 1 && track.DiscNum > 0 {
+		metadata.DiscNum = track.DiscNum
+		metadata.DiscTotal = discs
+	}
+	return metadata
+}
+
+// chooseTrackQuality resolves a track's stream URLs and picks the one to
+// download, falling back from the wanted format unless --no-fallback is set.
+// It also reports whether the track is only available over HLS, and for those
+// returns the track's other HLS streams to fall back on if the chosen one fails.
+func (p *Processor) chooseTrackQuality(track *models.Track, streamParams *models.StreamParams) (*models.Quality, []*models.Quality, bool, error) {
+	quals, unsupported, err := p.probeTrackQualities(track, streamParams)
+	if err != nil {
+		return nil, nil, false, err
+	}
+	if len(quals) == 0 {
+		return nil, nil, false, unsupportedFormatError(unsupported)
+	}
+	return p.selectTrackQuality(track, quals)
+}
+
+// probeTrackQualities resolves a track's stream URLs, returning the qualities
+// of the ones in a known format and the URLs of the rest
+func (p *Processor) probeTrackQualities(track *models.Track, streamParams *models.StreamParams) ([]*models.Quality, []string, error) {
 	var (
-		quals     []*models.Quality
-		chosenQual *models.Quality
+		quals       []*models.Quality
+		unsupported []string
 	)
 
 	// Call the stream meta endpoint four times to get all avail formats since the formats can shift.
@@ -401,65 +1152,120 @@ func (p *Processor) ProcessTrackWithMetadata(folPath string, trackNum, trackTota
 		streamUrl, err := p.apiClient.GetStreamMeta(track.TrackID, 0, i, streamParams)
 		if err != nil {
 			logger.GetLogger().Error("Failed to get track stream metadata", "error", err, "track_id", track.TrackID)
-			return err
+			return nil, nil, err
 		} else if streamUrl == "" {
-			return fmt.Errorf("the api didn't return a track stream URL")
+			return nil, nil, fmt.Errorf("the api didn't return a track stream URL")
 		}
 
 		quality := downloader.QueryQuality(streamUrl)
 		if quality == nil {
-			logger.GetLogger().Warn("API returned unsupported format", "url", streamUrl, "track_id", track.TrackID)
+			logger.GetLogger().WithFields(map[string]interface{}{
+				"url":      streamUrl,
+				"track_id": track.TrackID,
+			}).Warn("API returned unsupported format")
+			unsupported = append(unsupported, streamUrl)
 			continue
 		}
 		quals = append(quals, quality)
 	}
+	return quals, unsupported, nil
+}
 
-	if len(quals) == 0 {
-		return fmt.Errorf("the api didn't return any formats")
-	}
+// selectTrackQuality picks the quality to download from a track's probed
+// qualities
+func (p *Processor) selectTrackQuality(track *models.Track, quals []*models.Quality) (*models.Quality, []*models.Quality, bool, error) {
+	origWantFmt := p.config.Format
+	wantFmt := origWantFmt
+	var (
+		chosenQual   *models.Quality
+		hlsFallbacks []*models.Quality
+	)
 
 	isHlsOnly := downloader.CheckIfHlsOnly(quals)
 
-	// Create metadata for the track
-	var metadata *models.TrackMetadata
-	if albumMeta != nil {
-		metadata = &models.TrackMetadata{
-			Title:    track.SongTitle,
-			Artist:   albumMeta.ArtistName,
-			Album:    albumMeta.ContainerInfo,
-			TrackNum: trackNum,
-		}
-	}
-
 	if isHlsOnly {
 		fmt.Println("HLS-only track. Only AAC is available.")
-		chosenQual = quals[0]
-		err := p.downloader.ParseHlsMaster(chosenQual)
+		if p.config.NoFallback && origWantFmt != 4 && origWantFmt != 5 {
+			return nil, nil, false, p.formatUnavailable(track, origWantFmt)
+		}
+		var err error
+		chosenQual, hlsFallbacks, err = p.nextHlsQual(uniqueQuals(quals))
 		if err != nil {
-			return err
+			return nil, nil, false, err
 		}
 	} else {
-		for {
-			chosenQual = downloader.GetTrackQual(quals, wantFmt)
-			if chosenQual != nil {
-				break
-			} else {
-				// Fallback quality.
-				wantFmt = models.TrackFallback[wantFmt]
-			}
-		}
+		chosenQual, wantFmt = downloader.GetTrackQualWithFallback(quals, wantFmt)
 		if chosenQual == nil {
-			return fmt.Errorf("no track format was chosen")
+			return nil, nil, false, fmt.Errorf("no matching format was available for this track")
 		}
 		if wantFmt != origWantFmt && origWantFmt != 4 {
+			if p.config.NoFallback {
+				return nil, nil, false, p.formatUnavailable(track, origWantFmt)
+			}
 			fmt.Println("Unavailable in your chosen format.")
 		}
 	}
 
+	return chosenQual, hlsFallbacks, isHlsOnly, nil
+}
+
+// unsupportedFormatError reports a track none of whose stream URLs match a
+// known format. The URLs are listed without their query, which only holds
+// auth tokens, so the new format can be added to the quality map.
+func unsupportedFormatError(streamUrls []string) error {
+	if len(streamUrls) == 0 {
+		return fmt.Errorf("the api didn't return any formats")
+	}
+	seen := make(map[string]bool)
+	var paths []string
+	for _, streamUrl := range streamUrls {
+		path, _, _ := strings.Cut(streamUrl, "?")
+		if !seen[path] {
+			seen[path] = true
+			paths = append(paths, path)
+		}
+	}
+	return fmt.Errorf("%w, the api only returned formats this version doesn't recognise: %s",
+		models.ErrUnsupportedFormat, strings.Join(paths, ", "))
+}
+
+// processTrackWithTags downloads a single track and tags it with the given
+// metadata, if any
+func (p *Processor) processTrackWithTags(folPath string, trackNum, trackTotal int, track *models.Track, streamParams *models.StreamParams, metadata *models.TrackMetadata) error {
+	chosenQual, hlsFallbacks, isHlsOnly, err := p.chooseTrackQuality(track, streamParams)
+	if err != nil {
+		return err
+	}
+
+	folPath, err = p.formatSubfolder(folPath, chosenQual)
+	if err != nil {
+		fmt.Println("Failed to make format subfolder.")
+		return err
+	}
+	folPath, err = p.discSubfolder(folPath, track)
+	if err != nil {
+		fmt.Println("Failed to make disc subfolder.")
+		return err
+	}
+
 	trackFname := fmt.Sprintf("%02d. %s%s", trackNum, downloader.Sanitise(track.SongTitle), chosenQual.Extension)
-	trackPath := filepath.Join(folPath, trackFname)
+	// HLS URLs point at a manifest rather than the file, so those keep the template
+	if p.config.OriginalNames && !isHlsOnly {
+		if cdnName := cdnFilename(chosenQual.URL, chosenQual.Extension); cdnName != "" {
+			trackFname = cdnName
+		}
+	}
+	trackPath := filepath.Join(folPath, p.trackPrefix+trackFname)
+	if err := checkPathWithin(p.config.OutPath, trackPath); err != nil {
+		return err
+	}
 
-	exists, err := downloader.FileExists(trackPath)
+	finalPath := trackPath
+	if p.wantsConversion(chosenQual) {
+		finalPath = convertedPath(trackPath, p.config.ConvertTo)
+	}
+
+	exists, err := downloader.FileExists(finalPath)
 	if err != nil {
 		fmt.Println("Failed to check if track already exists locally.")
 		return err
@@ -467,17 +1273,15 @@ func (p *Processor) ProcessTrackWithMetadata(folPath string, trackNum, trackTota
 
 	if exists {
 		fmt.Println("Track already exists locally.")
+		p.recordTrack(finalPath)
 		return nil
 	}
 
 	fmt.Printf("Downloading track %d of %d: %s - %s\n", trackNum, trackTotal, track.SongTitle, chosenQual.Specs)
 
+	start := time.Now()
 	if isHlsOnly {
-		if metadata != nil {
-			err = p.processHlsOnlyWithMetadata(trackPath, chosenQual.URL, metadata)
-		} else {
-			err = p.processHlsOnly(trackPath, chosenQual.URL)
-		}
+		err = p.downloadHlsTrack(trackPath, chosenQual, hlsFallbacks, metadata)
 	} else {
 		if metadata != nil {
 			err = p.processTrackWithMetadata(trackPath, chosenQual.URL, metadata)
@@ -486,6 +1290,11 @@ func (p *Processor) ProcessTrackWithMetadata(folPath string, trackNum, trackTota
 		}
 	}
 
+	lastModified, _ := p.downloader.TakeLastModified(trackPath)
+	if errors.Is(err, models.ErrSampleLimit) {
+		// Samples are only for testing the flow, so they're not validated or tagged
+		return nil
+	}
 	if err != nil {
 		// Provide user-friendly error messages
 		if dlErr, ok := err.(*models.DownloadError); ok {
@@ -501,28 +1310,364 @@ func (p *Processor) ProcessTrackWithMetadata(folPath string, trackNum, trackTota
 		return err
 	}
 
+	trackPath, err = p.convertTrack(trackPath, chosenQual, metadata)
+	if err != nil {
+		return err
+	}
+
+	p.filterTrack(trackPath, chosenQual)
+	p.embedArtwork(trackPath)
+	p.setTrackMtime(trackPath, metadata, lastModified)
+	p.logTrackDownload(track, chosenQual, trackPath, time.Since(start))
+	p.recordTrack(trackPath)
+	p.stats.tracks.Add(1)
+	return p.runPostDownloadHook("track", trackPath, metadata)
+}
+
+// formatUnavailable reports a track skipped with --no-fallback because the
+// requested format isn't available. Format 4 asks for the best available, so
+// it's never skipped.
+func (p *Processor) formatUnavailable(track *models.Track, wantFmt int) error {
+	fmt.Println("Unavailable in your chosen format, skipped.")
+	logger.GetLogger().WithFields(map[string]interface{}{
+		"track":    track.SongTitle,
+		"track_id": track.TrackID,
+		"format":   wantFmt,
+	}).Info("Skipped track unavailable in the requested format")
+	return models.ErrFormatUnavailable
+}
+
+// isSegmentedVideo reports whether a video is split across distinct segments
+// and must be downloaded as a livestream. Player album page videos aren't
+// always only the first seg for the entire vid, and short videos may have a
+// single segment.
+func isSegmentedVideo(segUrls []string) bool {
+	return len(segUrls) > 1 && segUrls[0] != segUrls[1]
+}
+
+// checkPathWithin rejects paths that resolve outside root. Names come from
+// the API, so a release or track called ".." mustn't escape the output folder.
+func checkPathWithin(root, path string) error {
+	if err := fsutil.EnsureWithin(root, path); err != nil {
+		logger.GetLogger().WithFields(map[string]interface{}{
+			"path": path,
+			"root": root,
+		}).Warn("Refusing to write outside the output folder")
+		return models.NewDownloadError(models.ErrFileSystem, "Refusing to write outside the output folder", "The name from the API isn't safe to use as a path", false, err)
+	}
 	return nil
 }
 
-// ProcessPaidLstream processes a paid livestream
-func (p *Processor) ProcessPaidLstream(query, uguID string, streamParams *models.StreamParams) error {
-	q, err := url.ParseQuery(query)
+// cdnFilename returns the sanitised last path segment of a stream URL with
+// the given extension, or an empty string if the URL has no usable name
+func cdnFilename(streamUrl, ext string) string {
+	u, err := url.Parse(streamUrl)
 	if err != nil {
+		return ""
+	}
+
+	base := path.Base(u.Path)
+	base = strings.TrimSuffix(base, path.Ext(base))
+	if base == "" || base == "." || base == "/" {
+		return ""
+	}
+	return downloader.Sanitise(base) + ext
+}
+
+// folderNameLimit returns the longest album or playlist folder name allowed
+func (p *Processor) folderNameLimit() int {
+	if p.config.MaxFolderNameLen > 0 {
+		return p.config.MaxFolderNameLen
+	}
+	if runtime.GOOS == "windows" {
+		return WindowsMaxFolderNameLen
+	}
+	return MaxFolderNameLen
+}
+
+// videoFilenameLimit returns the longest video filename allowed
+func (p *Processor) videoFilenameLimit() int {
+	if p.config.MaxVideoFilenameLen > 0 {
+		return p.config.MaxVideoFilenameLen
+	}
+	if runtime.GOOS == "windows" {
+		return WindowsMaxVideoFilenameLen
+	}
+	return MaxVideoFilenameLen
+}
+
+// truncateName shortens a name to at most max bytes without splitting a
+// multi-byte character, and reports whether it was shortened
+func truncateName(name string, max int) (string, bool) {
+	if len(name) <= max {
+		return name, false
+	}
+
+	cut := max
+	for cut > 0 && !utf8.RuneStart(name[cut]) {
+		cut--
+	}
+	return name[:cut], true
+}
+
+// claimFolder makes a folder whose name was chopped and records its owner.
+// Two releases can chop down to the same name, so if the folder already
+// belongs to another release the owner id is appended instead of merging
+// their downloads.
+func claimFolder(folPath, ownerId string) (string, error) {
+	marker := filepath.Join(folPath, folderOwnerFile)
+	data, err := os.ReadFile(marker)
+	if err == nil && strings.TrimSpace(string(data)) != ownerId {
+		disambiguated := folPath + " [" + ownerId + "]"
+		fmt.Printf("Chopped folder name is already used by another release, using %s instead.\n", filepath.Base(disambiguated))
+		logger.GetLogger().WithFields(map[string]interface{}{
+			"folder":   folPath,
+			"owner_id": strings.TrimSpace(string(data)),
+			"id":       ownerId,
+		}).Warn("Disambiguated chopped folder name")
+		folPath = disambiguated
+		marker = filepath.Join(folPath, folderOwnerFile)
+	}
+
+	err = fsutil.MakeDirs(folPath)
+	if err != nil {
+		return "", err
+	}
+	err = os.WriteFile(marker, []byte(ownerId), fsutil.GetFileMode())
+	if err != nil {
+		return "", err
+	}
+	return folPath, nil
+}
+
+// formatSubfolder returns the folder to download a track of the given quality
+// into. With --format-subfolder the quality label is appended to the folder
+// name, e.g. "Artist - Album [FLAC16]", so releases can be archived in several
+// qualities side by side. Tracks saved straight to the output folder, as with
+// --no-folder, aren't labelled, as that would put them beside it.
+func (p *Processor) formatSubfolder(folPath string, qual *models.Quality) (string, error) {
+	label := models.QualityLabel(qual.Specs)
+	if !p.config.FormatSubfolder || label == "" || filepath.Clean(folPath) == filepath.Clean(p.config.OutPath) {
+		return folPath, nil
+	}
+
+	labelled := folPath + " [" + label + "]"
+	err := fsutil.MakeDirs(labelled)
+	if err != nil {
+		return "", err
+	}
+	return labelled, nil
+}
+
+// useDiscFolders puts the tracks of multi-disc releases in a subfolder per
+// disc with --disc-folders, until the returned func is called. Releases with
+// one disc or no disc numbers stay flat, as do ones saved with --no-folder.
+func (p *Processor) useDiscFolders(tracks []models.Track) func() {
+	if !p.config.DiscFolders || p.config.NoFolder || discCount(tracks) < 2 {
+		return func() {}
+	}
+	p.discFolders = true
+	return func() { p.discFolders = false }
+}
+
+// discCount returns the number of discs a release's tracks are numbered across
+func discCount(tracks []models.Track) int {
+	discs := make(map[int]bool)
+	for _, track := range tracks {
+		if track.DiscNum > 0 {
+			discs[track.DiscNum] = true
+		}
+	}
+	return len(discs)
+}
+
+// discFolderName names a disc's subfolder
+func discFolderName(disc int) string {
+	return fmt.Sprintf("Disc %d", disc)
+}
+
+// discSubfolder returns the folder to download a track into for its disc.
+// Tracks without a disc number stay in the release folder.
+func (p *Processor) discSubfolder(folPath string, track *models.Track) (string, error) {
+	if !p.discFolders || track.DiscNum < 1 {
+		return folPath, nil
+	}
+
+	discPath := filepath.Join(folPath, discFolderName(track.DiscNum))
+	err := fsutil.MakeDirs(discPath)
+	if err != nil {
+		return "", err
+	}
+	return discPath, nil
+}
+
+// logTrackDownload writes a structured INFO entry for a finished track
+// download when verbose logging is on
+func (p *Processor) logTrackDownload(track *models.Track, qual *models.Quality, trackPath string, elapsed time.Duration) {
+	if !p.config.Verbose {
+		return
+	}
+
+	var size int64
+	if info, err := os.Stat(trackPath); err == nil {
+		size = info.Size()
+	}
+
+	var speed int64
+	if elapsed > 0 {
+		speed = int64(float64(size) / elapsed.Seconds())
+	}
+
+	logger.GetLogger().WithFields(map[string]interface{}{
+		"track_id":    track.TrackID,
+		"format":      qual.Format,
+		"specs":       qual.Specs,
+		"bytes":       size,
+		"duration_ms": elapsed.Milliseconds(),
+		"speed_bps":   speed,
+	}).Info("Track downloaded")
+}
+
+// trackComment returns the comment tag for downloaded tracks, preferring the
+// user's configured comment over the default provenance note
+func (p *Processor) trackComment() string {
+	if p.config.Comment != "" {
+		return p.config.Comment
+	}
+	return "Downloaded from Nugs via Nugs-Downloader on " + time.Now().Format("2006-01-02")
+}
+
+// ProcessSingleTrack downloads one track from a release straight into the
+// output folder. itemId is "<release id>/track/<track id>". There's no
+// track-level metadata endpoint, so the track is looked up in its release's
+// metadata, which also supplies the tags.
+func (p *Processor) ProcessSingleTrack(itemId string, streamParams *models.StreamParams) error {
+	parts := strings.SplitN(itemId, "/track/", 2)
+	if len(parts) != 2 {
+		return fmt.Errorf("invalid track id: %s", itemId)
+	}
+	releaseId := parts[0]
+	trackId, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return fmt.Errorf("invalid track id: %s", parts[1])
+	}
+
+	_meta, err := p.apiClient.GetAlbumMeta(releaseId)
+	if err != nil {
+		logger.GetLogger().WithError(err).WithField("album_id", releaseId).Error("Failed to get album metadata")
+		return models.NewDownloadError(models.ErrNetwork, "Failed to get album metadata", "Check your internet connection and try again", true, err)
+	}
+	meta := _meta.Response
+	if err := checkAvailable(meta); err != nil {
 		return err
 	}
 
-	showId := q["showID"][0]
-	if showId == "" {
-		return fmt.Errorf("url didn't contain a show id parameter")
+	tracks := releaseTracks(meta)
+	for trackNum, track := range tracks {
+		if track.TrackID != trackId {
+			continue
+		}
+		fmt.Println(meta.ArtistName + " - " + track.SongTitle)
+		if p.config.DumpURLs {
+			return p.dumpTrackURLs([]models.Track{track}, streamParams)
+		}
+		if p.config.SimulateQuality {
+			return p.simulateTrackQuality([]models.Track{track}, streamParams)
+		}
+		defer p.useArtwork(meta)()
+
+		var trackPaths []string
+		if p.remote != nil {
+			p.trackPaths = &trackPaths
+			defer func() { p.trackPaths = nil }()
+		}
+		// Single tracks go straight to the output folder, which
+		// --format-subfolder leaves unlabelled
+		err := p.ProcessTrackWithMetadata(p.config.OutPath, trackNum+1, len(tracks), &track, streamParams, meta)
+		if err != nil {
+			return err
+		}
+		return p.moveToRemote(outputRoots(p.config.OutPath, trackPaths)...)
+	}
+
+	return models.NewDownloadError(models.ErrUnknown, fmt.Sprintf("Track %d isn't on release %s", trackId, releaseId), "Check the track URL", false, nil)
+}
+
+// ProcessPaidLstream processes a paid livestream
+func (p *Processor) ProcessPaidLstream(query, uguID string, streamParams *models.StreamParams) error {
+	showId, err := stashShowID(query)
+	if err != nil {
+		return err
 	}
 
 	err = p.ProcessVideo(showId, uguID, streamParams, nil, true)
 	return err
 }
 
+// ProcessWebcast processes a webcast from the My Webcasts page. Those are
+// bought rather than part of a subscription, so their manifest is requested
+// with the account's uguID like a paid livestream's.
+func (p *Processor) ProcessWebcast(showID, uguID string, streamParams *models.StreamParams) error {
+	if uguID == "" {
+		return models.NewDownloadError(models.ErrAuthentication, "Purchased webcasts need the account's uguID", "The login token didn't include it. Try signing in again", false, nil)
+	}
+	return p.ProcessVideo(showID, uguID, streamParams, nil, true)
+}
+
+// waitForLstream polls a livestream's metadata until it becomes available
+// with --wait-for-available, and returns it. Failed checks are logged and
+// retried, as a wait can span hours, but it gives up after lstreamMaxWait.
+func (p *Processor) waitForLstream(videoID string) (*models.AlbArtResp, error) {
+	deadline := time.Now().Add(lstreamMaxWait)
+	announced := false
+	status := "no metadata"
+	for {
+		m, err := p.apiClient.GetAlbumMeta(videoID)
+		switch {
+		case err != nil:
+			logger.GetLogger().WithError(err).WithField("video_id", videoID).Warn("Failed to check livestream availability, retrying")
+		case m.Response == nil:
+			logger.GetLogger().WithField("video_id", videoID).Warn("No livestream metadata, retrying")
+		case m.Response.AvailabilityTypeStr == "AVAILABLE":
+			return m.Response, nil
+		default:
+			status = m.Response.AvailabilityTypeStr
+			if !announced {
+				fmt.Printf("Livestream isn't available yet (%s), checking every %v...\n", status, lstreamPollInterval)
+				announced = true
+			}
+		}
+
+		if time.Now().Add(lstreamPollInterval).After(deadline) {
+			fmt.Printf("Livestream still isn't available after %v, giving up.\n", lstreamMaxWait)
+			return nil, fmt.Errorf("%w: livestream %s still %s after %v", models.ErrNotAvailable, videoID, status, lstreamMaxWait)
+		}
+		time.Sleep(lstreamPollInterval)
+	}
+}
+
+// stashShowID returns the show id from the query of a purchased livestream
+// URL. Stash-QueueVideo URLs carry it as showID, and NugsVideo-GetStashVideo
+// URLs have been seen with other casings or as the container id.
+func stashShowID(query string) (string, error) {
+	q, err := url.ParseQuery(query)
+	if err != nil {
+		return "", err
+	}
+
+	for _, key := range []string{"showid", "containerid"} {
+		for name, values := range q {
+			if strings.ToLower(name) == key && len(values) > 0 && values[0] != "" {
+				return values[0], nil
+			}
+		}
+	}
+	return "", fmt.Errorf("url didn't contain a show id parameter")
+}
+
 // ProcessCatalogPlist processes a catalog playlist
 func (p *Processor) ProcessCatalogPlist(_plistId, legacyToken string, streamParams *models.StreamParams) error {
-	plistId, err := resolveCatPlistId(_plistId)
+	plistId, err := p.resolveCatPlistId(_plistId)
 	if err != nil {
 		fmt.Println("Failed to resolve playlist ID.")
 		return err
@@ -534,23 +1679,107 @@ func (p *Processor) ProcessCatalogPlist(_plistId, legacyToken string, streamPara
 
 // Helper functions
 func getAlbumTotal(meta []*models.ArtistMeta) int {
-	var total int
+	return len(artistContainers(meta))
+}
+
+// artistContainers flattens the containers of every artist meta page,
+// skipping pages the API returned without a response
+func artistContainers(meta []*models.ArtistMeta) []*models.AlbArtResp {
+	var containers []*models.AlbArtResp
 	for _, _meta := range meta {
-		total += len(_meta.Response.Containers)
+		if _meta == nil || _meta.Response == nil {
+			continue
+		}
+		containers = append(containers, _meta.Response.Containers...)
 	}
-	return total
+	return containers
 }
 
 func getVideoSku(products []models.Product) int {
+	videos := getVideoProducts(products)
+	if len(videos) == 0 {
+		return 0
+	}
+	return videos[0].SkuID
+}
+
+// getAudioSku returns the SKU of a container's audio-only product, or 0 if
+// it has none
+func getAudioSku(products []models.Product) int {
 	for _, product := range products {
-		formatStr := product.FormatStr
-		if formatStr == "VIDEO ON DEMAND" || formatStr == "LIVE HD VIDEO" {
+		if product.FormatStr == "AUDIO ONLY" {
 			return product.SkuID
 		}
 	}
 	return 0
 }
 
+// getVideoProducts returns the video products of a container in API order
+func getVideoProducts(products []models.Product) []models.Product {
+	var videos []models.Product
+	for _, product := range products {
+		formatStr := product.FormatStr
+		if formatStr == "VIDEO ON DEMAND" || formatStr == "LIVE HD VIDEO" {
+			videos = append(videos, product)
+		}
+	}
+	return videos
+}
+
+// selectProduct finds a product by its 1-based index or, case-insensitively,
+// by its format name
+func selectProduct(products []models.Product, selector string) (models.Product, bool) {
+	if idx, err := strconv.Atoi(selector); err == nil {
+		if idx >= 1 && idx <= len(products) {
+			return products[idx-1], true
+		}
+		return models.Product{}, false
+	}
+	for _, product := range products {
+		if strings.EqualFold(product.FormatStr, selector) {
+			return product, true
+		}
+	}
+	return models.Product{}, false
+}
+
+// videoProducts returns the video products to download. That's the first
+// one by default, the one picked with --product, or all of them with
+// --all-products. Livestreams only ever have the one.
+func (p *Processor) videoProducts(meta *models.AlbArtResp, isLstream bool) ([]models.Product, error) {
+	if isLstream {
+		skuID := getLstreamSku(meta.ProductFormatList)
+		if skuID == 0 {
+			return nil, fmt.Errorf("no video available")
+		}
+		return []models.Product{{FormatStr: "LIVE HD VIDEO", SkuID: skuID}}, nil
+	}
+
+	products := getVideoProducts(meta.Products)
+	if len(products) == 0 {
+		return nil, fmt.Errorf("no video available")
+	}
+	if len(products) > 1 {
+		fmt.Println("Available products:")
+		for i, product := range products {
+			fmt.Printf("  %d) %s\n", i+1, product.FormatStr)
+		}
+	}
+
+	switch {
+	case p.config.AllProducts:
+		return products, nil
+	case p.config.Product != "":
+		product, ok := selectProduct(products, p.config.Product)
+		if !ok {
+			return nil, fmt.Errorf("no product matches %q", p.config.Product)
+		}
+		return []models.Product{product}, nil
+	default:
+		return products[:1], nil
+	}
+}
+
 func getLstreamSku(products []*models.ProductFormatList) int {
 	for _, product := range products {
 		if product.FormatStr == "LIVE HD VIDEO" {
@@ -595,6 +1824,9 @@ func (p *Processor) processTrackWithMetadata(trackPath, url string, metadata *mo
 	// For now, use the existing method but with better error handling
 	err := p.downloader.DownloadTrackWithMetadata(trackPath, url, metadata, p.config.FfmpegNameStr)
 	if err != nil {
+		if timeoutErr, ok := trackTimeout(err); ok {
+			return timeoutErr
+		}
 		// Parse FFmpeg errors if they occur
 		if strings.Contains(err.Error(), "ffmpeg") {
 			errType, msg, guide := downloader.ParseFFmpegError(err, "")
@@ -605,10 +1837,23 @@ func (p *Processor) processTrackWithMetadata(trackPath, url string, metadata *mo
 	return nil
 }
 
+// trackTimeout returns the error of a download cut off by --track-timeout,
+// which already says the track can be retried
+func trackTimeout(err error) (*models.DownloadError, bool) {
+	var dlErr *models.DownloadError
+	if errors.As(err, &dlErr) && dlErr.Type == models.ErrTimeout {
+		return dlErr, true
+	}
+	return nil, false
+}
+
 // processHlsOnly processes HLS-only track with robust error handling
 func (p *Processor) processHlsOnly(trackPath, manifestUrl string) error {
 	err := p.downloader.HlsOnly(trackPath, manifestUrl, p.config.FfmpegNameStr)
 	if err != nil {
+		if timeoutErr, ok := trackTimeout(err); ok {
+			return timeoutErr
+		}
 		// Parse FFmpeg errors
 		if strings.Contains(err.Error(), "ffmpeg") {
 			errType, msg, guide := downloader.ParseFFmpegError(err, "")
@@ -619,10 +1864,70 @@ func (p *Processor) processHlsOnly(trackPath, manifestUrl string) error {
 	return nil
 }
 
+// uniqueQuals drops qualities whose stream URL repeats an earlier one
+func uniqueQuals(quals []*models.Quality) []*models.Quality {
+	seen := make(map[string]bool)
+	var unique []*models.Quality
+	for _, qual := range quals {
+		if !seen[qual.URL] {
+			seen[qual.URL] = true
+			unique = append(unique, qual)
+		}
+	}
+	return unique
+}
+
+// nextHlsQual picks the first HLS-only quality whose master playlist can be
+// parsed, returning it with the qualities left after it
+func (p *Processor) nextHlsQual(quals []*models.Quality) (*models.Quality, []*models.Quality, error) {
+	err := errors.New("no HLS streams to try")
+	for i, qual := range quals {
+		if err = p.downloader.ParseHlsMaster(qual); err == nil {
+			return qual, quals[i+1:], nil
+		}
+		if errors.Is(err, models.ErrBelowMinBitrate) {
+			logger.GetLogger().WithError(err).WithField("url", qual.URL).Info("Skipping HLS stream below the minimum bitrate")
+			continue
+		}
+		logger.GetLogger().WithError(err).WithField("url", qual.URL).Warn("Failed to parse HLS master playlist")
+	}
+	return nil, nil, err
+}
+
+// downloadHlsTrack downloads an HLS-only track, moving on to the track's
+// other HLS streams if it fails. Every stream of an HLS-only track is HLS,
+// so there's no direct download to fall back on, but the streams come from
+// separate stream meta calls and one can fail while another works, such as
+// when its key is rejected.
+func (p *Processor) downloadHlsTrack(trackPath string, qual *models.Quality, fallbacks []*models.Quality, metadata *models.TrackMetadata) error {
+	for {
+		var err error
+		if metadata != nil {
+			err = p.processHlsOnlyWithMetadata(trackPath, qual.URL, metadata)
+		} else {
+			err = p.processHlsOnly(trackPath, qual.URL)
+		}
+		if err == nil || errors.Is(err, models.ErrSampleLimit) || len(fallbacks) == 0 {
+			return err
+		}
+
+		logger.GetLogger().WithError(err).WithField("path", trackPath).Warn("HLS download failed, trying the next stream")
+		fmt.Println("HLS download failed, trying the track's next stream...")
+		next, rest, parseErr := p.nextHlsQual(fallbacks)
+		if parseErr != nil {
+			return err
+		}
+		qual, fallbacks = next, rest
+	}
+}
+
 // processHlsOnlyWithMetadata processes HLS-only track with metadata and robust error handling
 func (p *Processor) processHlsOnlyWithMetadata(trackPath, manifestUrl string, metadata *models.TrackMetadata) error {
 	err := p.downloader.HlsOnlyWithMetadata(trackPath, manifestUrl, p.config.FfmpegNameStr, metadata)
 	if err != nil {
+		if timeoutErr, ok := trackTimeout(err); ok {
+			return timeoutErr
+		}
 		// Parse FFmpeg errors
 		if strings.Contains(err.Error(), "ffmpeg") {
 			errType, msg, guide := downloader.ParseFFmpegError(err, "")
@@ -633,34 +1938,86 @@ func (p *Processor) processHlsOnlyWithMetadata(trackPath, manifestUrl string, me
 	return nil
 }
 
-func resolveCatPlistId(plistUrl string) (string, error) {
-	// Create a new HTTP client for this request
-	httpClient := &http.Client{}
-	req, err := httpClient.Get(plistUrl)
-	if err != nil {
-		return "", models.NewDownloadError(models.ErrNetwork, "Failed to resolve playlist URL", "Check your internet connection", true, err)
-	}
-	defer req.Body.Close()
+// Catalog playlist shortlinks are resolved with a few retries, and give up on
+// redirect chains longer than maxPlistRedirects
+const (
+	plistResolveAttempts = 3
+	maxPlistRedirects    = 10
+)
 
-	if req.StatusCode != http.StatusOK {
-		return "", models.NewDownloadError(models.ErrNetwork, "Playlist URL returned error", "The playlist may not be publicly available", false, errors.New(req.Status))
+var (
+	plistRetryDelay          = time.Second
+	errTooManyPlistRedirects = errors.New("too many redirects")
+)
+
+// resolveCatPlistId follows a catalog playlist shortlink and returns the
+// plGUID it redirects to. Redirects stop as soon as the playlist id or a
+// login page is reached, so the final page is never fetched. It goes through
+// the API client's transport like every other request.
+func (p *Processor) resolveCatPlistId(plistUrl string) (string, error) {
+	checkRedirect := func(req *http.Request, via []*http.Request) error {
+		if len(via) >= maxPlistRedirects {
+			return errTooManyPlistRedirects
+		}
+		if req.URL.Query().Get("plGUID") != "" || isLoginURL(req.URL) {
+			return http.ErrUseLastResponse
+		}
+		return nil
 	}
 
-	location := req.Request.URL.String()
-	u, err := url.Parse(location)
+	var (
+		resp *http.Response
+		err  error
+	)
+	for attempt := 0; attempt < plistResolveAttempts; attempt++ {
+		if attempt > 0 {
+			time.Sleep(time.Duration(attempt) * plistRetryDelay)
+		}
+		resp, err = p.apiClient.GetWithRedirectPolicy(plistUrl, checkRedirect)
+		if errors.Is(err, errTooManyPlistRedirects) {
+			return "", models.NewDownloadError(models.ErrNetwork, "Playlist URL redirected too many times", "Check the playlist URL and try again", false, err)
+		}
+		if err != nil {
+			// Only timeouts are worth retrying, like the API client does
+			if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+				continue
+			}
+			break
+		}
+		if resp.StatusCode < http.StatusInternalServerError {
+			break
+		}
+		resp.Body.Close()
+		err = errors.New(resp.Status)
+	}
 	if err != nil {
-		return "", models.NewDownloadError(models.ErrUnknown, "Invalid playlist URL format", "Check the playlist URL and try again", false, err)
+		return "", models.NewDownloadError(models.ErrNetwork, "Failed to resolve playlist URL", "Check your internet connection", true, err)
+	}
+	defer resp.Body.Close()
+
+	// Redirects that were stopped early leave the target in Location
+	u := resp.Request.URL
+	if location, err := resp.Location(); err == nil {
+		u = location
+	} else if resp.StatusCode != http.StatusOK {
+		return "", models.NewDownloadError(models.ErrNetwork, "Playlist URL returned error", "The playlist may not be publicly available", false, errors.New(resp.Status))
 	}
 
-	q, err := url.ParseQuery(u.RawQuery)
-	if err != nil {
-		return "", models.NewDownloadError(models.ErrUnknown, "Failed to parse playlist URL", "The playlist URL format is invalid", false, err)
+	if isLoginURL(u) {
+		return "", models.NewDownloadError(models.ErrAuthentication, "Playlist requires login", "This playlist is only visible when signed in to nugs.net. It may be private", false, nil)
 	}
 
-	resolvedId := q.Get("plGUID")
+	resolvedId := u.Query().Get("plGUID")
 	if resolvedId == "" {
 		return "", models.NewDownloadError(models.ErrUnknown, "Not a catalog playlist", "This appears to be a user playlist, not a catalog playlist", false, nil)
 	}
 
 	return resolvedId, nil
 }
+
+// isLoginURL reports whether a URL is a nugs.net sign-in page
+func isLoginURL(u *url.URL) bool {
+	host := strings.ToLower(u.Hostname())
+	path := strings.ToLower(u.Path)
+	return host == "id.nugs.net" || strings.Contains(path, "login") || strings.Contains(path, "signin")
+}
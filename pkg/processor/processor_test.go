@@ -1,21 +1,32 @@
 package processor
 
 import (
+	"bytes"
+	"encoding/binary"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"os"
 	"path/filepath"
+	"runtime"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/grafov/m3u8"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/suite"
 	"main/pkg/api"
 	"main/pkg/config"
 	"main/pkg/downloader"
+	"main/pkg/logger"
 	"main/pkg/models"
 )
 
+// testJPEG is served as release artwork, starting like a real JPEG
+var testJPEG = []byte("\xff\xd8\xff\xe0jpeg")
+
 // TestSuite for processor package
 type ProcessorTestSuite struct {
 	suite.Suite
@@ -25,6 +36,7 @@ type ProcessorTestSuite struct {
 	downloader *downloader.Downloader
 	config     *config.Config
 	processor  *Processor
+	streamLink string // returned by the stream meta endpoint when set
 }
 
 // SetupTest creates a temporary directory and test infrastructure
@@ -84,6 +96,8 @@ func (suite *ProcessorTestSuite) handleRequest(w http.ResponseWriter, r *http.Re
 		suite.handleApiAsx(w, r)
 	case "/bigriver/subPlayer.aspx":
 		suite.handleSubPlayer(w, r)
+	case "/images/front.jpg":
+		w.Write(testJPEG)
 	default:
 		w.WriteHeader(http.StatusNotFound)
 	}
@@ -158,6 +172,9 @@ func (suite *ProcessorTestSuite) handleSubPlayer(w http.ResponseWriter, r *http.
 	response := models.StreamMeta{
 		StreamLink: "https://stream.example.com/audio.m3u8",
 	}
+	if suite.streamLink != "" {
+		response.StreamLink = suite.streamLink
+	}
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(response)
 }
@@ -214,6 +231,46 @@ func (suite *ProcessorTestSuite) TestGetVideoSku() {
 	assert.Equal(suite.T(), 0, skuID)
 }
 
+// TestGetAudioSku tests audio-only SKU extraction
+func (suite *ProcessorTestSuite) TestGetAudioSku() {
+	products := []models.Product{
+		{FormatStr: "VIDEO ON DEMAND", SkuID: 2},
+		{FormatStr: "AUDIO ONLY", SkuID: 1},
+	}
+	assert.Equal(suite.T(), 1, getAudioSku(products))
+
+	videoOnly := []models.Product{
+		{FormatStr: "VIDEO ON DEMAND", SkuID: 2},
+	}
+	assert.Equal(suite.T(), 0, getAudioSku(videoOnly))
+}
+
+// TestDownloadVideoAudio_NoAudioProduct tests that --also-audio skips videos
+// without an audio-only product, or without tracks to download from it
+func (suite *ProcessorTestSuite) TestDownloadVideoAudio_NoAudioProduct() {
+	streamParams := &models.StreamParams{SubscriptionID: "sub-123", UserID: "user-456"}
+	tracks := []models.Track{{TrackID: 1, SongTitle: "Tweezer"}}
+
+	videoOnly := &models.AlbArtResp{
+		ArtistName:    "Test Artist",
+		ContainerInfo: "Test Video",
+		Tracks:        tracks,
+		Products:      []models.Product{{FormatStr: "VIDEO ON DEMAND", SkuID: 2}},
+	}
+	assert.NoError(suite.T(), suite.processor.downloadVideoAudio(videoOnly, streamParams))
+
+	noTracks := &models.AlbArtResp{
+		ArtistName:    "Test Artist",
+		ContainerInfo: "Test Video",
+		Products:      []models.Product{{FormatStr: "AUDIO ONLY", SkuID: 1}},
+	}
+	assert.NoError(suite.T(), suite.processor.downloadVideoAudio(noTracks, streamParams))
+
+	entries, err := os.ReadDir(suite.config.OutPath)
+	suite.Require().NoError(err)
+	assert.Empty(suite.T(), entries)
+}
+
 // TestGetLstreamSku tests livestream SKU extraction
 func (suite *ProcessorTestSuite) TestGetLstreamSku() {
 	products := []*models.ProductFormatList{
@@ -282,6 +339,679 @@ func (suite *ProcessorTestSuite) TestParseLstreamMeta() {
 	assert.Len(suite.T(), parsed.Response.VideoChapters, 2)
 }
 
+// TestLogTrackDownload tests the verbose per-track log entry
+func (suite *ProcessorTestSuite) TestLogTrackDownload() {
+	var buf bytes.Buffer
+	log := logger.GetLogger()
+	log.SetOutput(&buf)
+	defer log.SetOutput(os.Stdout)
+
+	trackPath := filepath.Join(suite.tempDir, "01. Test Song.flac")
+	suite.Require().NoError(os.WriteFile(trackPath, make([]byte, 2048), 0644))
+	track := &models.Track{TrackID: 42, SongTitle: "Test Song"}
+	qual := &models.Quality{Format: 2, Specs: "16-bit / 44.1 kHz FLAC"}
+
+	// Nothing is logged by default
+	suite.processor.logTrackDownload(track, qual, trackPath, time.Second)
+	assert.Empty(suite.T(), buf.String())
+
+	suite.config.Verbose = true
+	suite.processor.logTrackDownload(track, qual, trackPath, 2*time.Second)
+
+	var entry map[string]interface{}
+	suite.Require().NoError(json.Unmarshal(buf.Bytes(), &entry))
+	assert.Equal(suite.T(), "info", entry["level"])
+	assert.Equal(suite.T(), "Track downloaded", entry["msg"])
+	assert.Equal(suite.T(), float64(42), entry["track_id"])
+	assert.Equal(suite.T(), float64(2), entry["format"])
+	assert.Equal(suite.T(), float64(2048), entry["bytes"])
+	assert.Equal(suite.T(), float64(2000), entry["duration_ms"])
+	assert.Equal(suite.T(), float64(1024), entry["speed_bps"])
+}
+
+// TestFilterArtistContainers tests --artist-filter by name and by id
+func (suite *ProcessorTestSuite) TestFilterArtistContainers() {
+	containers := []*models.AlbArtResp{
+		{ArtistID: 62, ArtistName: "Phish", ContainerID: 1},
+		{ArtistID: 1105, ArtistName: "Trey Anastasio Band", ContainerID: 2},
+		{ArtistID: 62, ArtistName: "Phish", ContainerID: 3},
+	}
+
+	assert.Len(suite.T(), suite.processor.filterArtistContainers(containers), 3)
+
+	suite.config.ArtistFilter = "^phish$"
+	kept := suite.processor.filterArtistContainers(containers)
+	suite.Require().Len(kept, 2)
+	assert.Equal(suite.T(), 1, kept[0].ContainerID)
+	assert.Equal(suite.T(), 3, kept[1].ContainerID)
+
+	suite.config.ArtistFilter = "1105"
+	kept = suite.processor.filterArtistContainers(containers)
+	suite.Require().Len(kept, 1)
+	assert.Equal(suite.T(), 2, kept[0].ContainerID)
+}
+
+// TestPreferSources tests keeping the best source recording of each show
+func (suite *ProcessorTestSuite) TestPreferSources() {
+	containers := []*models.AlbArtResp{
+		{ArtistID: 62, ContainerID: 1, ContainerInfo: "12/31/99 Big Cypress (AUD)"},
+		{ArtistID: 62, ContainerID: 2, ContainerInfo: "12/31/99 Big Cypress (SBD)"},
+		{ArtistID: 62, ContainerID: 3, ContainerInfo: "12/31/99 Big Cypress Webcast"},
+		{ArtistID: 62, ContainerID: 4, ContainerInfo: "12/30/99 Big Cypress (AUD)"},
+		{ArtistID: 62, ContainerID: 5, ContainerInfo: "12/30/99 Big Cypress (Matrix)"},
+		{ArtistID: 1105, ContainerID: 6, ContainerInfo: "12/31/99 New Year's (AUD)"},
+	}
+	ids := func(containers []*models.AlbArtResp) []int {
+		var ids []int
+		for _, container := range containers {
+			ids = append(ids, container.ContainerID)
+		}
+		return ids
+	}
+
+	assert.Equal(suite.T(), []int{1, 2, 3, 4, 5, 6}, ids(suite.processor.preferSources(containers)))
+
+	// Unlabelled releases and other artists' shows are kept, and the matrix
+	// stands in for a missing soundboard
+	suite.config.SourcePreference = "soundboard"
+	assert.Equal(suite.T(), []int{2, 3, 5, 6}, ids(suite.processor.preferSources(containers)))
+
+	suite.config.SourcePreference = "audience"
+	assert.Equal(suite.T(), []int{1, 3, 4, 6}, ids(suite.processor.preferSources(containers)))
+}
+
+// TestPartialFailureError tests that partial album failures only fail with
+// --strict, and leave the item incomplete either way
+func (suite *ProcessorTestSuite) TestPartialFailureError() {
+	assert.False(suite.T(), suite.processor.Incomplete())
+	assert.NoError(suite.T(), suite.processor.partialFailureError(1, 10))
+	assert.True(suite.T(), suite.processor.Incomplete())
+
+	suite.processor.ResetIncomplete()
+	assert.False(suite.T(), suite.processor.Incomplete())
+
+	suite.config.Strict = true
+	err := suite.processor.partialFailureError(1, 10)
+	assert.Error(suite.T(), err)
+	assert.Contains(suite.T(), err.Error(), "1 of 10 tracks failed")
+	assert.True(suite.T(), suite.processor.Incomplete())
+}
+
+// TestVideoProducts tests picking video products with --product and --all-products
+func (suite *ProcessorTestSuite) TestVideoProducts() {
+	meta := &models.AlbArtResp{Products: []models.Product{
+		{FormatStr: "16-bit FLAC", SkuID: 1},
+		{FormatStr: "VIDEO ON DEMAND", SkuID: 2},
+		{FormatStr: "LIVE HD VIDEO", SkuID: 3},
+	}}
+
+	products, err := suite.processor.videoProducts(meta, false)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), []models.Product{{FormatStr: "VIDEO ON DEMAND", SkuID: 2}}, products)
+
+	suite.config.Product = "2"
+	products, err = suite.processor.videoProducts(meta, false)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 3, products[0].SkuID)
+
+	suite.config.Product = "video on demand"
+	products, err = suite.processor.videoProducts(meta, false)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 2, products[0].SkuID)
+
+	suite.config.Product = "5"
+	_, err = suite.processor.videoProducts(meta, false)
+	assert.Error(suite.T(), err)
+
+	suite.config.Product = ""
+	suite.config.AllProducts = true
+	products, err = suite.processor.videoProducts(meta, false)
+	assert.NoError(suite.T(), err)
+	assert.Len(suite.T(), products, 2)
+
+	_, err = suite.processor.videoProducts(&models.AlbArtResp{}, false)
+	assert.Error(suite.T(), err)
+}
+
+// TestSetReleaseMtime tests that --set-mtime dates tracks and skips unknown dates
+func (suite *ProcessorTestSuite) TestSetReleaseMtime() {
+	trackPath := filepath.Join(suite.tempDir, "01. Tweezer.flac")
+	suite.Require().NoError(os.WriteFile(trackPath, []byte("audio"), 0644))
+	showDate := time.Date(1997, 11, 17, 0, 0, 0, 0, time.UTC)
+
+	suite.processor.setReleaseMtime(trackPath, &models.TrackMetadata{Date: showDate})
+	stat, err := os.Stat(trackPath)
+	suite.Require().NoError(err)
+	assert.False(suite.T(), stat.ModTime().Equal(showDate))
+
+	suite.config.SetMtime = true
+	suite.processor.setReleaseMtime(trackPath, &models.TrackMetadata{})
+	suite.processor.setReleaseMtime(trackPath, nil)
+	stat, err = os.Stat(trackPath)
+	suite.Require().NoError(err)
+	assert.False(suite.T(), stat.ModTime().Equal(showDate))
+
+	suite.processor.setReleaseMtime(trackPath, &models.TrackMetadata{Date: showDate})
+	stat, err = os.Stat(trackPath)
+	suite.Require().NoError(err)
+	assert.True(suite.T(), stat.ModTime().Equal(showDate))
+}
+
+// TestSetTrackMtime tests the show date takes precedence over Last-Modified,
+// which is only used with --mtime-from-header
+func (suite *ProcessorTestSuite) TestSetTrackMtime() {
+	trackPath := filepath.Join(suite.tempDir, "01. Tweezer.flac")
+	suite.Require().NoError(os.WriteFile(trackPath, []byte("audio"), 0644))
+	showDate := time.Date(1997, 11, 17, 0, 0, 0, 0, time.UTC)
+	lastModified := time.Date(2019, 7, 14, 18, 30, 0, 0, time.UTC)
+	modTime := func() time.Time {
+		stat, err := os.Stat(trackPath)
+		suite.Require().NoError(err)
+		return stat.ModTime()
+	}
+
+	suite.processor.setTrackMtime(trackPath, &models.TrackMetadata{}, lastModified)
+	assert.False(suite.T(), modTime().Equal(lastModified))
+
+	suite.config.MtimeFromHeader = true
+	suite.processor.setTrackMtime(trackPath, &models.TrackMetadata{}, lastModified)
+	assert.True(suite.T(), modTime().Equal(lastModified))
+
+	suite.config.SetMtime = true
+	suite.processor.setTrackMtime(trackPath, &models.TrackMetadata{Date: showDate}, lastModified)
+	assert.True(suite.T(), modTime().Equal(showDate))
+
+	suite.processor.setTrackMtime(trackPath, nil, lastModified)
+	assert.True(suite.T(), modTime().Equal(lastModified))
+}
+
+// TestAddIDTags tests that Nugs id tags are only filled in with idTags set
+func (suite *ProcessorTestSuite) TestAddIDTags() {
+	track := &models.Track{TrackID: 456789, SongID: 12}
+
+	metadata := &models.TrackMetadata{}
+	suite.processor.addIDTags(metadata, track, 23329, 1045)
+	assert.Equal(suite.T(), &models.TrackMetadata{}, metadata)
+
+	suite.config.IDTags = true
+	suite.processor.addIDTags(metadata, track, 23329, 1045)
+	assert.Equal(suite.T(), &models.TrackMetadata{ContainerID: 23329, ArtistID: 1045, TrackID: 456789, SongID: 12}, metadata)
+}
+
+// TestAddSortTags tests that sort names are only filled in with sortTags set,
+// and only for names starting with an article
+func (suite *ProcessorTestSuite) TestAddSortTags() {
+	metadata := &models.TrackMetadata{Artist: "The Disco Biscuits", Album: "The Wall", AlbumArtist: "Phish"}
+	suite.processor.addSortTags(metadata)
+	assert.Empty(suite.T(), metadata.ArtistSort)
+
+	suite.config.SortTags = true
+	suite.processor.addSortTags(metadata)
+	assert.Equal(suite.T(), "Disco Biscuits", metadata.ArtistSort)
+	assert.Equal(suite.T(), "Wall", metadata.AlbumSort)
+	assert.Empty(suite.T(), metadata.AlbumArtistSort)
+
+	suite.config.SortArticles = []string{"Die"}
+	metadata = &models.TrackMetadata{Artist: "Die Toten Hosen", Album: "The Wall"}
+	suite.processor.addSortTags(metadata)
+	assert.Equal(suite.T(), "Toten Hosen", metadata.ArtistSort)
+	assert.Empty(suite.T(), metadata.AlbumSort)
+}
+
+// TestIsSegmentedVideo tests livestream detection from video segment URLs
+func (suite *ProcessorTestSuite) TestIsSegmentedVideo() {
+	assert.False(suite.T(), isSegmentedVideo([]string{"video.ts?q=1"}))
+	assert.False(suite.T(), isSegmentedVideo([]string{"video.ts?q=1", "video.ts?q=1"}))
+	assert.True(suite.T(), isSegmentedVideo([]string{"seg1.ts?q=1", "seg2.ts?q=1"}))
+}
+
+// TestCheckPathWithin tests that adversarial names from the API can't escape OutPath
+func (suite *ProcessorTestSuite) TestCheckPathWithin() {
+	outPath := suite.config.OutPath
+
+	for _, name := range []string{"Phish - 1997-11-17 Denver, CO", "../../etc/passwd", "..\\..\\evil", "-rf", "...", "..hidden"} {
+		path := filepath.Join(outPath, downloader.Sanitise(name), "01. Track.flac")
+		assert.NoError(suite.T(), checkPathWithin(outPath, path), name)
+	}
+
+	err := checkPathWithin(outPath, filepath.Join(outPath, downloader.Sanitise("..")))
+	assert.Error(suite.T(), err)
+	dlErr, ok := err.(*models.DownloadError)
+	assert.True(suite.T(), ok)
+	assert.Equal(suite.T(), models.ErrFileSystem, dlErr.Type)
+
+	// Playlist tracks by an artist named ".." must stay in the playlist folder
+	suite.config.PlaylistByArtist = true
+	_, err = suite.processor.playlistTrackDir(filepath.Join(outPath, "Playlist"), &models.Track{ArtistName: ".."})
+	assert.Error(suite.T(), err)
+}
+
+// TestCdnFilename tests track names taken from stream URLs
+func (suite *ProcessorTestSuite) TestCdnFilename() {
+	assert.Equal(suite.T(), "gd1977-05-08d1t01.flac",
+		cdnFilename("https://cdn.example.com/files/flac16/gd1977-05-08d1t01.flac?token=abc", ".flac"))
+	assert.Equal(suite.T(), "track_01.m4a",
+		cdnFilename("https://cdn.example.com/alac16/track_01?token=abc", ".m4a"))
+	assert.Equal(suite.T(), "", cdnFilename("https://cdn.example.com/", ".flac"))
+	assert.Equal(suite.T(), "", cdnFilename("://bad", ".flac"))
+}
+
+// TestConvertTargets tests converted paths and which sources get converted
+func (suite *ProcessorTestSuite) TestConvertTargets() {
+	assert.Equal(suite.T(), filepath.Join("show", "03. Tweezer.wav"),
+		convertedPath(filepath.Join("show", "03. Tweezer.flac"), "wav"))
+	assert.Equal(suite.T(), 24, bitDepth("24-bit / 48 kHz MQA"))
+	assert.Equal(suite.T(), 16, bitDepth("16-bit / 44.1 kHz FLAC"))
+	assert.Equal(suite.T(), 0, bitDepth("150 Kbps AAC"))
+	assert.Equal(suite.T(), 48000, sampleRate("24-bit / 48 kHz MQA"))
+	assert.Equal(suite.T(), 44100, sampleRate("16-bit / 44.1 kHz FLAC"))
+	assert.Equal(suite.T(), 0, sampleRate("150 Kbps AAC"))
+
+	flac := &models.Quality{Specs: "16-bit / 44.1 kHz FLAC", Format: 2}
+	aac := &models.Quality{Specs: "150 Kbps AAC", Format: 5}
+	assert.False(suite.T(), suite.processor.wantsConversion(flac))
+
+	suite.config.ConvertTo = "wav"
+	assert.True(suite.T(), suite.processor.wantsConversion(flac))
+	assert.False(suite.T(), suite.processor.wantsConversion(aac))
+}
+
+// TestReleasePictures tests which images are picked for each artwork mode
+func (suite *ProcessorTestSuite) TestReleasePictures() {
+	meta := &models.AlbArtResp{
+		Img: models.Picture{URL: "/images/front.jpg"},
+		Pics: []models.Picture{
+			{URL: "/images/back.jpg", Caption: "Back Cover"},
+			{URL: "/images/disc.jpg", Caption: "Disc 1"},
+			{URL: "/images/band.jpg", Caption: "Band photo"},
+		},
+	}
+
+	assert.Empty(suite.T(), releasePictures(meta, ""))
+	assert.Equal(suite.T(), map[string]string{pictureFront: "/images/front.jpg"}, releasePictures(meta, "front"))
+	assert.Equal(suite.T(), map[string]string{
+		pictureFront: "/images/front.jpg",
+		pictureBack:  "/images/back.jpg",
+		pictureDisc:  "/images/disc.jpg",
+	}, releasePictures(meta, "all"))
+
+	assert.Equal(suite.T(), imageHost+"/images/front.jpg", imageURL("/images/front.jpg"))
+	assert.Equal(suite.T(), "https://example.com/a.jpg", imageURL("https://example.com/a.jpg"))
+}
+
+// TestFetchArtwork tests that unavailable images are skipped rather than failing
+func (suite *ProcessorTestSuite) TestFetchArtwork() {
+	suite.config.Artwork = "all"
+	meta := &models.AlbArtResp{
+		Img:  models.Picture{URL: suite.server.URL + "/images/front.jpg"},
+		Pics: []models.Picture{{URL: suite.server.URL + "/images/back.jpg", Caption: "Back"}},
+	}
+
+	artwork, cleanup := suite.processor.fetchArtwork(meta)
+	suite.Require().Len(artwork, 1)
+	assert.Equal(suite.T(), pictureFront, artwork[0].Type)
+	assert.FileExists(suite.T(), artwork[0].Path)
+
+	cleanup()
+	assert.NoFileExists(suite.T(), artwork[0].Path)
+}
+
+// TestDedupPlaylistItems tests repeated tracks are dropped after their first appearance
+func (suite *ProcessorTestSuite) TestDedupPlaylistItems() {
+	items := []models.PlistItem{
+		{Track: models.Track{TrackID: 1, SongTitle: "Tweezer"}},
+		{Track: models.Track{TrackID: 2, SongTitle: "Harry Hood"}},
+		{Track: models.Track{TrackID: 1, SongTitle: "Tweezer"}},
+		{Track: models.Track{TrackID: 3, SongTitle: "Tweezer Reprise"}},
+	}
+
+	deduped := dedupPlaylistItems(items)
+	suite.Require().Len(deduped, 3)
+	assert.Equal(suite.T(), 1, deduped[0].Track.TrackID)
+	assert.Equal(suite.T(), 2, deduped[1].Track.TrackID)
+	assert.Equal(suite.T(), 3, deduped[2].Track.TrackID)
+}
+
+// TestSavePoster tests the poster is saved next to the video when there is one
+func (suite *ProcessorTestSuite) TestSavePoster() {
+	vidPath := filepath.Join(suite.tempDir, "Show_1080p.mp4")
+	suite.config.Poster = "save"
+
+	suite.processor.savePoster(&models.AlbArtResp{}, vidPath)
+	assert.NoFileExists(suite.T(), posterPath(vidPath))
+
+	meta := &models.AlbArtResp{Img: models.Picture{URL: suite.server.URL + "/images/front.jpg"}}
+	suite.processor.savePoster(meta, vidPath)
+	assert.Equal(suite.T(), filepath.Join(suite.tempDir, "Show_1080p-poster.jpg"), posterPath(vidPath))
+	data, err := os.ReadFile(posterPath(vidPath))
+	suite.Require().NoError(err)
+	assert.Equal(suite.T(), testJPEG, data)
+}
+
+// TestWriteChecksums tests the manifest lists tracks relative to the album folder
+func (suite *ProcessorTestSuite) TestWriteChecksums() {
+	first := filepath.Join(suite.tempDir, "01. Tweezer.flac")
+	second := filepath.Join(suite.tempDir, "FLAC", "02. Harry Hood.flac")
+	suite.Require().NoError(os.MkdirAll(filepath.Dir(second), 0755))
+	suite.Require().NoError(os.WriteFile(first, []byte("abc"), 0644))
+	suite.Require().NoError(os.WriteFile(second, []byte(""), 0644))
+
+	var trackPaths []string
+	suite.processor.recordTrack(first)
+	suite.processor.trackPaths = &trackPaths
+	suite.processor.recordTrack(first)
+	suite.processor.recordTrack(second)
+	suite.Require().Len(trackPaths, 2)
+
+	suite.Require().NoError(writeChecksums(filepath.Join(suite.tempDir, checksumsFile), trackPaths))
+	manifest, err := os.ReadFile(filepath.Join(suite.tempDir, checksumsFile))
+	suite.Require().NoError(err)
+	assert.Equal(suite.T(),
+		"900150983cd24fb0d6963f7d28e17f72  01. Tweezer.flac\n"+
+			"d41d8cd98f00b204e9800998ecf8427e  FLAC/02. Harry Hood.flac\n",
+		string(manifest))
+}
+
+// TestUseTrackPrefix tests --no-folder tracks are named after their release
+// until the release finishes
+func (suite *ProcessorTestSuite) TestUseTrackPrefix() {
+	reset := suite.processor.useTrackPrefix("Phish - 12/31/95 MSG")
+	assert.Equal(suite.T(), downloader.Sanitise("Phish - 12/31/95 MSG")+" - ", suite.processor.trackPrefix)
+	assert.NotContains(suite.T(), suite.processor.trackPrefix, "/")
+
+	reset()
+	assert.Empty(suite.T(), suite.processor.trackPrefix)
+}
+
+// TestWriteSetlist tests the setlist is split into sets with timings and notes
+func (suite *ProcessorTestSuite) TestWriteSetlist() {
+	meta := &models.AlbArtResp{
+		ArtistName:    "Phish",
+		ContainerInfo: "12/31/1995 Madison Square Garden ",
+		Notes:         "Gamehendge narration during the second set.",
+	}
+	tracks := []models.Track{
+		{SongTitle: "Tweezer", SetNum: 1, RunningTime: 754},
+		{SongTitle: "Harry Hood", SetNum: 2, RunningTime: 61},
+		{SongTitle: "Tweezer Reprise", SetNum: 2},
+	}
+
+	suite.Require().NoError(writeSetlist(filepath.Join(suite.tempDir, setlistFile), meta, tracks))
+	setlist, err := os.ReadFile(filepath.Join(suite.tempDir, setlistFile))
+	suite.Require().NoError(err)
+	assert.Equal(suite.T(), "Phish - 12/31/1995 Madison Square Garden\n"+
+		"\nSet 1\n01. Tweezer (12:34)\n"+
+		"\nSet 2\n02. Harry Hood (1:01)\n03. Tweezer Reprise\n"+
+		"\nNotes\nGamehendge narration during the second set.\n", string(setlist))
+
+	// A single set isn't labelled
+	assert.Equal(suite.T(), "Phish - 12/31/1995 Madison Square Garden\n\n01. Tweezer (12:34)\n",
+		formatSetlist(meta, tracks[:1], ""))
+
+	empty := suite.T().TempDir()
+	suite.Require().NoError(writeSetlist(filepath.Join(empty, setlistFile), &models.AlbArtResp{}, nil))
+	assert.NoFileExists(suite.T(), filepath.Join(empty, setlistFile))
+}
+
+// writeFlacHeader writes the start of a FLAC file with the given STREAMINFO
+// sample rate and count, which is all trackOffsets reads
+func writeFlacHeader(path string, rate, samples uint64) error {
+	header := append([]byte("fLaC"), 0x80, 0, 0, 34)
+	header = append(header, make([]byte, 10)...)
+	// Stereo 16-bit
+	header = binary.BigEndian.AppendUint64(header, rate<<44|1<<41|15<<36|samples)
+	return os.WriteFile(path, header, 0644)
+}
+
+// TestTrackOffsets tests track offsets are read from FLAC headers
+func (suite *ProcessorTestSuite) TestTrackOffsets() {
+	first := filepath.Join(suite.tempDir, "01. Tweezer.flac")
+	second := filepath.Join(suite.tempDir, "02. Harry Hood.flac")
+	third := filepath.Join(suite.tempDir, "03. Tweezer Reprise.flac")
+	// 12:34 and a half, then 1:01
+	suite.Require().NoError(writeFlacHeader(first, 44100, 754*44100+22050))
+	suite.Require().NoError(writeFlacHeader(second, 44100, 61*44100))
+	suite.Require().NoError(writeFlacHeader(third, 44100, 44100))
+
+	rate, samples, err := flacSamples(first)
+	suite.Require().NoError(err)
+	assert.Equal(suite.T(), 44100, rate)
+	assert.Equal(suite.T(), int64(754*44100+22050), samples)
+
+	offsets, err := trackOffsets([]string{first, second, third})
+	suite.Require().NoError(err)
+	assert.Equal(suite.T(), []int64{0, 754*75 + 37, 815*75 + 37}, offsets)
+
+	suite.Require().NoError(writeFlacHeader(third, 96000, 96000))
+	_, err = trackOffsets([]string{first, third})
+	assert.Error(suite.T(), err)
+
+	notFlac := filepath.Join(suite.tempDir, "04. Bonus.m4a")
+	suite.Require().NoError(os.WriteFile(notFlac, []byte("....ftypM4A "), 0644))
+	_, _, err = flacSamples(notFlac)
+	assert.Error(suite.T(), err)
+}
+
+// TestFormatCueSheet tests the CUE sheet lists each track at its offset
+func (suite *ProcessorTestSuite) TestFormatCueSheet() {
+	meta := &models.AlbArtResp{
+		ArtistName:    "Phish",
+		ContainerInfo: "12/31/1995 Madison Square Garden ",
+	}
+	tracks := []models.Track{
+		{SongTitle: "Tweezer"},
+		{SongTitle: `Harry "Hood"`},
+	}
+	cue := formatCueSheet(meta, tracks, "Phish - 12_31_1995.flac", []int64{0, 754*75 + 37})
+	assert.Equal(suite.T(), `PERFORMER "Phish"
+TITLE "12/31/1995 Madison Square Garden"
+REM DATE 1995-12-31
+FILE "Phish - 12_31_1995.flac" WAVE
+  TRACK 01 AUDIO
+    TITLE "Tweezer"
+    PERFORMER "Phish"
+    INDEX 01 00:00:00
+  TRACK 02 AUDIO
+    TITLE "Harry 'Hood'"
+    PERFORMER "Phish"
+    INDEX 01 12:34:37
+`, cue)
+}
+
+// TestUseArtwork tests artwork downloads in the background and is removed once the release is done
+func (suite *ProcessorTestSuite) TestUseArtwork() {
+	suite.config.Artwork = "front"
+	suite.processor.useArtwork(&models.AlbArtResp{})()
+	assert.Nil(suite.T(), suite.processor.artwork)
+
+	done := suite.processor.useArtwork(&models.AlbArtResp{Img: models.Picture{URL: suite.server.URL + "/images/front.jpg"}})
+	suite.Require().NotNil(suite.processor.artwork)
+	artwork := suite.processor.artwork.wait()
+	suite.Require().Len(artwork, 1)
+	assert.FileExists(suite.T(), artwork[0].Path)
+
+	done()
+	assert.Nil(suite.T(), suite.processor.artwork)
+	assert.NoFileExists(suite.T(), artwork[0].Path)
+}
+
+// TestVideoName tests video filenames are built from the template, with the
+// default naming videos as before
+func (suite *ProcessorTestSuite) TestVideoName() {
+	meta := &models.AlbArtResp{
+		ArtistName:      "Phish",
+		ContainerInfo:   "Madison Square Garden ",
+		PerformanceDate: "12/31/1995",
+	}
+	assert.Equal(suite.T(), "Phish - Madison Square Garden_1080p", suite.processor.videoName(meta, "1080p", 29.97))
+
+	suite.config.VideoTemplate = "{date} {artist} - {title} [{res} {fps}fps]"
+	assert.Equal(suite.T(), "1995-12-31 Phish - Madison Square Garden [4K 59.94fps]", suite.processor.videoName(meta, "4K", 59.94006))
+
+	// Livestreams have no frame rate and audio-only saves no resolution, so
+	// trailing separators are dropped
+	suite.config.VideoTemplate = "{artist} - {title} - {fps}"
+	assert.Equal(suite.T(), "Phish - Madison Square Garden", suite.processor.videoName(meta, "720p", 0))
+	suite.config.VideoTemplate = ""
+	suite.config.AudioOnly = true
+	assert.Equal(suite.T(), "Phish - Madison Square Garden", suite.processor.videoName(meta, "1080p", 29.97))
+
+	// Placeholders are filled in before sanitising
+	suite.config.AudioOnly = false
+	suite.config.VideoTemplate = "{artist}/{title}"
+	assert.Equal(suite.T(), "Phish_Madison Square Garden", suite.processor.videoName(meta, "1080p", 0))
+}
+
+// TestSubtitlePaths tests subtitle files are named after their video and language
+func (suite *ProcessorTestSuite) TestSubtitlePaths() {
+	renditions := []*m3u8.Alternative{
+		{Language: "en", Name: "English"},
+		{Language: "en", Name: "English CC"},
+		{Name: "Commentary"},
+		{},
+	}
+	assert.Equal(suite.T(), []string{
+		filepath.Join("out", "Show_1080p.en.vtt"),
+		filepath.Join("out", "Show_1080p.en2.vtt"),
+		filepath.Join("out", "Show_1080p.Commentary.vtt"),
+		filepath.Join("out", "Show_1080p.4.vtt"),
+	}, subtitlePaths(filepath.Join("out", "Show_1080p.mp4"), renditions))
+}
+
+// TestAlbumFolderName tests --date-prefix starts folder names with the show
+// date, wherever it comes from, and leaves undated releases alone
+func (suite *ProcessorTestSuite) TestAlbumFolderName() {
+	meta := &models.AlbArtResp{ArtistName: "Billy Strings", ContainerInfo: "10/29/22 Asheville, NC "}
+	assert.Equal(suite.T(), "Billy Strings - 10/29/22 Asheville, NC", suite.processor.albumFolderName(meta))
+
+	suite.config.DatePrefix = true
+	assert.Equal(suite.T(), "2022-10-29 Billy Strings - 10/29/22 Asheville, NC", suite.processor.albumFolderName(meta))
+
+	meta.PerformanceDate = "2022-10-28"
+	assert.Equal(suite.T(), "2022-10-28 Billy Strings - 10/29/22 Asheville, NC", suite.processor.albumFolderName(meta))
+
+	undated := &models.AlbArtResp{ArtistName: "Billy Strings", ContainerInfo: "Home"}
+	assert.Equal(suite.T(), "Billy Strings - Home", suite.processor.albumFolderName(undated))
+}
+
+// TestTruncateName tests that names are cut without splitting characters
+func (suite *ProcessorTestSuite) TestTruncateName() {
+	name, chopped := truncateName("Short Name", 20)
+	assert.Equal(suite.T(), "Short Name", name)
+	assert.False(suite.T(), chopped)
+
+	// "é" is two bytes, so a cut at byte 4 would split it
+	name, chopped = truncateName("Beyoncé Live", 7)
+	assert.Equal(suite.T(), "Beyonc", name)
+	assert.True(suite.T(), chopped)
+
+	name, chopped = truncateName("Beyoncé Live", 8)
+	assert.Equal(suite.T(), "Beyoncé", name)
+	assert.True(suite.T(), chopped)
+}
+
+// TestClaimFolder tests disambiguating chopped folder names shared by two releases
+func (suite *ProcessorTestSuite) TestClaimFolder() {
+	folPath := filepath.Join(suite.tempDir, "Test Artist - A Very Long Album Na")
+
+	claimed, err := claimFolder(folPath, "123")
+	suite.Require().NoError(err)
+	assert.Equal(suite.T(), folPath, claimed)
+
+	// Same release again reuses the folder
+	claimed, err = claimFolder(folPath, "123")
+	suite.Require().NoError(err)
+	assert.Equal(suite.T(), folPath, claimed)
+
+	// A different release gets its own folder
+	claimed, err = claimFolder(folPath, "456")
+	suite.Require().NoError(err)
+	assert.Equal(suite.T(), folPath+" [456]", claimed)
+	assert.DirExists(suite.T(), claimed)
+
+	data, err := os.ReadFile(filepath.Join(claimed, folderOwnerFile))
+	suite.Require().NoError(err)
+	assert.Equal(suite.T(), "456", string(data))
+}
+
+// TestFormatSubfolder tests quality-labelled album folders
+func (suite *ProcessorTestSuite) TestFormatSubfolder() {
+	albumPath := filepath.Join(suite.tempDir, "Test Artist - Test Album")
+	qual := &models.Quality{Specs: "16-bit / 44.1 kHz FLAC", Format: 2}
+
+	folPath, err := suite.processor.formatSubfolder(albumPath, qual)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), albumPath, folPath)
+
+	suite.config.FormatSubfolder = true
+	folPath, err = suite.processor.formatSubfolder(albumPath, qual)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), albumPath+" [FLAC16]", folPath)
+	assert.DirExists(suite.T(), folPath)
+
+	// With --no-folder tracks go straight to the output folder, unlabelled
+	folPath, err = suite.processor.formatSubfolder(suite.tempDir, qual)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), suite.tempDir, folPath)
+	assert.NoDirExists(suite.T(), suite.tempDir+" [FLAC16]")
+}
+
+// TestTrackComment tests the default and configured comment tags
+func (suite *ProcessorTestSuite) TestTrackComment() {
+	assert.Contains(suite.T(), suite.processor.trackComment(), "Downloaded from Nugs via Nugs-Downloader on ")
+
+	suite.config.Comment = "My comment"
+	assert.Equal(suite.T(), "My comment", suite.processor.trackComment())
+}
+
+// TestPlaylistTrackMetadata tests tagging of playlist items
+func (suite *ProcessorTestSuite) TestPlaylistTrackMetadata() {
+	// Items without release info are grouped under the playlist
+	track := &models.Track{TrackID: 1, SongTitle: "Test Song", ArtistName: "Test Artist"}
+	metadata := suite.processor.playlistTrackMetadata("plist-1", "Test Playlist", 4, 9, track)
+	assert.Equal(suite.T(), "Test Song", metadata.Title)
+	assert.Equal(suite.T(), "Test Artist", metadata.Artist)
+	assert.Equal(suite.T(), "Test Playlist", metadata.Album)
+	assert.Equal(suite.T(), VariousArtists, metadata.AlbumArtist)
+	assert.Equal(suite.T(), 4, metadata.TrackNum)
+	assert.Equal(suite.T(), 9, metadata.TrackTotal)
+	assert.Equal(suite.T(), "plist-1", metadata.SourceID)
+
+	// Items with release info are tagged with their own release, but keep
+	// their playlist position so players sort them in playlist order
+	track.ContainerID = 123
+	track.ContainerInfo = "Test Album "
+	track.TrackNum = 7
+	metadata = suite.processor.playlistTrackMetadata("plist-1", "Test Playlist", 4, 9, track)
+	assert.Equal(suite.T(), "Test Album", metadata.Album)
+	assert.Equal(suite.T(), "Test Artist", metadata.AlbumArtist)
+	assert.Equal(suite.T(), 4, metadata.TrackNum)
+	assert.Equal(suite.T(), 9, metadata.TrackTotal)
+	assert.Equal(suite.T(), "123", metadata.SourceID)
+}
+
+// TestPlaylistTrackDir tests playlist track placement with and without per-artist folders
+func (suite *ProcessorTestSuite) TestPlaylistTrackDir() {
+	plistPath := filepath.Join(suite.tempDir, "Test Playlist")
+	track := &models.Track{SongTitle: "Test Song", ArtistName: "Test: Artist"}
+
+	// Flat layout by default
+	dir, err := suite.processor.playlistTrackDir(plistPath, track)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), plistPath, dir)
+
+	suite.config.PlaylistByArtist = true
+	dir, err = suite.processor.playlistTrackDir(plistPath, track)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), filepath.Join(plistPath, "Test_ Artist"), dir)
+	assert.DirExists(suite.T(), dir)
+
+	// Unknown artists stay in the playlist root
+	dir, err = suite.processor.playlistTrackDir(plistPath, &models.Track{SongTitle: "Test Song"})
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), plistPath, dir)
+}
+
 // TestResolveCatPlistId tests catalog playlist ID resolution
 func (suite *ProcessorTestSuite) TestResolveCatPlistId() {
 	// Create a test server that redirects to a catalog playlist URL
@@ -293,12 +1023,31 @@ func (suite *ProcessorTestSuite) TestResolveCatPlistId() {
 	defer testServer.Close()
 
 	// Test with a catalog playlist URL
-	plistID, err := resolveCatPlistId(testServer.URL + "/playlist")
+	plistID, err := suite.processor.resolveCatPlistId(testServer.URL + "/playlist")
 
 	assert.NoError(suite.T(), err)
 	assert.Equal(suite.T(), "test-playlist-id", plistID)
 }
 
+// TestResolveCatPlistId_Proxies tests that shortlinks are resolved through
+// the configured proxies like the API requests
+func (suite *ProcessorTestSuite) TestResolveCatPlistId_Proxies() {
+	var proxied []string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied = append(proxied, r.URL.String())
+		w.Header().Set("Location", "https://play.nugs.net/?plGUID=proxied-playlist-id")
+		w.WriteHeader(http.StatusFound)
+	}))
+	defer proxy.Close()
+	suite.Require().NoError(suite.apiClient.SetProxies([]string{proxy.URL}))
+
+	plistID, err := suite.processor.resolveCatPlistId("http://2nu.gs.invalid/abc123")
+
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "proxied-playlist-id", plistID)
+	assert.Equal(suite.T(), []string{"http://2nu.gs.invalid/abc123"}, proxied)
+}
+
 // TestResolveCatPlistId_Invalid tests invalid catalog playlist URL
 func (suite *ProcessorTestSuite) TestResolveCatPlistId_Invalid() {
 	// Test with a non-redirecting URL
@@ -308,12 +1057,43 @@ func (suite *ProcessorTestSuite) TestResolveCatPlistId_Invalid() {
 	}))
 	defer testServer.Close()
 
-	plistID, err := resolveCatPlistId(testServer.URL)
+	plistID, err := suite.processor.resolveCatPlistId(testServer.URL)
 
 	assert.Error(suite.T(), err)
 	assert.Empty(suite.T(), plistID)
 }
 
+// TestResolveCatPlistId_LoginRedirect tests that a shortlink which redirects to
+// a sign-in page is reported as needing login
+func (suite *ProcessorTestSuite) TestResolveCatPlistId_LoginRedirect() {
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Location", "https://id.nugs.net/account/login?ReturnUrl=%2Fplaylist")
+		w.WriteHeader(http.StatusFound)
+	}))
+	defer testServer.Close()
+
+	plistID, err := suite.processor.resolveCatPlistId(testServer.URL + "/playlist")
+
+	assert.Empty(suite.T(), plistID)
+	dlErr, ok := err.(*models.DownloadError)
+	assert.True(suite.T(), ok)
+	assert.Equal(suite.T(), models.ErrAuthentication, dlErr.Type)
+}
+
+// TestResolveCatPlistId_RedirectLoop tests that endless redirects give up
+func (suite *ProcessorTestSuite) TestResolveCatPlistId_RedirectLoop() {
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/loop", http.StatusFound)
+	}))
+	defer testServer.Close()
+
+	plistID, err := suite.processor.resolveCatPlistId(testServer.URL + "/playlist")
+
+	assert.Empty(suite.T(), plistID)
+	assert.Error(suite.T(), err)
+	assert.Contains(suite.T(), err.Error(), "redirected too many times")
+}
+
 // TestProcessCatalogPlist tests catalog playlist processing
 func (suite *ProcessorTestSuite) TestProcessCatalogPlist() {
 	// This test would require more complex mocking of the entire flow
@@ -342,6 +1122,70 @@ func (suite *ProcessorTestSuite) TestProcessPaidLstream() {
 	assert.Error(suite.T(), err)
 }
 
+// TestProcessWebcast tests purchased webcasts request their manifest with
+// the account's uguID
+func (suite *ProcessorTestSuite) TestProcessWebcast() {
+	var manifestQuery url.Values
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api.aspx":
+			response := models.AlbumMeta{
+				Response: &models.AlbArtResp{
+					ArtistName:        "Test Artist",
+					ContainerID:       30486,
+					ContainerInfo:     "Test Webcast",
+					ProductFormatList: []*models.ProductFormatList{{FormatStr: "LIVE HD VIDEO", SkuID: 624602}},
+				},
+			}
+			json.NewEncoder(w).Encode(response)
+		case "/bigriver/vidPlayer.aspx":
+			manifestQuery = r.URL.Query()
+			json.NewEncoder(w).Encode(models.PurchasedManResp{})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer testServer.Close()
+	suite.apiClient.BaseStreamURL = testServer.URL + "/"
+
+	streamParams := &models.StreamParams{
+		SubscriptionID: "sub-123",
+		UserID:         "user-456",
+	}
+
+	// Fails once the empty manifest URL comes back
+	err := suite.processor.ProcessWebcast("30486", "ugu-789", streamParams)
+	assert.Error(suite.T(), err)
+	suite.Require().NotNil(manifestQuery)
+	assert.Equal(suite.T(), "30486", manifestQuery.Get("showId"))
+	assert.Equal(suite.T(), "624602", manifestQuery.Get("skuId"))
+	assert.Equal(suite.T(), "ugu-789", manifestQuery.Get("uguid"))
+
+	// Without a uguID it isn't sent down the subscription path
+	manifestQuery = nil
+	err = suite.processor.ProcessWebcast("30486", "", streamParams)
+	assert.Error(suite.T(), err)
+	assert.Nil(suite.T(), manifestQuery)
+}
+
+// TestStashShowID tests the show id is found in both stash URL query shapes
+func (suite *ProcessorTestSuite) TestStashShowID() {
+	queueVideo := "skuID=624598&showID=30367&perfDate=10-29-2022&artistName=Billy%20Strings&format=liveHdStream"
+	id, err := stashShowID(queueVideo)
+	suite.Require().NoError(err)
+	assert.Equal(suite.T(), "30367", id)
+
+	getStashVideo := "containerId=30367&skuId=624598"
+	id, err = stashShowID(getStashVideo)
+	suite.Require().NoError(err)
+	assert.Equal(suite.T(), "30367", id)
+
+	_, err = stashShowID("skuID=624598")
+	assert.Error(suite.T(), err)
+	_, err = stashShowID("showID=")
+	assert.Error(suite.T(), err)
+}
+
 // TestProcessAlbum_NoTracks tests album processing with no tracks
 func (suite *ProcessorTestSuite) TestProcessAlbum_NoTracks() {
 	// Create album metadata with no tracks
@@ -365,6 +1209,328 @@ func (suite *ProcessorTestSuite) TestProcessAlbum_NoTracks() {
 	assert.Contains(suite.T(), err.Error(), "release has no tracks")
 }
 
+// TestAlbumTrackMetadata_Songs tests tracks listed as songs, as in artist
+// discographies, are tagged the same as tracks from release metadata
+func (suite *ProcessorTestSuite) TestAlbumTrackMetadata_Songs() {
+	songs := []models.Track{
+		{TrackID: 11, SongTitle: "Tweezer", TrackNum: 7},
+		{TrackID: 12, SongTitle: "Harry Hood", TrackNum: 9},
+	}
+	fromArtist := &models.AlbArtResp{ArtistName: "Phish", ContainerInfo: "12/31/1995 Madison Square Garden", ContainerID: 5, Songs: songs}
+	fromRelease := &models.AlbArtResp{ArtistName: "Phish", ContainerInfo: "12/31/1995 Madison Square Garden", ContainerID: 5, Tracks: songs}
+
+	suite.Require().Equal(songs, releaseTracks(fromArtist))
+	suite.Require().Equal(songs, releaseTracks(fromRelease))
+
+	for i := range songs {
+		viaSongs := suite.processor.albumTrackMetadata(fromArtist, &releaseTracks(fromArtist)[i], i+1, len(songs))
+		viaTracks := suite.processor.albumTrackMetadata(fromRelease, &releaseTracks(fromRelease)[i], i+1, len(songs))
+		assert.Equal(suite.T(), viaTracks, viaSongs)
+		assert.Equal(suite.T(), i+1, viaSongs.TrackNum)
+		assert.Equal(suite.T(), 2, viaSongs.TrackTotal)
+		assert.Equal(suite.T(), "Phish", viaSongs.Artist)
+	}
+}
+
+// TestAlbumTrackMetadata_Discs tests disc numbers are only tagged on
+// releases spanning more than one disc
+func (suite *ProcessorTestSuite) TestAlbumTrackMetadata_Discs() {
+	tracks := []models.Track{
+		{TrackID: 11, SongTitle: "Tweezer", DiscNum: 1},
+		{TrackID: 12, SongTitle: "Harry Hood", DiscNum: 2},
+	}
+	albumMeta := &models.AlbArtResp{ArtistName: "Phish", ContainerInfo: "12/31/1995 Madison Square Garden", Tracks: tracks}
+
+	metadata := suite.processor.albumTrackMetadata(albumMeta, &tracks[1], 2, 2)
+	assert.Equal(suite.T(), 2, metadata.DiscNum)
+	assert.Equal(suite.T(), 2, metadata.DiscTotal)
+
+	albumMeta.Tracks = tracks[:1]
+	metadata = suite.processor.albumTrackMetadata(albumMeta, &tracks[0], 1, 1)
+	assert.Zero(suite.T(), metadata.DiscNum)
+	assert.Zero(suite.T(), metadata.DiscTotal)
+}
+
+// TestNextHlsQual tests an HLS-only track moves on to its next stream when a
+// master playlist can't be fetched, skipping repeated stream URLs
+func (suite *ProcessorTestSuite) TestNextHlsQual() {
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/good/master.m3u8" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte("#EXTM3U\n#EXT-X-STREAM-INF:BANDWIDTH=256000\naudio_256k_v1.m3u8\n"))
+	}))
+	defer testServer.Close()
+
+	bad := testServer.URL + "/bad/master.m3u8?token=1"
+	good := testServer.URL + "/good/master.m3u8?token=2"
+	quals := uniqueQuals([]*models.Quality{{URL: bad}, {URL: bad}, {URL: good}, {URL: bad + "3"}})
+	suite.Require().Len(quals, 3)
+
+	qual, rest, err := suite.processor.nextHlsQual(quals)
+	suite.Require().NoError(err)
+	assert.Equal(suite.T(), testServer.URL+"/good/audio_256k_v1.m3u8?token=2", qual.URL)
+	assert.Equal(suite.T(), "256 Kbps AAC", qual.Specs)
+	suite.Require().Len(rest, 1)
+	assert.Equal(suite.T(), bad+"3", rest[0].URL)
+
+	_, _, err = suite.processor.nextHlsQual(rest)
+	assert.Error(suite.T(), err)
+	_, _, err = suite.processor.nextHlsQual(nil)
+	assert.Error(suite.T(), err)
+}
+
+// TestDumpURLs tests --dump-urls prints each track's stream URL without
+// creating any folders or files
+func (suite *ProcessorTestSuite) TestDumpURLs() {
+	var dumped bytes.Buffer
+	dumpOutput = &dumped
+	defer func() { dumpOutput = os.Stderr }()
+
+	suite.config.DumpURLs = true
+	suite.config.Setlist = true
+	suite.streamLink = "https://stream.example.com/track.flac16/01?token=x"
+
+	suite.Require().NoError(suite.processor.ProcessAlbum("123", &models.StreamParams{}, nil))
+	assert.Equal(suite.T(),
+		"Track 1: Test Song (16-bit / 44.1 kHz FLAC)\n"+suite.streamLink+"\n",
+		dumped.String())
+
+	entries, err := os.ReadDir(suite.tempDir)
+	suite.Require().NoError(err)
+	assert.Empty(suite.T(), entries)
+}
+
+// TestSimulateQuality tests --simulate-quality reports the probed formats and
+// the fallback taken without creating any folders or files
+func (suite *ProcessorTestSuite) TestSimulateQuality() {
+	var report bytes.Buffer
+	simulateOutput = &report
+	defer func() { simulateOutput = os.Stdout }()
+
+	suite.config.SimulateQuality = true
+	suite.config.Format = 3
+	suite.streamLink = "https://stream.example.com/track.flac16/01?token=x"
+
+	suite.Require().NoError(suite.processor.ProcessAlbum("123", &models.StreamParams{}, nil))
+	flac := "format 2 (16-bit / 44.1 kHz FLAC)"
+	assert.Equal(suite.T(),
+		"Track 1: Test Song\n"+
+			"  Probed: "+strings.Repeat(flac+", ", 3)+flac+"\n"+
+			"  Selection: format 3 → format 2 → chosen "+flac+"\n",
+		report.String())
+
+	entries, err := os.ReadDir(suite.tempDir)
+	suite.Require().NoError(err)
+	assert.Empty(suite.T(), entries)
+}
+
+// TestFallbackSteps tests the formats walked from the wanted format to the
+// chosen one
+func (suite *ProcessorTestSuite) TestFallbackSteps() {
+	assert.Empty(suite.T(), fallbackSteps(2, 2))
+	assert.Equal(suite.T(), []int{3, 2, 5}, fallbackSteps(4, 5))
+	assert.Equal(suite.T(), []int{5}, fallbackSteps(2, 6))
+}
+
+// TestChooseTrackQuality_Unsupported tests stream URLs of unknown formats are
+// reported as such, apart from the API returning nothing
+func (suite *ProcessorTestSuite) TestChooseTrackQuality_Unsupported() {
+	track := &models.Track{TrackID: 1, SongTitle: "Test Song"}
+	suite.streamLink = "https://stream.example.com/track.opus96/01.ogg?token=x"
+
+	_, _, _, err := suite.processor.chooseTrackQuality(track, &models.StreamParams{})
+	assert.ErrorIs(suite.T(), err, models.ErrUnsupportedFormat)
+	assert.Contains(suite.T(), err.Error(), "https://stream.example.com/track.opus96/01.ogg")
+	assert.NotContains(suite.T(), err.Error(), "token=x")
+	// All four probes returned the same URL, so it's listed once
+	assert.Equal(suite.T(), 1, strings.Count(err.Error(), "track.opus96"))
+
+	err = unsupportedFormatError(nil)
+	assert.Error(suite.T(), err)
+	assert.NotErrorIs(suite.T(), err, models.ErrUnsupportedFormat)
+}
+
+// TestDumpMeta tests --json-meta prints the parsed metadata as JSON
+func (suite *ProcessorTestSuite) TestDumpMeta() {
+	var dumped bytes.Buffer
+	SetJSONMetaOutput(&dumped)
+	defer SetJSONMetaOutput(os.Stdout)
+
+	suite.Require().NoError(suite.processor.DumpMeta("123", 0, ""))
+	var meta models.AlbumMeta
+	suite.Require().NoError(json.Unmarshal(dumped.Bytes(), &meta))
+	assert.Equal(suite.T(), "Test Album", meta.Response.ContainerInfo)
+	assert.Contains(suite.T(), dumped.String(), "\n  \"response\": {")
+
+	// Track URLs dump their release
+	dumped.Reset()
+	suite.Require().NoError(suite.processor.DumpMeta("123/track/1", 11, ""))
+	suite.Require().NoError(json.Unmarshal(dumped.Bytes(), &meta))
+	assert.Equal(suite.T(), "Test Song", meta.Response.Tracks[0].SongTitle)
+
+	dumped.Reset()
+	assert.Error(suite.T(), suite.processor.DumpMeta("123", 99, ""))
+	assert.Empty(suite.T(), dumped.String())
+}
+
+// TestVerifyAlbum tests a local folder is checked against the album's tracks
+func (suite *ProcessorTestSuite) TestVerifyAlbum() {
+	folder := filepath.Join(suite.tempDir, "Test Artist - Test Album")
+	suite.Require().NoError(os.MkdirAll(folder, 0755))
+
+	err := suite.processor.VerifyAlbum("123", folder)
+	assert.Error(suite.T(), err)
+
+	// Cut-short downloads count as missing
+	trackPath := filepath.Join(folder, "01. Test Song.flac")
+	suite.Require().NoError(os.WriteFile(trackPath, nil, 0644))
+	err = suite.processor.VerifyAlbum("123", folder)
+	assert.Error(suite.T(), err)
+
+	suite.Require().NoError(os.WriteFile(trackPath, []byte("fLaC"), 0644))
+	suite.Require().NoError(os.WriteFile(filepath.Join(folder, "cover.jpg"), testJPEG, 0644))
+	err = suite.processor.VerifyAlbum("123", folder)
+	assert.NoError(suite.T(), err)
+
+	// Extra files are reported but don't fail the album
+	suite.Require().NoError(os.WriteFile(filepath.Join(folder, "02. Encore.flac"), []byte("fLaC"), 0644))
+	err = suite.processor.VerifyAlbum("123", folder)
+	assert.NoError(suite.T(), err)
+
+	err = suite.processor.VerifyAlbum("123", filepath.Join(suite.tempDir, "missing"))
+	assert.Error(suite.T(), err)
+}
+
+// TestDiffAlbumFiles tests matching tracks to files by their numbered titles
+func (suite *ProcessorTestSuite) TestDiffAlbumFiles() {
+	tracks := []models.Track{{SongTitle: "Tweezer"}, {SongTitle: "What's the Use?"}, {SongTitle: "Ghost"}}
+	files := []string{
+		"01. tweezer.flac",
+		"Show - 02. What's the Use_.m4a",
+		"04. Bonus.flac",
+	}
+
+	missing, extra := diffAlbumFiles(tracks, "Show - ", files)
+	assert.Equal(suite.T(), []string{"Track 3 (Ghost)"}, missing)
+	assert.Equal(suite.T(), []string{"04. Bonus.flac"}, extra)
+
+	// Tracks in disc subfolders match by their filename
+	missing, _ = diffAlbumFiles(tracks, "Show - ", append(files, filepath.Join("Disc 2", "03. Ghost.flac")))
+	assert.Empty(suite.T(), missing)
+}
+
+// TestDiscSubfolder tests that only multi-disc releases get disc subfolders
+func (suite *ProcessorTestSuite) TestDiscSubfolder() {
+	albumPath := filepath.Join(suite.tempDir, "Box Set")
+	tracks := []models.Track{{DiscNum: 1}, {DiscNum: 1}, {DiscNum: 2}}
+
+	// Off by default
+	done := suite.processor.useDiscFolders(tracks)
+	path, err := suite.processor.discSubfolder(albumPath, &tracks[2])
+	suite.Require().NoError(err)
+	assert.Equal(suite.T(), albumPath, path)
+	done()
+
+	suite.config.DiscFolders = true
+	done = suite.processor.useDiscFolders(tracks)
+	path, err = suite.processor.discSubfolder(albumPath, &tracks[2])
+	suite.Require().NoError(err)
+	assert.Equal(suite.T(), filepath.Join(albumPath, "Disc 2"), path)
+	assert.DirExists(suite.T(), path)
+	// Tracks without a disc number stay in the album folder
+	path, err = suite.processor.discSubfolder(albumPath, &models.Track{})
+	suite.Require().NoError(err)
+	assert.Equal(suite.T(), albumPath, path)
+	done()
+	assert.False(suite.T(), suite.processor.discFolders)
+
+	// Single-disc releases stay flat
+	defer suite.processor.useDiscFolders(tracks[:2])()
+	path, err = suite.processor.discSubfolder(albumPath, &tracks[0])
+	suite.Require().NoError(err)
+	assert.Equal(suite.T(), albumPath, path)
+}
+
+// TestWaitForLstream tests --wait-for-available polls until the livestream
+// becomes available
+func (suite *ProcessorTestSuite) TestWaitForLstream() {
+	polls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		polls++
+		availability := "PREORDER"
+		if polls == 3 {
+			availability = "AVAILABLE"
+		}
+		json.NewEncoder(w).Encode(models.AlbumMeta{Response: &models.AlbArtResp{
+			ContainerID:         456,
+			AvailabilityTypeStr: availability,
+		}})
+	}))
+	defer server.Close()
+	suite.apiClient.BaseStreamURL = server.URL + "/"
+
+	interval := lstreamPollInterval
+	lstreamPollInterval = time.Millisecond
+	defer func() { lstreamPollInterval = interval }()
+
+	meta, err := suite.processor.waitForLstream("456")
+	suite.Require().NoError(err)
+	assert.Equal(suite.T(), 3, polls)
+	assert.Equal(suite.T(), 456, meta.ContainerID)
+}
+
+// TestWaitForLstream_Errors tests failed checks are retried, and the wait
+// gives up after lstreamMaxWait
+func (suite *ProcessorTestSuite) TestWaitForLstream_Errors() {
+	polls := 0
+	available := true
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		polls++
+		if polls == 1 {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		availability := "PREORDER"
+		if available {
+			availability = "AVAILABLE"
+		}
+		json.NewEncoder(w).Encode(models.AlbumMeta{Response: &models.AlbArtResp{
+			ContainerID:         456,
+			AvailabilityTypeStr: availability,
+		}})
+	}))
+	defer server.Close()
+	suite.apiClient.BaseStreamURL = server.URL + "/"
+
+	interval, maxWait := lstreamPollInterval, lstreamMaxWait
+	lstreamPollInterval = time.Millisecond
+	defer func() { lstreamPollInterval, lstreamMaxWait = interval, maxWait }()
+
+	meta, err := suite.processor.waitForLstream("456")
+	suite.Require().NoError(err)
+	assert.Equal(suite.T(), 2, polls)
+	assert.Equal(suite.T(), 456, meta.ContainerID)
+
+	available = false
+	lstreamMaxWait = 20 * time.Millisecond
+	_, err = suite.processor.waitForLstream("456")
+	assert.ErrorIs(suite.T(), err, models.ErrNotAvailable)
+	assert.Contains(suite.T(), err.Error(), "PREORDER")
+}
+
+// TestNoFallback tests tracks missing the requested format are skipped, not
+// downloaded in another format
+func (suite *ProcessorTestSuite) TestNoFallback() {
+	suite.streamLink = "https://stream.example.com/track.aac150/01.m4a?token=x"
+	suite.config.NoFallback = true
+	track := &models.Track{TrackID: 1, SongTitle: "Tweezer"}
+
+	err := suite.processor.processTrackWithTags(suite.tempDir, 1, 1, track, &models.StreamParams{}, nil)
+	assert.ErrorIs(suite.T(), err, models.ErrFormatUnavailable)
+}
+
 // TestProcessAlbum_VideoOnly tests video-only album processing
 func (suite *ProcessorTestSuite) TestProcessAlbum_VideoOnly() {
 	// Create album metadata with video but no tracks
@@ -387,6 +1553,63 @@ func (suite *ProcessorTestSuite) TestProcessAlbum_VideoOnly() {
 	assert.Error(suite.T(), err) // Will fail due to incomplete mocking
 }
 
+// TestProcessAlbum_NotAvailable tests that releases the API doesn't mark as
+// available are skipped before anything is downloaded
+func (suite *ProcessorTestSuite) TestProcessAlbum_NotAvailable() {
+	albumMeta := &models.AlbArtResp{
+		ArtistName:          "Test Artist",
+		ContainerID:         123,
+		ContainerInfo:       "Test Album",
+		AvailabilityTypeStr: "COMING SOON",
+		Tracks:              []models.Track{{TrackID: 1, SongTitle: "Test Song"}},
+	}
+
+	err := suite.processor.ProcessAlbum("", &models.StreamParams{}, albumMeta)
+	assert.ErrorIs(suite.T(), err, models.ErrNotAvailable)
+	assert.NoDirExists(suite.T(), filepath.Join(suite.tempDir, "Test Artist - Test Album"))
+
+	assert.NoError(suite.T(), checkAvailable(&models.AlbArtResp{AvailabilityTypeStr: "AVAILABLE"}))
+	assert.NoError(suite.T(), checkAvailable(&models.AlbArtResp{}))
+	assert.ErrorIs(suite.T(), checkAvailable(&models.AlbArtResp{AvailabilityTypeStr: "UNAVAILABLE"}), models.ErrNotAvailable)
+}
+
+// TestProcessAlbum_VideoReusesMeta tests that a video-only release doesn't
+// fetch its album metadata a second time in ProcessVideo
+func (suite *ProcessorTestSuite) TestProcessAlbum_VideoReusesMeta() {
+	metaCalls := 0
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api.aspx" || r.URL.Query().Get("method") != "catalog.container" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		metaCalls++
+		response := models.AlbumMeta{
+			Response: &models.AlbArtResp{
+				ArtistName:    "Test Artist",
+				ContainerID:   123,
+				ContainerInfo: "Test Video Album",
+				Products: []models.Product{
+					{FormatStr: "VIDEO ON DEMAND", SkuID: 456},
+				},
+			},
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+	}))
+	defer testServer.Close()
+	suite.apiClient.BaseStreamURL = testServer.URL + "/"
+
+	streamParams := &models.StreamParams{
+		SubscriptionID: "sub-123",
+		UserID:         "user-456",
+	}
+
+	// Fails at the stream manifest, after the video branch has been taken
+	err := suite.processor.ProcessAlbum("123", streamParams, nil)
+	assert.Error(suite.T(), err)
+	assert.Equal(suite.T(), 1, metaCalls)
+}
+
 // TestProcessPlaylist tests playlist processing
 func (suite *ProcessorTestSuite) TestProcessPlaylist() {
 	streamParams := &models.StreamParams{
@@ -411,6 +1634,98 @@ func (suite *ProcessorTestSuite) TestProcessArtist() {
 	assert.Error(suite.T(), err)
 }
 
+// TestProcessArtist_Limit tests that --limit caps how many containers are fetched
+func (suite *ProcessorTestSuite) TestProcessArtist_Limit() {
+	albumCalls := 0
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Query().Get("method") {
+		case "catalog.containersAll":
+			// Two pages of containers, then an empty page
+			var containers []*models.AlbArtResp
+			switch r.URL.Query().Get("startOffset") {
+			case "1":
+				containers = []*models.AlbArtResp{
+					{ArtistName: "Test Artist", ContainerID: 1},
+					{ArtistName: "Test Artist", ContainerID: 2},
+				}
+			case "3":
+				containers = []*models.AlbArtResp{
+					{ArtistName: "Test Artist", ContainerID: 3},
+				}
+			}
+			response := models.ArtistMeta{Response: &models.ArtistResp{Containers: containers}}
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(response)
+		case "catalog.container":
+			albumCalls++
+			w.WriteHeader(http.StatusNotFound)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer testServer.Close()
+	suite.apiClient.BaseStreamURL = testServer.URL + "/"
+	suite.config.Limit = 2
+
+	streamParams := &models.StreamParams{
+		SubscriptionID: "sub-123",
+		UserID:         "user-456",
+	}
+
+	err := suite.processor.ProcessArtist("test-artist-id", streamParams)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 2, albumCalls)
+	// The failed containers leave the artist incomplete
+	assert.True(suite.T(), suite.processor.Incomplete())
+}
+
+// TestProcessArtist_NoContainers tests that an artist without any available
+// releases is reported instead of crashing
+func (suite *ProcessorTestSuite) TestProcessArtist_NoContainers() {
+	containerCalls := 0
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Query().Get("method") {
+		case "catalog.containersAll":
+			response := models.ArtistMeta{Response: &models.ArtistResp{Containers: []*models.AlbArtResp{}}}
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(response)
+		case "catalog.container":
+			containerCalls++
+			w.WriteHeader(http.StatusNotFound)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer testServer.Close()
+	suite.apiClient.BaseStreamURL = testServer.URL + "/"
+
+	streamParams := &models.StreamParams{
+		SubscriptionID: "sub-123",
+		UserID:         "user-456",
+	}
+
+	err := suite.processor.ProcessArtist("test-artist-id", streamParams)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 0, containerCalls)
+}
+
+// TestArtistContainers tests flattening artist pages with empty or missing responses
+func (suite *ProcessorTestSuite) TestArtistContainers() {
+	meta := []*models.ArtistMeta{
+		{Response: &models.ArtistResp{Containers: []*models.AlbArtResp{}}},
+		{Response: nil},
+	}
+	assert.Empty(suite.T(), artistContainers(meta))
+	assert.Equal(suite.T(), 0, getAlbumTotal(meta))
+
+	meta = append(meta, &models.ArtistMeta{Response: &models.ArtistResp{
+		Containers: []*models.AlbArtResp{{ArtistName: "Test Artist", ContainerID: 1}},
+	}})
+	containers := artistContainers(meta)
+	assert.Len(suite.T(), containers, 1)
+	assert.Equal(suite.T(), "Test Artist", containers[0].ArtistName)
+}
+
 // TestProcessTrack tests individual track processing
 func (suite *ProcessorTestSuite) TestProcessTrack() {
 	track := &models.Track{
@@ -433,6 +1748,135 @@ func (suite *ProcessorTestSuite) TestProcessTrack() {
 	assert.Error(suite.T(), err)
 }
 
+// TestProcessSingleTrack tests single track lookups within a release
+func (suite *ProcessorTestSuite) TestProcessSingleTrack() {
+	streamParams := &models.StreamParams{
+		SubscriptionID: "sub-123",
+		UserID:         "user-456",
+	}
+
+	err := suite.processor.ProcessSingleTrack("123/track/999", streamParams)
+	suite.Require().Error(err)
+	assert.Contains(suite.T(), err.Error(), "isn't on release")
+
+	err = suite.processor.ProcessSingleTrack("123/track/abc", streamParams)
+	assert.Error(suite.T(), err)
+
+	// The track is found, then fails at the mocked stream URL
+	err = suite.processor.ProcessSingleTrack("123/track/1", streamParams)
+	suite.Require().Error(err)
+	assert.NotContains(suite.T(), err.Error(), "isn't on release")
+}
+
+// TestProcessSingleTrack_FormatSubfolder tests single tracks stay in the
+// output folder with --format-subfolder, rather than a labelled copy of it
+func (suite *ProcessorTestSuite) TestProcessSingleTrack_FormatSubfolder() {
+	suite.streamLink = "https://stream.example.com/track.flac16/01?token=x"
+	suite.config.FormatSubfolder = true
+	trackPath := filepath.Join(suite.tempDir, "01. Test Song.flac")
+	suite.Require().NoError(os.WriteFile(trackPath, []byte("flac"), 0644))
+
+	suite.Require().NoError(suite.processor.ProcessSingleTrack("123/track/1", &models.StreamParams{}))
+	assert.FileExists(suite.T(), trackPath)
+	assert.NoDirExists(suite.T(), suite.tempDir+" [FLAC16]")
+}
+
+// TestProcessSingleTrack_Remote tests a finished single track is moved to
+// the remote destination
+func (suite *ProcessorTestSuite) TestProcessSingleTrack_Remote() {
+	suite.streamLink = "https://stream.example.com/track.flac16/01?token=x"
+	trackPath := filepath.Join(suite.tempDir, "01. Test Song.flac")
+	suite.Require().NoError(os.WriteFile(trackPath, []byte("flac"), 0644))
+	dest := &fakeDestination{put: map[string]string{}}
+	suite.processor.SetRemote(dest)
+
+	suite.Require().NoError(suite.processor.ProcessSingleTrack("123/track/1", &models.StreamParams{}))
+	assert.Equal(suite.T(), map[string]string{"01. Test Song.flac": "flac"}, dest.put)
+	assert.NoFileExists(suite.T(), trackPath)
+}
+
+// TestProcessPlaylist_Remote tests a playlist is only moved to the remote
+// destination once all its tracks are done
+func (suite *ProcessorTestSuite) TestProcessPlaylist_Remote() {
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api.aspx":
+			json.NewEncoder(w).Encode(models.PlistMeta{Response: &models.PlistResp{
+				PlayListName: "Test Playlist",
+				Items: []models.PlistItem{
+					{Track: models.Track{TrackID: 1, SongTitle: "Test Song"}},
+					{Track: models.Track{TrackID: 2, SongTitle: "Other Song"}},
+				},
+			}})
+		case "/bigriver/subPlayer.aspx":
+			json.NewEncoder(w).Encode(models.StreamMeta{StreamLink: server.URL + "/track.flac16/01?token=x"})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	suite.apiClient.BaseStreamURL = server.URL + "/"
+	dest := &fakeDestination{put: map[string]string{}}
+	suite.processor.SetRemote(dest)
+
+	plistPath := filepath.Join(suite.tempDir, "Test Playlist")
+	suite.Require().NoError(os.MkdirAll(plistPath, 0755))
+	first := filepath.Join(plistPath, "01. Test Song.flac")
+	suite.Require().NoError(os.WriteFile(first, []byte("one"), 0644))
+
+	// The second track fails to download, so nothing is moved
+	suite.processor.ProcessPlaylist("plist-1", "", &models.StreamParams{}, true)
+	assert.Empty(suite.T(), dest.put)
+	assert.FileExists(suite.T(), first)
+	assert.True(suite.T(), suite.processor.Incomplete())
+	suite.processor.ResetIncomplete()
+
+	suite.Require().NoError(os.WriteFile(filepath.Join(plistPath, "02. Other Song.flac"), []byte("two"), 0644))
+	suite.Require().NoError(suite.processor.ProcessPlaylist("plist-1", "", &models.StreamParams{}, true))
+	assert.Equal(suite.T(), map[string]string{
+		"Test Playlist/01. Test Song.flac":  "one",
+		"Test Playlist/02. Other Song.flac": "two",
+	}, dest.put)
+	assert.NoDirExists(suite.T(), plistPath)
+	assert.False(suite.T(), suite.processor.Incomplete())
+}
+
+// TestSyncArtist_ArtistFilter tests --artist-filter also applies to the
+// shows an artist sync downloads
+func (suite *ProcessorTestSuite) TestSyncArtist_ArtistFilter() {
+	var requested []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		switch query.Get("method") {
+		case "catalog.containersAll":
+			if query.Get("startOffset") != "1" {
+				json.NewEncoder(w).Encode(models.ArtistMeta{})
+				return
+			}
+			json.NewEncoder(w).Encode(models.ArtistMeta{Response: &models.ArtistResp{
+				Containers: []*models.AlbArtResp{
+					{ContainerID: 1, ArtistID: 1105, ArtistName: "Phish", PerformanceDate: "2023-07-01"},
+					{ContainerID: 2, ArtistID: 62, ArtistName: "Trey Anastasio", PerformanceDate: "2023-07-02"},
+				},
+			}})
+		case "catalog.container":
+			// Fail the show quickly, only which ones are requested matters
+			requested = append(requested, query.Get("containerID"))
+			w.WriteHeader(http.StatusNotFound)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	suite.apiClient.BaseStreamURL = server.URL + "/"
+	suite.processor.syncStore = NewSyncStore(filepath.Join(suite.tempDir, "sync.json"))
+	suite.config.ArtistFilter = "1105"
+
+	suite.Require().NoError(suite.processor.SyncArtist("1105", &models.StreamParams{}))
+	assert.Equal(suite.T(), []string{"1"}, requested)
+}
+
 // TestProcessVideo tests video processing
 func (suite *ProcessorTestSuite) TestProcessVideo() {
 	streamParams := &models.StreamParams{
@@ -453,6 +1897,39 @@ func (suite *ProcessorTestSuite) TestConstants() {
 	assert.Equal(suite.T(), [4]int{1, 4, 7, 10}, streamMetaIndices)
 }
 
+// TestNameLimits tests configured name limits override the platform defaults
+func (suite *ProcessorTestSuite) TestNameLimits() {
+	if runtime.GOOS == "windows" {
+		assert.Equal(suite.T(), WindowsMaxFolderNameLen, suite.processor.folderNameLimit())
+	} else {
+		assert.Equal(suite.T(), MaxFolderNameLen, suite.processor.folderNameLimit())
+		assert.Equal(suite.T(), MaxVideoFilenameLen, suite.processor.videoFilenameLimit())
+	}
+
+	suite.config.MaxFolderNameLen = 40
+	suite.config.MaxVideoFilenameLen = 50
+	assert.Equal(suite.T(), 40, suite.processor.folderNameLimit())
+	assert.Equal(suite.T(), 50, suite.processor.videoFilenameLimit())
+}
+
+// TestFormatSummary tests the end of run summary line
+func (suite *ProcessorTestSuite) TestFormatSummary() {
+	assert.Equal(suite.T(), "Finished 2 items in 1m40s: 12 tracks, 1 video, 50 MB at 500 kB/s",
+		formatSummary(2, 12, 1, 50_000_000, 100*time.Second))
+	assert.Equal(suite.T(), "Finished 1 item in 0s: 0 tracks, 0 videos, 0 B at 0 B/s",
+		formatSummary(1, 0, 0, 0, 0))
+}
+
+// TestFork_SharesStats tests that forked processors add to the same run totals
+func (suite *ProcessorTestSuite) TestFork_SharesStats() {
+	fork := suite.processor.Fork()
+	fork.stats.tracks.Add(2)
+	suite.processor.stats.videos.Add(1)
+
+	assert.Equal(suite.T(), "Finished 1 item in 1s: 2 tracks, 1 video, 0 B at 0 B/s",
+		fork.Summary(1, time.Second))
+}
+
 // TestProcessTrackWithMetadata tests track processing with metadata
 func (suite *ProcessorTestSuite) TestProcessTrackWithMetadata() {
 	// Create test album metadata
@@ -0,0 +1,99 @@
+package processor
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"main/pkg/logger"
+	"main/pkg/remote"
+)
+
+// SetRemote sets where finished downloads are moved to. nil keeps
+// them in the output folder.
+func (p *Processor) SetRemote(dest remote.Destination) {
+	p.remote = dest
+}
+
+// outputRoots returns the entries directly in the output folder that hold
+// the given paths, so a release's folder moves as a whole wherever in it its
+// files are
+func outputRoots(outPath string, paths []string) []string {
+	var roots []string
+	seen := map[string]bool{}
+	for _, path := range paths {
+		rel, err := filepath.Rel(outPath, path)
+		if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+			continue
+		}
+		root := filepath.Join(outPath, strings.Split(filepath.ToSlash(rel), "/")[0])
+		if !seen[root] {
+			seen[root] = true
+			roots = append(roots, root)
+		}
+	}
+	return roots
+}
+
+// videoFiles returns a finished video and the poster and subtitles saved
+// next to it
+func videoFiles(vidPath string) []string {
+	files := []string{vidPath}
+	dir := filepath.Dir(vidPath)
+	base := strings.TrimSuffix(filepath.Base(vidPath), filepath.Ext(vidPath))
+	poster := filepath.Base(posterPath(vidPath))
+
+	entries, _ := os.ReadDir(dir)
+	for _, entry := range entries {
+		name := entry.Name()
+		if name == poster || strings.HasPrefix(name, base+".") && strings.HasSuffix(name, ".vtt") {
+			files = append(files, filepath.Join(dir, name))
+		}
+	}
+	return files
+}
+
+// moveToRemote copies files, and folders with everything in them, to the
+// remote destination under the same paths as in the output folder. They're
+// only deleted locally once all of them have been copied, so nothing is lost
+// when a transfer fails, and running the item again retries the move.
+func (p *Processor) moveToRemote(paths ...string) error {
+	if p.remote == nil || len(paths) == 0 {
+		return nil
+	}
+
+	var files []string
+	for _, path := range paths {
+		err := filepath.WalkDir(path, func(file string, entry fs.DirEntry, err error) error {
+			if err == nil && !entry.IsDir() {
+				files = append(files, file)
+			}
+			return err
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	fmt.Println("Moving to the remote destination...")
+	for _, file := range files {
+		rel, err := filepath.Rel(p.config.OutPath, file)
+		if err == nil {
+			err = p.remote.Put(file, filepath.ToSlash(rel))
+		}
+		if err != nil {
+			logger.GetLogger().WithError(err).WithField("path", file).Error("Failed to move to the remote destination")
+			fmt.Println("Failed to move to the remote destination, kept the local files.")
+			return fmt.Errorf("failed to move %s to the remote destination: %w", file, err)
+		}
+	}
+
+	for _, path := range paths {
+		if err := os.RemoveAll(path); err != nil {
+			logger.GetLogger().WithError(err).WithField("path", path).Warn("Failed to delete files moved to the remote destination")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,118 @@
+package processor
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"main/pkg/config"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type RemoteTestSuite struct {
+	suite.Suite
+	tempDir   string
+	processor *Processor
+	dest      *fakeDestination
+}
+
+// fakeDestination records the files put to it, failing on failRel
+type fakeDestination struct {
+	put     map[string]string
+	failRel string
+}
+
+func (d *fakeDestination) Put(localPath, rel string) error {
+	if rel == d.failRel {
+		return errors.New("connection lost")
+	}
+	data, err := os.ReadFile(localPath)
+	if err != nil {
+		return err
+	}
+	d.put[rel] = string(data)
+	return nil
+}
+
+func (d *fakeDestination) Close() error {
+	return nil
+}
+
+func (suite *RemoteTestSuite) SetupTest() {
+	tempDir, err := os.MkdirTemp("", "remote_test_*")
+	suite.Require().NoError(err)
+	suite.tempDir = tempDir
+
+	suite.dest = &fakeDestination{put: map[string]string{}}
+	suite.processor = &Processor{config: &config.Config{OutPath: tempDir}}
+	suite.processor.SetRemote(suite.dest)
+}
+
+func (suite *RemoteTestSuite) TearDownTest() {
+	os.RemoveAll(suite.tempDir)
+}
+
+func (suite *RemoteTestSuite) writeFile(rel, content string) string {
+	path := filepath.Join(suite.tempDir, rel)
+	suite.Require().NoError(os.MkdirAll(filepath.Dir(path), 0755))
+	suite.Require().NoError(os.WriteFile(path, []byte(content), 0644))
+	return path
+}
+
+func (suite *RemoteTestSuite) TestOutputRoots() {
+	out := suite.tempDir
+	roots := outputRoots(out, []string{
+		filepath.Join(out, "Album", "CD 1", "01.flac"),
+		filepath.Join(out, "Album", "CD 2", "01.flac"),
+		filepath.Join(out, "Album"),
+		filepath.Join(out, "Single.flac"),
+		out,
+		"/elsewhere/02.flac",
+	})
+	suite.Equal([]string{filepath.Join(out, "Album"), filepath.Join(out, "Single.flac")}, roots)
+}
+
+func (suite *RemoteTestSuite) TestVideoFiles() {
+	vidPath := suite.writeFile("Show.mp4", "video")
+	poster := suite.writeFile(filepath.Base(posterPath(vidPath)), "poster")
+	subs := suite.writeFile("Show.en.vtt", "subs")
+	suite.writeFile("Other Show.mp4", "video")
+	suite.writeFile("Other Show.en.vtt", "subs")
+
+	suite.ElementsMatch([]string{vidPath, poster, subs}, videoFiles(vidPath))
+}
+
+func (suite *RemoteTestSuite) TestMoveToRemote() {
+	albumPath := filepath.Join(suite.tempDir, "Album")
+	suite.writeFile("Album/01.flac", "one")
+	suite.writeFile("Album/CD 2/01.flac", "two")
+
+	suite.Require().NoError(suite.processor.moveToRemote(albumPath))
+	suite.Equal(map[string]string{"Album/01.flac": "one", "Album/CD 2/01.flac": "two"}, suite.dest.put)
+	suite.NoDirExists(albumPath)
+}
+
+func (suite *RemoteTestSuite) TestMoveToRemote_KeepsFilesOnFailure() {
+	albumPath := filepath.Join(suite.tempDir, "Album")
+	suite.writeFile("Album/01.flac", "one")
+	suite.writeFile("Album/02.flac", "two")
+	suite.dest.failRel = "Album/02.flac"
+
+	suite.Error(suite.processor.moveToRemote(albumPath))
+	suite.FileExists(filepath.Join(albumPath, "01.flac"))
+	suite.FileExists(filepath.Join(albumPath, "02.flac"))
+}
+
+func (suite *RemoteTestSuite) TestMoveToRemote_NoRemote() {
+	suite.processor.SetRemote(nil)
+	path := suite.writeFile("Album/01.flac", "one")
+
+	suite.NoError(suite.processor.moveToRemote(filepath.Dir(path)))
+	suite.FileExists(path)
+}
+
+func TestRemoteTestSuite(t *testing.T) {
+	suite.Run(t, new(RemoteTestSuite))
+}
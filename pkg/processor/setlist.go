@@ -0,0 +1,57 @@
+package processor
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"main/pkg/models"
+)
+
+// setlistFile is the track listing written to album folders with --setlist
+const setlistFile = "setlist.txt"
+
+// writeSetlist writes the release's track listing to path, split into sets
+// when it has more than one, with timings and any show notes. Releases
+// without tracks or notes are skipped.
+func writeSetlist(path string, meta *models.AlbArtResp, tracks []models.Track) error {
+	notes := strings.TrimSpace(meta.Notes)
+	if len(tracks) == 0 && notes == "" {
+		return nil
+	}
+	return os.WriteFile(path, []byte(formatSetlist(meta, tracks, notes)), 0644)
+}
+
+// formatSetlist renders the setlist file's contents
+func formatSetlist(meta *models.AlbArtResp, tracks []models.Track, notes string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s - %s\n", meta.ArtistName, strings.TrimRight(meta.ContainerInfo, " "))
+
+	multiSet := false
+	for _, track := range tracks {
+		if track.SetNum != tracks[0].SetNum {
+			multiSet = true
+			break
+		}
+	}
+
+	setNum := -1
+	for i, track := range tracks {
+		if multiSet && track.SetNum != setNum {
+			setNum = track.SetNum
+			fmt.Fprintf(&b, "\nSet %d\n", setNum)
+		} else if i == 0 {
+			b.WriteString("\n")
+		}
+		fmt.Fprintf(&b, "%02d. %s", i+1, track.SongTitle)
+		if track.RunningTime > 0 {
+			fmt.Fprintf(&b, " (%d:%02d)", track.RunningTime/60, track.RunningTime%60)
+		}
+		b.WriteString("\n")
+	}
+
+	if notes != "" {
+		fmt.Fprintf(&b, "\nNotes\n%s\n", notes)
+	}
+	return b.String()
+}
@@ -0,0 +1,103 @@
+package processor
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"main/pkg/logger"
+	"main/pkg/models"
+)
+
+// simulateOutput is where --simulate-quality prints its reports
+var simulateOutput io.Writer = os.Stdout
+
+// simulateTrackQuality probes the formats of tracks with --simulate-quality
+// and reports how one would be picked for each, instead of downloading. It
+// goes through the same selection as a download, so the report matches what
+// a download would get.
+func (p *Processor) simulateTrackQuality(tracks []models.Track, streamParams *models.StreamParams) error {
+	var failures int
+	for i, track := range tracks {
+		fmt.Fprintf(simulateOutput, "Track %d: %s\n", i+1, track.SongTitle)
+		if err := p.simulateTrack(&track, streamParams); err != nil {
+			failures++
+			logger.GetLogger().WithError(err).WithField("track", track.SongTitle).Error("Failed to simulate track quality")
+			fmt.Fprintf(simulateOutput, "  Failed: %v\n", err)
+		}
+	}
+
+	if failures > 0 {
+		return fmt.Errorf("%d of %d tracks failed to resolve a quality", failures, len(tracks))
+	}
+	return nil
+}
+
+func (p *Processor) simulateTrack(track *models.Track, streamParams *models.StreamParams) error {
+	quals, unsupported, err := p.probeTrackQualities(track, streamParams)
+	if err != nil {
+		return err
+	}
+
+	probed := make([]string, 0, len(quals)+len(unsupported))
+	for _, qual := range quals {
+		probed = append(probed, qualityLabel(qual))
+	}
+	for _, streamUrl := range unsupported {
+		path, _, _ := strings.Cut(streamUrl, "?")
+		probed = append(probed, "unrecognised "+path)
+	}
+	fmt.Fprintf(simulateOutput, "  Probed: %s\n", strings.Join(probed, ", "))
+	if len(quals) == 0 {
+		return unsupportedFormatError(unsupported)
+	}
+
+	chosen, hlsFallbacks, isHlsOnly, err := p.selectTrackQuality(track, quals)
+	if errors.Is(err, models.ErrFormatUnavailable) {
+		fmt.Fprintf(simulateOutput, "  Selection: format %d unavailable, skipped with --no-fallback\n", p.config.Format)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	steps := []string{fmt.Sprintf("format %d", p.config.Format)}
+	if isHlsOnly {
+		steps = append(steps, "HLS-only")
+	} else {
+		for _, format := range fallbackSteps(p.config.Format, chosen.Format) {
+			steps = append(steps, fmt.Sprintf("format %d", format))
+		}
+	}
+	fmt.Fprintf(simulateOutput, "  Selection: %s → chosen %s\n", strings.Join(steps, " → "), qualityLabel(chosen))
+	if len(hlsFallbacks) > 0 {
+		fmt.Fprintf(simulateOutput, "  %d more HLS streams to fall back on if it fails\n", len(hlsFallbacks))
+	}
+	return nil
+}
+
+// fallbackSteps returns the formats after wantFmt that TrackFallback goes
+// through to reach chosenFmt
+func fallbackSteps(wantFmt, chosenFmt int) []int {
+	var steps []int
+	for format := wantFmt; format != chosenFmt; {
+		next, ok := models.TrackFallback[format]
+		if !ok {
+			break
+		}
+		steps = append(steps, next)
+		format = next
+	}
+	return steps
+}
+
+// qualityLabel describes a probed quality. HLS streams only get their specs
+// once their master playlist is parsed.
+func qualityLabel(qual *models.Quality) string {
+	if qual.Specs == "" {
+		return fmt.Sprintf("format %d (HLS)", qual.Format)
+	}
+	return fmt.Sprintf("format %d (%s)", qual.Format, qual.Specs)
+}
@@ -0,0 +1,116 @@
+package processor
+
+import (
+	"fmt"
+	"slices"
+
+	"main/pkg/logger"
+	"main/pkg/models"
+)
+
+// sourceRanking orders the recording sources with the preferred one first
+// and the rest from best to worst
+func sourceRanking(preferred string) []string {
+	ranking := []string{preferred}
+	for _, source := range models.Sources {
+		if source != preferred {
+			ranking = append(ranking, source)
+		}
+	}
+	return ranking
+}
+
+// outrankedSources returns the containers recorded from a worse source than
+// another container of the same show, by the same artist on the same date.
+// Containers without a date or a source label are never outranked.
+func outrankedSources(containers []*models.AlbArtResp, ranking []string) map[*models.AlbArtResp]bool {
+	type show struct {
+		artistId int
+		date     string
+	}
+	rank := func(c *models.AlbArtResp) int {
+		return slices.Index(ranking, models.ContainerSource(c))
+	}
+
+	best := make(map[show]int)
+	shows := make(map[*models.AlbArtResp]show)
+	for _, container := range containers {
+		date, ok := models.ParseContainerDate(container)
+		r := rank(container)
+		if !ok || r < 0 {
+			continue
+		}
+		key := show{container.ArtistID, date.Format("2006-01-02")}
+		shows[container] = key
+		if bestRank, seen := best[key]; !seen || r < bestRank {
+			best[key] = r
+		}
+	}
+
+	outranked := make(map[*models.AlbArtResp]bool)
+	for container, key := range shows {
+		if rank(container) > best[key] {
+			outranked[container] = true
+		}
+	}
+	return outranked
+}
+
+// skipOutrankedSources returns the containers to skip under
+// --source-preference, reporting how many there are
+func (p *Processor) skipOutrankedSources(containers []*models.AlbArtResp) map[*models.AlbArtResp]bool {
+	if p.config.SourcePreference == "" {
+		return nil
+	}
+
+	outranked := outrankedSources(containers, sourceRanking(p.config.SourcePreference))
+	for container := range outranked {
+		logger.GetLogger().WithFields(map[string]interface{}{
+			"container_id": container.ContainerID,
+			"source":       models.ContainerSource(container),
+			"preference":   p.config.SourcePreference,
+		}).Debug("Skipping container with a less preferred source")
+	}
+	if len(outranked) > 0 {
+		fmt.Printf("Skipped %d items with a better source recording of the same show.\n", len(outranked))
+	}
+	return outranked
+}
+
+// preferSources drops containers outranked by a better source recording of
+// the same show
+func (p *Processor) preferSources(containers []*models.AlbArtResp) []*models.AlbArtResp {
+	outranked := p.skipOutrankedSources(containers)
+	if len(outranked) == 0 {
+		return containers
+	}
+
+	var kept []*models.AlbArtResp
+	for _, container := range containers {
+		if !outranked[container] {
+			kept = append(kept, container)
+		}
+	}
+	return kept
+}
+
+// preferPendingSources is preferSources for the shows an artist sync has
+// yet to download
+func (p *Processor) preferPendingSources(pending []datedContainer) []datedContainer {
+	containers := make([]*models.AlbArtResp, len(pending))
+	for i, item := range pending {
+		containers[i] = item.container
+	}
+	outranked := p.skipOutrankedSources(containers)
+	if len(outranked) == 0 {
+		return pending
+	}
+
+	var kept []datedContainer
+	for _, item := range pending {
+		if !outranked[item.container] {
+			kept = append(kept, item)
+		}
+	}
+	return kept
+}
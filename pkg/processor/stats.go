@@ -0,0 +1,42 @@
+package processor
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/dustin/go-humanize"
+)
+
+// runStats counts what a run downloaded. It's shared by forked processors.
+type runStats struct {
+	tracks atomic.Int64
+	videos atomic.Int64
+}
+
+// plural formats a count with its noun, adding an s unless there's one
+func plural(n int64, noun string) string {
+	if n == 1 {
+		return fmt.Sprintf("%d %s", n, noun)
+	}
+	return fmt.Sprintf("%d %ss", n, noun)
+}
+
+// formatSummary formats the end of run summary line
+func formatSummary(items int, tracks, videos, bytes int64, elapsed time.Duration) string {
+	var speed uint64
+	if secs := elapsed.Seconds(); secs > 0 {
+		speed = uint64(float64(bytes) / secs)
+	}
+	return fmt.Sprintf("Finished %s in %s: %s, %s, %s at %s/s",
+		plural(int64(items), "item"), elapsed.Round(time.Second),
+		plural(tracks, "track"), plural(videos, "video"),
+		humanize.Bytes(uint64(bytes)), humanize.Bytes(speed))
+}
+
+// Summary returns a one-line summary of the tracks, videos and bytes
+// downloaded over a run of the given items, which took elapsed
+func (p *Processor) Summary(items int, elapsed time.Duration) string {
+	return formatSummary(items, p.stats.tracks.Load(), p.stats.videos.Load(),
+		p.downloader.BytesDownloaded(), elapsed)
+}
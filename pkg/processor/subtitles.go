@@ -0,0 +1,83 @@
+package processor
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/grafov/m3u8"
+	"main/pkg/downloader"
+	"main/pkg/logger"
+)
+
+// subtitlePaths names the subtitle files of a video after it and each
+// rendition's language, e.g. "Show_1080p.en.vtt", falling back to the
+// rendition's name or position. Repeated labels are numbered.
+func subtitlePaths(vidPath string, renditions []*m3u8.Alternative) []string {
+	base := strings.TrimSuffix(vidPath, filepath.Ext(vidPath))
+	seen := make(map[string]int)
+	paths := make([]string, len(renditions))
+	for i, rendition := range renditions {
+		label := rendition.Language
+		if label == "" {
+			label = rendition.Name
+		}
+		if label == "" {
+			label = strconv.Itoa(i + 1)
+		}
+		label = downloader.Sanitise(label)
+		seen[label]++
+		if seen[label] > 1 {
+			label += strconv.Itoa(seen[label])
+		}
+		paths[i] = base + "." + label + ".vtt"
+	}
+	return paths
+}
+
+// saveSubtitles downloads the subtitle renditions in a video's master
+// playlist with --subtitles, next to the video as WebVTT files, and muxes
+// them into it with "embed". Videos without subtitles are skipped, and since
+// subtitles are extras, failures are logged and the video is kept as is.
+func (p *Processor) saveSubtitles(manifestUrl string, variant *m3u8.Variant, vidPath, container string) {
+	if p.config.Subtitles == "" || p.config.AudioOnly {
+		return
+	}
+	renditions := downloader.SubtitleRenditions(variant)
+	if len(renditions) == 0 {
+		fmt.Println("No subtitles available for this video.")
+		return
+	}
+
+	var subtitles []downloader.Subtitle
+	for i, vttPath := range subtitlePaths(vidPath, renditions) {
+		if err := p.downloader.DownloadSubtitles(vttPath, manifestUrl, renditions[i]); err != nil {
+			logger.GetLogger().WithError(err).WithField("path", vttPath).Warn("Failed to download subtitles")
+			fmt.Printf("Failed to download %s subtitles.\n", filepath.Base(vttPath))
+			continue
+		}
+		subtitles = append(subtitles, downloader.Subtitle{Path: vttPath, Language: renditions[i].Language})
+	}
+	fmt.Printf("Saved %d of %d subtitle tracks.\n", len(subtitles), len(renditions))
+	if p.config.Subtitles != "embed" || len(subtitles) == 0 {
+		return
+	}
+
+	ext := filepath.Ext(vidPath)
+	tempPath := strings.TrimSuffix(vidPath, ext) + ".subtitles" + ext
+	err := downloader.MuxSubtitles(vidPath, tempPath, p.config.FfmpegNameStr, container, subtitles)
+	if err == nil {
+		err = os.Rename(tempPath, vidPath)
+	}
+	if err != nil {
+		os.Remove(tempPath)
+		logger.GetLogger().WithError(err).WithField("path", vidPath).Warn("Failed to embed subtitles")
+		fmt.Println("Failed to embed subtitles, keeping them alongside.")
+		return
+	}
+	for _, sub := range subtitles {
+		os.Remove(sub.Path)
+	}
+}
@@ -0,0 +1,108 @@
+package processor
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"main/pkg/fsutil"
+)
+
+// SyncState holds the newest container date downloaded for each artist
+type SyncState struct {
+	Artists map[string]ArtistWatermark `json:"artists"`
+}
+
+// ArtistWatermark records how far an artist has been synced
+type ArtistWatermark struct {
+	NewestDate time.Time `json:"newest_date"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// SyncStore persists per-artist sync watermarks to a JSON file
+type SyncStore struct {
+	path string
+	mu   sync.Mutex
+}
+
+// NewSyncStore creates a sync store backed by the given file
+func NewSyncStore(path string) *SyncStore {
+	return &SyncStore{path: path}
+}
+
+// load reads the sync state from disk, returning an empty state if none exists
+func (s *SyncStore) load() (*SyncState, error) {
+	state := &SyncState{Artists: map[string]ArtistWatermark{}}
+
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return state, nil
+		}
+		return nil, fmt.Errorf("failed to read sync state: %w", err)
+	}
+
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal sync state: %w", err)
+	}
+	if state.Artists == nil {
+		state.Artists = map[string]ArtistWatermark{}
+	}
+
+	return state, nil
+}
+
+// GetWatermark returns the newest synced date for an artist, or false if the
+// artist has never been synced
+func (s *SyncStore) GetWatermark(artistId string) (time.Time, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	state, err := s.load()
+	if err != nil {
+		return time.Time{}, false, err
+	}
+
+	watermark, ok := state.Artists[artistId]
+	return watermark.NewestDate, ok, nil
+}
+
+// SetWatermark saves the newest synced date for an artist
+func (s *SyncStore) SetWatermark(artistId string, newest time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	state, err := s.load()
+	if err != nil {
+		return err
+	}
+
+	state.Artists[artistId] = ArtistWatermark{
+		NewestDate: newest,
+		UpdatedAt:  time.Now(),
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return fmt.Errorf("failed to create sync state directory: %w", err)
+	}
+
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal sync state: %w", err)
+	}
+
+	// Write to temporary file first for atomicity
+	tempFile := fsutil.TempPathFor(s.path)
+	if err := os.WriteFile(tempFile, data, 0644); err != nil {
+		return fmt.Errorf("failed to write sync state: %w", err)
+	}
+	if err := os.Rename(tempFile, s.path); err != nil {
+		os.Remove(tempFile)
+		return fmt.Errorf("failed to save sync state: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,87 @@
+package processor
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"main/pkg/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type SyncStoreTestSuite struct {
+	suite.Suite
+	tempDir string
+	store   *SyncStore
+}
+
+func (suite *SyncStoreTestSuite) SetupTest() {
+	tempDir, err := os.MkdirTemp("", "sync_test_*")
+	suite.Require().NoError(err)
+	suite.tempDir = tempDir
+
+	suite.store = NewSyncStore(filepath.Join(tempDir, "state", "sync.json"))
+}
+
+func (suite *SyncStoreTestSuite) TearDownTest() {
+	os.RemoveAll(suite.tempDir)
+}
+
+func (suite *SyncStoreTestSuite) TestGetWatermark_NeverSynced() {
+	watermark, ok, err := suite.store.GetWatermark("461")
+	suite.NoError(err)
+	suite.False(ok)
+	suite.True(watermark.IsZero())
+}
+
+func (suite *SyncStoreTestSuite) TestSetAndGetWatermark() {
+	newest := time.Date(2023, 7, 4, 0, 0, 0, 0, time.UTC)
+	suite.NoError(suite.store.SetWatermark("461", newest))
+
+	// A fresh store should read the persisted state
+	store := NewSyncStore(suite.store.path)
+	watermark, ok, err := store.GetWatermark("461")
+	suite.NoError(err)
+	suite.True(ok)
+	suite.True(newest.Equal(watermark))
+
+	_, ok, err = store.GetWatermark("1045")
+	suite.NoError(err)
+	suite.False(ok)
+}
+
+func (suite *SyncStoreTestSuite) TestGetWatermark_CorruptState() {
+	suite.Require().NoError(os.MkdirAll(filepath.Dir(suite.store.path), 0755))
+	suite.Require().NoError(os.WriteFile(suite.store.path, []byte("{not json"), 0644))
+
+	_, _, err := suite.store.GetWatermark("461")
+	suite.Error(err)
+}
+
+func (suite *SyncStoreTestSuite) TestNewContainersSince() {
+	meta := []*models.ArtistMeta{{Response: &models.ArtistResp{
+		Containers: []*models.AlbArtResp{
+			{ContainerID: 3, PerformanceDate: "2023-08-01"},
+			{ContainerID: 1, ContainerInfo: "6/30/23 Some Venue"},
+			{ContainerID: 2, PerformanceDate: "2023-07-15"},
+			{ContainerID: 4, ContainerInfo: "Studio Release"},
+		},
+	}}}
+
+	// First sync takes everything, undated containers included
+	pending := newContainersSince(meta, time.Time{}, false)
+	assert.Len(suite.T(), pending, 4)
+
+	watermark := time.Date(2023, 7, 1, 0, 0, 0, 0, time.UTC)
+	pending = newContainersSince(meta, watermark, true)
+	suite.Require().Len(pending, 2)
+	assert.Equal(suite.T(), 2, pending[0].container.ContainerID)
+	assert.Equal(suite.T(), 3, pending[1].container.ContainerID)
+}
+
+func TestSyncStoreTestSuite(t *testing.T) {
+	suite.Run(t, new(SyncStoreTestSuite))
+}
@@ -0,0 +1,119 @@
+package processor
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"main/pkg/downloader"
+	"main/pkg/logger"
+	"main/pkg/models"
+)
+
+// audioExts are the extensions of files saved as tracks, including converted ones
+var audioExts = map[string]bool{".flac": true, ".m4a": true, ".mp4": true, ".wav": true}
+
+// VerifyAlbum checks a local folder against an album's track list and
+// reports missing and extra tracks. Tracks are matched by their numbered
+// title, with or without the release prefix --no-folder adds, and empty
+// files count as missing. Only missing tracks fail the album.
+func (p *Processor) VerifyAlbum(albumID, folder string) error {
+	_meta, err := p.apiClient.GetAlbumMeta(albumID)
+	if err != nil {
+		logger.GetLogger().WithError(err).WithField("album_id", albumID).Error("Failed to get album metadata")
+		return models.NewDownloadError(models.ErrNetwork, "Failed to get album metadata", "Check your internet connection and try again", true, err)
+	}
+	meta := _meta.Response
+	albumFolder := p.albumFolderName(meta)
+	fmt.Printf("Verifying %s against %s\n", folder, albumFolder)
+
+	entries, err := os.ReadDir(folder)
+	if err != nil {
+		return models.NewDownloadError(models.ErrFileSystem, "Failed to read album folder", "Check the folder path", false, err)
+	}
+	files := audioFiles(entries)
+	// Multi-disc releases saved with --disc-folders have a subfolder per disc
+	for _, entry := range entries {
+		if !entry.IsDir() || !discFolderRegex.MatchString(entry.Name()) {
+			continue
+		}
+		discEntries, err := os.ReadDir(filepath.Join(folder, entry.Name()))
+		if err != nil {
+			return models.NewDownloadError(models.ErrFileSystem, "Failed to read disc folder", "Check the folder path", false, err)
+		}
+		for _, file := range audioFiles(discEntries) {
+			files = append(files, filepath.Join(entry.Name(), file))
+		}
+	}
+
+	tracks := releaseTracks(meta)
+	missing, extra := diffAlbumFiles(tracks, downloader.Sanitise(albumFolder)+" - ", files)
+	if len(missing) == 0 && len(extra) == 0 {
+		fmt.Printf("All %d tracks present.\n", len(tracks))
+		return nil
+	}
+	if len(missing) > 0 {
+		fmt.Printf("%d of %d tracks missing:\n", len(missing), len(tracks))
+		for _, m := range missing {
+			fmt.Printf("   - %s\n", m)
+		}
+	}
+	if len(extra) > 0 {
+		fmt.Printf("%d files not in the album:\n", len(extra))
+		for _, e := range extra {
+			fmt.Printf("   - %s\n", e)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("%d of %d tracks missing from %s", len(missing), len(tracks), folder)
+	}
+	return nil
+}
+
+// discFolderRegex matches the disc subfolders made with --disc-folders
+var discFolderRegex = regexp.MustCompile(`^Disc \d+$`)
+
+// audioFiles returns the names of the non-empty audio files in a folder listing
+func audioFiles(entries []os.DirEntry) []string {
+	var files []string
+	for _, entry := range entries {
+		if entry.IsDir() || !audioExts[strings.ToLower(filepath.Ext(entry.Name()))] {
+			continue
+		}
+		// Cut-short downloads can leave empty files behind
+		if info, err := entry.Info(); err == nil && info.Size() == 0 {
+			continue
+		}
+		files = append(files, entry.Name())
+	}
+	return files
+}
+
+// diffAlbumFiles compares an album's tracks to the audio files in its folder.
+// missing lists the tracks with no file, as "Track N (title)", and extra the
+// files that don't match any track.
+func diffAlbumFiles(tracks []models.Track, releasePrefix string, files []string) (missing, extra []string) {
+	matched := make([]bool, len(files))
+	for i, track := range tracks {
+		name := fmt.Sprintf("%02d. %s", i+1, downloader.Sanitise(track.SongTitle))
+		found := false
+		for j, file := range files {
+			base := strings.TrimSuffix(filepath.Base(file), filepath.Ext(file))
+			if strings.EqualFold(base, name) || strings.EqualFold(base, releasePrefix+name) {
+				matched[j] = true
+				found = true
+			}
+		}
+		if !found {
+			missing = append(missing, fmt.Sprintf("Track %d (%s)", i+1, track.SongTitle))
+		}
+	}
+	for j, file := range files {
+		if !matched[j] {
+			extra = append(extra, file)
+		}
+	}
+	return missing, extra
+}
@@ -0,0 +1,59 @@
+package processor
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"main/pkg/downloader"
+	"main/pkg/models"
+)
+
+// defaultVideoTemplate names videos the way they were named before
+// videoTemplate existed
+const defaultVideoTemplate = "{artist} - {title}_{res}"
+
+// videoName builds a video's filename, without its extension, from
+// videoTemplate. The name is chopped to the filename limit and sanitised
+// after the placeholders are filled in.
+func (p *Processor) videoName(meta *models.AlbArtResp, res string, fps float64) string {
+	template := p.config.VideoTemplate
+	if template == "" {
+		template = defaultVideoTemplate
+	}
+	// Audio-only saves have no picture to describe
+	if p.config.AudioOnly {
+		res, fps = "", 0
+	}
+
+	name := expandVideoTemplate(template, meta, res, fps)
+	name, chopped := truncateName(name, p.videoFilenameLimit())
+	if chopped {
+		fmt.Printf("Video filename was chopped because it exceeds %d characters.\n", p.videoFilenameLimit())
+	}
+	return downloader.Sanitise(name)
+}
+
+// expandVideoTemplate fills in a video filename template's placeholders:
+// {artist}, {title}, {date} (YYYY-MM-DD), {res} and {fps}. Ones without a
+// value, like {fps} for livestreams, are left empty, and any separators left
+// dangling at the end are dropped.
+func expandVideoTemplate(template string, meta *models.AlbArtResp, res string, fps float64) string {
+	var date, fpsStr string
+	if d, ok := models.ParseContainerDate(meta); ok {
+		date = d.Format("2006-01-02")
+	}
+	if fps > 0 {
+		fpsStr = strconv.FormatFloat(math.Round(fps*100)/100, 'f', -1, 64)
+	}
+
+	name := strings.NewReplacer(
+		"{artist}", meta.ArtistName,
+		"{title}", strings.TrimRight(meta.ContainerInfo, " "),
+		"{date}", date,
+		"{res}", res,
+		"{fps}", fpsStr,
+	).Replace(template)
+	return strings.TrimRight(name, " _-.")
+}
@@ -0,0 +1,80 @@
+package remote
+
+import (
+	"bytes"
+	"fmt"
+	"net/url"
+	"os/exec"
+	"strings"
+)
+
+// Destination receives finished downloads. Put copies a local file to the
+// given slash-separated path under the destination; the local file is left
+// for the caller to delete once it's safely there.
+type Destination interface {
+	Put(localPath, rel string) error
+	Close() error
+}
+
+// ParseDest checks an sftp:// destination URL, e.g.
+// sftp://user@nas:22/volume1/music
+func ParseDest(dest string) (*url.URL, error) {
+	u, err := url.Parse(dest)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "sftp" {
+		return nil, fmt.Errorf("remote destination must be an sftp:// URL: %s", dest)
+	}
+	if u.Hostname() == "" || u.User.Username() == "" {
+		return nil, fmt.Errorf("remote destination needs a user and host, e.g. sftp://user@host/path: %s", dest)
+	}
+	return u, nil
+}
+
+// Open connects to the SFTP destination, or sets up the command, whichever
+// is given
+func Open(dest, command, keyPath string) (Destination, error) {
+	if command != "" {
+		return &commandDestination{command: command}, nil
+	}
+	u, err := ParseDest(dest)
+	if err != nil {
+		return nil, err
+	}
+	return dialSftp(u, keyPath)
+}
+
+// commandDestination runs a command for each file, with {local} replaced by
+// the local path and {remote} by the path under the destination, e.g.
+// "rclone copyto {local} nas:music/{remote}"
+type commandDestination struct {
+	command string
+}
+
+// commandArgs splits the command into arguments before filling in the
+// paths, so paths with spaces stay one argument
+func (d *commandDestination) commandArgs(localPath, rel string) []string {
+	replacer := strings.NewReplacer("{local}", localPath, "{remote}", rel)
+	var args []string
+	for _, field := range strings.Fields(d.command) {
+		args = append(args, replacer.Replace(field))
+	}
+	return args
+}
+
+func (d *commandDestination) Put(localPath, rel string) error {
+	args := d.commandArgs(localPath, rel)
+	var output bytes.Buffer
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%w\n%s", err, output.String())
+	}
+	return nil
+}
+
+func (d *commandDestination) Close() error {
+	return nil
+}
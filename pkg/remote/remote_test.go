@@ -0,0 +1,54 @@
+package remote
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type RemoteTestSuite struct {
+	suite.Suite
+}
+
+func (suite *RemoteTestSuite) TestParseDest() {
+	u, err := ParseDest("sftp://me@nas:2222/volume1/music")
+	suite.Require().NoError(err)
+	suite.Equal("me", u.User.Username())
+	suite.Equal("nas:2222", u.Host)
+	suite.Equal("/volume1/music", u.Path)
+
+	_, err = ParseDest("ftp://me@nas/music")
+	suite.Error(err)
+	_, err = ParseDest("sftp://nas/music")
+	suite.Error(err)
+}
+
+func (suite *RemoteTestSuite) TestCommandArgs() {
+	d := &commandDestination{command: "rclone copyto {local} nas:music/{remote}"}
+	args := d.commandArgs("/out/My Album/01.flac", "My Album/01.flac")
+	suite.Equal([]string{"rclone", "copyto", "/out/My Album/01.flac", "nas:music/My Album/01.flac"}, args)
+}
+
+func (suite *RemoteTestSuite) TestCommandPut() {
+	tempDir := suite.T().TempDir()
+	local := filepath.Join(tempDir, "01.flac")
+	suite.Require().NoError(os.WriteFile(local, []byte("flac"), 0644))
+
+	dest, err := Open("", "cp {local} "+tempDir+"/{remote}", "")
+	suite.Require().NoError(err)
+	defer dest.Close()
+
+	suite.Require().NoError(dest.Put(local, "copy.flac"))
+	data, err := os.ReadFile(filepath.Join(tempDir, "copy.flac"))
+	suite.Require().NoError(err)
+	suite.Equal("flac", string(data))
+
+	err = dest.Put(filepath.Join(tempDir, "missing.flac"), "missing.flac")
+	suite.Error(err)
+}
+
+func TestRemoteTestSuite(t *testing.T) {
+	suite.Run(t, new(RemoteTestSuite))
+}
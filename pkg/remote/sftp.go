@@ -0,0 +1,341 @@
+package remote
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// SFTP version 3 packet types, the version OpenSSH speaks
+const (
+	sshFxpInit    = 1
+	sshFxpVersion = 2
+	sshFxpOpen    = 3
+	sshFxpClose   = 4
+	sshFxpWrite   = 6
+	sshFxpRemove  = 13
+	sshFxpMkdir   = 14
+	sshFxpRename  = 18
+	sshFxpStatus  = 101
+	sshFxpHandle  = 102
+
+	sshFxOk = 0
+
+	sshFxfWrite = 0x02
+	sshFxfCreat = 0x08
+	sshFxfTrunc = 0x10
+
+	sftpVersion = 3
+	// sftpChunk is the most data sent in one write, well under the 34000
+	// byte packets every server must accept
+	sftpChunk = 32 * 1024
+)
+
+// sftpClient speaks just enough SFTP to upload files: making folders and
+// writing, renaming and removing files. Requests are sent one at a time.
+type sftpClient struct {
+	r      io.Reader
+	w      io.Writer
+	nextID uint32
+}
+
+// newSftpClient starts an SFTP session over the given streams
+func newSftpClient(r io.Reader, w io.Writer) (*sftpClient, error) {
+	c := &sftpClient{r: r, w: w}
+	if err := c.send(sshFxpInit, uint32Bytes(sftpVersion)); err != nil {
+		return nil, err
+	}
+	packetType, _, err := c.recv()
+	if err != nil {
+		return nil, err
+	}
+	if packetType != sshFxpVersion {
+		return nil, fmt.Errorf("sftp server answered init with packet type %d", packetType)
+	}
+	return c, nil
+}
+
+func uint32Bytes(n uint32) []byte {
+	return binary.BigEndian.AppendUint32(nil, n)
+}
+
+// appendString appends an SFTP string, its length then its bytes
+func appendString(b []byte, s string) []byte {
+	b = binary.BigEndian.AppendUint32(b, uint32(len(s)))
+	return append(b, s...)
+}
+
+// readString reads an SFTP string off the front of b
+func readString(b []byte) (string, []byte, error) {
+	if len(b) < 4 {
+		return "", nil, errors.New("sftp packet too short")
+	}
+	n := binary.BigEndian.Uint32(b)
+	if uint32(len(b)-4) < n {
+		return "", nil, errors.New("sftp packet too short")
+	}
+	return string(b[4 : 4+n]), b[4+n:], nil
+}
+
+func (c *sftpClient) send(packetType byte, payload []byte) error {
+	packet := binary.BigEndian.AppendUint32(nil, uint32(len(payload)+1))
+	packet = append(packet, packetType)
+	_, err := c.w.Write(append(packet, payload...))
+	return err
+}
+
+func (c *sftpClient) recv() (byte, []byte, error) {
+	var header [5]byte
+	if _, err := io.ReadFull(c.r, header[:]); err != nil {
+		return 0, nil, err
+	}
+	length := binary.BigEndian.Uint32(header[:4])
+	if length < 1 || length > 256*1024 {
+		return 0, nil, fmt.Errorf("invalid sftp packet length %d", length)
+	}
+	payload := make([]byte, length-1)
+	if _, err := io.ReadFull(c.r, payload); err != nil {
+		return 0, nil, err
+	}
+	return header[4], payload, nil
+}
+
+// request sends a request with a fresh id and returns the response payload
+// after the id
+func (c *sftpClient) request(packetType byte, body []byte) (byte, []byte, error) {
+	c.nextID++
+	id := c.nextID
+	if err := c.send(packetType, append(uint32Bytes(id), body...)); err != nil {
+		return 0, nil, err
+	}
+	respType, payload, err := c.recv()
+	if err != nil {
+		return 0, nil, err
+	}
+	if len(payload) < 4 || binary.BigEndian.Uint32(payload) != id {
+		return 0, nil, errors.New("sftp response doesn't match the request")
+	}
+	return respType, payload[4:], nil
+}
+
+// statusError turns a status response into an error, nil for OK
+func statusError(payload []byte) error {
+	if len(payload) < 4 {
+		return errors.New("sftp status too short")
+	}
+	code := binary.BigEndian.Uint32(payload)
+	if code == sshFxOk {
+		return nil
+	}
+	msg, _, _ := readString(payload[4:])
+	return fmt.Errorf("sftp error %d: %s", code, msg)
+}
+
+// expectStatus sends a request answered with a status
+func (c *sftpClient) expectStatus(packetType byte, body []byte) error {
+	respType, payload, err := c.request(packetType, body)
+	if err != nil {
+		return err
+	}
+	if respType != sshFxpStatus {
+		return fmt.Errorf("unexpected sftp response type %d", respType)
+	}
+	return statusError(payload)
+}
+
+// mkdirAll makes a remote folder and its parents. Folders that already
+// exist fail to be made, so failures are only reported by the upload.
+func (c *sftpClient) mkdirAll(dir string) {
+	var parts []string
+	for d := dir; d != "." && d != "/" && d != ""; d = path.Dir(d) {
+		parts = append([]string{d}, parts...)
+	}
+	for _, part := range parts {
+		// No attributes, so the server's defaults apply
+		c.expectStatus(sshFxpMkdir, append(appendString(nil, part), 0, 0, 0, 0))
+	}
+}
+
+// upload writes r to a remote file, replacing it
+func (c *sftpClient) upload(r io.Reader, remotePath string) error {
+	body := appendString(nil, remotePath)
+	body = binary.BigEndian.AppendUint32(body, sshFxfWrite|sshFxfCreat|sshFxfTrunc)
+	body = append(body, 0, 0, 0, 0)
+	respType, payload, err := c.request(sshFxpOpen, body)
+	if err != nil {
+		return err
+	}
+	if respType == sshFxpStatus {
+		return statusError(payload)
+	}
+	if respType != sshFxpHandle {
+		return fmt.Errorf("unexpected sftp response type %d", respType)
+	}
+	handle, _, err := readString(payload)
+	if err != nil {
+		return err
+	}
+
+	writeErr := c.writeAll(handle, r)
+	closeErr := c.expectStatus(sshFxpClose, appendString(nil, handle))
+	if writeErr != nil {
+		return writeErr
+	}
+	return closeErr
+}
+
+func (c *sftpClient) writeAll(handle string, r io.Reader) error {
+	buf := make([]byte, sftpChunk)
+	var offset uint64
+	for {
+		n, readErr := r.Read(buf)
+		if n > 0 {
+			body := appendString(nil, handle)
+			body = binary.BigEndian.AppendUint64(body, offset)
+			body = appendString(body, string(buf[:n]))
+			if err := c.expectStatus(sshFxpWrite, body); err != nil {
+				return err
+			}
+			offset += uint64(n)
+		}
+		if readErr == io.EOF {
+			return nil
+		}
+		if readErr != nil {
+			return readErr
+		}
+	}
+}
+
+func (c *sftpClient) remove(remotePath string) error {
+	return c.expectStatus(sshFxpRemove, appendString(nil, remotePath))
+}
+
+func (c *sftpClient) rename(from, to string) error {
+	return c.expectStatus(sshFxpRename, appendString(appendString(nil, from), to))
+}
+
+// put uploads a local file to remotePath. It's written under a .part name
+// and renamed into place, so an interrupted upload never looks complete.
+func (c *sftpClient) put(localPath, remotePath string) error {
+	f, err := os.Open(localPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	c.mkdirAll(path.Dir(remotePath))
+	partPath := remotePath + ".part"
+	if err := c.upload(f, partPath); err != nil {
+		return err
+	}
+	// SFTP 3 renames don't overwrite, so an old copy goes first
+	c.remove(remotePath)
+	return c.rename(partPath, remotePath)
+}
+
+// sftpDestination uploads over SFTP to a folder on an SSH server
+type sftpDestination struct {
+	conn    *ssh.Client
+	session *ssh.Session
+	client  *sftpClient
+	base    string
+	mu      sync.Mutex
+}
+
+// dialSftp connects to an sftp:// URL. Host keys are checked against
+// ~/.ssh/known_hosts. It logs in with the URL's password or, failing that,
+// the private key at keyPath.
+func dialSftp(dest *url.URL, keyPath string) (*sftpDestination, error) {
+	var auth []ssh.AuthMethod
+	if password, ok := dest.User.Password(); ok {
+		auth = append(auth, ssh.Password(password))
+	}
+	if keyPath != "" {
+		key, err := os.ReadFile(keyPath)
+		if err != nil {
+			return nil, err
+		}
+		signer, err := ssh.ParsePrivateKey(key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse ssh key: %w", err)
+		}
+		auth = append(auth, ssh.PublicKeys(signer))
+	}
+
+	hostKeys, err := knownhosts.New(filepath.Join(os.Getenv("HOME"), ".ssh", "known_hosts"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read known hosts: %w", err)
+	}
+
+	host := dest.Host
+	if dest.Port() == "" {
+		host = net.JoinHostPort(dest.Hostname(), "22")
+	}
+	conn, err := ssh.Dial("tcp", host, &ssh.ClientConfig{
+		User:            dest.User.Username(),
+		Auth:            auth,
+		HostKeyCallback: hostKeys,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	session, err := conn.NewSession()
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	client, err := startSftp(session)
+	if err != nil {
+		session.Close()
+		conn.Close()
+		return nil, err
+	}
+
+	return &sftpDestination{
+		conn:    conn,
+		session: session,
+		client:  client,
+		base:    strings.TrimSuffix(dest.Path, "/"),
+	}, nil
+}
+
+// startSftp starts the sftp subsystem on an SSH session
+func startSftp(session *ssh.Session) (*sftpClient, error) {
+	w, err := session.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	r, err := session.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := session.RequestSubsystem("sftp"); err != nil {
+		return nil, err
+	}
+	return newSftpClient(r, w)
+}
+
+func (d *sftpDestination) Put(localPath, rel string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return d.client.put(localPath, path.Join(d.base, rel))
+}
+
+func (d *sftpDestination) Close() error {
+	d.session.Close()
+	return d.conn.Close()
+}
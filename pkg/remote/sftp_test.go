@@ -0,0 +1,143 @@
+package remote
+
+import (
+	"encoding/binary"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type SftpTestSuite struct {
+	suite.Suite
+	tempDir string
+	root    string
+	client  *sftpClient
+	closers []io.Closer
+}
+
+// fakeSftpServer answers the requests sftpClient sends with files under root
+type fakeSftpServer struct {
+	root    string
+	handles map[string]*os.File
+}
+
+func (s *fakeSftpServer) serve(r io.Reader, w io.Writer) {
+	c := &sftpClient{r: r, w: w}
+	for {
+		packetType, payload, err := c.recv()
+		if err != nil {
+			return
+		}
+		if packetType == sshFxpInit {
+			c.send(sshFxpVersion, uint32Bytes(sftpVersion))
+			continue
+		}
+
+		id, body := payload[:4], payload[4:]
+		status := func(err error) {
+			code := uint32(sshFxOk)
+			msg := ""
+			if err != nil {
+				code, msg = 4, err.Error()
+			}
+			resp := append(append([]byte{}, id...), uint32Bytes(code)...)
+			c.send(sshFxpStatus, appendString(appendString(resp, msg), ""))
+		}
+
+		name, rest, _ := readString(body)
+		switch packetType {
+		case sshFxpOpen:
+			f, err := os.OpenFile(filepath.Join(s.root, name), os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+			if err != nil {
+				status(err)
+				continue
+			}
+			s.handles[name] = f
+			c.send(sshFxpHandle, appendString(append([]byte{}, id...), name))
+		case sshFxpWrite:
+			offset := binary.BigEndian.Uint64(rest)
+			data, _, _ := readString(rest[8:])
+			_, err := s.handles[name].WriteAt([]byte(data), int64(offset))
+			status(err)
+		case sshFxpClose:
+			status(s.handles[name].Close())
+			delete(s.handles, name)
+		case sshFxpMkdir:
+			status(os.Mkdir(filepath.Join(s.root, name), 0755))
+		case sshFxpRemove:
+			status(os.Remove(filepath.Join(s.root, name)))
+		case sshFxpRename:
+			to, _, _ := readString(rest)
+			status(os.Rename(filepath.Join(s.root, name), filepath.Join(s.root, to)))
+		}
+	}
+}
+
+func (suite *SftpTestSuite) SetupTest() {
+	tempDir, err := os.MkdirTemp("", "sftp_test_*")
+	suite.Require().NoError(err)
+	suite.tempDir = tempDir
+	suite.root = filepath.Join(tempDir, "remote")
+	suite.Require().NoError(os.Mkdir(suite.root, 0755))
+
+	clientR, serverW := io.Pipe()
+	serverR, clientW := io.Pipe()
+	server := &fakeSftpServer{root: suite.root, handles: map[string]*os.File{}}
+	go server.serve(serverR, serverW)
+	suite.closers = []io.Closer{clientR, clientW, serverR, serverW}
+
+	suite.client, err = newSftpClient(clientR, clientW)
+	suite.Require().NoError(err)
+}
+
+func (suite *SftpTestSuite) TearDownTest() {
+	for _, closer := range suite.closers {
+		closer.Close()
+	}
+	os.RemoveAll(suite.tempDir)
+}
+
+func (suite *SftpTestSuite) writeLocal(name, content string) string {
+	path := filepath.Join(suite.tempDir, name)
+	suite.Require().NoError(os.WriteFile(path, []byte(content), 0644))
+	return path
+}
+
+func (suite *SftpTestSuite) TestPut() {
+	// Bigger than a chunk so it takes several writes
+	content := strings.Repeat("flac", sftpChunk)
+	local := suite.writeLocal("01.flac", content)
+
+	suite.Require().NoError(suite.client.put(local, "Artist/Album/01.flac"))
+
+	data, err := os.ReadFile(filepath.Join(suite.root, "Artist", "Album", "01.flac"))
+	suite.Require().NoError(err)
+	suite.Equal(content, string(data))
+	suite.NoFileExists(filepath.Join(suite.root, "Artist", "Album", "01.flac.part"))
+}
+
+func (suite *SftpTestSuite) TestPut_Replaces() {
+	suite.Require().NoError(suite.client.put(suite.writeLocal("old.flac", "old"), "01.flac"))
+	suite.Require().NoError(suite.client.put(suite.writeLocal("new.flac", "new"), "01.flac"))
+
+	data, err := os.ReadFile(filepath.Join(suite.root, "01.flac"))
+	suite.Require().NoError(err)
+	suite.Equal("new", string(data))
+}
+
+func (suite *SftpTestSuite) TestPut_Error() {
+	// A file where the folder should be
+	suite.Require().NoError(os.WriteFile(filepath.Join(suite.root, "Album"), nil, 0644))
+
+	err := suite.client.put(suite.writeLocal("01.flac", "flac"), "Album/01.flac")
+	suite.Error(err)
+	suite.Contains(err.Error(), "sftp error 4")
+}
+
+func TestSftpTestSuite(t *testing.T) {
+	suite.Run(t, new(SftpTestSuite))
+}